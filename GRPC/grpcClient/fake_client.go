@@ -0,0 +1,184 @@
+package grpcClient
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sort"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the auth service methods for FakeClient.FailWith
+const (
+	MethodGetUserWithRights        = "GetUserWithRights"
+	MethodBindUserAndRole          = "BindUserAndRole"
+	MethodTokenGenerationByRefresh = "TokenGenerationByRefresh"
+	MethodTokenGenerationByUserId  = "TokenGenerationByUserId"
+	MethodGetAllRoles              = "GetAllRoles"
+)
+
+// FakeClient is a deterministic in-memory stand-in for the auth service. It
+// keeps the bindings of users to roles, issues tokens which it can parse back
+// and returns the errors it was told to with FailWith. Calls with a done
+// context fail with its error.
+type FakeClient struct {
+	mu            sync.Mutex
+	roles         map[string]string
+	bindings      map[int32]string
+	accessTokens  map[string]*authProto.UserRole
+	refreshTokens map[string]*authProto.User
+	issued        int
+	errs          map[string]error
+}
+
+// NewFakeClient knows the given roles, none of them carries permissions until
+// SetPermissions is called
+func NewFakeClient(roles ...string) *FakeClient {
+	f := &FakeClient{
+		roles:         make(map[string]string),
+		bindings:      make(map[int32]string),
+		accessTokens:  make(map[string]*authProto.UserRole),
+		refreshTokens: make(map[string]*authProto.User),
+		errs:          make(map[string]error),
+	}
+	for _, role := range roles {
+		f.roles[role] = ""
+	}
+	return f
+}
+
+func (f *FakeClient) SetPermissions(role string, permissions string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.roles[role] = permissions
+}
+
+// FailWith makes every next call of the method return err, a nil err restores
+// the normal behavior
+func (f *FakeClient) FailWith(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.errs, method)
+		return
+	}
+	f.errs[method] = err
+}
+
+// Binding returns the role the user was bound to
+func (f *FakeClient) Binding(userId int32) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	role, ok := f.bindings[userId]
+	return role, ok
+}
+
+func (f *FakeClient) GetUserWithRights(ctx context.Context, in *authProto.AccessToken, opts ...grpc.CallOption) (*authProto.UserRole, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail(ctx, MethodGetUserWithRights); err != nil {
+		return nil, err
+	}
+	user, ok := f.accessTokens[in.AccessToken]
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid access token")
+	}
+	return &authProto.UserRole{UserId: user.UserId, Role: user.Role, Permissions: user.Permissions}, nil
+}
+
+func (f *FakeClient) BindUserAndRole(ctx context.Context, in *authProto.User, opts ...grpc.CallOption) (*authProto.ResultBinding, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail(ctx, MethodBindUserAndRole); err != nil {
+		return nil, err
+	}
+	if _, ok := f.roles[in.Role]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown role %q", in.Role)
+	}
+	f.bindings[in.UserId] = in.Role
+	return &authProto.ResultBinding{Result: true}, nil
+}
+
+func (f *FakeClient) TokenGenerationByRefresh(ctx context.Context, in *authProto.RefreshToken, opts ...grpc.CallOption) (*authProto.GeneratedTokens, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail(ctx, MethodTokenGenerationByRefresh); err != nil {
+		return nil, err
+	}
+	user, ok := f.refreshTokens[in.RefreshToken]
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+	}
+	role := user.Role
+	if bound, ok := f.bindings[user.UserId]; ok {
+		role = bound
+	}
+	return f.generate(user.UserId, role), nil
+}
+
+func (f *FakeClient) TokenGenerationByUserId(ctx context.Context, in *authProto.User, opts ...grpc.CallOption) (*authProto.GeneratedTokens, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail(ctx, MethodTokenGenerationByUserId); err != nil {
+		return nil, err
+	}
+	if _, ok := f.roles[in.Role]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown role %q", in.Role)
+	}
+	return f.generate(in.UserId, in.Role), nil
+}
+
+func (f *FakeClient) GetAllRoles(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*authProto.Roles, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail(ctx, MethodGetAllRoles); err != nil {
+		return nil, err
+	}
+	roles := make([]string, 0, len(f.roles))
+	for role := range f.roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return &authProto.Roles{Roles: strings.Join(roles, ",")}, nil
+}
+
+// fail returns the error of a done context like a real connection would, or the
+// error the method was told to fail with
+func (f *FakeClient) fail(ctx context.Context, method string) error {
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return f.errs[method]
+}
+
+// generate issues tokens numbered in the order they were issued, see FakeToken
+func (f *FakeClient) generate(userId int32, role string) *authProto.GeneratedTokens {
+	f.issued++
+	tokens := &authProto.GeneratedTokens{
+		AccessToken:  FakeToken("access", userId, f.issued),
+		RefreshToken: FakeToken("refresh", userId, f.issued),
+	}
+	f.accessTokens[tokens.AccessToken] = &authProto.UserRole{UserId: userId, Role: role, Permissions: f.roles[role]}
+	f.refreshTokens[tokens.RefreshToken] = &authProto.User{UserId: userId, Role: role}
+	return tokens
+}
+
+// FakeIssuedAt is the time the first token of FakeClient is issued at, every
+// next one is issued a second later
+var FakeIssuedAt = time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)
+
+// FakeToken returns the n-th token of the kind FakeClient issues for the user. It
+// is an unsigned JWT with the user_id, iat and exp claims and a signature like
+// "access-1-2" for the access token of user 1 issued second.
+func FakeToken(kind string, userId int32, n int) string {
+	issuedAt := FakeIssuedAt.Add(time.Duration(n-1) * time.Second)
+	payload := fmt.Sprintf(`{"user_id":%d,"iat":%d,"exp":%d}`, userId, issuedAt.Unix(), issuedAt.Add(time.Hour).Unix())
+	return "fake." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + fmt.Sprintf(".%s-%d-%d", kind, userId, n)
+}
@@ -5,25 +5,77 @@ import (
 	"fmt"
 	"github.com/golang/protobuf/ptypes/empty"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/metrics"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/tracing"
+	"time"
 )
 
 var logger = logging.GetLogger()
 
 type GRPCClient struct {
-	cli authProto.AuthClient
+	cli  authProto.AuthClient
+	conn *grpc.ClientConn
 }
 
-func NewGRPCClient(host string) *GRPCClient {
-	Target := fmt.Sprintf("%s:8090", host)
-	conn, err := grpc.Dial(Target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// NewGRPCClient connects to the auth service at address like "host:8090", it does
+// not wait for the connection, an unreachable host is reported by the first call.
+// The calls carry the trace context of the caller.
+func NewGRPCClient(address string) (*GRPCClient, error) {
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor, observe))
 	if err != nil {
-		logger.Fatalf("NewGRPCClient, Dial:%s", err)
+		logger.Errorf("NewGRPCClient, Dial:%s", err)
+		return nil, fmt.Errorf("newGRPCClient:%w", err)
 	}
 	cli := authProto.NewAuthClient(conn)
-	return &GRPCClient{cli: cli}
+	return &GRPCClient{cli: cli, conn: conn}, nil
+}
+
+// observe measures the calls to the auth service by method and status code
+func observe(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	metrics.GRPCClientDuration.WithLabelValues(method, status.Code(err).String()).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// NewGRPCClientWithConn wraps an already established connection, e.g. an in-memory one in tests
+func NewGRPCClientWithConn(conn grpc.ClientConnInterface) *GRPCClient {
+	return &GRPCClient{cli: authProto.NewAuthClient(conn)}
+}
+
+// Close closes the connection made by NewGRPCClient, the one passed to NewGRPCClientWithConn is left to its owner
+func (c *GRPCClient) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Check waits until the connection is ready, an idle one is woken up. It fails
+// while the auth service is unreachable or when ctx is done first.
+func (c *GRPCClient) Check(ctx context.Context) error {
+	if c.conn == nil {
+		return nil
+	}
+	for {
+		state := c.conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.Idle:
+			c.conn.Connect()
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return fmt.Errorf("check: connection is %s", state)
+		}
+		if !c.conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("check: connection is %s:%w", state, ctx.Err())
+		}
+	}
 }
 
 func (c *GRPCClient) GetUserWithRights(ctx context.Context, in *authProto.AccessToken, opts ...grpc.CallOption) (*authProto.UserRole, error) {
@@ -35,7 +87,7 @@ func (c *GRPCClient) BindUserAndRole(ctx context.Context, in *authProto.User, op
 }
 
 func (c *GRPCClient) TokenGenerationByRefresh(ctx context.Context, in *authProto.RefreshToken, opts ...grpc.CallOption) (*authProto.GeneratedTokens, error) {
-	return nil, nil
+	return c.cli.TokenGenerationByRefresh(ctx, in)
 }
 
 func (c *GRPCClient) TokenGenerationByUserId(ctx context.Context, in *authProto.User, opts ...grpc.CallOption) (*authProto.GeneratedTokens, error) {
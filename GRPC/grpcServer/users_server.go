@@ -0,0 +1,204 @@
+package grpcServer
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net"
+	usersProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/usersProto"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
+	"strconv"
+	"time"
+)
+
+// MaxUsersByIds is the most ids GetUsersByIds takes in one call
+const MaxUsersByIds = 100
+
+// UsersServer serves the Users API to the other food delivery services on top of the AppUser service
+type UsersServer struct {
+	usersProto.UnimplementedUsersServer
+	logger  logging.Logger
+	service *service.Service
+}
+
+func NewUsersServer(logger logging.Logger, service *service.Service) *UsersServer {
+	return &UsersServer{logger: logger, service: service}
+}
+
+func (s *UsersServer) GetUserById(ctx context.Context, in *usersProto.UserId) (*usersProto.User, error) {
+	if in.Id <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+	user, err := s.service.AppUser.GetUser(ctx, int(in.Id))
+	if err != nil {
+		return nil, s.statusError(ctx, "GetUserById", err)
+	}
+	if user.Deleted {
+		return nil, status.Error(codes.NotFound, pkg.UserNotFound)
+	}
+	return toProtoUser(user), nil
+}
+
+func (s *UsersServer) GetUsersByIds(ctx context.Context, in *usersProto.UserIds) (*usersProto.UserList, error) {
+	if len(in.Ids) > MaxUsersByIds {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d ids are allowed", MaxUsersByIds)
+	}
+	ids := make([]int, len(in.Ids))
+	for i, id := range in.Ids {
+		ids[i] = int(id)
+	}
+	users, err := s.service.AppUser.GetUsersByIds(ctx, ids)
+	if err != nil {
+		return nil, s.statusError(ctx, "GetUsersByIds", err)
+	}
+	return toProtoUsers(users, 1), nil
+}
+
+func (s *UsersServer) GetUserByEmail(ctx context.Context, in *usersProto.Email) (*usersProto.User, error) {
+	if in.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty email")
+	}
+	if err := s.rateLimit(ctx, "userByEmail", in.Email); err != nil {
+		return nil, err
+	}
+	user, err := s.service.AppUser.GetUserByEmail(ctx, in.Email)
+	if err != nil {
+		return nil, s.statusError(ctx, "GetUserByEmail", err)
+	}
+	if user.Deleted {
+		return nil, status.Error(codes.NotFound, pkg.EmailDoesNotExist)
+	}
+	return toProtoUser(user), nil
+}
+
+func (s *UsersServer) VerifyCredentials(ctx context.Context, in *usersProto.Credentials) (*usersProto.User, error) {
+	if in.Email == "" || in.Password == "" {
+		return nil, status.Error(codes.InvalidArgument, "email and password are required")
+	}
+	if err := s.rateLimit(ctx, "verifyCredentials", in.Email); err != nil {
+		return nil, err
+	}
+	user, err := s.service.AppUser.VerifyCredentials(ctx, in.Email, in.Password)
+	if err != nil {
+		return nil, s.statusError(ctx, "VerifyCredentials", err)
+	}
+	return toProtoUser(user), nil
+}
+
+func (s *UsersServer) ListUsersByRole(ctx context.Context, in *usersProto.RoleFilter) (*usersProto.UserList, error) {
+	if in.Role == "" || in.Page < 0 || in.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid role filter")
+	}
+	// A zero page or limit makes GetUsers return every user, the listing is
+	// paged anyway so that one call can not load the whole table.
+	pageNumber, limit := int(in.Page), int(in.Limit)
+	if pageNumber == 0 {
+		pageNumber = 1
+	}
+	if limit == 0 {
+		limit = service.DefaultPageLimit
+	}
+	if limit > service.MaxPageLimit {
+		limit = service.MaxPageLimit
+	}
+	page, err := s.service.AppUser.GetUsers(ctx, pageNumber, limit, &model.RequestFilters{Roles: []string{in.Role}}, "")
+	if err != nil {
+		return nil, s.statusError(ctx, "ListUsersByRole", err)
+	}
+	return toProtoUsers(page.Users, page.Pages), nil
+}
+
+// rateLimit throttles the call like the rateLimit middleware of the HTTP API, the
+// address of the calling service stands for the client IP. Calls are let through
+// if the rate limit store is unavailable.
+func (s *UsersServer) rateLimit(ctx context.Context, route string, email string) error {
+	rule, ok := s.service.RateLimiter.Rule(route)
+	if !ok {
+		return nil
+	}
+	result, err := s.service.RateLimiter.Allow(ctx, route, rateLimitKeys(ctx, rule.KeyBy, email))
+	if err != nil {
+		s.logger.WithContext(ctx).Errorf("rateLimit:%s", err)
+		return nil
+	}
+	if !result.Allowed {
+		retryAfter := time.Until(result.ResetAt).Round(time.Second)
+		_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(retryAfter.Seconds()))))
+		return status.Errorf(codes.ResourceExhausted, "too many requests, retry after %s", retryAfter)
+	}
+	return nil
+}
+
+// rateLimitKeys returns the address of the caller and/or the email of the call
+func rateLimitKeys(ctx context.Context, keyBy string, email string) []string {
+	ip := "ip:"
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			ip += host
+		} else {
+			ip += p.Addr.String()
+		}
+	}
+	email = service.RateLimitEmailKey(email)
+	switch keyBy {
+	case service.RateLimitByIP:
+		return []string{ip}
+	case service.RateLimitByEmail:
+		return []string{email}
+	default:
+		return []string{ip, email}
+	}
+}
+
+// statusError converts the errors of the service layer to gRPC status codes
+func (s *UsersServer) statusError(ctx context.Context, method string, err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		s.logger.WithContext(ctx).Warnf("%s:%s", method, err)
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		s.logger.WithContext(ctx).Warnf("%s:%s", method, err)
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, pkg.ErrorUserNotFound), errors.Is(err, pkg.ErrorEmailDoesNotExist):
+		s.logger.WithContext(ctx).Warnf("%s:%s", method, err)
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, pkg.ErrorInvalidCredentials):
+		s.logger.WithContext(ctx).Warnf("%s:%s", method, err)
+		return status.Error(codes.Unauthenticated, pkg.InvalidCredentials)
+	case errors.Is(err, pkg.ErrorTwoFactorRequired), errors.Is(err, pkg.ErrorEmailNotVerified):
+		s.logger.WithContext(ctx).Warnf("%s:%s", method, err)
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, pkg.ErrorAccountLocked):
+		s.logger.WithContext(ctx).Warnf("%s:%s", method, err)
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		s.logger.WithContext(ctx).Errorf("%s:%s", method, err)
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func toProtoUser(user *model.ResponseUser) *usersProto.User {
+	return &usersProto.User{
+		Id:            int32(user.ID),
+		Email:         user.Email,
+		Role:          user.Role,
+		CreatedAt:     timestamppb.New(user.CreatedAt.Time),
+		EmailVerified: user.EmailVerified,
+	}
+}
+
+func toProtoUsers(users []model.ResponseUser, pages int) *usersProto.UserList {
+	list := &usersProto.UserList{Users: make([]*usersProto.User, len(users)), Pages: int32(pages)}
+	for i := range users {
+		list.Users[i] = toProtoUser(&users[i])
+	}
+	return list
+}
@@ -0,0 +1,404 @@
+package grpcServer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthProto "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionProto "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net"
+	usersProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/usersProto"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/server"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
+	mock_service "stlab.itechart-group.com/go/food_delivery/authentication_service/service/mocks"
+	"testing"
+	"time"
+)
+
+var createdAt = time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)
+
+const testServiceToken = "service-token"
+
+// newTestConn serves the Users API over an in-memory listener, the calls carry the token when it is set
+func newTestConn(t *testing.T, appUser service.AppUser, token string) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	rateLimiter := service.NewRateLimitService(repository.Repository{RateLimit: repository.NewRateLimitMemory()}, logging.GetLogger(), nil)
+	grpcServ := server.NewGRPCServer(NewUsersServer(logging.GetLogger(), &service.Service{AppUser: appUser, RateLimiter: rateLimiter}), server.GRPCConfig{
+		ServiceTokens: []string{testServiceToken},
+		Timeout:       func(string) time.Duration { return 100 * time.Millisecond },
+	})
+	go func() {
+		_ = grpcServ.Serve(listener)
+	}()
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			if token != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			if token != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+			}
+			return streamer(ctx, desc, cc, method, opts...)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("bufconn dial:%s", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = grpcServ.Shutdown(context.Background())
+	})
+	return conn
+}
+
+func TestUsersServer_GetUserById(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser)
+	testTable := []struct {
+		name         string
+		inputId      int32
+		mockBehavior mockBehavior
+		expectedUser *usersProto.User
+		expectedCode codes.Code
+	}{
+		{
+			name:    "OK",
+			inputId: 1,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().GetUser(gomock.Any(), 1).Return(&model.ResponseUser{
+					ID:            1,
+					Email:         "test@yandex.ru",
+					Role:          "Courier",
+					CreatedAt:     model.MyTime{Time: createdAt},
+					EmailVerified: true,
+				}, nil)
+			},
+			expectedUser: &usersProto.User{
+				Id:            1,
+				Email:         "test@yandex.ru",
+				Role:          "Courier",
+				CreatedAt:     timestamppb.New(createdAt),
+				EmailVerified: true,
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "Invalid id",
+			inputId:      0,
+			mockBehavior: func(s *mock_service.MockAppUser) {},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:    "Not found",
+			inputId: 2,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().GetUser(gomock.Any(), 2).Return(nil, fmt.Errorf("getUserByID:%w", pkg.ErrorUserNotFound))
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:    "Deleted user",
+			inputId: 2,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().GetUser(gomock.Any(), 2).Return(&model.ResponseUser{ID: 2, Email: "test@yandex.ru", Deleted: true}, nil)
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:    "Service error",
+			inputId: 1,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().GetUser(gomock.Any(), 1).Return(nil, errors.New("repository error"))
+			},
+			expectedCode: codes.Internal,
+		},
+		{
+			name:    "Deadline exceeded",
+			inputId: 1,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().GetUser(gomock.Any(), 1).DoAndReturn(func(ctx context.Context, id int) (*model.ResponseUser, error) {
+					<-ctx.Done()
+					return nil, fmt.Errorf("getUserByID: repository error:%w", ctx.Err())
+				})
+			},
+			expectedCode: codes.DeadlineExceeded,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			appUser := mock_service.NewMockAppUser(c)
+			testCase.mockBehavior(appUser)
+			client := usersProto.NewUsersClient(newTestConn(t, appUser, testServiceToken))
+			user, err := client.GetUserById(context.Background(), &usersProto.UserId{Id: testCase.inputId})
+			//Assert
+			assert.Equal(t, testCase.expectedCode, status.Code(err))
+			if testCase.expectedUser != nil {
+				assert.Equal(t, testCase.expectedUser.String(), user.String())
+			}
+		})
+	}
+}
+
+func TestUsersServer_VerifyCredentials(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser)
+	testTable := []struct {
+		name          string
+		inputPassword string
+		mockBehavior  mockBehavior
+		expectedCode  codes.Code
+	}{
+		{
+			name:          "OK",
+			inputPassword: "HGYKnu!98Tg",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().VerifyCredentials(gomock.Any(), "test@yandex.ru", "HGYKnu!98Tg").Return(&model.ResponseUser{ID: 1}, nil)
+			},
+			expectedCode: codes.OK,
+		},
+		{
+			name:          "Empty password",
+			inputPassword: "",
+			mockBehavior:  func(s *mock_service.MockAppUser) {},
+			expectedCode:  codes.InvalidArgument,
+		},
+		{
+			name:          "Wrong password",
+			inputPassword: "HGYKnu!9Tg",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().VerifyCredentials(gomock.Any(), "test@yandex.ru", "HGYKnu!9Tg").
+					Return(nil, fmt.Errorf("verifyCredentials:%w", pkg.ErrorInvalidCredentials))
+			},
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:          "Locked user",
+			inputPassword: "HGYKnu!98Tg",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().VerifyCredentials(gomock.Any(), "test@yandex.ru", "HGYKnu!98Tg").
+					Return(nil, &pkg.LockedError{Until: createdAt})
+			},
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:          "Two-factor required",
+			inputPassword: "HGYKnu!98Tg",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().VerifyCredentials(gomock.Any(), "test@yandex.ru", "HGYKnu!98Tg").
+					Return(nil, fmt.Errorf("verifyCredentials:%w", pkg.ErrorTwoFactorRequired))
+			},
+			expectedCode: codes.FailedPrecondition,
+		},
+		{
+			name:          "Unverified email",
+			inputPassword: "HGYKnu!98Tg",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().VerifyCredentials(gomock.Any(), "test@yandex.ru", "HGYKnu!98Tg").
+					Return(nil, fmt.Errorf("authUser:%w", pkg.ErrorEmailNotVerified))
+			},
+			expectedCode: codes.FailedPrecondition,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			appUser := mock_service.NewMockAppUser(c)
+			testCase.mockBehavior(appUser)
+			client := usersProto.NewUsersClient(newTestConn(t, appUser, testServiceToken))
+			_, err := client.VerifyCredentials(context.Background(), &usersProto.Credentials{
+				Email:    "test@yandex.ru",
+				Password: testCase.inputPassword,
+			})
+			//Assert
+			assert.Equal(t, testCase.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestUsersServer_Lists(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	appUser := mock_service.NewMockAppUser(c)
+	appUser.EXPECT().GetUsersByIds(gomock.Any(), []int{2, 1}).Return([]model.ResponseUser{{ID: 2}, {ID: 1}}, nil)
+	appUser.EXPECT().GetUsers(gomock.Any(), 1, 10, &model.RequestFilters{Roles: []string{"Courier"}}, "").
+		Return(&model.UsersPage{Users: []model.ResponseUser{{ID: 3}}, Total: 31, Page: 1, Limit: 10, Pages: 4}, nil)
+	appUser.EXPECT().GetUsers(gomock.Any(), 1, service.DefaultPageLimit, &model.RequestFilters{Roles: []string{"Courier"}}, "").
+		Return(&model.UsersPage{Users: []model.ResponseUser{{ID: 3}}, Total: 31, Page: 1, Limit: service.DefaultPageLimit, Pages: 2}, nil)
+	appUser.EXPECT().GetUsers(gomock.Any(), 2, service.MaxPageLimit, &model.RequestFilters{Roles: []string{"Courier"}}, "").
+		Return(&model.UsersPage{Total: 31, Page: 2, Limit: service.MaxPageLimit, Pages: 1}, nil)
+	appUser.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(nil, fmt.Errorf("getUserByEmail:%w", pkg.ErrorEmailDoesNotExist))
+	appUser.EXPECT().GetUserByEmail(gomock.Any(), "deleted@yandex.ru").Return(&model.ResponseUser{ID: 5, Email: "deleted@yandex.ru", Deleted: true}, nil)
+	client := usersProto.NewUsersClient(newTestConn(t, appUser, testServiceToken))
+	ctx := context.Background()
+
+	list, err := client.GetUsersByIds(ctx, &usersProto.UserIds{Ids: []int32{2, 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(list.Users))
+	assert.Equal(t, int32(2), list.Users[0].Id)
+
+	list, err = client.ListUsersByRole(ctx, &usersProto.RoleFilter{Role: "Courier", Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), list.Users[0].Id)
+	assert.Equal(t, int32(4), list.Pages)
+
+	list, err = client.ListUsersByRole(ctx, &usersProto.RoleFilter{Role: "Courier"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), list.Pages)
+
+	list, err = client.ListUsersByRole(ctx, &usersProto.RoleFilter{Role: "Courier", Page: 2, Limit: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(list.Users))
+
+	_, err = client.ListUsersByRole(ctx, &usersProto.RoleFilter{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.GetUserByEmail(ctx, &usersProto.Email{Email: "test@yandex.ru"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetUserByEmail(ctx, &usersProto.Email{Email: "deleted@yandex.ru"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetUsersByIds(ctx, &usersProto.UserIds{Ids: make([]int32, MaxUsersByIds+1)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUsersServer_ServiceAuth(t *testing.T) {
+	testTable := []struct {
+		name         string
+		token        string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "OK",
+			token:        testServiceToken,
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "No token",
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "Invalid token",
+			token:        "other-token",
+			expectedCode: codes.Unauthenticated,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			appUser := mock_service.NewMockAppUser(c)
+			if testCase.expectedCode == codes.OK {
+				appUser.EXPECT().GetUser(gomock.Any(), 1).Return(&model.ResponseUser{ID: 1}, nil)
+			}
+			client := usersProto.NewUsersClient(newTestConn(t, appUser, testCase.token))
+			_, err := client.GetUserById(context.Background(), &usersProto.UserId{Id: 1})
+			//Assert
+			assert.Equal(t, testCase.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestUsersServer_StreamServiceAuth(t *testing.T) {
+	testTable := []struct {
+		name         string
+		token        string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "OK",
+			token:        testServiceToken,
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "No token",
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "Invalid token",
+			token:        "other-token",
+			expectedCode: codes.Unauthenticated,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			conn := newTestConn(t, mock_service.NewMockAppUser(c), testCase.token)
+			stream, err := reflectionProto.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+			assert.NoError(t, err)
+			//Test request
+			err = stream.Send(&reflectionProto.ServerReflectionRequest{
+				MessageRequest: &reflectionProto.ServerReflectionRequest_ListServices{},
+			})
+			assert.NoError(t, err)
+			_, err = stream.Recv()
+			//Assert
+			assert.Equal(t, testCase.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestUsersServer_RateLimit(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	appUser := mock_service.NewMockAppUser(c)
+	rule := service.DefaultRateLimits["verifyCredentials"]
+	appUser.EXPECT().VerifyCredentials(gomock.Any(), "test@yandex.ru", "HGYKnu!9Tg").
+		Return(nil, fmt.Errorf("verifyCredentials:%w", pkg.ErrorInvalidCredentials)).Times(rule.Requests)
+	client := usersProto.NewUsersClient(newTestConn(t, appUser, testServiceToken))
+
+	for i := 0; i < rule.Requests; i++ {
+		_, err := client.VerifyCredentials(context.Background(), &usersProto.Credentials{Email: "test@yandex.ru", Password: "HGYKnu!9Tg"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+	var header metadata.MD
+	_, err := client.VerifyCredentials(context.Background(), &usersProto.Credentials{Email: "Test@yandex.ru", Password: "HGYKnu!9Tg"}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.NotEmpty(t, header.Get("retry-after"))
+}
+
+func TestUsersServer_Health(t *testing.T) {
+	client := healthProto.NewHealthClient(newTestConn(t, nil, ""))
+	response, err := client.Check(context.Background(), &healthProto.HealthCheckRequest{
+		Service: usersProto.Users_ServiceDesc.ServiceName,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, healthProto.HealthCheckResponse_SERVING, response.Status)
+
+	stream, err := client.Watch(context.Background(), &healthProto.HealthCheckRequest{
+		Service: usersProto.Users_ServiceDesc.ServiceName,
+	})
+	assert.NoError(t, err)
+	response, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, healthProto.HealthCheckResponse_SERVING, response.Status)
+}
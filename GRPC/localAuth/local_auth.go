@@ -0,0 +1,308 @@
+package localAuth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sort"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+)
+
+// DefaultRoles are used when no roles are configured, none of them carries permissions
+var DefaultRoles = map[string]string{
+	"Superadmin":          "",
+	"Courier manager":     "",
+	"Courier":             "",
+	"Restaurant manager":  "",
+	"Authorized Customer": "",
+}
+
+// Config of the local issuer. PrivateKey is a PEM encoded PKCS#8 key (PKCS#1
+// is accepted for RSA), a random key is generated if it is empty. Roles maps
+// every known role to its comma separated permissions.
+type Config struct {
+	Algorithm  string
+	PrivateKey []byte
+	Issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	Roles      map[string]string
+}
+
+func (c Config) withDefaults() Config {
+	if c.Algorithm == "" {
+		c.Algorithm = AlgorithmRS256
+	}
+	if c.Issuer == "" {
+		c.Issuer = "authentication_service"
+	}
+	if c.AccessTTL <= 0 {
+		c.AccessTTL = 15 * time.Minute
+	}
+	if c.RefreshTTL <= 0 {
+		c.RefreshTTL = 30 * 24 * time.Hour
+	}
+	if len(c.Roles) == 0 {
+		c.Roles = DefaultRoles
+	}
+	return c
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	UserId      int32  `json:"user_id"`
+	Role        string `json:"role"`
+	Permissions string `json:"permissions,omitempty"`
+	Type        string `json:"typ"`
+}
+
+// LocalAuth signs and verifies tokens in-process, it serves the same requests
+// as the remote auth service so it can be used in place of its client
+type LocalAuth struct {
+	cfg      Config
+	method   jwt.SigningMethod
+	key      crypto.Signer
+	mu       sync.RWMutex
+	bindings map[int32]string
+}
+
+func NewLocalAuth(cfg Config) (*LocalAuth, error) {
+	cfg = cfg.withDefaults()
+	var method jwt.SigningMethod
+	switch cfg.Algorithm {
+	case AlgorithmRS256:
+		method = jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("newLocalAuth: unsupported algorithm %q", cfg.Algorithm)
+	}
+	var key crypto.Signer
+	var err error
+	if len(cfg.PrivateKey) == 0 {
+		key, err = generateKey(cfg.Algorithm)
+	} else {
+		key, err = parseKey(cfg.Algorithm, cfg.PrivateKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("newLocalAuth:%w", err)
+	}
+	return &LocalAuth{
+		cfg:      cfg,
+		method:   method,
+		key:      key,
+		bindings: make(map[int32]string),
+	}, nil
+}
+
+// PublicKey verifies the tokens issued by this instance
+func (l *LocalAuth) PublicKey() crypto.PublicKey {
+	return l.key.Public()
+}
+
+func (l *LocalAuth) GetUserWithRights(ctx context.Context, in *authProto.AccessToken, opts ...grpc.CallOption) (*authProto.UserRole, error) {
+	tokenClaims, err := l.parse(in.AccessToken, accessTokenType)
+	if err != nil {
+		return nil, err
+	}
+	return &authProto.UserRole{
+		UserId:      tokenClaims.UserId,
+		Role:        tokenClaims.Role,
+		Permissions: tokenClaims.Permissions,
+	}, nil
+}
+
+func (l *LocalAuth) BindUserAndRole(ctx context.Context, in *authProto.User, opts ...grpc.CallOption) (*authProto.ResultBinding, error) {
+	if _, ok := l.cfg.Roles[in.Role]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown role %q", in.Role)
+	}
+	l.mu.Lock()
+	l.bindings[in.UserId] = in.Role
+	l.mu.Unlock()
+	return &authProto.ResultBinding{Result: true}, nil
+}
+
+func (l *LocalAuth) TokenGenerationByRefresh(ctx context.Context, in *authProto.RefreshToken, opts ...grpc.CallOption) (*authProto.GeneratedTokens, error) {
+	tokenClaims, err := l.parse(in.RefreshToken, refreshTokenType)
+	if err != nil {
+		return nil, err
+	}
+	// a role bound after the refresh token was issued takes precedence
+	role := tokenClaims.Role
+	l.mu.RLock()
+	if bound, ok := l.bindings[tokenClaims.UserId]; ok {
+		role = bound
+	}
+	l.mu.RUnlock()
+	return l.generate(tokenClaims.UserId, role)
+}
+
+func (l *LocalAuth) TokenGenerationByUserId(ctx context.Context, in *authProto.User, opts ...grpc.CallOption) (*authProto.GeneratedTokens, error) {
+	return l.generate(in.UserId, in.Role)
+}
+
+func (l *LocalAuth) GetAllRoles(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*authProto.Roles, error) {
+	roles := make([]string, 0, len(l.cfg.Roles))
+	for role := range l.cfg.Roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return &authProto.Roles{Roles: strings.Join(roles, ",")}, nil
+}
+
+func (l *LocalAuth) generate(userId int32, role string) (*authProto.GeneratedTokens, error) {
+	permissions, ok := l.cfg.Roles[role]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown role %q", role)
+	}
+	now := time.Now()
+	accessToken, err := l.sign(userId, role, permissions, accessTokenType, now, l.cfg.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := l.sign(userId, role, "", refreshTokenType, now, l.cfg.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &authProto.GeneratedTokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+func (l *LocalAuth) sign(userId int32, role, permissions, tokenType string, now time.Time, ttl time.Duration) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", status.Errorf(codes.Internal, "generate token id:%s", err)
+	}
+	token := jwt.NewWithClaims(l.method, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    l.cfg.Issuer,
+			Subject:   strconv.Itoa(int(userId)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			ID:        hex.EncodeToString(id),
+		},
+		UserId:      userId,
+		Role:        role,
+		Permissions: permissions,
+		Type:        tokenType,
+	})
+	signed, err := token.SignedString(l.key)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "sign token:%s", err)
+	}
+	return signed, nil
+}
+
+// parse verifies the token and its type, rejected tokens are reported with
+// codes.Unauthenticated like the remote auth service does
+func (l *LocalAuth) parse(token string, tokenType string) (*claims, error) {
+	tokenClaims := &claims{}
+	_, err := jwt.ParseWithClaims(token, tokenClaims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != l.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		return l.key.Public(), nil
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token:%s", err)
+	}
+	if !tokenClaims.VerifyIssuer(l.cfg.Issuer, true) {
+		return nil, status.Error(codes.Unauthenticated, "invalid token: wrong issuer")
+	}
+	if tokenClaims.Type != tokenType {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %s token expected", tokenType)
+	}
+	return tokenClaims, nil
+}
+
+func generateKey(algorithm string) (crypto.Signer, error) {
+	if algorithm == AlgorithmEdDSA {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return rsa.GenerateKey(rand.Reader, 2048)
+}
+
+func parseKey(algorithm string, data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		rsaKey, pkcs1Err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if pkcs1Err != nil {
+			return nil, fmt.Errorf("parse private key:%w", err)
+		}
+		key = rsaKey
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if algorithm == AlgorithmRS256 {
+			return key, nil
+		}
+	case ed25519.PrivateKey:
+		if algorithm == AlgorithmEdDSA {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("private key of type %T can not be used with %s", key, algorithm)
+}
+
+// Roles are the permissions of the roles, they can be configured as text like ParseRoles reads
+type Roles map[string]string
+
+func (r *Roles) UnmarshalText(text []byte) error {
+	roles, err := ParseRoles(string(text))
+	if err != nil {
+		return err
+	}
+	*r = roles
+	return nil
+}
+
+// ParseRoles reads roles like "Superadmin=users:read,users:write;Courier", where
+// roles are separated by semicolons and followed by their permissions
+func ParseRoles(value string) (map[string]string, error) {
+	roles := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		role, permissions := entry, ""
+		if i := strings.Index(entry, "="); i >= 0 {
+			role, permissions = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		}
+		if role == "" {
+			return nil, fmt.Errorf("empty role in %q", entry)
+		}
+		roles[role] = permissions
+	}
+	return roles, nil
+}
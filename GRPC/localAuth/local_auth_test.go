@@ -0,0 +1,194 @@
+package localAuth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
+	"testing"
+	"time"
+)
+
+func pemKey(t *testing.T, key interface{}) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestLocalAuth_Tokens(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTable := []struct {
+		name string
+		cfg  Config
+	}{
+		{
+			name: "RS256",
+			cfg:  Config{Algorithm: AlgorithmRS256, PrivateKey: pemKey(t, rsaKey)},
+		},
+		{
+			name: "RS256 PKCS#1",
+			cfg: Config{Algorithm: AlgorithmRS256, PrivateKey: pem.EncodeToMemory(&pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+			})},
+		},
+		{
+			name: "EdDSA",
+			cfg:  Config{Algorithm: AlgorithmEdDSA, PrivateKey: pemKey(t, edKey)},
+		},
+		{
+			name: "Generated key",
+			cfg:  Config{Algorithm: AlgorithmEdDSA},
+		},
+	}
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.cfg.Roles = map[string]string{"Courier": "orders:read", "Superadmin": "users:read,users:write"}
+			issuer, err := NewLocalAuth(testCase.cfg)
+			assert.NoError(t, err)
+			ctx := context.Background()
+
+			tokens, err := issuer.TokenGenerationByUserId(ctx, &authProto.User{UserId: 7, Role: "Courier"})
+			assert.NoError(t, err)
+			user, err := issuer.GetUserWithRights(ctx, &authProto.AccessToken{AccessToken: tokens.AccessToken})
+			assert.NoError(t, err)
+			assert.Equal(t, int32(7), user.UserId)
+			assert.Equal(t, "Courier", user.Role)
+			assert.Equal(t, "orders:read", user.Permissions)
+
+			// a refresh token is not accepted as an access token and vice versa
+			_, err = issuer.GetUserWithRights(ctx, &authProto.AccessToken{AccessToken: tokens.RefreshToken})
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+			_, err = issuer.TokenGenerationByRefresh(ctx, &authProto.RefreshToken{RefreshToken: tokens.AccessToken})
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+			_, err = issuer.BindUserAndRole(ctx, &authProto.User{UserId: 7, Role: "Superadmin"})
+			assert.NoError(t, err)
+			refreshed, err := issuer.TokenGenerationByRefresh(ctx, &authProto.RefreshToken{RefreshToken: tokens.RefreshToken})
+			assert.NoError(t, err)
+			assert.NotEqual(t, tokens.AccessToken, refreshed.AccessToken)
+			user, err = issuer.GetUserWithRights(ctx, &authProto.AccessToken{AccessToken: refreshed.AccessToken})
+			assert.NoError(t, err)
+			assert.Equal(t, "Superadmin", user.Role)
+			assert.Equal(t, "users:read,users:write", user.Permissions)
+		})
+	}
+}
+
+func TestLocalAuth_RejectedTokens(t *testing.T) {
+	issuer, err := NewLocalAuth(Config{Algorithm: AlgorithmEdDSA})
+	assert.NoError(t, err)
+	other, err := NewLocalAuth(Config{Algorithm: AlgorithmEdDSA})
+	assert.NoError(t, err)
+	expiring, err := NewLocalAuth(Config{Algorithm: AlgorithmEdDSA, AccessTTL: time.Nanosecond})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	foreign, err := other.TokenGenerationByUserId(ctx, &authProto.User{UserId: 1, Role: "Courier"})
+	assert.NoError(t, err)
+	expired, err := expiring.TokenGenerationByUserId(ctx, &authProto.User{UserId: 1, Role: "Courier"})
+	assert.NoError(t, err)
+	time.Sleep(time.Second)
+
+	testTable := []struct {
+		name   string
+		issuer *LocalAuth
+		token  string
+	}{
+		{
+			name:   "Malformed",
+			issuer: issuer,
+			token:  "not a token",
+		},
+		{
+			name:   "Signed by other key",
+			issuer: issuer,
+			token:  foreign.AccessToken,
+		},
+		{
+			name:   "Expired",
+			issuer: expiring,
+			token:  expired.AccessToken,
+		},
+	}
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := testCase.issuer.GetUserWithRights(ctx, &authProto.AccessToken{AccessToken: testCase.token})
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		})
+	}
+}
+
+func TestLocalAuth_Roles(t *testing.T) {
+	issuer, err := NewLocalAuth(Config{Algorithm: AlgorithmEdDSA})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	roles, err := issuer.GetAllRoles(ctx, &empty.Empty{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Authorized Customer,Courier,Courier manager,Restaurant manager,Superadmin", roles.Roles)
+
+	_, err = issuer.BindUserAndRole(ctx, &authProto.User{UserId: 1, Role: "Pilot"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = issuer.TokenGenerationByUserId(ctx, &authProto.User{UserId: 1, Role: "Pilot"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestNewLocalAuth(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTable := []struct {
+		name string
+		cfg  Config
+	}{
+		{
+			name: "Unknown algorithm",
+			cfg:  Config{Algorithm: "HS256"},
+		},
+		{
+			name: "Key of other algorithm",
+			cfg:  Config{Algorithm: AlgorithmRS256, PrivateKey: pemKey(t, edKey)},
+		},
+		{
+			name: "Not PEM",
+			cfg:  Config{Algorithm: AlgorithmEdDSA, PrivateKey: []byte("key")},
+		},
+	}
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := NewLocalAuth(testCase.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseRoles(t *testing.T) {
+	roles, err := ParseRoles("Superadmin=users:read,users:write; Courier ;Courier manager=orders:read")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"Superadmin":      "users:read,users:write",
+		"Courier":         "",
+		"Courier manager": "orders:read",
+	}, roles)
+
+	_, err = ParseRoles("=users:read")
+	assert.Error(t, err)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/config"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/audit"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/database"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
+)
+
+const usage = `usage: audit [flags] <command>
+
+commands:
+  verify               walk the audit chain and report the first break
+  verify-export <file> check the signature and the chain of an export saved
+                       from GET /audit/export, the database is not used
+
+The signatures are checked with the key of audit.public_key_file, or with the
+public part of audit.signing_key_file when it is not set.
+
+The exit status is 1 when the chain or the export is broken. The database and
+the keys are configured with the same config file, environment variables and
+flags as the service, run audit -h to list the flags.
+`
+
+func main() {
+	logger := logging.GetLogger()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, "\n"+usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		logger.Fatalf("failed to load config:%s", err)
+	}
+	if !(len(args) == 1 && args[0] == "verify") && !(len(args) == 2 && args[0] == "verify-export") {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if _, err = logging.Configure(cfg.Logging.Config()); err != nil {
+		logger.Fatalf("failed to configure logging:%s", err)
+	}
+	verifier, err := loadVerifier(cfg.Audit)
+	if err != nil {
+		logger.Fatalf("invalid audit key:%s", err)
+	}
+	if args[0] == "verify-export" {
+		if verifier == nil {
+			logger.Fatal("audit.public_key_file or audit.signing_key_file must be set to verify an export")
+		}
+		if err = verifyExport(args[1], verifier); err != nil {
+			fmt.Printf("export is broken: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("export is intact")
+		return
+	}
+
+	db, err := database.NewPostgresDB(database.PostgresDB{
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		Username: cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.Name,
+		SSLMode:  cfg.Database.SSLMode,
+	})
+	if err != nil {
+		logger.Fatalf("failed to initialize db:%s", err)
+	}
+	defer db.Close()
+	auditService := service.NewAuditService(*repository.NewRepository(db, logger), logger, service.AuditPolicy{Verifier: verifier})
+
+	result, err := auditService.VerifyAuditChain(context.Background())
+	if err != nil {
+		logger.Fatalf("audit verify:%s", err)
+	}
+	printVerification(result)
+	if result.Break != nil {
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+func printVerification(result *model.AuditVerification) {
+	fmt.Printf("events: %d, recorded before the chain: %d\n", result.Events, result.Unchained)
+	fmt.Printf("checkpoints: %d\n", result.Checkpoints)
+	if !result.SignaturesChecked {
+		fmt.Println("signatures of the checkpoints are not checked, neither audit.public_key_file nor audit.signing_key_file is set")
+	}
+	if result.Break != nil {
+		fmt.Printf("chain is broken at event %d: %s\n", result.Break.EventID, result.Break.Reason)
+		return
+	}
+	fmt.Println("chain is intact")
+}
+
+// loadVerifier prefers the public key file, the signing key is only used when
+// it is not set. A signing key which does not match the public key is refused.
+func loadVerifier(cfg config.Audit) (*audit.Verifier, error) {
+	verifier, err := cfg.Verifier()
+	if err != nil {
+		return nil, err
+	}
+	signer, err := cfg.Signer()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case signer == nil:
+		return verifier, nil
+	case verifier == nil:
+		return signer.Verifier(), nil
+	case verifier.PublicKey() != signer.Verifier().PublicKey():
+		return nil, errors.New("audit.signing_key_file does not match audit.public_key_file")
+	}
+	return verifier, nil
+}
+
+func verifyExport(path string, verifier *audit.Verifier) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var export model.AuditExport
+	if err = json.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("decode:%w", err)
+	}
+	return verifier.VerifyExport(&export)
+}
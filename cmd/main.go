@@ -1,14 +1,30 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/grpcClient"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/grpcServer"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/localAuth"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/config"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/handler"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/mail"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/database"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/metrics"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/tracing"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/server"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
+	"strings"
+	"syscall"
+	"time"
 )
 
 // @title Authenticate Service
@@ -20,27 +36,225 @@ import (
 func main() {
 	logger := logging.GetLogger()
 
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		logger.Fatalf("failed to load config:%s", err)
+	}
+	if len(args) > 0 {
+		logger.Fatalf("unexpected arguments:%s", strings.Join(args, " "))
+	}
+	logFile, err := logging.Configure(cfg.Logging.Config())
+	if err != nil {
+		logger.Fatalf("failed to configure logging:%s", err)
+	}
+
+	tracer, err := tracing.Setup(context.Background(), cfg.Tracing.Config())
+	if err != nil {
+		logger.Fatalf("failed to initialize tracing:%s", err)
+	}
+
 	db, err := database.NewPostgresDB(database.PostgresDB{
-		Host:     os.Getenv("HOST"),
-		Port:     os.Getenv("DB_PORT"),
-		Username: os.Getenv("DB_USER"),
-		Password: os.Getenv("DB_PASSWORD"),
-		DBName:   os.Getenv("DB_DATABASE"),
-		SSLMode:  os.Getenv("DB_SSL_MODE"),
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		Username: cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.Name,
+		SSLMode:  cfg.Database.SSLMode,
 	})
 	if err != nil {
 		logger.Panicf("failed to initialize db:%s", err.Error())
 	}
+	metrics.RegisterDB(db, cfg.Database.Name)
+	if cfg.Database.MigrateOnStart {
+		migrator, err := database.NewMigrator(db, logger)
+		if err != nil {
+			logger.Panicf("failed to load migrations:%s", err)
+		}
+		if err = migrator.Up(context.Background(), 0); err != nil {
+			logger.Panicf("failed to apply migrations:%s", err)
+		}
+	}
 
-	grpcCli := grpcClient.NewGRPCClient(os.Getenv("HOST"))
+	authCli := newAuthClient(logger, cfg)
 	rep := repository.NewRepository(db, logger)
-	ser := service.NewService(rep, grpcCli, logger)
+	if cfg.RateLimits.Store == "memory" {
+		rep.RateLimit = repository.NewRateLimitMemory()
+	}
+	if cfg.EmailVerification.Secret == "" {
+		logger.Warn("email_verification.secret is not set, verification links will not survive a restart")
+	}
+	if cfg.TwoFactor.Secret == "" {
+		logger.Warn("two_factor.secret is not set, login challenges are accepted only by this instance")
+	}
+	auditSigner, err := cfg.Audit.Signer()
+	if err != nil {
+		logger.Panicf("invalid audit.signing_key_file:%s", err)
+	}
+	if auditSigner == nil {
+		logger.Warn("audit.signing_key_file is not set, audit checkpoints can not be verified after a restart")
+	}
+	grpcTLS, err := cfg.GRPC.TLSConfig()
+	if err != nil {
+		logger.Panicf("invalid grpc TLS settings:%s", err)
+	}
+	if len(cfg.GRPC.ServiceTokens) == 0 && cfg.GRPC.ClientCAFile == "" {
+		logger.Warn("neither grpc.service_tokens nor grpc.client_ca_file is set, the Users gRPC API refuses every call")
+	}
+	ser := service.NewService(rep, authCli, logger, service.Config{
+		Lockout: service.LockoutPolicy{
+			MaxAttempts:  cfg.Lockout.MaxAttempts,
+			BaseDuration: cfg.Lockout.BaseDuration,
+			MaxDuration:  cfg.Lockout.MaxDuration,
+		},
+		PasswordReset: service.PasswordResetPolicy{
+			TTL: cfg.PasswordReset.TTL,
+			URL: cfg.PasswordReset.URL,
+		},
+		EmailVerification: service.EmailVerificationPolicy{
+			Secret:     cfg.EmailVerification.Secret,
+			TTL:        cfg.EmailVerification.TTL,
+			URL:        cfg.EmailVerification.URL,
+			Unverified: cfg.EmailVerification.Unverified,
+		},
+		TwoFactor: service.TwoFactorPolicy{
+			Secret:        cfg.TwoFactor.Secret,
+			ChallengeTTL:  cfg.TwoFactor.ChallengeTTL,
+			Issuer:        cfg.TwoFactor.Issuer,
+			EnrollmentTTL: cfg.TwoFactor.EnrollmentTTL,
+			EnrollmentURL: cfg.TwoFactor.EnrollmentURL,
+		},
+		RateLimits: cfg.RateLimits.Rules(),
+		Timeouts: service.TimeoutPolicy{
+			Request:    cfg.Timeouts.Request,
+			Auth:       cfg.Timeouts.Auth,
+			Operations: cfg.Timeouts.Operations,
+		},
+		Mail: mail.Config{
+			Host:     cfg.Mail.Host,
+			Port:     cfg.Mail.Port,
+			From:     cfg.Mail.From,
+			Password: cfg.Mail.Password,
+		},
+		Audit:      service.AuditPolicy{Signer: auditSigner},
+		BcryptCost: cfg.Passwords.BcryptCost,
+	})
+	cleanupCtx, stopCleanup := context.WithCancel(context.Background())
+	cleanupDone := make(chan struct{})
+	go func() {
+		ser.RunCleanup(cleanupCtx, cfg.CleanupInterval)
+		close(cleanupDone)
+	}()
 	handlers := handler.NewHandler(logger, ser)
+	handlers.TrustProxies(cfg.HTTP.TrustedProxies)
 
-	port := os.Getenv("API_SERVER_PORT")
-	serv := new(server.Server)
+	serv := server.NewServer(server.Config{
+		ReadTimeout:    cfg.HTTP.ReadTimeout,
+		WriteTimeout:   cfg.HTTP.WriteTimeout,
+		MaxHeaderBytes: cfg.HTTP.MaxHeaderBytes,
+	})
+	grpcServ := server.NewGRPCServer(grpcServer.NewUsersServer(logger, ser), server.GRPCConfig{
+		ServiceTokens: cfg.GRPC.ServiceTokens,
+		TLS:           grpcTLS,
+		Timeout:       ser.Timeouts.Timeout,
+	})
+
+	// both servers live and die together, the first one to fail or a signal stops them
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	errs := make(chan error, 2)
+	go func() {
+		errs <- fmt.Errorf("http server:%w", serv.Run(cfg.HTTP.Port, handlers.InitRoutes()))
+	}()
+	go func() {
+		errs <- fmt.Errorf("grpc server:%w", grpcServ.Run(cfg.GRPC.Port))
+	}()
+	logger.Infof("Service started, http port %s, grpc port %s", cfg.HTTP.Port, cfg.GRPC.Port)
+	select {
+	case err = <-errs:
+		logger.Errorf("Error occured while running servers: %s", err)
+	case <-signals.Done():
+		logger.Infof("Shutting down, draining for at most %s", cfg.ShutdownTimeout)
+	}
+	// a second signal kills the process at once
+	stopSignals()
+	ser.Health.Drain()
+	if err == nil && cfg.ShutdownDelay > 0 {
+		logger.Infof("Shutdown: reporting not ready for %s", cfg.ShutdownDelay)
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	shutdown := func(step string, stop func() error) {
+		logger.Infof("Shutdown: %s", step)
+		if stopErr := stop(); stopErr != nil {
+			logger.Errorf("Shutdown: %s:%s", step, stopErr)
+		}
+	}
+	shutdown("stopping http server", func() error {
+		return serv.Shutdown(ctx)
+	})
+	shutdown("stopping grpc server", func() error {
+		return grpcServ.Shutdown(ctx)
+	})
+	shutdown("waiting for cleanup", func() error {
+		stopCleanup()
+		select {
+		case <-cleanupDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	shutdown("waiting for emails", func() error {
+		return ser.Shutdown(ctx)
+	})
+	shutdown("flushing traces", func() error {
+		return tracer.Shutdown(ctx)
+	})
+	if closer, ok := authCli.(io.Closer); ok {
+		shutdown("closing auth connection", closer.Close)
+	}
+	shutdown("closing database", db.Close)
+	if err != nil {
+		logger.Fatalf("Service stopped: %s", err)
+	}
+	logger.Info("Service stopped")
+	_ = logFile.Close()
+}
 
-	if err := serv.Run(port, handlers.InitRoutes()); err != nil {
-		logger.Panicf("Error occured while running http server: %s", err.Error())
+// newAuthClient connects to the remote auth service unless the local provider
+// asks for tokens to be issued in-process
+func newAuthClient(logger logging.Logger, cfg *config.Config) authProto.AuthClient {
+	if cfg.Auth.Provider != "local" {
+		cli, err := grpcClient.NewGRPCClient(cfg.Auth.Address(cfg.Database))
+		if err != nil {
+			logger.Panicf("failed to initialize auth client:%s", err)
+		}
+		return cli
+	}
+	local := localAuth.Config{
+		Algorithm:  cfg.Auth.Local.Algorithm,
+		Issuer:     cfg.Auth.Local.Issuer,
+		AccessTTL:  cfg.Auth.Local.AccessTTL,
+		RefreshTTL: cfg.Auth.Local.RefreshTTL,
+		Roles:      cfg.Auth.Local.Roles,
+	}
+	if path := cfg.Auth.Local.PrivateKeyFile; path != "" {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			logger.Panicf("invalid auth.local.private_key_file:%s", err)
+		}
+		local.PrivateKey = key
+	} else {
+		logger.Warn("auth.local.private_key_file is not set, issued tokens will not survive a restart")
+	}
+	issuer, err := localAuth.NewLocalAuth(local)
+	if err != nil {
+		logger.Panicf("failed to initialize local auth:%s", err)
 	}
+	return issuer
 }
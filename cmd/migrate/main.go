@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/config"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/database"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"strconv"
+	"text/tabwriter"
+)
+
+const usage = `usage: migrate [flags] <command> [argument]
+
+commands:
+  up [N]          apply N pending migrations, all of them by default
+  down [N]        roll back N applied migrations, one by default
+  status          list migrations and whether they are applied
+  force VERSION   mark migrations up to VERSION as applied without running them
+
+The database is configured with the same config file, environment variables
+and flags as the service, run migrate -h to list the flags.
+`
+
+func main() {
+	logger := logging.GetLogger()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, "\n"+usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		logger.Fatalf("failed to load config:%s", err)
+	}
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if _, err = logging.Configure(cfg.Logging.Config()); err != nil {
+		logger.Fatalf("failed to configure logging:%s", err)
+	}
+	command := args[0]
+	argument := ""
+	if len(args) == 2 {
+		argument = args[1]
+	}
+
+	db, err := database.NewPostgresDB(database.PostgresDB{
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		Username: cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.Name,
+		SSLMode:  cfg.Database.SSLMode,
+	})
+	if err != nil {
+		logger.Fatalf("failed to initialize db:%s", err)
+	}
+	defer db.Close()
+	migrator, err := database.NewMigrator(db, logger)
+	if err != nil {
+		logger.Fatalf("failed to load migrations:%s", err)
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		err = migrator.Up(ctx, parseNumber(argument, 0))
+	case "down":
+		err = migrator.Down(ctx, parseNumber(argument, 1))
+	case "status":
+		err = printStatus(ctx, migrator)
+	case "force":
+		if argument == "" {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		err = migrator.Force(ctx, int64(parseNumber(argument, 0)))
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		logger.Fatalf("migrate %s:%s", command, err)
+	}
+}
+
+func parseNumber(argument string, defaultValue int) int {
+	if argument == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(argument)
+	if err != nil || number < 0 {
+		fmt.Fprintf(os.Stderr, "invalid number %q\n\n%s", argument, usage)
+		os.Exit(2)
+	}
+	return number
+}
+
+func printStatus(ctx context.Context, migrator *database.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT\tNOTE")
+	for _, status := range statuses {
+		appliedAt, note := "pending", ""
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if status.Changed {
+			note = "changed after it was applied"
+		}
+		if status.Unknown {
+			note = "unknown to this build"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", status.Version, status.Name, appliedAt, note)
+	}
+	return w.Flush()
+}
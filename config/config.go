@@ -0,0 +1,406 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"net"
+	"os"
+	"sort"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/localAuth"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/audit"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/tracing"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the service. Every setting can be overridden by
+// the environment variable named in its env tag and by the flag named after its
+// path in the file, e.g. -database.host. Secrets marked with "file" can also be
+// read from the file named by the variable with the _FILE suffix.
+type Config struct {
+	HTTP              HTTP              `yaml:"http"`
+	GRPC              GRPC              `yaml:"grpc"`
+	Database          Database          `yaml:"database"`
+	Auth              Auth              `yaml:"auth"`
+	Mail              Mail              `yaml:"mail"`
+	Passwords         Passwords         `yaml:"passwords"`
+	Lockout           Lockout           `yaml:"lockout"`
+	PasswordReset     PasswordReset     `yaml:"password_reset"`
+	EmailVerification EmailVerification `yaml:"email_verification"`
+	TwoFactor         TwoFactor         `yaml:"two_factor"`
+	RateLimits        RateLimits        `yaml:"rate_limits"`
+	Timeouts          Timeouts          `yaml:"timeouts"`
+	Tracing           Tracing           `yaml:"tracing"`
+	Logging           Logging           `yaml:"logging"`
+	Audit             Audit             `yaml:"audit"`
+	CleanupInterval   time.Duration     `yaml:"cleanup_interval" env:"CLEANUP_INTERVAL"`
+	ShutdownTimeout   time.Duration     `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	ShutdownDelay     time.Duration     `yaml:"shutdown_delay" env:"SHUTDOWN_DELAY"`
+}
+
+type HTTP struct {
+	Port           string        `yaml:"port" env:"API_SERVER_PORT"`
+	ReadTimeout    time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout   time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	MaxHeaderBytes int           `yaml:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES"`
+	TrustedProxies List          `yaml:"trusted_proxies" env:"HTTP_TRUSTED_PROXIES"`
+}
+
+// List is written as a YAML sequence or as comma separated values
+type List []string
+
+func (l *List) UnmarshalText(text []byte) error {
+	*l = nil
+	for _, item := range strings.Split(string(text), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// GRPC serves the Users API to the services holding one of the tokens, over TLS
+// when the certificate is set and over mutual TLS when the client CA is set as well
+type GRPC struct {
+	Port          string `yaml:"port" env:"GRPC_SERVER_PORT"`
+	ServiceTokens List   `yaml:"service_tokens" env:"GRPC_SERVICE_TOKENS,file"`
+	TLSCertFile   string `yaml:"tls_cert_file" env:"GRPC_TLS_CERT_FILE"`
+	TLSKeyFile    string `yaml:"tls_key_file" env:"GRPC_TLS_KEY_FILE"`
+	ClientCAFile  string `yaml:"client_ca_file" env:"GRPC_CLIENT_CA_FILE"`
+}
+
+// TLSConfig reads the certificates, nil is returned when the certificate is not set
+func (g GRPC) TLSConfig() (*tls.Config, error) {
+	if g.TLSCertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(g.TLSCertFile, g.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tlsConfig:%w", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if g.ClientCAFile == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(g.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("tlsConfig:%w", err)
+	}
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("tlsConfig: no certificates in %s", g.ClientCAFile)
+	}
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+type Database struct {
+	Host           string `yaml:"host" env:"HOST"`
+	Port           string `yaml:"port" env:"DB_PORT"`
+	User           string `yaml:"user" env:"DB_USER"`
+	Password       string `yaml:"password" env:"DB_PASSWORD,file"`
+	Name           string `yaml:"name" env:"DB_DATABASE"`
+	SSLMode        string `yaml:"ssl_mode" env:"DB_SSL_MODE"`
+	MigrateOnStart bool   `yaml:"migrate_on_start" env:"MIGRATE_ON_START"`
+}
+
+// Auth chooses who issues the tokens, the remote auth service or the service itself
+type Auth struct {
+	Provider string    `yaml:"provider" env:"AUTH_PROVIDER"`
+	Host     string    `yaml:"host" env:"AUTH_HOST"`
+	Port     string    `yaml:"port" env:"AUTH_PORT"`
+	Local    LocalAuth `yaml:"local"`
+}
+
+// Address of the remote auth service, it runs on the database host unless its own is set
+func (a Auth) Address(database Database) string {
+	host := a.Host
+	if host == "" {
+		host = database.Host
+	}
+	return host + ":" + a.Port
+}
+
+type LocalAuth struct {
+	Algorithm      string          `yaml:"algorithm" env:"LOCAL_AUTH_ALGORITHM"`
+	Issuer         string          `yaml:"issuer" env:"LOCAL_AUTH_ISSUER"`
+	AccessTTL      time.Duration   `yaml:"access_ttl" env:"LOCAL_AUTH_ACCESS_TTL"`
+	RefreshTTL     time.Duration   `yaml:"refresh_ttl" env:"LOCAL_AUTH_REFRESH_TTL"`
+	PrivateKeyFile string          `yaml:"private_key_file" env:"LOCAL_AUTH_PRIVATE_KEY_FILE"`
+	Roles          localAuth.Roles `yaml:"roles" env:"LOCAL_AUTH_ROLES"`
+}
+
+type Mail struct {
+	Host     string `yaml:"host" env:"SMTP_HOST"`
+	Port     string `yaml:"port" env:"SMTP_PORT"`
+	From     string `yaml:"from" env:"POST_FROM"`
+	Password string `yaml:"password" env:"POST_PASSWORD,file"`
+}
+
+type Passwords struct {
+	BcryptCost int `yaml:"bcrypt_cost" env:"BCRYPT_COST"`
+}
+
+type Lockout struct {
+	MaxAttempts  int           `yaml:"max_attempts" env:"LOGIN_MAX_ATTEMPTS"`
+	BaseDuration time.Duration `yaml:"base_duration" env:"LOGIN_LOCKOUT_BASE"`
+	MaxDuration  time.Duration `yaml:"max_duration" env:"LOGIN_LOCKOUT_MAX"`
+}
+
+type PasswordReset struct {
+	TTL time.Duration `yaml:"ttl" env:"PASSWORD_RESET_TTL"`
+	URL string        `yaml:"url" env:"PASSWORD_RESET_URL"`
+}
+
+type EmailVerification struct {
+	Secret     string        `yaml:"secret" env:"EMAIL_VERIFICATION_SECRET,file"`
+	TTL        time.Duration `yaml:"ttl" env:"EMAIL_VERIFICATION_TTL"`
+	URL        string        `yaml:"url" env:"EMAIL_VERIFICATION_URL"`
+	Unverified string        `yaml:"unverified" env:"EMAIL_UNVERIFIED_POLICY"`
+}
+
+type TwoFactor struct {
+	Secret        string        `yaml:"secret" env:"TWO_FACTOR_SECRET,file"`
+	ChallengeTTL  time.Duration `yaml:"challenge_ttl" env:"TWO_FACTOR_CHALLENGE_TTL"`
+	Issuer        string        `yaml:"issuer" env:"TWO_FACTOR_ISSUER"`
+	EnrollmentTTL time.Duration `yaml:"enrollment_ttl" env:"TWO_FACTOR_ENROLLMENT_TTL"`
+	EnrollmentURL string        `yaml:"enrollment_url" env:"TWO_FACTOR_ENROLLMENT_URL"`
+}
+
+// RateLimits are written like "10/1m/ip_email", routes without a rule use service.DefaultRateLimits
+type RateLimits struct {
+	Store           string                `yaml:"store" env:"RATE_LIMIT_STORE"`
+	Login           service.RateLimitRule `yaml:"login" env:"RATE_LIMIT_LOGIN"`
+	Login2FA        service.RateLimitRule `yaml:"login2fa" env:"RATE_LIMIT_LOGIN_2FA"`
+	Customer        service.RateLimitRule `yaml:"customer" env:"RATE_LIMIT_CUSTOMER"`
+	RestorePassword service.RateLimitRule `yaml:"restore_password" env:"RATE_LIMIT_RESTORE_PASSWORD"`
+	ResetPassword   service.RateLimitRule `yaml:"reset_password" env:"RATE_LIMIT_RESET_PASSWORD"`
+	Verify          service.RateLimitRule `yaml:"verify" env:"RATE_LIMIT_VERIFY"`
+	// the gRPC calls are counted by the address of the calling service
+	VerifyCredentials service.RateLimitRule `yaml:"verify_credentials" env:"RATE_LIMIT_VERIFY_CREDENTIALS"`
+	UserByEmail       service.RateLimitRule `yaml:"user_by_email" env:"RATE_LIMIT_USER_BY_EMAIL"`
+}
+
+// Rules returns the configured rules by the routes they throttle
+func (r RateLimits) Rules() map[string]service.RateLimitRule {
+	rules := make(map[string]service.RateLimitRule)
+	for route, rule := range map[string]service.RateLimitRule{
+		"login":             r.Login,
+		"login2fa":          r.Login2FA,
+		"customer":          r.Customer,
+		"restorePassword":   r.RestorePassword,
+		"resetPassword":     r.ResetPassword,
+		"verify":            r.Verify,
+		"verifyCredentials": r.VerifyCredentials,
+		"userByEmail":       r.UserByEmail,
+	} {
+		if rule.Requests != 0 {
+			rules[route] = rule
+		}
+	}
+	return rules
+}
+
+type Timeouts struct {
+	Request    time.Duration             `yaml:"request" env:"REQUEST_TIMEOUT"`
+	Auth       time.Duration             `yaml:"auth" env:"AUTH_TIMEOUT"`
+	Operations service.OperationTimeouts `yaml:"operations" env:"OPERATION_TIMEOUTS"`
+}
+
+// Tracing chooses the exporter of the spans, none, otlp or stdout, the memory one is meant for tests
+type Tracing struct {
+	Exporter     string  `yaml:"exporter" env:"TRACING_EXPORTER"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool    `yaml:"otlp_insecure" env:"TRACING_OTLP_INSECURE"`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+// Config of the tracing package
+func (t Tracing) Config() tracing.Config {
+	return tracing.Config{Exporter: t.Exporter, Endpoint: t.OTLPEndpoint, Insecure: t.OTLPInsecure, SampleRatio: t.SampleRatio}
+}
+
+// Logging writes text or JSON lines to stdout or to a file rotated by size and age
+type Logging struct {
+	Format     string        `yaml:"format" env:"LOG_FORMAT"`
+	Level      string        `yaml:"level" env:"LOG_LEVEL"`
+	Output     string        `yaml:"output" env:"LOG_OUTPUT"`
+	File       string        `yaml:"file" env:"LOG_FILE"`
+	MaxSizeMB  int           `yaml:"max_size_mb" env:"LOG_MAX_SIZE_MB"`
+	MaxAge     time.Duration `yaml:"max_age" env:"LOG_MAX_AGE"`
+	MaxBackups int           `yaml:"max_backups" env:"LOG_MAX_BACKUPS"`
+}
+
+// Config of the logging package
+func (l Logging) Config() logging.Config {
+	return logging.Config{Format: l.Format, Level: l.Level, Output: l.Output, File: l.File,
+		MaxSizeMB: l.MaxSizeMB, MaxAge: l.MaxAge, MaxBackups: l.MaxBackups}
+}
+
+// Audit holds the Ed25519 key the audit chain is signed with and the public key it is verified with
+type Audit struct {
+	SigningKeyFile string `yaml:"signing_key_file" env:"AUDIT_SIGNING_KEY_FILE"`
+	PublicKeyFile  string `yaml:"public_key_file" env:"AUDIT_PUBLIC_KEY_FILE"`
+}
+
+// Signer reads the signing key, nil is returned when the file is not set
+func (a Audit) Signer() (*audit.Signer, error) {
+	if a.SigningKeyFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(a.SigningKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("signer:%w", err)
+	}
+	return audit.NewSigner(data)
+}
+
+// Verifier reads the public key, nil is returned when the file is not set
+func (a Audit) Verifier() (*audit.Verifier, error) {
+	if a.PublicKeyFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(a.PublicKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("verifier:%w", err)
+	}
+	return audit.NewVerifier(data)
+}
+
+// Default returns the settings used for everything the file, the environment and the flags leave out
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Port:           "8080",
+			ReadTimeout:    10 * time.Second,
+			WriteTimeout:   10 * time.Second,
+			MaxHeaderBytes: 1 << 20,
+		},
+		GRPC:       GRPC{Port: "9090"},
+		Database:   Database{Port: "5432", MigrateOnStart: true},
+		Auth:       Auth{Provider: "remote", Port: "8090"},
+		Mail:       Mail{Host: "smtp.gmail.com", Port: "587"},
+		Passwords:  Passwords{BcryptCost: bcrypt.DefaultCost},
+		RateLimits: RateLimits{Store: "postgres"},
+		Tracing:    Tracing{Exporter: tracing.ExporterNone, OTLPEndpoint: "localhost:4317", SampleRatio: 1},
+		Logging: Logging{
+			Format:     logging.FormatJSON,
+			Level:      "info",
+			Output:     logging.OutputStdout,
+			File:       "logs/service.log",
+			MaxSizeMB:  100,
+			MaxAge:     7 * 24 * time.Hour,
+			MaxBackups: 5,
+		},
+		CleanupInterval: time.Hour,
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	names := make(map[string]string)
+	for _, f := range fieldsOf(c) {
+		names[f.path] = f.path + " (" + f.env + ")"
+	}
+	var problems []string
+	check := func(ok bool, path string, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, names[path]+" "+fmt.Sprintf(format, args...))
+		}
+	}
+	for _, port := range []struct {
+		path  string
+		value string
+	}{
+		{"http.port", c.HTTP.Port},
+		{"grpc.port", c.GRPC.Port},
+		{"database.port", c.Database.Port},
+		{"auth.port", c.Auth.Port},
+		{"mail.port", c.Mail.Port},
+	} {
+		number, err := strconv.Atoi(port.value)
+		check(err == nil && number > 0 && number < 1<<16, port.path, "must be a port number, got %q", port.value)
+	}
+	check(c.HTTP.ReadTimeout > 0, "http.read_timeout", "must be positive")
+	check(c.HTTP.WriteTimeout > 0, "http.write_timeout", "must be positive")
+	check(c.HTTP.MaxHeaderBytes > 0, "http.max_header_bytes", "must be positive")
+	for _, proxy := range c.HTTP.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "http.trusted_proxies", "must be IP addresses or CIDRs, got %q", proxy)
+	}
+	check(c.GRPC.TLSCertFile == "" || c.GRPC.TLSKeyFile != "", "grpc.tls_key_file", "is required by %s", names["grpc.tls_cert_file"])
+	check(c.GRPC.TLSKeyFile == "" || c.GRPC.TLSCertFile != "", "grpc.tls_cert_file", "is required by %s", names["grpc.tls_key_file"])
+	check(c.GRPC.ClientCAFile == "" || c.GRPC.TLSCertFile != "", "grpc.tls_cert_file", "is required by %s", names["grpc.client_ca_file"])
+	check(c.Database.Host != "", "database.host", "is required")
+	check(c.Database.User != "", "database.user", "is required")
+	check(c.Database.Name != "", "database.name", "is required")
+	// the modes lib/pq supports, empty is its default (require)
+	check(oneOf(c.Database.SSLMode, "", "disable", "require", "verify-ca", "verify-full"), "database.ssl_mode",
+		"must be empty or one of disable, require, verify-ca, verify-full")
+	check(oneOf(c.Auth.Provider, "remote", "local"), "auth.provider", "must be remote or local")
+	check(oneOf(c.Auth.Local.Algorithm, "", localAuth.AlgorithmRS256, localAuth.AlgorithmEdDSA), "auth.local.algorithm",
+		"must be %s or %s", localAuth.AlgorithmRS256, localAuth.AlgorithmEdDSA)
+	// the revocations of the tokens are kept for MaxTokenTTL only
+	check(c.Auth.Local.AccessTTL <= service.MaxTokenTTL, "auth.local.access_ttl", "can not be longer than %s", service.MaxTokenTTL)
+	check(c.Auth.Local.RefreshTTL <= service.MaxTokenTTL, "auth.local.refresh_ttl", "can not be longer than %s", service.MaxTokenTTL)
+	check(c.Mail.Host != "", "mail.host", "is required")
+	check(c.Passwords.BcryptCost >= bcrypt.MinCost && c.Passwords.BcryptCost <= bcrypt.MaxCost, "passwords.bcrypt_cost",
+		"must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	check(c.Lockout.MaxAttempts >= 0, "lockout.max_attempts", "can not be negative")
+	check(c.Lockout.MaxDuration == 0 || c.Lockout.MaxDuration >= c.Lockout.BaseDuration, "lockout.max_duration",
+		"can not be shorter than %s", names["lockout.base_duration"])
+	check(oneOf(c.EmailVerification.Unverified, "", service.UnverifiedAllowLogin, service.UnverifiedDenyLogin), "email_verification.unverified",
+		"must be %s or %s", service.UnverifiedAllowLogin, service.UnverifiedDenyLogin)
+	check(oneOf(c.RateLimits.Store, "postgres", "memory"), "rate_limits.store", "must be postgres or memory")
+	check(oneOf(c.Tracing.Exporter, tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout, tracing.ExporterMemory), "tracing.exporter",
+		"must be one of %s, %s, %s, %s", tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout, tracing.ExporterMemory)
+	check(c.Tracing.Exporter != tracing.ExporterOTLP || c.Tracing.OTLPEndpoint != "", "tracing.otlp_endpoint", "is required by the otlp exporter")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+	check(oneOf(c.Logging.Format, logging.FormatText, logging.FormatJSON), "logging.format", "must be %s or %s", logging.FormatText, logging.FormatJSON)
+	_, err := logrus.ParseLevel(c.Logging.Level)
+	check(err == nil, "logging.level", "must be one of trace, debug, info, warn, error, fatal, panic")
+	check(oneOf(c.Logging.Output, logging.OutputStdout, logging.OutputFile), "logging.output", "must be %s or %s", logging.OutputStdout, logging.OutputFile)
+	check(c.Logging.Output != logging.OutputFile || c.Logging.File != "", "logging.file", "is required by the file output")
+	check(c.Logging.MaxSizeMB > 0, "logging.max_size_mb", "must be positive")
+	check(c.Logging.MaxBackups >= 0, "logging.max_backups", "can not be negative")
+	check(c.CleanupInterval > 0, "cleanup_interval", "must be positive")
+	check(c.ShutdownTimeout > 0, "shutdown_timeout", "must be positive")
+	for path, duration := range map[string]time.Duration{
+		"auth.local.access_ttl":     c.Auth.Local.AccessTTL,
+		"auth.local.refresh_ttl":    c.Auth.Local.RefreshTTL,
+		"lockout.base_duration":     c.Lockout.BaseDuration,
+		"lockout.max_duration":      c.Lockout.MaxDuration,
+		"password_reset.ttl":        c.PasswordReset.TTL,
+		"email_verification.ttl":    c.EmailVerification.TTL,
+		"two_factor.challenge_ttl":  c.TwoFactor.ChallengeTTL,
+		"two_factor.enrollment_ttl": c.TwoFactor.EnrollmentTTL,
+		"timeouts.request":          c.Timeouts.Request,
+		"timeouts.auth":             c.Timeouts.Auth,
+		"shutdown_delay":            c.ShutdownDelay,
+		"logging.max_age":           c.Logging.MaxAge,
+	} {
+		check(duration >= 0, path, "can not be negative")
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	// the map above is iterated in a random order
+	sort.Strings(problems)
+	return fmt.Errorf("invalid config:\n  %s", strings.Join(problems, "\n  "))
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
+	"testing"
+	"time"
+)
+
+// writeFile creates a file in a temporary directory and returns its path
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+const validConfig = `
+database:
+  host: localhost
+  user: postgres
+  name: food_delivery
+`
+
+func TestLoad(t *testing.T) {
+	testTable := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		args     []string
+		expected func(cfg *Config)
+		rest     []string
+	}{
+		{
+			name: "Defaults",
+			file: validConfig,
+			expected: func(cfg *Config) {
+				cfg.Database.Host, cfg.Database.User, cfg.Database.Name = "localhost", "postgres", "food_delivery"
+			},
+		},
+		{
+			name: "File, env and flags",
+			file: validConfig + `
+http:
+  port: "8000"
+  read_timeout: 5s
+  trusted_proxies: [10.0.0.1]
+lockout:
+  max_attempts: 3
+rate_limits:
+  login: 5/1m/ip
+timeouts:
+  operations:
+    GET /users/: 30s
+`,
+			env:  map[string]string{"API_SERVER_PORT": "8001", "DB_PASSWORD": "qwerty", "MIGRATE_ON_START": "false"},
+			args: []string{"-http.port", "8002", "-lockout.max_attempts=4", "up", "1"},
+			expected: func(cfg *Config) {
+				cfg.Database.Host, cfg.Database.User, cfg.Database.Name = "localhost", "postgres", "food_delivery"
+				cfg.Database.Password, cfg.Database.MigrateOnStart = "qwerty", false
+				cfg.HTTP.Port, cfg.HTTP.ReadTimeout = "8002", 5*time.Second
+				cfg.HTTP.TrustedProxies = List{"10.0.0.1"}
+				cfg.Lockout.MaxAttempts = 4
+				cfg.RateLimits.Login = service.RateLimitRule{Requests: 5, Window: time.Minute, KeyBy: service.RateLimitByIP}
+				cfg.Timeouts.Operations = service.OperationTimeouts{"GET /users/": 30 * time.Second}
+			},
+			rest: []string{"up", "1"},
+		},
+		{
+			name: "Env only",
+			env: map[string]string{"HOST": "db", "DB_USER": "postgres", "DB_DATABASE": "food_delivery",
+				"RATE_LIMIT_VERIFY": "1/1h/email", "OPERATION_TIMEOUTS": "GET /users/=30s", "LOCAL_AUTH_ROLES": "Superadmin=users:read;Courier",
+				"TRACING_SAMPLE_RATIO": "0.25", "LOG_FORMAT": "text", "HTTP_TRUSTED_PROXIES": "10.0.0.0/8, 127.0.0.1"},
+			expected: func(cfg *Config) {
+				cfg.Database.Host, cfg.Database.User, cfg.Database.Name = "db", "postgres", "food_delivery"
+				cfg.RateLimits.Verify = service.RateLimitRule{Requests: 1, Window: time.Hour, KeyBy: service.RateLimitByEmail}
+				cfg.Timeouts.Operations = service.OperationTimeouts{"GET /users/": 30 * time.Second}
+				cfg.Auth.Local.Roles = map[string]string{"Superadmin": "users:read", "Courier": ""}
+				cfg.Tracing.SampleRatio = 0.25
+				cfg.Logging.Format = "text"
+				cfg.HTTP.TrustedProxies = List{"10.0.0.0/8", "127.0.0.1"}
+			},
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			//Init dependencies
+			t.Setenv("CONFIG_PATH", "")
+			if tt.file != "" {
+				t.Setenv("CONFIG_PATH", writeFile(t, "config.yaml", tt.file))
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			expected := Default()
+			tt.expected(&expected)
+
+			cfg, rest, err := Load(tt.args)
+			//Assert
+			assert.NoError(t, err)
+			assert.Equal(t, &expected, cfg)
+			assert.Equal(t, tt.rest, rest)
+		})
+	}
+}
+
+func TestLoad_secretFile(t *testing.T) {
+	t.Setenv("CONFIG_PATH", writeFile(t, "config.yaml", validConfig))
+	t.Setenv("DB_PASSWORD_FILE", writeFile(t, "db_password", "qwerty\n"))
+
+	cfg, _, err := Load(nil)
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "qwerty", cfg.Database.Password)
+
+	t.Setenv("DB_PASSWORD", "asdfgh")
+	_, _, err = Load(nil)
+	assert.EqualError(t, err, "database.password: only one of DB_PASSWORD and DB_PASSWORD_FILE can be set")
+}
+
+func TestLoad_errors(t *testing.T) {
+	testTable := []struct {
+		name          string
+		file          string
+		env           map[string]string
+		args          []string
+		expectedError string
+	}{
+		{
+			name:          "Missing file",
+			env:           map[string]string{"CONFIG_PATH": "missing.yaml"},
+			expectedError: "config file:open missing.yaml: no such file or directory",
+		},
+		{
+			name:          "Unknown key",
+			file:          validConfig + "databse:\n  port: \"5433\"\n",
+			expectedError: "field databse not found",
+		},
+		{
+			name:          "Invalid env",
+			file:          validConfig,
+			env:           map[string]string{"LOGIN_MAX_ATTEMPTS": "many"},
+			expectedError: "lockout.max_attempts: invalid LOGIN_MAX_ATTEMPTS:strconv.Atoi: parsing \"many\": invalid syntax",
+		},
+		{
+			name:          "Invalid flag",
+			file:          validConfig,
+			args:          []string{"-timeouts.request", "soon"},
+			expectedError: "timeouts.request: invalid flag -timeouts.request:time: invalid duration \"soon\"",
+		},
+		{
+			name:          "Unknown flag",
+			file:          validConfig,
+			args:          []string{"-database.hots", "localhost"},
+			expectedError: "flag provided but not defined: -database.hots",
+		},
+		{
+			name: "Invalid settings",
+			file: `
+http:
+  port: "80800"
+database:
+  host: localhost
+  ssl_mode: always
+rate_limits:
+  store: redis
+`,
+			env: map[string]string{"BCRYPT_COST": "2", "CLEANUP_INTERVAL": "-1h", "TRACING_SAMPLE_RATIO": "2", "LOG_LEVEL": "verbose",
+				"LOGIN_LOCKOUT_BASE": "1h", "LOGIN_LOCKOUT_MAX": "30m", "HTTP_TRUSTED_PROXIES": "10.0.0.0/8,proxy",
+				"GRPC_CLIENT_CA_FILE": "ca.pem", "LOCAL_AUTH_REFRESH_TTL": "1000h"},
+			expectedError: "invalid config:\n" +
+				"  auth.local.refresh_ttl (LOCAL_AUTH_REFRESH_TTL) can not be longer than 720h0m0s\n" +
+				"  cleanup_interval (CLEANUP_INTERVAL) must be positive\n" +
+				"  database.name (DB_DATABASE) is required\n" +
+				"  database.ssl_mode (DB_SSL_MODE) must be empty or one of disable, require, verify-ca, verify-full\n" +
+				"  database.user (DB_USER) is required\n" +
+				"  grpc.tls_cert_file (GRPC_TLS_CERT_FILE) is required by grpc.client_ca_file (GRPC_CLIENT_CA_FILE)\n" +
+				"  http.port (API_SERVER_PORT) must be a port number, got \"80800\"\n" +
+				"  http.trusted_proxies (HTTP_TRUSTED_PROXIES) must be IP addresses or CIDRs, got \"proxy\"\n" +
+				"  lockout.max_duration (LOGIN_LOCKOUT_MAX) can not be shorter than lockout.base_duration (LOGIN_LOCKOUT_BASE)\n" +
+				"  logging.level (LOG_LEVEL) must be one of trace, debug, info, warn, error, fatal, panic\n" +
+				"  passwords.bcrypt_cost (BCRYPT_COST) must be between 4 and 31\n" +
+				"  rate_limits.store (RATE_LIMIT_STORE) must be postgres or memory\n" +
+				"  tracing.sample_ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1",
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			//Init dependencies
+			t.Setenv("CONFIG_PATH", "")
+			if tt.file != "" {
+				t.Setenv("CONFIG_PATH", writeFile(t, "config.yaml", tt.file))
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			_, _, err := Load(tt.args)
+			//Assert
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+}
+
+func TestLoad_defaultFile(t *testing.T) {
+	t.Setenv("CONFIG_PATH", "")
+	t.Setenv("HOST", "localhost")
+	t.Setenv("DB_USER", "postgres")
+	t.Setenv("DB_DATABASE", "food_delivery")
+	// the file shipped with the service only spells out the defaults
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(".."))
+	defer os.Chdir(wd)
+
+	cfg, _, err := Load(nil)
+	//Assert
+	assert.NoError(t, err)
+	expected := Default()
+	expected.Database.Host, expected.Database.User, expected.Database.Name = "localhost", "postgres", "food_delivery"
+	assert.Equal(t, expected.HTTP, cfg.HTTP)
+	assert.Equal(t, expected.Database, cfg.Database)
+	assert.Equal(t, expected.RateLimits, cfg.RateLimits)
+	assert.Equal(t, expected.CleanupInterval, cfg.CleanupInterval)
+}
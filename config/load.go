@@ -0,0 +1,180 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPath is read when neither the -config flag nor CONFIG_PATH name a file,
+// unlike a named file it may be missing
+const DefaultPath = "configs/config.yaml"
+
+// Load reads the config file, overrides it with the environment and then with the
+// flags in args and validates the result. args are the command line arguments
+// without the program name, the ones left after the flags are returned.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+	fields := fieldsOf(&cfg)
+
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	path := flags.String("config", "", "path to the YAML config file (CONFIG_PATH), "+DefaultPath+" by default")
+	// flags are applied last, after the file they may point to is read
+	var overrides []func() error
+	for _, f := range fields {
+		f := f
+		flags.Func(f.path, "overrides "+f.env, func(value string) error {
+			overrides = append(overrides, func() error {
+				return f.set(value, "flag -"+f.path)
+			})
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if err := readFile(&cfg, *path); err != nil {
+		return nil, nil, err
+	}
+	for _, f := range fields {
+		if err := f.setFromEnv(); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, override := range overrides {
+		if err := override(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &cfg, flags.Args(), nil
+}
+
+func readFile(cfg *Config, path string) error {
+	named := path != ""
+	if !named {
+		path = os.Getenv("CONFIG_PATH")
+		named = path != ""
+	}
+	if !named {
+		path = DefaultPath
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !named {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config file:%w", err)
+	}
+	// unknown keys are rejected, so that a misspelled setting does not go unnoticed
+	if err = yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("config file %s:%w", path, err)
+	}
+	return nil
+}
+
+// field is a setting which can be overridden, path is its key in the file like "database.host"
+type field struct {
+	path   string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fieldsOf lists the settings of cfg having an env tag, nested structs without one are walked
+func fieldsOf(cfg *Config) []field {
+	var fields []field
+	var walk func(prefix string, value reflect.Value)
+	walk = func(prefix string, value reflect.Value) {
+		for i := 0; i < value.NumField(); i++ {
+			structField := value.Type().Field(i)
+			path := prefix + structField.Tag.Get("yaml")
+			env, options := structField.Tag.Get("env"), ""
+			if i := strings.Index(env, ","); i >= 0 {
+				env, options = env[:i], env[i+1:]
+			}
+			if env == "" {
+				walk(path+".", value.Field(i))
+				continue
+			}
+			fields = append(fields, field{path: path, env: env, secret: options == "file", value: value.Field(i)})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+	return fields
+}
+
+// setFromEnv applies the variable of the field, an empty one is treated as unset.
+// Secrets are also read from the file named by the _FILE variable, like Docker secrets.
+func (f field) setFromEnv() error {
+	value, source := os.Getenv(f.env), f.env
+	if f.secret {
+		if file := os.Getenv(f.env + "_FILE"); file != "" {
+			if value != "" {
+				return fmt.Errorf("%s: only one of %s and %s_FILE can be set", f.path, f.env, f.env)
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("%s: %s_FILE:%w", f.path, f.env, err)
+			}
+			value, source = strings.TrimRight(string(data), "\r\n"), f.env+"_FILE"
+		}
+	}
+	if value == "" {
+		return nil
+	}
+	return f.set(value, source)
+}
+
+// set parses the text value, the value itself is not repeated in errors as it may be a secret
+func (f field) set(value string, source string) error {
+	var err error
+	if unmarshaler, ok := f.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		err = unmarshaler.UnmarshalText([]byte(value))
+	} else if f.value.Type() == durationType {
+		var duration time.Duration
+		if duration, err = time.ParseDuration(value); err == nil {
+			f.value.SetInt(int64(duration))
+		}
+	} else {
+		switch f.value.Kind() {
+		case reflect.String:
+			f.value.SetString(value)
+		case reflect.Int:
+			var number int
+			if number, err = strconv.Atoi(value); err == nil {
+				f.value.SetInt(int64(number))
+			}
+		case reflect.Float64:
+			var number float64
+			if number, err = strconv.ParseFloat(value, 64); err == nil {
+				f.value.SetFloat(number)
+			}
+		case reflect.Bool:
+			var flag bool
+			if flag, err = strconv.ParseBool(value); err == nil {
+				f.value.SetBool(flag)
+			}
+		default:
+			err = fmt.Errorf("unsupported type %s", f.value.Type())
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: invalid %s:%w", f.path, source, err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"strconv"
+)
+
+type listAuditEvents struct {
+	Data []model.AuditEvent `json:"data"`
+	Meta pageMeta           `json:"meta"`
+}
+
+// getAuditEvents godoc
+// @Summary getAuditEvents
+// @Security ApiKeyAuth
+// @Description get the audit log of administrative and security events, the newest first
+// @Tags Audit
+// @Produce  json
+// @Param actor_id query int false "ID of the user who made the change"
+// @Param target_id query int false "ID of the changed user"
+// @Param action query string false "Action, like staff.created"
+// @Param from query string false "RFC 3339 time, inclusive"
+// @Param to query string false "RFC 3339 time, exclusive"
+// @Param page query int false "Page"
+// @Param limit query int false "Limit"
+// @Success 200 {object} listAuditEvents
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /audit [get]
+func (h *Handler) getAuditEvents(ctx *gin.Context) {
+	necessaryRole := []string{"Superadmin"}
+	if err := h.service.CheckRole(necessaryRole, ctx.GetString("role")); err != nil {
+		h.log(ctx).Warnf("Handler getAuditEvents:not enough rights")
+		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "not enough rights"})
+		return
+	}
+	var filters model.AuditFilters
+	if err := ctx.ShouldBindQuery(&filters); err != nil {
+		h.log(ctx).Warnf("Handler getAuditEvents (bind query):%s", err)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid url query"})
+		return
+	}
+	var page, limit int
+	for param, value := range map[string]*int{"page": &page, "limit": &limit} {
+		if ctx.Query(param) == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(ctx.Query(param))
+		if err != nil || parsed < 0 {
+			h.log(ctx).Warnf("Handler getAuditEvents (reading %s):%s", param, err)
+			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid url query"})
+			return
+		}
+		*value = parsed
+	}
+	list, err := h.service.Audit.GetAuditEvents(ctx.Request.Context(), &filters, page, limit)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorInvalidTimeRange) {
+			h.log(ctx).Warnf("Handler getAuditEvents:%s", err)
+			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: pkg.InvalidTimeRange})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, listAuditEvents{
+		Data: list.Events,
+		Meta: pageMeta{Total: list.Total, Page: list.Page, Limit: list.Limit, Pages: list.Pages},
+	})
+}
+
+// exportAuditEvents godoc
+// @Summary exportAuditEvents
+// @Security ApiKeyAuth
+// @Description export a range of the audit chain signed with the Ed25519 audit key for external archiving.
+// @Description Each event hash covers the event and the hash of the previous one, the signature covers
+// @Description the range, the number of the events and the hashes they start after and end with.
+// @Tags Audit
+// @Produce  json
+// @Param from_id query int true "ID of the first event"
+// @Param to_id query int true "ID of the last event, at most 10000 events are exported at once"
+// @Success 200 {object} model.AuditExport
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /audit/export [get]
+func (h *Handler) exportAuditEvents(ctx *gin.Context) {
+	necessaryRole := []string{"Superadmin"}
+	if err := h.service.CheckRole(necessaryRole, ctx.GetString("role")); err != nil {
+		h.log(ctx).Warnf("Handler exportAuditEvents:not enough rights")
+		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "not enough rights"})
+		return
+	}
+	fromID, fromErr := strconv.ParseInt(ctx.Query("from_id"), 10, 64)
+	toID, toErr := strconv.ParseInt(ctx.Query("to_id"), 10, 64)
+	if fromErr != nil || toErr != nil {
+		h.log(ctx).Warnf("Handler exportAuditEvents (reading query):%v %v", fromErr, toErr)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid url query"})
+		return
+	}
+	export, err := h.service.Audit.ExportAuditEvents(ctx.Request.Context(), fromID, toID)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorInvalidAuditRange) {
+			h.log(ctx).Warnf("Handler exportAuditEvents:%s", err)
+			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: pkg.InvalidAuditRange})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, export)
+}
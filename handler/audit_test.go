@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/magiconair/properties/assert"
+	"net/http/httptest"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
+	mock_service "stlab.itechart-group.com/go/food_delivery/authentication_service/service/mocks"
+	"testing"
+	"time"
+)
+
+func TestHandler_getAuditEvents(t *testing.T) {
+	createdAt := time.Date(2022, 03, 11, 1, 0, 0, 0, time.UTC)
+	type mockBehavior func(s *mock_service.MockAudit)
+	testTable := []struct {
+		name                string
+		query               string
+		role                string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:  "OK",
+			query: "?actor_id=1&action=user.deleted&from=2022-03-01T00:00:00Z&page=2&limit=10",
+			role:  "Superadmin",
+			mockBehavior: func(s *mock_service.MockAudit) {
+				filters := &model.AuditFilters{ActorID: 1, Action: model.AuditUserDeleted, From: time.Date(2022, 03, 1, 0, 0, 0, 0, time.UTC)}
+				s.EXPECT().GetAuditEvents(gomock.Any(), filters, 2, 10).Return(&model.AuditPage{
+					Events: []model.AuditEvent{{
+						ID:        11,
+						CreatedAt: createdAt,
+						ActorID:   1,
+						ActorRole: "Superadmin",
+						Action:    model.AuditUserDeleted,
+						TargetID:  2,
+						Diff:      map[string]model.AuditChange{"deleted": {Old: false, New: true}},
+					}},
+					Total: 11, Page: 2, Limit: 10, Pages: 2,
+				}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedRequestBody: `{"data":[{"id":11,"created_at":"2022-03-11T01:00:00Z","actor_id":1,"actor_role":"Superadmin",` +
+				`"action":"user.deleted","target_id":2,"diff":{"deleted":{"old":false,"new":true}}}],` +
+				`"meta":{"total":11,"page":2,"limit":10,"pages":2}}`,
+		},
+		{
+			name:                "Not enough rights",
+			role:                "Courier manager",
+			mockBehavior:        func(s *mock_service.MockAudit) {},
+			expectedStatusCode:  401,
+			expectedRequestBody: `{"message":"not enough rights"}`,
+		},
+		{
+			name:                "Invalid query",
+			query:               "?from=yesterday",
+			role:                "Superadmin",
+			mockBehavior:        func(s *mock_service.MockAudit) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"Invalid url query"}`,
+		},
+		{
+			name:                "Invalid page",
+			query:               "?page=-1",
+			role:                "Superadmin",
+			mockBehavior:        func(s *mock_service.MockAudit) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"Invalid url query"}`,
+		},
+		{
+			name:  "Invalid time range",
+			query: "?from=2022-03-02T00:00:00Z&to=2022-03-01T00:00:00Z",
+			role:  "Superadmin",
+			mockBehavior: func(s *mock_service.MockAudit) {
+				s.EXPECT().GetAuditEvents(gomock.Any(), gomock.Any(), 0, 0).Return(nil, pkg.ErrorInvalidTimeRange)
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"invalid time range, from must be before to"}`,
+		},
+		{
+			name: "Service failure",
+			role: "Superadmin",
+			mockBehavior: func(s *mock_service.MockAudit) {
+				s.EXPECT().GetAuditEvents(gomock.Any(), gomock.Any(), 0, 0).Return(nil, errors.New("service failure"))
+			},
+			expectedStatusCode:  500,
+			expectedRequestBody: `{"message":"service failure"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			auth.EXPECT().ParseToken(gomock.Any(), "testToken").Return(&authProto.UserRole{UserId: 1, Role: testCase.role}, nil)
+			auth.EXPECT().CheckRole([]string{"Superadmin"}, testCase.role).DoAndReturn(func(neededRoles []string, role string) error {
+				if role != "Superadmin" {
+					return errors.New("not enough rights")
+				}
+				return nil
+			})
+			audit := mock_service.NewMockAudit(c)
+			testCase.mockBehavior(audit)
+			logger := logging.GetLogger()
+			services := newTestService(c, auth)
+			services.Audit = audit
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/audit"+testCase.query, nil)
+			req.Header.Set("Authorization", "Bearer testToken")
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_exportAuditEvents(t *testing.T) {
+	exportedAt := time.Date(2022, 03, 11, 1, 0, 0, 0, time.UTC)
+	type mockBehavior func(s *mock_service.MockAudit)
+	testTable := []struct {
+		name                string
+		query               string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:  "OK",
+			query: "?from_id=2&to_id=4",
+			mockBehavior: func(s *mock_service.MockAudit) {
+				s.EXPECT().ExportAuditEvents(gomock.Any(), int64(2), int64(4)).Return(&model.AuditExport{
+					FromID:     2,
+					ToID:       4,
+					Events:     []model.AuditEvent{},
+					ExportedAt: exportedAt,
+					Signature:  "signature",
+				}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedRequestBody: `{"from_id":2,"to_id":4,"prev_hash":"","hash":"","events":[],` +
+				`"exported_at":"2022-03-11T01:00:00Z","signature":"signature"}`,
+		},
+		{
+			name:                "Missing range",
+			query:               "?from_id=2",
+			mockBehavior:        func(s *mock_service.MockAudit) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"Invalid url query"}`,
+		},
+		{
+			name:  "Invalid range",
+			query: "?from_id=4&to_id=2",
+			mockBehavior: func(s *mock_service.MockAudit) {
+				s.EXPECT().ExportAuditEvents(gomock.Any(), int64(4), int64(2)).Return(nil, pkg.ErrorInvalidAuditRange)
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"invalid audit range"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			auth.EXPECT().ParseToken(gomock.Any(), "testToken").Return(&authProto.UserRole{UserId: 1, Role: "Superadmin"}, nil)
+			auth.EXPECT().CheckRole([]string{"Superadmin"}, "Superadmin").Return(nil)
+			audit := mock_service.NewMockAudit(c)
+			testCase.mockBehavior(audit)
+			services := newTestService(c, auth)
+			services.Audit = audit
+			handler := NewHandler(logging.GetLogger(), services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/audit/export"+testCase.query, nil)
+			req.Header.Set("Authorization", "Bearer testToken")
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_auditActor(t *testing.T) {
+	//Init dependencies
+	c := gomock.NewController(t)
+	defer c.Finish()
+	auth := mock_service.NewMockAppUser(c)
+	auth.EXPECT().ParseToken(gomock.Any(), "testToken").Return(&authProto.UserRole{UserId: 1, Role: "Superadmin"}, nil)
+	auth.EXPECT().CheckRole(gomock.Any(), "Superadmin").Return(nil)
+	var actor model.AuditActor
+	auth.EXPECT().UnlockUser(gomock.Any(), 2).DoAndReturn(func(ctx context.Context, id int) (int, error) {
+		actor = service.AuditActorFrom(ctx)
+		return id, nil
+	})
+	handler := NewHandler(logging.GetLogger(), newTestService(c, auth))
+
+	//Init server
+	r := handler.InitRoutes()
+
+	//Test request
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/users/2/unlock", nil)
+	req.Header.Set("Authorization", "Bearer testToken")
+	req.Header.Set("User-Agent", "test agent")
+	req.Header.Set(requestIDHeader, "test-request-id")
+
+	//Execute the request
+	r.ServeHTTP(w, req)
+
+	//Assert
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, model.AuditActor{
+		ID:        1,
+		Role:      "Superadmin",
+		IP:        "192.0.2.1",
+		UserAgent: "test agent",
+		RequestID: "test-request-id",
+	}, actor)
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+)
+
+// live godoc
+// @Summary live
+// @Description liveness probe, the service is running
+// @Tags Health
+// @Produce  json
+// @Success 200 {object} model.Health
+// @Router /healthz [get]
+func (h *Handler) live(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, model.Health{Status: model.HealthOK})
+}
+
+// ready godoc
+// @Summary ready
+// @Description readiness probe, the database and the auth service are reachable and the service is not shutting down
+// @Tags Health
+// @Produce  json
+// @Success 200 {object} model.Health
+// @Failure 503 {object} model.Health
+// @Router /readyz [get]
+func (h *Handler) ready(ctx *gin.Context) {
+	health := h.service.Health.Ready(ctx.Request.Context())
+	if health.Status != model.HealthOK {
+		ctx.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+	ctx.JSON(http.StatusOK, health)
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/magiconair/properties/assert"
+	"net/http/httptest"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
+	mock_service "stlab.itechart-group.com/go/food_delivery/authentication_service/service/mocks"
+	"testing"
+)
+
+func TestHandler_live(t *testing.T) {
+	//Init dependencies
+	handler := NewHandler(logging.GetLogger(), &service.Service{})
+
+	//Init server
+	r := handler.InitRoutes()
+
+	//Test request
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/healthz", nil)
+
+	//Execute the request
+	r.ServeHTTP(w, req)
+
+	//Assert
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestHandler_ready(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockHealth)
+	testTable := []struct {
+		name                string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(s *mock_service.MockHealth) {
+				s.EXPECT().Ready(gomock.Any()).Return(&model.Health{
+					Status: model.HealthOK,
+					Checks: map[string]model.DependencyHealth{
+						"auth":     {Status: model.HealthOK, LatencyMs: 0.5},
+						"postgres": {Status: model.HealthOK, LatencyMs: 1.25},
+					},
+				})
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"status":"ok","checks":{"auth":{"status":"ok","latency_ms":0.5},"postgres":{"status":"ok","latency_ms":1.25}}}`,
+		},
+		{
+			name: "Dependency unavailable",
+			mockBehavior: func(s *mock_service.MockHealth) {
+				s.EXPECT().Ready(gomock.Any()).Return(&model.Health{
+					Status: model.HealthUnavailable,
+					Checks: map[string]model.DependencyHealth{
+						"postgres": {Status: model.HealthUnavailable, LatencyMs: 2, Error: "connection refused"},
+					},
+				})
+			},
+			expectedStatusCode:  503,
+			expectedRequestBody: `{"status":"unavailable","checks":{"postgres":{"status":"unavailable","latency_ms":2,"error":"connection refused"}}}`,
+		},
+		{
+			name: "Shutting down",
+			mockBehavior: func(s *mock_service.MockHealth) {
+				s.EXPECT().Ready(gomock.Any()).Return(&model.Health{Status: model.HealthShuttingDown})
+			},
+			expectedStatusCode:  503,
+			expectedRequestBody: `{"status":"shutting_down"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			health := mock_service.NewMockHealth(c)
+			testCase.mockBehavior(health)
+			handler := NewHandler(logging.GetLogger(), &service.Service{Health: health})
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/readyz", nil)
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
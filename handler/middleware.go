@@ -1,16 +1,37 @@
 package handler
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"io/ioutil"
+	"math"
 	"net/http"
+	"regexp"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/metrics"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/tracing"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func (h *Handler) CorsMiddleware(c *gin.Context) {
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("Access-Control-Allow-Methods", "*")
 	c.Header("Access-Control-Allow-Headers", "*")
+	c.Header("Access-Control-Expose-Headers", "Link, pages, X-Request-ID")
 	c.Header("Content-Type", "application/json")
 
 	if c.Request.Method != "OPTIONS" {
@@ -20,31 +41,226 @@ func (h *Handler) CorsMiddleware(c *gin.Context) {
 	}
 }
 
+// instrument counts the requests and measures them by route, the ones matching no
+// route are put together and so are the methods outside the standard set
+func (h *Handler) instrument(ctx *gin.Context) {
+	start := time.Now()
+	ctx.Next()
+	route := ctx.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	method := ctx.Request.Method
+	if !standardMethods[method] {
+		method = "other"
+	}
+	status := strconv.Itoa(ctx.Writer.Status())
+	metrics.HTTPRequests.WithLabelValues(method, route, status).Inc()
+	metrics.HTTPRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+}
+
+// standardMethods keep the method label bounded whatever the clients send
+var standardMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true,
+	http.MethodDelete: true, http.MethodConnect: true, http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// requestIDHeader carries the id of the request, the one sent by a proxy is kept
+const requestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,64}$`)
+
+// requestID tags the log lines of the request with its id and returns the id to the client
+func (h *Handler) requestID(ctx *gin.Context) {
+	id := ctx.GetHeader(requestIDHeader)
+	if !requestIDPattern.MatchString(id) {
+		id = logging.NewRequestID()
+	}
+	ctx.Header(requestIDHeader, id)
+	requestCtx := logging.ContextWithField(ctx.Request.Context(), "request_id", id)
+	requestCtx = service.ContextWithAuditActor(requestCtx, model.AuditActor{
+		IP:        ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		RequestID: id,
+	})
+	ctx.Request = ctx.Request.WithContext(requestCtx)
+	ctx.Next()
+}
+
+// logRequest logs the outcome of the request, the query is left out as it may carry a token
+func (h *Handler) logRequest(ctx *gin.Context) {
+	start := time.Now()
+	ctx.Next()
+	h.log(ctx).WithFields(map[string]interface{}{
+		"method":     ctx.Request.Method,
+		"path":       ctx.Request.URL.Path,
+		"status":     ctx.Writer.Status(),
+		"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+		"client_ip":  ctx.ClientIP(),
+	}).Info("request")
+}
+
+// trace records a span for the request, continuing the trace of the client when
+// it sends a traceparent header. Server errors mark the span as failed.
+func (h *Handler) trace(ctx *gin.Context) {
+	route := ctx.FullPath()
+	name := ctx.Request.Method + " " + route
+	if route == "" {
+		name = ctx.Request.Method
+	}
+	requestCtx := otel.GetTextMapPropagator().Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+	requestCtx, span := tracing.Start(requestCtx, name, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(ctx.Request.Method), semconv.HTTPRoute(route), semconv.URLPath(ctx.Request.URL.Path)))
+	defer span.End()
+	ctx.Request = ctx.Request.WithContext(requestCtx)
+	ctx.Next()
+	status := ctx.Writer.Status()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
+
+// timeout sets the deadline of the request, the work of the services is cancelled
+// once it is over or the client goes away
+func (h *Handler) timeout(ctx *gin.Context) {
+	timeout := h.service.Timeouts.Timeout(ctx.Request.Method + " " + ctx.FullPath())
+	if timeout <= 0 {
+		return
+	}
+	requestCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+	defer cancel()
+	ctx.Request = ctx.Request.WithContext(requestCtx)
+	ctx.Next()
+}
+
 func (h *Handler) userIdentity(ctx *gin.Context) {
 	header := ctx.GetHeader("Authorization")
 	if header == "" {
-		h.logger.Errorf("userIdentity:empty auth header")
+		h.log(ctx).Errorf("userIdentity:empty auth header")
 		ctx.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{Message: "empty auth header"})
 		return
 	}
 	headerParts := strings.Split(header, " ")
 	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-		h.logger.Errorf("userIdentity:invalid auth header")
+		h.log(ctx).Errorf("userIdentity:invalid auth header")
 		ctx.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{Message: "invalid auth header"})
 		return
 	}
 	if len(headerParts[1]) == 0 {
-		h.logger.Errorf("userIdentity:token is empty")
+		h.log(ctx).Errorf("userIdentity:token is empty")
 		ctx.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{Message: "token is empty"})
 		return
 	}
-	userPerms, err := h.service.AppUser.ParseToken(headerParts[1])
+	userPerms, err := h.service.AppUser.ParseToken(ctx.Request.Context(), headerParts[1])
 	if err != nil {
-		h.logger.Errorf("userIdentity:%s", err)
+		h.log(ctx).Errorf("userIdentity:%s", err)
 		ctx.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{Message: err.Error()})
 		return
 	}
+	err = h.service.TokenRevocation.CheckTokenRevoked(ctx.Request.Context(), int(userPerms.UserId), headerParts[1])
+	if err != nil {
+		h.log(ctx).Errorf("userIdentity:%s", err)
+		if errors.Is(err, pkg.ErrorTokenRevoked) || errors.Is(err, pkg.ErrorInvalidToken) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, model.ErrorResponse{Message: err.Error()})
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
 	ctx.Set("perms", userPerms.Permissions)
 	ctx.Set("role", userPerms.Role)
 	ctx.Set("userId", userPerms.UserId)
+	ctx.Set("token", headerParts[1])
+	requestCtx := logging.ContextWithField(ctx.Request.Context(), "user_id", userPerms.UserId)
+	actor := service.AuditActorFrom(requestCtx)
+	actor.ID, actor.Role = int(userPerms.UserId), userPerms.Role
+	ctx.Request = ctx.Request.WithContext(service.ContextWithAuditActor(requestCtx, actor))
+}
+
+// getUserId returns the id of the user set by userIdentity
+func getUserId(ctx *gin.Context) int {
+	value, _ := ctx.Get("userId")
+	userId, _ := value.(int32)
+	return int(userId)
+}
+
+// rateLimit throttles the route according to its configured rule, requests
+// are let through if the rate limit store is unavailable
+func (h *Handler) rateLimit(route string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		rule, ok := h.service.RateLimiter.Rule(route)
+		if !ok {
+			return
+		}
+		keys, err := rateLimitKeys(ctx, rule.KeyBy)
+		if err != nil {
+			h.log(ctx).Warnf("rateLimit:%s", err)
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, model.ErrorResponse{Message: "Request body is too large"})
+			return
+		}
+		result, err := h.service.RateLimiter.Allow(ctx.Request.Context(), route, keys)
+		if err != nil {
+			h.log(ctx).Errorf("rateLimit:%s", err)
+			return
+		}
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		ctx.Header("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(time.Until(result.ResetAt).Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, model.ErrorResponse{Message: "Too many requests"})
+		}
+	}
+}
+
+// rateLimitKeys returns the client IP and/or the email from the JSON body,
+// falling back to the IP when there is no email to count by
+func rateLimitKeys(ctx *gin.Context, keyBy string) ([]string, error) {
+	ip := "ip:" + ctx.ClientIP()
+	if keyBy == service.RateLimitByIP {
+		return []string{ip}, nil
+	}
+	email, err := requestEmail(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if email == "" {
+		return []string{ip}, nil
+	}
+	if keyBy == service.RateLimitByEmail {
+		return []string{service.RateLimitEmailKey(email)}, nil
+	}
+	return []string{ip, service.RateLimitEmailKey(email)}, nil
+}
+
+// maxPeekedBody is the largest body requestEmail reads, the throttled requests are small
+const maxPeekedBody = 64 << 10
+
+// requestEmail peeks the email field of the JSON body leaving the body readable
+// for the handler, a body over maxPeekedBody is an error
+func requestEmail(ctx *gin.Context) (string, error) {
+	if ctx.Request.Body == nil {
+		return "", nil
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxPeekedBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return "", fmt.Errorf("requestEmail: body is over %d bytes", tooLarge.Limit)
+	}
+	ctx.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	if err != nil {
+		return "", nil
+	}
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err = json.Unmarshal(body, &input); err != nil {
+		return "", nil
+	}
+	return strings.ToLower(strings.TrimSpace(input.Email)), nil
 }
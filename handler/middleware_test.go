@@ -0,0 +1,456 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/metrics"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/tracing"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
+	mock_service "stlab.itechart-group.com/go/food_delivery/authentication_service/service/mocks"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_rateLimit(t *testing.T) {
+	resetAt := time.Now().Add(time.Minute)
+	type mockBehavior func(s *mock_service.MockRateLimiter)
+	testTable := []struct {
+		name                string
+		inputBody           string
+		trustedProxies      []string
+		forwardedFor        string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+		expectedHeaders     map[string]string
+	}{
+		{
+			name:      "No rule",
+			inputBody: `{"email":"test@yandex.ru"}`,
+			mockBehavior: func(s *mock_service.MockRateLimiter) {
+				s.EXPECT().Rule("login").Return(service.RateLimitRule{}, false)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"email":"test@yandex.ru"}`,
+		},
+		{
+			name:      "Allowed by ip and email",
+			inputBody: `{"email":" Test@Yandex.ru"}`,
+			mockBehavior: func(s *mock_service.MockRateLimiter) {
+				s.EXPECT().Rule("login").Return(service.RateLimitRule{Requests: 10, Window: time.Minute, KeyBy: service.RateLimitByIPEmail}, true)
+				s.EXPECT().Allow(gomock.Any(), "login", []string{"ip:192.0.2.1", service.RateLimitEmailKey("test@yandex.ru")}).Return(&model.RateLimitResult{
+					Allowed:   true,
+					Limit:     10,
+					Remaining: 9,
+					ResetAt:   resetAt,
+				}, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"email":" Test@Yandex.ru"}`,
+			expectedHeaders: map[string]string{
+				"X-RateLimit-Limit":     "10",
+				"X-RateLimit-Remaining": "9",
+			},
+		},
+		{
+			name:      "Email key falls back to ip",
+			inputBody: `{}`,
+			mockBehavior: func(s *mock_service.MockRateLimiter) {
+				s.EXPECT().Rule("login").Return(service.RateLimitRule{Requests: 10, Window: time.Minute, KeyBy: service.RateLimitByEmail}, true)
+				s.EXPECT().Allow(gomock.Any(), "login", []string{"ip:192.0.2.1"}).Return(&model.RateLimitResult{
+					Allowed:   true,
+					Limit:     10,
+					Remaining: 9,
+					ResetAt:   resetAt,
+				}, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{}`,
+		},
+		{
+			name:      "Too many requests",
+			inputBody: `{"email":"test@yandex.ru"}`,
+			mockBehavior: func(s *mock_service.MockRateLimiter) {
+				s.EXPECT().Rule("login").Return(service.RateLimitRule{Requests: 10, Window: time.Minute, KeyBy: service.RateLimitByIP}, true)
+				s.EXPECT().Allow(gomock.Any(), "login", []string{"ip:192.0.2.1"}).Return(&model.RateLimitResult{
+					Allowed:   false,
+					Limit:     10,
+					Remaining: 0,
+					ResetAt:   resetAt,
+				}, nil)
+			},
+			expectedStatusCode:  429,
+			expectedRequestBody: `{"message":"Too many requests"}`,
+			expectedHeaders: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"Retry-After":           "60",
+			},
+		},
+		{
+			name:         "Forwarded for without a trusted proxy",
+			inputBody:    `{}`,
+			forwardedFor: "203.0.113.9",
+			mockBehavior: func(s *mock_service.MockRateLimiter) {
+				s.EXPECT().Rule("login").Return(service.RateLimitRule{Requests: 10, Window: time.Minute, KeyBy: service.RateLimitByIP}, true)
+				s.EXPECT().Allow(gomock.Any(), "login", []string{"ip:192.0.2.1"}).Return(&model.RateLimitResult{
+					Allowed: true, Limit: 10, Remaining: 9, ResetAt: resetAt,
+				}, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{}`,
+		},
+		{
+			name:           "Forwarded for by a trusted proxy",
+			inputBody:      `{}`,
+			trustedProxies: []string{"192.0.2.0/24"},
+			forwardedFor:   "203.0.113.9",
+			mockBehavior: func(s *mock_service.MockRateLimiter) {
+				s.EXPECT().Rule("login").Return(service.RateLimitRule{Requests: 10, Window: time.Minute, KeyBy: service.RateLimitByIP}, true)
+				s.EXPECT().Allow(gomock.Any(), "login", []string{"ip:203.0.113.9"}).Return(&model.RateLimitResult{
+					Allowed: true, Limit: 10, Remaining: 9, ResetAt: resetAt,
+				}, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{}`,
+		},
+		{
+			name:      "Body too large",
+			inputBody: `{"email":"` + strings.Repeat("a", maxPeekedBody) + `"}`,
+			mockBehavior: func(s *mock_service.MockRateLimiter) {
+				s.EXPECT().Rule("login").Return(service.RateLimitRule{Requests: 10, Window: time.Minute, KeyBy: service.RateLimitByIPEmail}, true)
+			},
+			expectedStatusCode:  413,
+			expectedRequestBody: `{"message":"Request body is too large"}`,
+		},
+		{
+			name:      "Store failure lets the request through",
+			inputBody: `{"email":"test@yandex.ru"}`,
+			mockBehavior: func(s *mock_service.MockRateLimiter) {
+				s.EXPECT().Rule("login").Return(service.RateLimitRule{Requests: 10, Window: time.Minute, KeyBy: service.RateLimitByIP}, true)
+				s.EXPECT().Allow(gomock.Any(), "login", []string{"ip:192.0.2.1"}).Return(nil, errors.New("store failure"))
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"email":"test@yandex.ru"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			rateLimiter := mock_service.NewMockRateLimiter(c)
+			testCase.mockBehavior(rateLimiter)
+			logger := logging.GetLogger()
+			services := &service.Service{RateLimiter: rateLimiter}
+			handler := NewHandler(logger, services)
+			handler.TrustProxies(testCase.trustedProxies)
+
+			//Init server
+			r := handler.newEngine()
+			r.POST("/login", handler.rateLimit("login"), func(ctx *gin.Context) {
+				body, _ := ioutil.ReadAll(ctx.Request.Body)
+				ctx.String(http.StatusOK, string(body))
+			})
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(testCase.inputBody))
+			if testCase.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", testCase.forwardedFor)
+			}
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+			for key, value := range testCase.expectedHeaders {
+				assert.Equal(t, value, w.Header().Get(key))
+			}
+		})
+	}
+}
+
+// varcharStore fails like the rate_limits table of Postgres on a key over
+// varchar(255) or with a NUL byte
+type varcharStore struct {
+	*repository.RateLimitMemory
+}
+
+func (v varcharStore) HitRateLimit(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	if len(key) > 255 || strings.ContainsRune(key, 0) {
+		return 0, time.Time{}, errors.New("value too long for type character varying(255)")
+	}
+	return v.RateLimitMemory.HitRateLimit(ctx, key, window)
+}
+
+func TestHandler_rateLimit_oversizedEmail(t *testing.T) {
+	//Init dependencies
+	rules := map[string]service.RateLimitRule{"login": {Requests: 2, Window: time.Minute, KeyBy: service.RateLimitByIPEmail}}
+	store := varcharStore{RateLimitMemory: repository.NewRateLimitMemory()}
+	services := &service.Service{RateLimiter: service.NewRateLimitService(repository.Repository{RateLimit: store}, logging.GetLogger(), rules)}
+	handler := NewHandler(logging.GetLogger(), services)
+
+	//Init server
+	r := handler.newEngine()
+	r.POST("/login", handler.rateLimit("login"), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	inputBody := `{"email":"` + strings.Repeat("a", 300) + `\u0000@yandex.ru"}`
+	var codes []int
+	for i := 0; i < 3; i++ {
+		//Test request
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/login", bytes.NewBufferString(inputBody))
+
+		//Execute the request
+		r.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+
+	//Assert
+	assert.Equal(t, []int{200, 200, 429}, codes)
+}
+
+func TestHandler_timeout(t *testing.T) {
+	testTable := []struct {
+		name             string
+		timeouts         service.TimeoutPolicy
+		path             string
+		expectedDeadline time.Duration
+	}{
+		{
+			name:             "Request timeout",
+			timeouts:         service.TimeoutPolicy{Request: time.Minute},
+			path:             "/users/1",
+			expectedDeadline: time.Minute,
+		},
+		{
+			name:             "Operation timeout",
+			timeouts:         service.TimeoutPolicy{Request: time.Minute, Operations: map[string]time.Duration{"GET /users/:id": time.Hour}},
+			path:             "/users/1",
+			expectedDeadline: time.Hour,
+		},
+		{
+			name:     "No timeout",
+			timeouts: service.TimeoutPolicy{},
+			path:     "/users/1",
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			logger := logging.GetLogger()
+			handler := NewHandler(logger, &service.Service{Timeouts: testCase.timeouts})
+
+			//Init server
+			var deadline time.Time
+			var hasDeadline bool
+			r := gin.New()
+			r.Use(handler.timeout)
+			r.GET("/users/:id", func(ctx *gin.Context) {
+				deadline, hasDeadline = ctx.Request.Context().Deadline()
+				ctx.Status(http.StatusOK)
+			})
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", testCase.path, nil)
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, testCase.expectedDeadline != 0, hasDeadline)
+			if hasDeadline {
+				assert.WithinDuration(t, time.Now().Add(testCase.expectedDeadline), deadline, time.Second)
+			}
+		})
+	}
+}
+
+func TestHandler_instrument(t *testing.T) {
+	//Init dependencies
+	handler := NewHandler(logging.GetLogger(), &service.Service{})
+	requests := func(method string, route string, status string) float64 {
+		return testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(method, route, status))
+	}
+	healthz, unmatched, other := requests("GET", "/healthz", "200"), requests("GET", "unmatched", "404"), requests("other", "unmatched", "404")
+
+	//Init server
+	r := handler.InitRoutes()
+
+	//Execute the request
+	for _, path := range []string{"/healthz", "/healthz", "/missing/1"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PROPFIND", "/missing/1", nil))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	//Assert
+	assert.Equal(t, healthz+2, requests("GET", "/healthz", "200"))
+	assert.Equal(t, unmatched+1, requests("GET", "unmatched", "404"))
+	assert.Equal(t, other+1, requests("other", "unmatched", "404"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `http_request_duration_seconds_count{method="GET",route="/healthz",status="200"}`)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+}
+
+func TestHandler_trace(t *testing.T) {
+	//Init dependencies
+	provider, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterMemory, SampleRatio: 1})
+	assert.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+	handler := NewHandler(logging.GetLogger(), &service.Service{})
+
+	//Init server
+	r := handler.InitRoutes()
+
+	//Test request
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	//Execute the request
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing/1", nil))
+
+	//Assert
+	spans := provider.Spans()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "GET /healthz", spans[0].Name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+		assert.Contains(t, spans[0].Attributes, semconv.HTTPResponseStatusCode(http.StatusOK))
+		// the path of a request matching no route is left out of the name
+		assert.Equal(t, "GET", spans[1].Name)
+		assert.False(t, spans[1].Parent.IsValid())
+	}
+}
+
+func TestHandler_requestID(t *testing.T) {
+	testTable := []struct {
+		name       string
+		requestID  string
+		expectedID func(t *testing.T, id string)
+	}{
+		{
+			name:      "Sent by the client",
+			requestID: "9f1c-42",
+			expectedID: func(t *testing.T, id string) {
+				assert.Equal(t, "9f1c-42", id)
+			},
+		},
+		{
+			name: "Missing",
+			expectedID: func(t *testing.T, id string) {
+				assert.Regexp(t, "^[0-9a-f]{32}$", id)
+			},
+		},
+		{
+			name:      "Invalid",
+			requestID: "<script>",
+			expectedID: func(t *testing.T, id string) {
+				assert.Regexp(t, "^[0-9a-f]{32}$", id)
+			},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			logger := logging.GetLogger()
+			hooks := make(logrus.LevelHooks)
+			for level, levelHooks := range logger.Logger.Hooks {
+				hooks[level] = levelHooks
+			}
+			defer logger.Logger.ReplaceHooks(hooks)
+			hook := test.NewLocal(logger.Logger)
+			handler := NewHandler(logger, &service.Service{})
+
+			//Init server
+			r := gin.New()
+			r.GET("/", handler.requestID, func(ctx *gin.Context) {
+				handler.log(ctx).Info("handled")
+			})
+
+			//Test request
+			req := httptest.NewRequest("GET", "/", nil)
+			if testCase.requestID != "" {
+				req.Header.Set("X-Request-ID", testCase.requestID)
+			}
+
+			//Execute the request
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			//Assert
+			id := w.Header().Get("X-Request-ID")
+			testCase.expectedID(t, id)
+			assert.Equal(t, id, hook.LastEntry().Data["request_id"])
+		})
+	}
+}
+
+func TestHandler_requestID_auditIP(t *testing.T) {
+	testTable := []struct {
+		name           string
+		trustedProxies []string
+		expectedIP     string
+	}{
+		{
+			name:       "Forwarded by an untrusted client",
+			expectedIP: "192.0.2.1",
+		},
+		{
+			name:           "Forwarded by a trusted proxy",
+			trustedProxies: []string{"192.0.2.1"},
+			expectedIP:     "203.0.113.9",
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			handler := NewHandler(logging.GetLogger(), &service.Service{})
+			handler.TrustProxies(testCase.trustedProxies)
+
+			//Init server
+			var actor model.AuditActor
+			r := handler.newEngine()
+			r.GET("/", handler.requestID, func(ctx *gin.Context) {
+				actor = service.AuditActorFrom(ctx.Request.Context())
+			})
+
+			//Test request
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-Forwarded-For", "203.0.113.9")
+
+			//Execute the request
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedIP, actor.IP)
+		})
+	}
+}
@@ -6,31 +6,67 @@ import (
 	"github.com/swaggo/gin-swagger"
 	_ "stlab.itechart-group.com/go/food_delivery/authentication_service/docs"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/metrics"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
 )
 
 type Handler struct {
-	logger  logging.Logger
-	service *service.Service
+	logger         logging.Logger
+	service        *service.Service
+	trustedProxies []string
 }
 
 func NewHandler(logger logging.Logger, service *service.Service) *Handler {
 	return &Handler{logger: logger, service: service}
 }
 
+// TrustProxies sets the proxies whose X-Forwarded-For and X-Real-IP headers tell
+// the client IP, without any the address of the connection is the client one
+func (h *Handler) TrustProxies(proxies []string) {
+	h.trustedProxies = proxies
+}
+
+// newEngine returns an engine trusting only the configured proxies
+func (h *Handler) newEngine() *gin.Engine {
+	// requests are logged by logRequest, gin's own lines would bypass the redaction
+	router := gin.New()
+	// gin trusts every proxy by default, a forged X-Forwarded-For would then
+	// get around the IP rate limits. A wrong list leaves no proxy trusted.
+	if err := router.SetTrustedProxies(h.trustedProxies); err != nil {
+		h.logger.Errorf("newEngine: invalid trusted proxies:%s", err)
+	}
+	return router
+}
+
 func (h *Handler) InitRoutes() *gin.Engine {
-	router := gin.Default()
+	router := h.newEngine()
+	router.Use(gin.Recovery())
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	router.Use(
+		h.requestID,
+		h.logRequest,
+		h.trace,
+		h.instrument,
 		h.CorsMiddleware,
+		h.timeout,
 	)
 
+	router.GET("/healthz", h.live)
+	router.GET("/readyz", h.ready)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	userNoAuth := router.Group("/users")
 	{
-		userNoAuth.POST("/login", h.authUser)
-		userNoAuth.POST("/customer", h.createCustomer)
-		userNoAuth.POST("/restorePassword", h.restorePassword)
+		userNoAuth.POST("/login", h.rateLimit("login"), h.authUser)
+		userNoAuth.POST("/login/2fa", h.rateLimit("login2fa"), h.authUserTwoFactor)
+		userNoAuth.POST("/login/2fa/enroll", h.rateLimit("login2fa"), h.startTwoFactorEnrollment)
+		userNoAuth.POST("/refresh", h.refreshTokens)
+		userNoAuth.POST("/customer", h.rateLimit("customer"), h.createCustomer)
+		userNoAuth.POST("/restorePassword", h.rateLimit("restorePassword"), h.restorePassword)
+		userNoAuth.POST("/resetPassword", h.rateLimit("resetPassword"), h.resetPassword)
+		userNoAuth.GET("/verify", h.verifyEmail)
+		userNoAuth.POST("/verify/resend", h.rateLimit("verify"), h.resendVerification)
 	}
 
 	userAuth := router.Group("/users")
@@ -41,6 +77,27 @@ func (h *Handler) InitRoutes() *gin.Engine {
 		userAuth.POST("/staff", h.createStaff)
 		userAuth.PUT("/", h.updateUser)
 		userAuth.DELETE("/:id", h.deleteUserByID)
+		userAuth.POST("/:id/unlock", h.unlockUser)
+		userAuth.POST("/:id/restore", h.restoreUser)
+		userAuth.POST("/logout", h.logout)
+		userAuth.POST("/logout-all", h.logoutAll)
+		userAuth.POST("/2fa/enroll", h.enrollTOTP)
+		userAuth.POST("/2fa/confirm", h.confirmTOTP)
+		userAuth.POST("/2fa/disable", h.disableTOTP)
+		userAuth.GET("/2fa/roles", h.getTwoFactorRoles)
+		userAuth.PUT("/2fa/roles", h.setTwoFactorRoles)
+	}
+
+	audit := router.Group("/audit")
+	audit.Use(h.userIdentity)
+	{
+		audit.GET("", h.getAuditEvents)
+		audit.GET("/export", h.exportAuditEvents)
 	}
 	return router
 }
+
+// log returns the logger of the request, its lines carry the ids of the request and the user
+func (h *Handler) log(ctx *gin.Context) logging.Logger {
+	return h.logger.WithContext(ctx.Request.Context())
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+)
+
+// enrollTOTP godoc
+// @Summary enrollTOTP
+// @Security ApiKeyAuth
+// @Description generate a TOTP secret for the current user, it is enabled by confirmTOTP
+// @Tags TwoFactor
+// @Produce  json
+// @Success 200 {object} model.TOTPEnrollment
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/2fa/enroll [post]
+func (h *Handler) enrollTOTP(ctx *gin.Context) {
+	enrollment, err := h.service.TwoFactor.EnrollTOTP(ctx.Request.Context(), getUserId(ctx))
+	if err != nil {
+		if errors.Is(err, pkg.ErrorTwoFactorEnabled) {
+			ctx.JSON(http.StatusConflict, model.ErrorResponse{Message: err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+// confirmTOTP godoc
+// @Summary confirmTOTP
+// @Security ApiKeyAuth
+// @Description enable two-factor authentication with the first code from the authenticator app
+// @Tags TwoFactor
+// @Accept  json
+// @Produce  json
+// @Param input body model.TwoFactorCode true "TOTP code"
+// @Success 200 {object} model.RecoveryCodes
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/2fa/confirm [post]
+func (h *Handler) confirmTOTP(ctx *gin.Context) {
+	var input model.TwoFactorCode
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		h.log(ctx).Warnf("Handler confirmTOTP (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	codes, err := h.service.TwoFactor.ConfirmTOTP(ctx.Request.Context(), getUserId(ctx), input.Code)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorInvalidTwoFactor) || errors.Is(err, pkg.ErrorTwoFactorNotEnabled) {
+			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: err.Error()})
+			return
+		} else if errors.Is(err, pkg.ErrorTwoFactorEnabled) {
+			ctx.JSON(http.StatusConflict, model.ErrorResponse{Message: err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.RecoveryCodes{RecoveryCodes: codes})
+}
+
+// disableTOTP godoc
+// @Summary disableTOTP
+// @Security ApiKeyAuth
+// @Description disable two-factor authentication with a TOTP or recovery code
+// @Tags TwoFactor
+// @Accept  json
+// @Produce  json
+// @Param input body model.TwoFactorCode true "TOTP or recovery code"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/2fa/disable [post]
+func (h *Handler) disableTOTP(ctx *gin.Context) {
+	var input model.TwoFactorCode
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		h.log(ctx).Warnf("Handler disableTOTP (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	err := h.service.TwoFactor.DisableTOTP(ctx.Request.Context(), getUserId(ctx), input.Code)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorInvalidTwoFactor) || errors.Is(err, pkg.ErrorTwoFactorNotEnabled) {
+			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: err.Error()})
+			return
+		} else if errors.Is(err, pkg.ErrorTwoFactorMandatory) {
+			ctx.JSON(http.StatusForbidden, model.ErrorResponse{Message: err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// getTwoFactorRoles godoc
+// @Summary getTwoFactorRoles
+// @Security ApiKeyAuth
+// @Description get roles which require two-factor authentication
+// @Tags TwoFactor
+// @Produce  json
+// @Success 200 {object} model.TwoFactorRoles
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/2fa/roles [get]
+func (h *Handler) getTwoFactorRoles(ctx *gin.Context) {
+	necessaryRole := []string{"Superadmin"}
+	if err := h.service.CheckRole(necessaryRole, ctx.GetString("role")); err != nil {
+		h.log(ctx).Warnf("Handler getTwoFactorRoles:not enough rights")
+		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "not enough rights"})
+		return
+	}
+	roles, err := h.service.TwoFactor.GetTwoFactorRoles(ctx.Request.Context())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, model.TwoFactorRoles{Roles: roles})
+}
+
+// setTwoFactorRoles godoc
+// @Summary setTwoFactorRoles
+// @Security ApiKeyAuth
+// @Description replace roles which require two-factor authentication
+// @Tags TwoFactor
+// @Accept  json
+// @Produce  json
+// @Param input body model.TwoFactorRoles true "Roles"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/2fa/roles [put]
+func (h *Handler) setTwoFactorRoles(ctx *gin.Context) {
+	necessaryRole := []string{"Superadmin"}
+	if err := h.service.CheckRole(necessaryRole, ctx.GetString("role")); err != nil {
+		h.log(ctx).Warnf("Handler setTwoFactorRoles:not enough rights")
+		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "not enough rights"})
+		return
+	}
+	var input model.TwoFactorRoles
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		h.log(ctx).Warnf("Handler setTwoFactorRoles (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	for _, role := range input.Roles {
+		if err := h.service.AppUser.CheckInputRole(ctx.Request.Context(), role); err != nil {
+			h.log(ctx).Warnf("Incorrect role came from the request:%s", err)
+			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Incorrect role came from the request"})
+			return
+		}
+	}
+	if err := h.service.TwoFactor.SetTwoFactorRoles(ctx.Request.Context(), input.Roles); err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
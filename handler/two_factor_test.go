@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/magiconair/properties/assert"
+	"net/http/httptest"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	mock_service "stlab.itechart-group.com/go/food_delivery/authentication_service/service/mocks"
+	"testing"
+)
+
+func TestHandler_enrollTOTP(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockTwoFactor)
+	testTable := []struct {
+		name                string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(s *mock_service.MockTwoFactor) {
+				s.EXPECT().EnrollTOTP(gomock.Any(), 1).Return(&model.TOTPEnrollment{
+					Secret:          "SECRET",
+					ProvisioningURI: "otpauth://totp/uri",
+				}, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"secret":"SECRET","provisioning_uri":"otpauth://totp/uri"}`,
+		},
+		{
+			name: "Already enabled",
+			mockBehavior: func(s *mock_service.MockTwoFactor) {
+				s.EXPECT().EnrollTOTP(gomock.Any(), 1).Return(nil, pkg.ErrorTwoFactorEnabled)
+			},
+			expectedStatusCode:  409,
+			expectedRequestBody: `{"message":"two-factor authentication is already enabled"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			auth.EXPECT().ParseToken(gomock.Any(), "testToken").Return(&authProto.UserRole{UserId: 1, Role: "Courier"}, nil)
+			twoFactor := mock_service.NewMockTwoFactor(c)
+			testCase.mockBehavior(twoFactor)
+			logger := logging.GetLogger()
+			services := newTestService(c, auth)
+			services.TwoFactor = twoFactor
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/users/2fa/enroll", nil)
+			req.Header.Set("Authorization", "Bearer testToken")
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_confirmTOTP(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockTwoFactor)
+	testTable := []struct {
+		name                string
+		inputBody           string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:      "OK",
+			inputBody: `{"code":"123456"}`,
+			mockBehavior: func(s *mock_service.MockTwoFactor) {
+				s.EXPECT().ConfirmTOTP(gomock.Any(), 1, "123456").Return([]string{"ABCDE-FGHIJ"}, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"recovery_codes":["ABCDE-FGHIJ"]}`,
+		},
+		{
+			name:                "Empty code",
+			inputBody:           `{}`,
+			mockBehavior:        func(s *mock_service.MockTwoFactor) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"invalid request"}`,
+		},
+		{
+			name:      "Wrong code",
+			inputBody: `{"code":"123456"}`,
+			mockBehavior: func(s *mock_service.MockTwoFactor) {
+				s.EXPECT().ConfirmTOTP(gomock.Any(), 1, "123456").Return(nil, pkg.ErrorInvalidTwoFactor)
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"two-factor authentication code is invalid"}`,
+		},
+		{
+			name:      "Server error",
+			inputBody: `{"code":"123456"}`,
+			mockBehavior: func(s *mock_service.MockTwoFactor) {
+				s.EXPECT().ConfirmTOTP(gomock.Any(), 1, "123456").Return(nil, errors.New("server error"))
+			},
+			expectedStatusCode:  500,
+			expectedRequestBody: `{"message":"server error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			auth.EXPECT().ParseToken(gomock.Any(), "testToken").Return(&authProto.UserRole{UserId: 1, Role: "Courier"}, nil)
+			twoFactor := mock_service.NewMockTwoFactor(c)
+			testCase.mockBehavior(twoFactor)
+			logger := logging.GetLogger()
+			services := newTestService(c, auth)
+			services.TwoFactor = twoFactor
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/users/2fa/confirm", bytes.NewBufferString(testCase.inputBody))
+			req.Header.Set("Authorization", "Bearer testToken")
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_disableTOTP(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockTwoFactor)
+	testTable := []struct {
+		name                string
+		inputBody           string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:      "OK",
+			inputBody: `{"code":"ABCDE-FGHIJ"}`,
+			mockBehavior: func(s *mock_service.MockTwoFactor) {
+				s.EXPECT().DisableTOTP(gomock.Any(), 1, "ABCDE-FGHIJ").Return(nil)
+			},
+			expectedStatusCode: 204,
+		},
+		{
+			name:      "Mandatory for role",
+			inputBody: `{"code":"ABCDE-FGHIJ"}`,
+			mockBehavior: func(s *mock_service.MockTwoFactor) {
+				s.EXPECT().DisableTOTP(gomock.Any(), 1, "ABCDE-FGHIJ").Return(pkg.ErrorTwoFactorMandatory)
+			},
+			expectedStatusCode:  403,
+			expectedRequestBody: `{"message":"two-factor authentication is mandatory for this role"}`,
+		},
+		{
+			name:      "Not enabled",
+			inputBody: `{"code":"ABCDE-FGHIJ"}`,
+			mockBehavior: func(s *mock_service.MockTwoFactor) {
+				s.EXPECT().DisableTOTP(gomock.Any(), 1, "ABCDE-FGHIJ").Return(pkg.ErrorTwoFactorNotEnabled)
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"two-factor authentication is not enabled"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			auth.EXPECT().ParseToken(gomock.Any(), "testToken").Return(&authProto.UserRole{UserId: 1, Role: "Courier"}, nil)
+			twoFactor := mock_service.NewMockTwoFactor(c)
+			testCase.mockBehavior(twoFactor)
+			logger := logging.GetLogger()
+			services := newTestService(c, auth)
+			services.TwoFactor = twoFactor
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/users/2fa/disable", bytes.NewBufferString(testCase.inputBody))
+			req.Header.Set("Authorization", "Bearer testToken")
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_setTwoFactorRoles(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser, tf *mock_service.MockTwoFactor)
+	testTable := []struct {
+		name                string
+		role                string
+		inputBody           string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:      "OK",
+			role:      "Superadmin",
+			inputBody: `{"roles":["Superadmin","Courier manager"]}`,
+			mockBehavior: func(s *mock_service.MockAppUser, tf *mock_service.MockTwoFactor) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, "Superadmin").Return(nil)
+				s.EXPECT().CheckInputRole(gomock.Any(), "Superadmin").Return(nil)
+				s.EXPECT().CheckInputRole(gomock.Any(), "Courier manager").Return(nil)
+				tf.EXPECT().SetTwoFactorRoles(gomock.Any(), []string{"Superadmin", "Courier manager"}).Return(nil)
+			},
+			expectedStatusCode: 204,
+		},
+		{
+			name:      "Not enough rights",
+			role:      "Courier",
+			inputBody: `{"roles":["Superadmin"]}`,
+			mockBehavior: func(s *mock_service.MockAppUser, tf *mock_service.MockTwoFactor) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, "Courier").Return(errors.New("not enough rights"))
+			},
+			expectedStatusCode:  401,
+			expectedRequestBody: `{"message":"not enough rights"}`,
+		},
+		{
+			name:      "Unknown role",
+			role:      "Superadmin",
+			inputBody: `{"roles":["Pilot"]}`,
+			mockBehavior: func(s *mock_service.MockAppUser, tf *mock_service.MockTwoFactor) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, "Superadmin").Return(nil)
+				s.EXPECT().CheckInputRole(gomock.Any(), "Pilot").Return(errors.New("incorrect role in request"))
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"Incorrect role came from the request"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			auth.EXPECT().ParseToken(gomock.Any(), "testToken").Return(&authProto.UserRole{UserId: 1, Role: testCase.role}, nil)
+			twoFactor := mock_service.NewMockTwoFactor(c)
+			testCase.mockBehavior(auth, twoFactor)
+			logger := logging.GetLogger()
+			services := newTestService(c, auth)
+			services.TwoFactor = twoFactor
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", "/users/2fa/roles", bytes.NewBufferString(testCase.inputBody))
+			req.Header.Set("Authorization", "Bearer testToken")
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
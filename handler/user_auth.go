@@ -1,43 +1,229 @@
 package handler
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"math"
 	"net/http"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
 	"strconv"
+	"time"
 )
 
 // authUser godoc
 // @Summary authUser
-// @Description check auth information
+// @Description check auth information, users with two-factor authentication get a challenge to finish at /users/login/2fa,
+// @Description users of a role requiring it who have not enrolled get 403 and a link to enroll by email
 // @Tags Auth
 // @Accept  json
 // @Produce  json
 // @Param input body model.AuthUser true "User"
 // @Success 200 {object} authProto.GeneratedTokens
+// @Success 202 {object} model.TwoFactorChallenge
 // @Failure 400 {object} model.ErrorResponse
 // @Failure 401 {object} model.ErrorResponse
+// @Failure 403 {object} model.ErrorResponse
+// @Failure 423 {object} model.LockedResponse
+// @Failure 429 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Router /users/login [post]
 func (h *Handler) authUser(ctx *gin.Context) {
-	h.logger.Info("Working authUser")
+	h.log(ctx).Info("Working authUser")
 	var input model.AuthUser
 	if err := ctx.BindJSON(&input); err != nil {
-		h.logger.Errorf("authUser: error while decoding request:%s", err)
+		h.log(ctx).Errorf("authUser: error while decoding request:%s", err)
 		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid input body"})
 		return
 	}
 	validationErrors := ValidateStruct(input)
 	if len(validationErrors) != 0 {
-		h.logger.Warnf("Incorrect data came from the request:%s", validationErrors)
+		h.log(ctx).Warnf("Incorrect data came from the request:%s", validationErrors)
 		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Wrong email or password entered"})
 		return
 	}
-	tokens, id, err := h.service.AppUser.AuthUser(input.Email, input.Password)
-	if err != nil {
+	tokens, id, err := h.service.AppUser.AuthUser(ctx.Request.Context(), input.Email, input.Password)
+	var lockedErr *pkg.LockedError
+	var challengeErr *pkg.ChallengeError
+	if errors.As(err, &challengeErr) {
+		// the id is sent only once the second factor is passed
+		ctx.JSON(http.StatusAccepted, model.TwoFactorChallenge{
+			Challenge: challengeErr.Challenge,
+			ExpiresAt: challengeErr.ExpiresAt,
+		})
+	} else if errors.As(err, &lockedErr) {
+		retryAfter := int(math.Ceil(time.Until(lockedErr.Until).Seconds()))
+		ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+		ctx.JSON(http.StatusLocked, model.LockedResponse{
+			Message:     "Account is temporarily locked due to too many failed login attempts",
+			LockedUntil: lockedErr.Until,
+		})
+	} else if errors.Is(err, pkg.ErrorEmailNotVerified) {
+		ctx.JSON(http.StatusForbidden, model.ErrorResponse{Message: "Email is not verified"})
+	} else if errors.Is(err, pkg.ErrorEnrollmentRequired) {
+		ctx.JSON(http.StatusForbidden, model.ErrorResponse{Message: pkg.EnrollmentRequired})
+	} else if err != nil {
 		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "Wrong email or password entered"})
 	} else {
 		ctx.Header("id", strconv.Itoa(id))
 		ctx.JSON(http.StatusOK, tokens)
 	}
 }
+
+// authUserTwoFactor godoc
+// @Summary authUserTwoFactor
+// @Description finish the login with a TOTP or recovery code, an enrollment challenge returns recovery codes as well
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param input body model.TwoFactorLogin true "Challenge and code"
+// @Success 200 {object} model.TwoFactorTokens
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 423 {object} model.LockedResponse
+// @Failure 429 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/login/2fa [post]
+func (h *Handler) authUserTwoFactor(ctx *gin.Context) {
+	var input model.TwoFactorLogin
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		h.log(ctx).Warnf("Handler authUserTwoFactor (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid input body"})
+		return
+	}
+	tokens, id, err := h.service.AppUser.AuthUserTwoFactor(ctx.Request.Context(), input.Challenge, input.Code)
+	var lockedErr *pkg.LockedError
+	if errors.As(err, &lockedErr) {
+		retryAfter := int(math.Ceil(time.Until(lockedErr.Until).Seconds()))
+		ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+		ctx.JSON(http.StatusLocked, model.LockedResponse{
+			Message:     "Account is temporarily locked due to too many failed login attempts",
+			LockedUntil: lockedErr.Until,
+		})
+	} else if errors.Is(err, pkg.ErrorInvalidChallenge) || errors.Is(err, pkg.ErrorInvalidTwoFactor) {
+		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: err.Error()})
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+	} else {
+		ctx.Header("id", strconv.Itoa(id))
+		ctx.JSON(http.StatusOK, tokens)
+	}
+}
+
+// startTwoFactorEnrollment godoc
+// @Summary startTwoFactorEnrollment
+// @Description open the emailed single-use enrollment link, the new secret is confirmed by finishing the challenge at /users/login/2fa
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param input body model.TwoFactorEnrollmentLink true "Token of the enrollment link"
+// @Success 200 {object} model.TwoFactorEnrollment
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 429 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/login/2fa/enroll [post]
+func (h *Handler) startTwoFactorEnrollment(ctx *gin.Context) {
+	var input model.TwoFactorEnrollmentLink
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		h.log(ctx).Warnf("Handler startTwoFactorEnrollment (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid input body"})
+		return
+	}
+	enrollment, err := h.service.AppUser.StartTwoFactorEnrollment(ctx.Request.Context(), input.Token)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorInvalidEnrollment) {
+			ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+// refreshTokens godoc
+// @Summary refreshTokens
+// @Description get a new pair of tokens by refresh token
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param input body model.RefreshToken true "Refresh token"
+// @Success 200 {object} authProto.GeneratedTokens
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/refresh [post]
+func (h *Handler) refreshTokens(ctx *gin.Context) {
+	var input model.RefreshToken
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		h.log(ctx).Warnf("Handler refreshTokens (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid input body"})
+		return
+	}
+	tokens, err := h.service.AppUser.RefreshTokens(ctx.Request.Context(), input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorInvalidRefreshToken) {
+			ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "Refresh token is expired or revoked"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+// logout godoc
+// @Summary logout
+// @Security ApiKeyAuth
+// @Description revoke the current access token and the given refresh token
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param input body model.Logout false "Refresh token"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/logout [post]
+func (h *Handler) logout(ctx *gin.Context) {
+	var input model.Logout
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&input); err != nil {
+			h.log(ctx).Warnf("Handler logout (binding JSON):%s", err)
+			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid input body"})
+			return
+		}
+	}
+	userId := getUserId(ctx)
+	err := h.service.TokenRevocation.Logout(ctx.Request.Context(), userId, ctx.GetString("token"), input.RefreshToken)
+	if errors.Is(err, pkg.ErrorForeignToken) {
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: pkg.ForeignToken})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// logoutAll godoc
+// @Summary logoutAll
+// @Security ApiKeyAuth
+// @Description revoke every token of the current user
+// @Tags Auth
+// @Produce  json
+// @Success 204
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/logout-all [post]
+func (h *Handler) logoutAll(ctx *gin.Context) {
+	userId := getUserId(ctx)
+	err := h.service.TokenRevocation.LogoutAll(ctx.Request.Context(), userId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
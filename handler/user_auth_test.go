@@ -3,16 +3,19 @@ package handler
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/magiconair/properties/assert"
 	"net/http/httptest"
 	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/service"
 	mock_service "stlab.itechart-group.com/go/food_delivery/authentication_service/service/mocks"
 	"testing"
+	"time"
 )
 
 func TestHandler_authUser(t *testing.T) {
@@ -33,7 +36,7 @@ func TestHandler_authUser(t *testing.T) {
 				Password: "HGYKnu!98Tg",
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, user model.AuthUser) {
-				s.EXPECT().AuthUser(user.Email, user.Password).Return(&authProto.GeneratedTokens{
+				s.EXPECT().AuthUser(gomock.Any(), user.Email, user.Password).Return(&authProto.GeneratedTokens{
 					AccessToken:  "qwerty",
 					RefreshToken: "qwerty",
 				}, 1, nil)
@@ -112,11 +115,67 @@ func TestHandler_authUser(t *testing.T) {
 				Password: "HGYKnu!98Tg",
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, user model.AuthUser) {
-				s.EXPECT().AuthUser(user.Email, user.Password).Return(nil, 0, errors.New("service failure"))
+				s.EXPECT().AuthUser(gomock.Any(), user.Email, user.Password).Return(nil, 0, errors.New("service failure"))
 			},
 			expectedStatusCode:  401,
 			expectedRequestBody: `{"message":"Wrong email or password entered"}`,
 		},
+		{
+			name:      "Locked account",
+			inputBody: `{"email":"test@yandex.ru", "password":"HGYKnu!98Tg"}`,
+			inputUser: model.AuthUser{
+				Email:    "test@yandex.ru",
+				Password: "HGYKnu!98Tg",
+			},
+			mockBehavior: func(s *mock_service.MockAppUser, user model.AuthUser) {
+				s.EXPECT().AuthUser(gomock.Any(), user.Email, user.Password).Return(nil, 0, &pkg.LockedError{
+					Until: time.Date(2100, 03, 11, 0, 0, 0, 0, time.UTC),
+				})
+			},
+			expectedStatusCode:  423,
+			expectedRequestBody: `{"message":"Account is temporarily locked due to too many failed login attempts","locked_until":"2100-03-11T00:00:00Z"}`,
+		},
+		{
+			name:      "Second factor required",
+			inputBody: `{"email":"test@yandex.ru", "password":"HGYKnu!98Tg"}`,
+			inputUser: model.AuthUser{
+				Email:    "test@yandex.ru",
+				Password: "HGYKnu!98Tg",
+			},
+			mockBehavior: func(s *mock_service.MockAppUser, user model.AuthUser) {
+				s.EXPECT().AuthUser(gomock.Any(), user.Email, user.Password).Return(nil, 0, &pkg.ChallengeError{
+					Challenge: "challenge",
+					ExpiresAt: time.Date(2100, 03, 11, 0, 0, 0, 0, time.UTC),
+				})
+			},
+			expectedStatusCode:  202,
+			expectedRequestBody: `{"challenge":"challenge","expires_at":"2100-03-11T00:00:00Z"}`,
+		},
+		{
+			name:      "Email not verified",
+			inputBody: `{"email":"test@yandex.ru", "password":"HGYKnu!98Tg"}`,
+			inputUser: model.AuthUser{
+				Email:    "test@yandex.ru",
+				Password: "HGYKnu!98Tg",
+			},
+			mockBehavior: func(s *mock_service.MockAppUser, user model.AuthUser) {
+				s.EXPECT().AuthUser(gomock.Any(), user.Email, user.Password).Return(nil, 0, fmt.Errorf("authUser:%w", pkg.ErrorEmailNotVerified))
+			},
+			expectedStatusCode:  403,
+			expectedRequestBody: `{"message":"Email is not verified"}`,
+		}, {
+			name:      "Enrollment required",
+			inputBody: `{"email":"test@yandex.ru", "password":"HGYKnu!98Tg"}`,
+			inputUser: model.AuthUser{
+				Email:    "test@yandex.ru",
+				Password: "HGYKnu!98Tg",
+			},
+			mockBehavior: func(s *mock_service.MockAppUser, user model.AuthUser) {
+				s.EXPECT().AuthUser(gomock.Any(), user.Email, user.Password).Return(nil, 0, fmt.Errorf("authUser:%w", pkg.ErrorEnrollmentRequired))
+			},
+			expectedStatusCode:  403,
+			expectedRequestBody: `{"message":"two-factor authentication is mandatory for this role, the link to enroll is sent by email"}`,
+		},
 	}
 
 	for _, testCase := range testTable {
@@ -141,10 +200,363 @@ func TestHandler_authUser(t *testing.T) {
 			//Execute the request
 			r.ServeHTTP(w, req)
 
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+			if w.Code != 200 {
+				assert.Equal(t, "", w.Header().Get("id"))
+			}
+		})
+	}
+
+}
+
+func TestHandler_authUserTwoFactor(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser)
+	testTable := []struct {
+		name                string
+		inputBody           string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:      "OK",
+			inputBody: `{"challenge":"challenge","code":"123456"}`,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().AuthUserTwoFactor(gomock.Any(), "challenge", "123456").Return(&model.TwoFactorTokens{
+					AccessToken:  "qwerty",
+					RefreshToken: "qwerty",
+				}, 1, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"accessToken":"qwerty","refreshToken":"qwerty"}`,
+		},
+		{
+			name:                "Empty code",
+			inputBody:           `{"challenge":"challenge"}`,
+			mockBehavior:        func(s *mock_service.MockAppUser) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"Invalid input body"}`,
+		},
+		{
+			name:      "Wrong code",
+			inputBody: `{"challenge":"challenge","code":"123456"}`,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().AuthUserTwoFactor(gomock.Any(), "challenge", "123456").Return(nil, 0, pkg.ErrorInvalidTwoFactor)
+			},
+			expectedStatusCode:  401,
+			expectedRequestBody: `{"message":"two-factor authentication code is invalid"}`,
+		},
+		{
+			name:      "Expired challenge",
+			inputBody: `{"challenge":"challenge","code":"123456"}`,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().AuthUserTwoFactor(gomock.Any(), "challenge", "123456").Return(nil, 0, pkg.ErrorInvalidChallenge)
+			},
+			expectedStatusCode:  401,
+			expectedRequestBody: `{"message":"login challenge is invalid or expired"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			testCase.mockBehavior(auth)
+			logger := logging.GetLogger()
+			services := &service.Service{AppUser: auth}
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := gin.New()
+			r.POST("/login/2fa", handler.authUserTwoFactor)
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/login/2fa", bytes.NewBufferString(testCase.inputBody))
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_startTwoFactorEnrollment(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser)
+	testTable := []struct {
+		name                string
+		inputBody           string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:      "OK",
+			inputBody: `{"token":"token"}`,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().StartTwoFactorEnrollment(gomock.Any(), "token").Return(&model.TwoFactorEnrollment{
+					Challenge:       "challenge",
+					ExpiresAt:       time.Date(2100, 03, 11, 0, 0, 0, 0, time.UTC),
+					Secret:          "SECRET",
+					ProvisioningURI: "otpauth://totp/uri",
+				}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedRequestBody: `{"challenge":"challenge","expires_at":"2100-03-11T00:00:00Z",` +
+				`"secret":"SECRET","provisioning_uri":"otpauth://totp/uri"}`,
+		},
+		{
+			name:                "Empty token",
+			inputBody:           `{}`,
+			mockBehavior:        func(s *mock_service.MockAppUser) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"Invalid input body"}`,
+		},
+		{
+			name:      "Used link",
+			inputBody: `{"token":"token"}`,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().StartTwoFactorEnrollment(gomock.Any(), "token").Return(nil, pkg.ErrorInvalidEnrollment)
+			},
+			expectedStatusCode:  401,
+			expectedRequestBody: `{"message":"two-factor enrollment link is invalid or expired"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			testCase.mockBehavior(auth)
+			logger := logging.GetLogger()
+			services := &service.Service{AppUser: auth}
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := gin.New()
+			r.POST("/login/2fa/enroll", handler.startTwoFactorEnrollment)
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/login/2fa/enroll", bytes.NewBufferString(testCase.inputBody))
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
 			//Assert
 			assert.Equal(t, testCase.expectedStatusCode, w.Code)
 			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
 		})
 	}
+}
+
+func TestHandler_refreshTokens(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser, token string)
+	testTable := []struct {
+		name                string
+		inputBody           string
+		inputToken          string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:       "OK",
+			inputBody:  `{"refreshToken":"qwerty"}`,
+			inputToken: "qwerty",
+			mockBehavior: func(s *mock_service.MockAppUser, token string) {
+				s.EXPECT().RefreshTokens(gomock.Any(), token).Return(&authProto.GeneratedTokens{
+					AccessToken:  "new_access",
+					RefreshToken: "new_refresh",
+				}, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"accessToken":"new_access","refreshToken":"new_refresh"}`,
+		},
+		{
+			name:                "Empty token",
+			inputBody:           `{}`,
+			mockBehavior:        func(s *mock_service.MockAppUser, token string) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"Invalid input body"}`,
+		},
+		{
+			name:       "Expired or revoked token",
+			inputBody:  `{"refreshToken":"qwerty"}`,
+			inputToken: "qwerty",
+			mockBehavior: func(s *mock_service.MockAppUser, token string) {
+				s.EXPECT().RefreshTokens(gomock.Any(), token).Return(nil, fmt.Errorf("refreshTokens:%w", pkg.ErrorInvalidRefreshToken))
+			},
+			expectedStatusCode:  401,
+			expectedRequestBody: `{"message":"Refresh token is expired or revoked"}`,
+		},
+		{
+			name:       "Service Failure",
+			inputBody:  `{"refreshToken":"qwerty"}`,
+			inputToken: "qwerty",
+			mockBehavior: func(s *mock_service.MockAppUser, token string) {
+				s.EXPECT().RefreshTokens(gomock.Any(), token).Return(nil, errors.New("service failure"))
+			},
+			expectedStatusCode:  500,
+			expectedRequestBody: `{"message":"service failure"}`,
+		},
+	}
 
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			testCase.mockBehavior(auth, testCase.inputToken)
+			logger := logging.GetLogger()
+			services := &service.Service{AppUser: auth}
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := gin.New()
+			r.POST("/refresh", handler.refreshTokens)
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/refresh", bytes.NewBufferString(testCase.inputBody))
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_logout(t *testing.T) {
+	type mockBehaviorRevoked func(s *mock_service.MockTokenRevocation)
+	type mockBehavior func(s *mock_service.MockTokenRevocation)
+	testTable := []struct {
+		name                string
+		path                string
+		inputBody           string
+		mockBehaviorRevoked mockBehaviorRevoked
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:      "OK",
+			path:      "/users/logout",
+			inputBody: `{"refreshToken":"refresh"}`,
+			mockBehaviorRevoked: func(s *mock_service.MockTokenRevocation) {
+				s.EXPECT().CheckTokenRevoked(gomock.Any(), 1, "testToken").Return(nil)
+			},
+			mockBehavior: func(s *mock_service.MockTokenRevocation) {
+				s.EXPECT().Logout(gomock.Any(), 1, "testToken", "refresh").Return(nil)
+			},
+			expectedStatusCode:  204,
+			expectedRequestBody: ``,
+		},
+		{
+			name: "OK without body",
+			path: "/users/logout",
+			mockBehaviorRevoked: func(s *mock_service.MockTokenRevocation) {
+				s.EXPECT().CheckTokenRevoked(gomock.Any(), 1, "testToken").Return(nil)
+			},
+			mockBehavior: func(s *mock_service.MockTokenRevocation) {
+				s.EXPECT().Logout(gomock.Any(), 1, "testToken", "").Return(nil)
+			},
+			expectedStatusCode:  204,
+			expectedRequestBody: ``,
+		},
+		{
+			name: "Revoked token",
+			path: "/users/logout",
+			mockBehaviorRevoked: func(s *mock_service.MockTokenRevocation) {
+				s.EXPECT().CheckTokenRevoked(gomock.Any(), 1, "testToken").Return(pkg.ErrorTokenRevoked)
+			},
+			mockBehavior:        func(s *mock_service.MockTokenRevocation) {},
+			expectedStatusCode:  401,
+			expectedRequestBody: `{"message":"token has been revoked"}`,
+		},
+		{
+			name:      "Foreign refresh token",
+			path:      "/users/logout",
+			inputBody: `{"refreshToken":"refresh"}`,
+			mockBehaviorRevoked: func(s *mock_service.MockTokenRevocation) {
+				s.EXPECT().CheckTokenRevoked(gomock.Any(), 1, "testToken").Return(nil)
+			},
+			mockBehavior: func(s *mock_service.MockTokenRevocation) {
+				s.EXPECT().Logout(gomock.Any(), 1, "testToken", "refresh").Return(fmt.Errorf("logout:%w", pkg.ErrorForeignToken))
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"token belongs to another user"}`,
+		},
+		{
+			name: "Service Failure",
+			path: "/users/logout",
+			mockBehaviorRevoked: func(s *mock_service.MockTokenRevocation) {
+				s.EXPECT().CheckTokenRevoked(gomock.Any(), 1, "testToken").Return(nil)
+			},
+			mockBehavior: func(s *mock_service.MockTokenRevocation) {
+				s.EXPECT().Logout(gomock.Any(), 1, "testToken", "").Return(errors.New("service failure"))
+			},
+			expectedStatusCode:  500,
+			expectedRequestBody: `{"message":"service failure"}`,
+		},
+		{
+			name: "OK logout all",
+			path: "/users/logout-all",
+			mockBehaviorRevoked: func(s *mock_service.MockTokenRevocation) {
+				s.EXPECT().CheckTokenRevoked(gomock.Any(), 1, "testToken").Return(nil)
+			},
+			mockBehavior: func(s *mock_service.MockTokenRevocation) {
+				s.EXPECT().LogoutAll(gomock.Any(), 1).Return(nil)
+			},
+			expectedStatusCode:  204,
+			expectedRequestBody: ``,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			auth.EXPECT().ParseToken(gomock.Any(), "testToken").Return(&authProto.UserRole{
+				UserId: 1,
+				Role:   "Authorized Customer",
+			}, nil)
+			revocation := mock_service.NewMockTokenRevocation(c)
+			testCase.mockBehaviorRevoked(revocation)
+			testCase.mockBehavior(revocation)
+			logger := logging.GetLogger()
+			services := &service.Service{AppUser: auth, TokenRevocation: revocation}
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", testCase.path, bytes.NewBufferString(testCase.inputBody))
+			req.Header.Set("Authorization", "Bearer testToken")
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
 }
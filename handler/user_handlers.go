@@ -2,11 +2,14 @@ package handler
 
 import (
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/url"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
 	"strconv"
+	"strings"
 )
 
 // getUserByID godoc
@@ -19,56 +22,84 @@ import (
 // @Param id path int true "User ID"
 // @Success 200 {object} model.ResponseUser
 // @Failure 400 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Router /users/{id} [get]
 func (h *Handler) getUser(ctx *gin.Context) {
 	necessaryRole := []string{"Superadmin"}
 	if err := h.service.CheckRole(necessaryRole, ctx.GetString("role")); err != nil {
-		h.logger.Warnf("Handler getUser:not enough rights")
+		h.log(ctx).Warnf("Handler getUser:not enough rights")
 		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "not enough rights"})
 		return
 	}
 	paramID := ctx.Param("id")
 	varID, err := strconv.Atoi(paramID)
 	if err != nil || varID <= 0 {
-		h.logger.Warnf("Handler getUser (reading param):%s", err)
+		h.log(ctx).Warnf("Handler getUser (reading param):%s", err)
 		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request"})
 		return
 	}
-	user, err := h.service.AppUser.GetUser(varID)
+	user, err := h.service.AppUser.GetUser(ctx.Request.Context(), varID)
 	if err != nil {
+		if errors.Is(err, pkg.ErrorUserNotFound) {
+			ctx.JSON(http.StatusNotFound, model.ErrorResponse{Message: pkg.UserNotFound})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, user)
 }
 
+// pageMeta describes a page taken by its number, Limit is zero when the whole list is returned
+type pageMeta struct {
+	Total int `json:"total"`
+	Page  int `json:"page"`
+	Limit int `json:"limit"`
+	Pages int `json:"pages"`
+}
+
 type listUsers struct {
-	Data []model.ResponseUser
+	Data       []model.ResponseUser
+	Meta       *pageMeta `json:"meta,omitempty"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	PrevCursor string    `json:"prev_cursor,omitempty"`
 }
 
 // getUsers godoc
 // @Summary getUsers
 // @Security ApiKeyAuth
-// @Description get list of users
+// @Description get list of users. Passing after, before or only limit switches to cursor pagination:
+// @Description the cursors of the neighbour pages are returned in the body and in the Link header.
+// @Description page and limit together keep the offset pagination described by meta.
+// @Description sort takes a comma separated list of id, email, role and created_at, a minus sorts in descending order.
 // @Tags User
 // @Accept  json
 // @Produce  json
+// @Param after query string false "Cursor of the page to continue after"
+// @Param before query string false "Cursor of the page to continue before"
 // @Param page query int false "Page"
 // @Param limit query int false "Limit"
-// @Param role query string false "Role"
-// @Param filter_data query bool false "FilterData"
-// @Param show_deleted query bool false "ShowDeleted"
-// @Param start_time query string false "StartTime"
-// @Param end_time query string false "EndTime"
+// @Param sort query string false "Sort, like created_at,-email"
+// @Param role query []string false "Roles, repeated or comma separated" collectionFormat(multi)
+// @Param deleted query string false "Deleted" Enums(active, deleted, all)
+// @Param q query string false "Search by a part of the email, exact and prefix matches go first unless sorted otherwise"
+// @Param email query string false "Part of the email"
+// @Param email_domain query string false "Email domain"
+// @Param start_time query string false "StartTime, inclusive"
+// @Param end_time query string false "EndTime, inclusive"
+// @Param filter_data query bool false "FilterData, deprecated"
+// @Param show_deleted query bool false "ShowDeleted, deprecated in favour of deleted=all"
 // @Success 200 {object} listUsers
+// @Header 200 {string} Link "next and prev pages in cursor mode"
+// @Header 200 {integer} pages "number of pages in page mode, deprecated in favour of meta"
 // @Failure 400 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Router /users/ [get]
 func (h *Handler) getUsers(ctx *gin.Context) {
 	necessaryRole := []string{"Superadmin"}
 	if err := h.service.CheckRole(necessaryRole, ctx.GetString("role")); err != nil {
-		h.logger.Warnf("Handler getUsers:not enough rights")
+		h.log(ctx).Warnf("Handler getUsers:not enough rights")
 		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "not enough rights"})
 		return
 	}
@@ -77,14 +108,14 @@ func (h *Handler) getUsers(ctx *gin.Context) {
 	var filters model.RequestFilters
 	err := ctx.Bind(&filters)
 	if err != nil {
-		h.logger.Warnf("Handler getUsers (bind query):%s", err)
+		h.log(ctx).Warnf("Handler getUsers (bind query):%s", err)
 		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request body"})
 		return
 	}
 	if ctx.Query("page") != "" {
 		paramPage, err := strconv.Atoi(ctx.Query("page"))
 		if err != nil || paramPage < 0 {
-			h.logger.Warnf("No url request:%s", err)
+			h.log(ctx).Warnf("No url request:%s", err)
 			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid url query"})
 			return
 		}
@@ -93,19 +124,68 @@ func (h *Handler) getUsers(ctx *gin.Context) {
 	if ctx.Query("limit") != "" {
 		paramLimit, err := strconv.Atoi(ctx.Query("limit"))
 		if err != nil || paramLimit < 0 {
-			h.logger.Warnf("No url request:%s", err)
+			h.log(ctx).Warnf("No url request:%s", err)
 			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid url query"})
 			return
 		}
 		limit = paramLimit
 	}
-	users, pages, err := h.service.AppUser.GetUsers(page, limit, &filters)
+	sort := ctx.Query("sort")
+	after, before := ctx.Query("after"), ctx.Query("before")
+	if after != "" || before != "" || ctx.Query("page") == "" && ctx.Query("limit") != "" {
+		h.getUsersPage(ctx, &filters, sort, after, before, limit)
+		return
+	}
+	list, err := h.service.AppUser.GetUsers(ctx.Request.Context(), page, limit, &filters, sort)
 	if err != nil {
+		if errors.Is(err, pkg.ErrorInvalidSort) {
+			h.log(ctx).Warnf("Handler getUsers:%s", err)
+			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
 		return
 	}
-	ctx.Header("pages", strconv.Itoa(pages))
-	ctx.JSON(http.StatusOK, listUsers{Data: users})
+	ctx.Header("pages", strconv.Itoa(list.Pages))
+	ctx.JSON(http.StatusOK, listUsers{
+		Data: list.Users,
+		Meta: &pageMeta{Total: list.Total, Page: list.Page, Limit: list.Limit, Pages: list.Pages},
+	})
+}
+
+func (h *Handler) getUsersPage(ctx *gin.Context, filters *model.RequestFilters, sort string, after string, before string, limit int) {
+	page, err := h.service.AppUser.GetUsersPage(ctx.Request.Context(), filters, sort, after, before, limit)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorInvalidCursor) || errors.Is(err, pkg.ErrorInvalidSort) {
+			h.log(ctx).Warnf("Handler getUsers:%s", err)
+			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	var links []string
+	if page.NextCursor != "" {
+		links = append(links, pageLink(ctx.Request.URL, "after", page.NextCursor, "next"))
+	}
+	if page.PrevCursor != "" {
+		links = append(links, pageLink(ctx.Request.URL, "before", page.PrevCursor, "prev"))
+	}
+	if len(links) != 0 {
+		ctx.Header("Link", strings.Join(links, ", "))
+	}
+	ctx.JSON(http.StatusOK, listUsers{Data: page.Users, NextCursor: page.NextCursor, PrevCursor: page.PrevCursor})
+}
+
+// pageLink is an RFC 8288 link to the same list with the cursor replaced
+func pageLink(requestURL *url.URL, param string, cursor string, rel string) string {
+	query := requestURL.Query()
+	query.Del("after")
+	query.Del("before")
+	query.Del("page")
+	query.Set(param, cursor)
+	link := url.URL{Path: requestURL.Path, RawQuery: query.Encode()}
+	return fmt.Sprintf("<%s>; rel=\"%s\"", link.String(), rel)
 }
 
 // createCustomer godoc
@@ -115,25 +195,26 @@ func (h *Handler) getUsers(ctx *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param input body model.CreateCustomer true "User"
-// @Success 201 {object} authProto.GeneratedTokens
+// @Success 201 {object} authProto.GeneratedTokens "tokens, or only the id while unverified customers can not log in"
 // @Failure 400 {object} model.ErrorResponse
 // @Failure 400 {object} map[string]string
+// @Failure 429 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Router /users/customer [post]
 func (h *Handler) createCustomer(ctx *gin.Context) {
 	var input model.CreateCustomer
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		h.logger.Warnf("Handler createCustomer (binding JSON):%s", err)
+		h.log(ctx).Warnf("Handler createCustomer (binding JSON):%s", err)
 		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request"})
 		return
 	}
 	validationErrors := ValidateStruct(input)
 	if len(validationErrors) != 0 {
-		h.logger.Warnf("Incorrect data came from the request:%s", validationErrors)
+		h.log(ctx).Warnf("Incorrect data came from the request:%s", validationErrors)
 		ctx.JSON(http.StatusBadRequest, validationErrors)
 		return
 	}
-	tokens, id, err := h.service.AppUser.CreateCustomer(&input)
+	tokens, id, err := h.service.AppUser.CreateCustomer(ctx.Request.Context(), &input)
 	if err != nil {
 		if err.Error() == "createCustomer: error while scanning for user:pq: duplicate key value violates unique constraint \"users_email_key\"" {
 			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "User with such an email already exists"})
@@ -144,6 +225,12 @@ func (h *Handler) createCustomer(ctx *gin.Context) {
 		}
 	}
 	ctx.Header("id", strconv.Itoa(id))
+	if tokens == nil {
+		ctx.JSON(http.StatusCreated, map[string]interface{}{
+			"id": id,
+		})
+		return
+	}
 	ctx.JSON(http.StatusCreated, tokens)
 }
 
@@ -163,29 +250,29 @@ func (h *Handler) createCustomer(ctx *gin.Context) {
 func (h *Handler) createStaff(ctx *gin.Context) {
 	necessaryRole := []string{"Superadmin", "Courier manager"}
 	if err := h.service.CheckRole(necessaryRole, ctx.GetString("role")); err != nil {
-		h.logger.Warnf("Handler createStaff:not enough rights")
+		h.log(ctx).Warnf("Handler createStaff:not enough rights")
 		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "not enough rights"})
 		return
 	}
 	var input model.CreateStaff
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		h.logger.Warnf("Handler createUser (binding JSON):%s", err)
+		h.log(ctx).Warnf("Handler createUser (binding JSON):%s", err)
 		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request"})
 		return
 	}
 	validationErrors := ValidateStruct(input)
 	if len(validationErrors) != 0 {
-		h.logger.Warnf("Incorrect data came from the request:%s", validationErrors)
+		h.log(ctx).Warnf("Incorrect data came from the request:%s", validationErrors)
 		ctx.JSON(http.StatusBadRequest, validationErrors)
 		return
 	}
-	err := h.service.AppUser.CheckInputRole(input.Role)
+	err := h.service.AppUser.CheckInputRole(ctx.Request.Context(), input.Role)
 	if err != nil {
-		h.logger.Warnf("Incorrect role came from the request:%s", err)
+		h.log(ctx).Warnf("Incorrect role came from the request:%s", err)
 		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Incorrect role came from the request"})
 		return
 	}
-	id, err := h.service.AppUser.CreateStaff(&input)
+	id, err := h.service.AppUser.CreateStaff(ctx.Request.Context(), &input)
 	if err != nil {
 		if err.Error() == "createStaff: error while scanning for user:pq: duplicate key value violates unique constraint users_email_key" {
 			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "User with such an email already exists"})
@@ -216,23 +303,23 @@ func (h *Handler) createStaff(ctx *gin.Context) {
 func (h *Handler) updateUser(ctx *gin.Context) {
 	necessaryRole := []string{"Superadmin", "Authorized Customer", "Courier", "Courier manager", "Restaurant manager"}
 	if err := h.service.CheckRole(necessaryRole, ctx.GetString("role")); err != nil {
-		h.logger.Warnf("Handler updateUser:not enough rights")
+		h.log(ctx).Warnf("Handler updateUser:not enough rights")
 		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "not enough rights"})
 		return
 	}
 	var input model.UpdateUser
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		h.logger.Warnf("Handler updateUser (binding JSON):%s", err)
+		h.log(ctx).Warnf("Handler updateUser (binding JSON):%s", err)
 		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request"})
 		return
 	}
 	validationErrors := ValidateStruct(input)
 	if len(validationErrors) != 0 {
-		h.logger.Warnf("Incorrect data came from the request:%s", validationErrors)
+		h.log(ctx).Warnf("Incorrect data came from the request:%s", validationErrors)
 		ctx.JSON(http.StatusBadRequest, validationErrors)
 		return
 	}
-	err := h.service.AppUser.UpdateUser(&input)
+	err := h.service.AppUser.UpdateUser(ctx.Request.Context(), &input)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
 		return
@@ -255,18 +342,18 @@ func (h *Handler) updateUser(ctx *gin.Context) {
 func (h *Handler) deleteUserByID(ctx *gin.Context) {
 	necessaryRole := []string{"Superadmin", "Courier manager"}
 	if err := h.service.CheckRole(necessaryRole, ctx.GetString("role")); err != nil {
-		h.logger.Warnf("Handler deleteUserByID:not enough rights")
+		h.log(ctx).Warnf("Handler deleteUserByID:not enough rights")
 		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "not enough rights"})
 		return
 	}
 	paramID := ctx.Param("id")
 	varID, err := strconv.Atoi(paramID)
 	if err != nil || varID <= 0 {
-		h.logger.Warnf("Handler deleteUserByID (reading param):%s", err)
+		h.log(ctx).Warnf("Handler deleteUserByID (reading param):%s", err)
 		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid id"})
 		return
 	}
-	id, err := h.service.AppUser.DeleteUserByID(varID)
+	id, err := h.service.AppUser.DeleteUserByID(ctx.Request.Context(), varID)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
 		return
@@ -277,39 +364,234 @@ func (h *Handler) deleteUserByID(ctx *gin.Context) {
 	}
 }
 
+// unlockUser godoc
+// @Summary unlockUser
+// @Security ApiKeyAuth
+// @Description lift the failed login lockout of the user
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200  {string} string
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/{id}/unlock [post]
+func (h *Handler) unlockUser(ctx *gin.Context) {
+	necessaryRole := []string{"Superadmin"}
+	if err := h.service.CheckRole(necessaryRole, ctx.GetString("role")); err != nil {
+		h.log(ctx).Warnf("Handler unlockUser:not enough rights")
+		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "not enough rights"})
+		return
+	}
+	paramID := ctx.Param("id")
+	varID, err := strconv.Atoi(paramID)
+	if err != nil || varID <= 0 {
+		h.log(ctx).Warnf("Handler unlockUser (reading param):%s", err)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid id"})
+		return
+	}
+	id, err := h.service.AppUser.UnlockUser(ctx.Request.Context(), varID)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorUserNotFound) {
+			ctx.JSON(http.StatusNotFound, model.ErrorResponse{Message: pkg.UserNotFound})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+// restoreUser godoc
+// @Summary restoreUser
+// @Security ApiKeyAuth
+// @Description restore the deleted user and bind its role again
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200  {string} string
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/{id}/restore [post]
+func (h *Handler) restoreUser(ctx *gin.Context) {
+	necessaryRole := []string{"Superadmin", "Courier manager"}
+	if err := h.service.CheckRole(necessaryRole, ctx.GetString("role")); err != nil {
+		h.log(ctx).Warnf("Handler restoreUser:not enough rights")
+		ctx.JSON(http.StatusUnauthorized, model.ErrorResponse{Message: "not enough rights"})
+		return
+	}
+	paramID := ctx.Param("id")
+	varID, err := strconv.Atoi(paramID)
+	if err != nil || varID <= 0 {
+		h.log(ctx).Warnf("Handler restoreUser (reading param):%s", err)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "Invalid id"})
+		return
+	}
+	id, err := h.service.AppUser.RestoreUser(ctx.Request.Context(), varID)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorUserNotFound) {
+			ctx.JSON(http.StatusNotFound, model.ErrorResponse{Message: pkg.UserNotFound})
+			return
+		}
+		if errors.Is(err, pkg.ErrorUserActive) {
+			ctx.JSON(http.StatusConflict, model.ErrorResponse{Message: pkg.UserActive})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
 // restorePassword godoc
 // @Summary restorePassword
-// @Description restore user password
+// @Description send a single-use password reset link to the user email, the answer is the same whether the email is registered or not
 // @Tags User
 // @Accept  json
 // @Produce  json
 // @Param input body model.RestorePassword true "Email"
 // @Success 204
 // @Failure 400 {object} model.ErrorResponse
+// @Failure 429 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Router /users/restorePassword [post]
 func (h *Handler) restorePassword(ctx *gin.Context) {
 	var input model.RestorePassword
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		h.logger.Warnf("Handler restorePassword (binding JSON):%s", err)
+		h.log(ctx).Warnf("Handler restorePassword (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	validationErrors := ValidateStruct(input)
+	if len(validationErrors) != 0 {
+		h.log(ctx).Warnf("Incorrect data came from the request:%s", validationErrors)
+		ctx.JSON(http.StatusBadRequest, validationErrors)
+		return
+	}
+	err := h.service.AppUser.RestorePassword(ctx.Request.Context(), &input)
+	// an unknown or deleted email gets the same answer, so that the registered ones can not be found out
+	if err != nil && !errors.Is(err, pkg.ErrorEmailDoesNotExist) {
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// resetPassword godoc
+// @Summary resetPassword
+// @Description set a new password using the token from the password reset email
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param input body model.ResetPassword true "Token and new password"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 429 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/resetPassword [post]
+func (h *Handler) resetPassword(ctx *gin.Context) {
+	var input model.ResetPassword
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		h.log(ctx).Warnf("Handler resetPassword (binding JSON):%s", err)
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	validationErrors := ValidateStruct(input)
+	if len(validationErrors) != 0 {
+		h.log(ctx).Warnf("Incorrect data came from the request:%s", validationErrors)
+		ctx.JSON(http.StatusBadRequest, validationErrors)
+		return
+	}
+	err := h.service.AppUser.ResetPassword(ctx.Request.Context(), &input)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorInvalidResetToken) {
+			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// verifyEmail godoc
+// @Summary verifyEmail
+// @Description verify the user email by the link from the verification email
+// @Tags User
+// @Produce  json
+// @Param token query string true "Verification token"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/verify [get]
+func (h *Handler) verifyEmail(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		h.log(ctx).Warn("Handler verifyEmail: empty token")
+		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request"})
+		return
+	}
+	id, err := h.service.AppUser.VerifyEmail(ctx.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorInvalidVerification) {
+			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+// resendVerification godoc
+// @Summary resendVerification
+// @Description send the email verification link again
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param input body model.ResendVerification true "Email"
+// @Success 204
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 429 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Router /users/verify/resend [post]
+func (h *Handler) resendVerification(ctx *gin.Context) {
+	var input model.ResendVerification
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		h.log(ctx).Warnf("Handler resendVerification (binding JSON):%s", err)
 		ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: "invalid request"})
 		return
 	}
 	validationErrors := ValidateStruct(input)
 	if len(validationErrors) != 0 {
-		h.logger.Warnf("Incorrect data came from the request:%s", validationErrors)
+		h.log(ctx).Warnf("Incorrect data came from the request:%s", validationErrors)
 		ctx.JSON(http.StatusBadRequest, validationErrors)
 		return
 	}
-	err := h.service.AppUser.RestorePassword(&input)
+	err := h.service.AppUser.ResendVerification(ctx.Request.Context(), input.Email)
 	if err != nil {
 		if errors.Is(err, pkg.ErrorEmailDoesNotExist) {
 			ctx.JSON(http.StatusBadRequest, model.ErrorResponse{Message: err.Error()})
 			return
-		} else {
-			ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		} else if errors.Is(err, pkg.ErrorEmailVerified) {
+			ctx.JSON(http.StatusConflict, model.ErrorResponse{Message: err.Error()})
 			return
 		}
+		ctx.JSON(http.StatusInternalServerError, model.ErrorResponse{Message: err.Error()})
+		return
 	}
 	ctx.Status(http.StatusNoContent)
 }
@@ -18,6 +18,15 @@ import (
 	"time"
 )
 
+// newTestService completes the user service mock with permissive mocks of the other services
+func newTestService(c *gomock.Controller, auth *mock_service.MockAppUser) *service.Service {
+	revocation := mock_service.NewMockTokenRevocation(c)
+	revocation.EXPECT().CheckTokenRevoked(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	rateLimiter := mock_service.NewMockRateLimiter(c)
+	rateLimiter.EXPECT().Rule(gomock.Any()).Return(service.RateLimitRule{}, false).AnyTimes()
+	return &service.Service{AppUser: auth, TokenRevocation: revocation, RateLimiter: rateLimiter}
+}
+
 func TestHandler_getUser(t *testing.T) {
 	type mockBehavior func(s *mock_service.MockAppUser, id int)
 	type mockBehaviorCheck func(s *mock_service.MockAppUser, role string)
@@ -41,7 +50,7 @@ func TestHandler_getUser(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -51,7 +60,7 @@ func TestHandler_getUser(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, id int) {
-				s.EXPECT().GetUser(id).Return(&model.ResponseUser{
+				s.EXPECT().GetUser(gomock.Any(), id).Return(&model.ResponseUser{
 					ID:        1,
 					Email:     "test@yande.ru",
 					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
@@ -59,7 +68,7 @@ func TestHandler_getUser(t *testing.T) {
 				}, nil)
 			},
 			expectedStatusCode:  200,
-			expectedRequestBody: `{"id":1,"email":"test@yande.ru","created_at":"20220311","role":"Courier"}`,
+			expectedRequestBody: `{"id":1,"email":"test@yande.ru","created_at":"20220311","role":"Courier","email_verified":false}`,
 		},
 		{
 			name:       "invalid token",
@@ -67,7 +76,7 @@ func TestHandler_getUser(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(nil, fmt.Errorf("invalid token"))
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(nil, fmt.Errorf("invalid token"))
 			},
 			mockBehaviorCheck:   func(s *mock_service.MockAppUser, role string) {},
 			mockBehavior:        func(s *mock_service.MockAppUser, id int) {},
@@ -80,7 +89,7 @@ func TestHandler_getUser(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -100,7 +109,7 @@ func TestHandler_getUser(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -110,11 +119,33 @@ func TestHandler_getUser(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, id int) {
-				s.EXPECT().GetUser(id).Return(nil, fmt.Errorf("server error"))
+				s.EXPECT().GetUser(gomock.Any(), id).Return(nil, fmt.Errorf("server error"))
 			},
 			expectedStatusCode:  500,
 			expectedRequestBody: `{"message":"server error"}`,
 		},
+		{
+			name:       "user not found",
+			input:      "2",
+			id:         2,
+			inputRole:  "Superadmin",
+			inputToken: "testToken",
+			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
+					UserId:      1,
+					Role:        "Superadmin",
+					Permissions: "",
+				}, nil)
+			},
+			mockBehaviorCheck: func(s *mock_service.MockAppUser, role string) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
+			},
+			mockBehavior: func(s *mock_service.MockAppUser, id int) {
+				s.EXPECT().GetUser(gomock.Any(), id).Return(nil, fmt.Errorf("getUserByID:%w", pkg.ErrorUserNotFound))
+			},
+			expectedStatusCode:  404,
+			expectedRequestBody: `{"message":"user not found"}`,
+		},
 	}
 
 	for _, testCase := range testTable {
@@ -127,7 +158,7 @@ func TestHandler_getUser(t *testing.T) {
 			testCase.mockBehaviorCheck(getUser, testCase.inputRole)
 			testCase.mockBehavior(getUser, testCase.id)
 			logger := logging.GetLogger()
-			services := &service.Service{AppUser: getUser}
+			services := newTestService(c, getUser)
 			handler := NewHandler(logger, services)
 
 			//Init server
@@ -179,12 +210,11 @@ func TestHandler_getUsers(t *testing.T) {
 				FilterData:  false,
 				StartTime:   model.MyTime{},
 				EndTime:     model.MyTime{},
-				Role:        "",
 			},
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -194,7 +224,7 @@ func TestHandler_getUsers(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, page int, limit int, filter *model.RequestFilters) {
-				s.EXPECT().GetUsers(page, limit, filter).Return([]model.ResponseUser{
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, "").Return(&model.UsersPage{Users: []model.ResponseUser{
 					{ID: 1,
 						Email:     "test@yande.ru",
 						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
@@ -204,10 +234,10 @@ func TestHandler_getUsers(t *testing.T) {
 						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
 						Role:      "Courier",
 					},
-				}, 1, nil)
+				}, Total: 2, Page: 1, Limit: limit, Pages: 1}, nil)
 			},
 			expectedStatusCode:  200,
-			expectedRequestBody: `{"Data":[{"id":1,"email":"test@yande.ru","created_at":"20220311","role":"Courier"},{"id":2,"email":"test2@yande.ru","created_at":"20220311","role":"Courier"}]}`,
+			expectedRequestBody: `{"Data":[{"id":1,"email":"test@yande.ru","created_at":"20220311","role":"Courier","email_verified":false},{"id":2,"email":"test2@yande.ru","created_at":"20220311","role":"Courier","email_verified":false}],"meta":{"total":2,"page":1,"limit":10,"pages":1}}`,
 		},
 		{
 			name:       "OK with role filter",
@@ -219,12 +249,12 @@ func TestHandler_getUsers(t *testing.T) {
 				FilterData:  false,
 				StartTime:   model.MyTime{},
 				EndTime:     model.MyTime{},
-				Role:        "Courier",
+				Roles:       []string{"Courier"},
 			},
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -234,7 +264,7 @@ func TestHandler_getUsers(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, page int, limit int, filter *model.RequestFilters) {
-				s.EXPECT().GetUsers(page, limit, filter).Return([]model.ResponseUser{
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, "").Return(&model.UsersPage{Users: []model.ResponseUser{
 					{ID: 1,
 						Email:     "test@yande.ru",
 						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
@@ -244,10 +274,10 @@ func TestHandler_getUsers(t *testing.T) {
 						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
 						Role:      "Courier",
 					},
-				}, 1, nil)
+				}, Total: 2, Page: 1, Limit: limit, Pages: 1}, nil)
 			},
 			expectedStatusCode:  200,
-			expectedRequestBody: `{"Data":[{"id":1,"email":"test@yande.ru","created_at":"20220311","role":"Courier"},{"id":2,"email":"test2@yande.ru","created_at":"20220311","role":"Courier"}]}`,
+			expectedRequestBody: `{"Data":[{"id":1,"email":"test@yande.ru","created_at":"20220311","role":"Courier","email_verified":false},{"id":2,"email":"test2@yande.ru","created_at":"20220311","role":"Courier","email_verified":false}],"meta":{"total":2,"page":1,"limit":10,"pages":1}}`,
 		},
 		{
 			name:       "OK with data filter",
@@ -259,12 +289,11 @@ func TestHandler_getUsers(t *testing.T) {
 				FilterData:  true,
 				StartTime:   model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
 				EndTime:     model.MyTime{},
-				Role:        "",
 			},
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -274,7 +303,7 @@ func TestHandler_getUsers(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, page int, limit int, filter *model.RequestFilters) {
-				s.EXPECT().GetUsers(page, limit, filter).Return([]model.ResponseUser{
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, "").Return(&model.UsersPage{Users: []model.ResponseUser{
 					{ID: 1,
 						Email:     "test@yande.ru",
 						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
@@ -284,10 +313,10 @@ func TestHandler_getUsers(t *testing.T) {
 						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
 						Role:      "Courier",
 					},
-				}, 1, nil)
+				}, Total: 2, Page: 1, Limit: limit, Pages: 1}, nil)
 			},
 			expectedStatusCode:  200,
-			expectedRequestBody: `{"Data":[{"id":1,"email":"test@yande.ru","created_at":"20220311","role":"Courier"},{"id":2,"email":"test2@yande.ru","created_at":"20220311","role":"Courier"}]}`,
+			expectedRequestBody: `{"Data":[{"id":1,"email":"test@yande.ru","created_at":"20220311","role":"Courier","email_verified":false},{"id":2,"email":"test2@yande.ru","created_at":"20220311","role":"Courier","email_verified":false}],"meta":{"total":2,"page":1,"limit":10,"pages":1}}`,
 		},
 		{
 			name:       "Empty url query",
@@ -299,12 +328,11 @@ func TestHandler_getUsers(t *testing.T) {
 				FilterData:  false,
 				StartTime:   model.MyTime{},
 				EndTime:     model.MyTime{},
-				Role:        "",
 			},
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -314,7 +342,7 @@ func TestHandler_getUsers(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, page int, limit int, filter *model.RequestFilters) {
-				s.EXPECT().GetUsers(page, limit, filter).Return([]model.ResponseUser{
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, "").Return(&model.UsersPage{Users: []model.ResponseUser{
 					{ID: 1,
 						Email:     "test@yande.ru",
 						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
@@ -324,10 +352,122 @@ func TestHandler_getUsers(t *testing.T) {
 						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
 						Role:      "Courier",
 					},
-				}, 1, nil)
+				}, Total: 2, Page: 1, Limit: limit, Pages: 1}, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"Data":[{"id":1,"email":"test@yande.ru","created_at":"20220311","role":"Courier","email_verified":false},{"id":2,"email":"test2@yande.ru","created_at":"20220311","role":"Courier","email_verified":false}],"meta":{"total":2,"page":1,"limit":0,"pages":1}}`,
+		},
+		{
+			name:       "OK with combined filters",
+			inputQuery: "?role=Courier&role=Courier%20manager&deleted=all&email=ivan&email_domain=yandex.ru&start_time=20220301&end_time=20220331&q=iva",
+			page:       0,
+			limit:      0,
+			inputFilter: &model.RequestFilters{
+				Deleted:     model.DeletedAll,
+				StartTime:   model.MyTime{Time: time.Date(2022, 03, 01, 0, 0, 0, 0, time.UTC)},
+				EndTime:     model.MyTime{Time: time.Date(2022, 03, 31, 0, 0, 0, 0, time.UTC)},
+				Roles:       []string{"Courier", "Courier manager"},
+				Email:       "ivan",
+				EmailDomain: "yandex.ru",
+				Query:       "iva",
+			},
+			inputRole:  "Superadmin",
+			inputToken: "testToken",
+			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
+					UserId:      1,
+					Role:        "Superadmin",
+					Permissions: "",
+				}, nil)
+			},
+			mockBehaviorCheck: func(s *mock_service.MockAppUser, role string) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
+			},
+			mockBehavior: func(s *mock_service.MockAppUser, page int, limit int, filter *model.RequestFilters) {
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, "").Return(&model.UsersPage{Users: []model.ResponseUser{
+					{ID: 1,
+						Email:     "ivan@yandex.ru",
+						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
+						Role:      "Courier",
+					},
+				}, Total: 1, Page: 1, Limit: limit, Pages: 1}, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"Data":[{"id":1,"email":"ivan@yandex.ru","created_at":"20220311","role":"Courier","email_verified":false}],"meta":{"total":1,"page":1,"limit":0,"pages":1}}`,
+		},
+		{
+			name:        "OK with sort",
+			inputQuery:  "?page=2&limit=1&sort=created_at,-email",
+			page:        2,
+			limit:       1,
+			inputFilter: &model.RequestFilters{},
+			inputRole:   "Superadmin",
+			inputToken:  "testToken",
+			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
+					UserId:      1,
+					Role:        "Superadmin",
+					Permissions: "",
+				}, nil)
+			},
+			mockBehaviorCheck: func(s *mock_service.MockAppUser, role string) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
+			},
+			mockBehavior: func(s *mock_service.MockAppUser, page int, limit int, filter *model.RequestFilters) {
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, "created_at,-email").Return(&model.UsersPage{Users: []model.ResponseUser{
+					{ID: 2,
+						Email:     "test2@yande.ru",
+						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
+						Role:      "Courier",
+					},
+				}, Total: 3, Page: 2, Limit: 1, Pages: 3}, nil)
 			},
 			expectedStatusCode:  200,
-			expectedRequestBody: `{"Data":[{"id":1,"email":"test@yande.ru","created_at":"20220311","role":"Courier"},{"id":2,"email":"test2@yande.ru","created_at":"20220311","role":"Courier"}]}`,
+			expectedRequestBody: `{"Data":[{"id":2,"email":"test2@yande.ru","created_at":"20220311","role":"Courier","email_verified":false}],"meta":{"total":3,"page":2,"limit":1,"pages":3}}`,
+		},
+		{
+			name:        "Invalid sort",
+			inputQuery:  "?page=1&limit=10&sort=password",
+			page:        1,
+			limit:       10,
+			inputFilter: &model.RequestFilters{},
+			inputRole:   "Superadmin",
+			inputToken:  "testToken",
+			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
+					UserId:      1,
+					Role:        "Superadmin",
+					Permissions: "",
+				}, nil)
+			},
+			mockBehaviorCheck: func(s *mock_service.MockAppUser, role string) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
+			},
+			mockBehavior: func(s *mock_service.MockAppUser, page int, limit int, filter *model.RequestFilters) {
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, "password").Return(nil, fmt.Errorf("%w: can not sort by \"password\"", pkg.ErrorInvalidSort))
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"invalid sort parameter: can not sort by \"password\""}`,
+		},
+		{
+			name:        "Invalid deleted mode",
+			inputQuery:  "?deleted=some",
+			inputFilter: &model.RequestFilters{},
+			inputRole:   "Superadmin",
+			inputToken:  "testToken",
+			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
+					UserId:      1,
+					Role:        "Superadmin",
+					Permissions: "",
+				}, nil)
+			},
+			mockBehaviorCheck: func(s *mock_service.MockAppUser, role string) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
+			},
+			mockBehavior:        func(s *mock_service.MockAppUser, page int, limit int, filter *model.RequestFilters) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"invalid request body"}`,
 		},
 		{
 			name:       "Invalid value of the page in url query",
@@ -339,12 +479,11 @@ func TestHandler_getUsers(t *testing.T) {
 				FilterData:  false,
 				StartTime:   model.MyTime{},
 				EndTime:     model.MyTime{},
-				Role:        "",
 			},
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -367,12 +506,11 @@ func TestHandler_getUsers(t *testing.T) {
 				FilterData:  false,
 				StartTime:   model.MyTime{},
 				EndTime:     model.MyTime{},
-				Role:        "",
 			},
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -395,12 +533,11 @@ func TestHandler_getUsers(t *testing.T) {
 				FilterData:  false,
 				StartTime:   model.MyTime{},
 				EndTime:     model.MyTime{},
-				Role:        "",
 			},
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -410,7 +547,7 @@ func TestHandler_getUsers(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin"}, role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, page int, limit int, filter *model.RequestFilters) {
-				s.EXPECT().GetUsers(page, limit, filter).Return(nil, 0, fmt.Errorf("server error"))
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, "").Return(nil, fmt.Errorf("server error"))
 			},
 			expectedStatusCode:  500,
 			expectedRequestBody: `{"message":"server error"}`,
@@ -427,7 +564,7 @@ func TestHandler_getUsers(t *testing.T) {
 			testCase.mockBehaviorCheck(getUsers, testCase.inputRole)
 			testCase.mockBehavior(getUsers, testCase.page, testCase.limit, testCase.inputFilter)
 			logger := logging.GetLogger()
-			services := &service.Service{AppUser: getUsers}
+			services := newTestService(c, getUsers)
 			handler := NewHandler(logger, services)
 
 			//Init server
@@ -449,6 +586,105 @@ func TestHandler_getUsers(t *testing.T) {
 	}
 
 }
+func TestHandler_getUsersPage(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser)
+	testTable := []struct {
+		name                string
+		inputQuery          string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedLink        string
+		expectedRequestBody string
+	}{
+		{
+			name:       "First page",
+			inputQuery: "?limit=2&role=Courier",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().GetUsersPage(gomock.Any(), &model.RequestFilters{Roles: []string{"Courier"}}, "", "", "", 2).Return(&model.UsersPage{
+					Users:      []model.ResponseUser{{ID: 1, Email: "test@yande.ru", CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}, Role: "Courier"}},
+					NextCursor: "next",
+				}, nil)
+			},
+			expectedStatusCode:  200,
+			expectedLink:        `</users/?after=next&limit=2&role=Courier>; rel="next"`,
+			expectedRequestBody: `{"Data":[{"id":1,"email":"test@yande.ru","created_at":"20220311","role":"Courier","email_verified":false}],"next_cursor":"next"}`,
+		},
+		{
+			name:       "Both directions",
+			inputQuery: "?after=cursor&page=3",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().GetUsersPage(gomock.Any(), &model.RequestFilters{}, "", "cursor", "", 0).Return(&model.UsersPage{
+					Users:      []model.ResponseUser{},
+					NextCursor: "next",
+					PrevCursor: "prev",
+				}, nil)
+			},
+			expectedStatusCode:  200,
+			expectedLink:        `</users/?after=next>; rel="next", </users/?before=prev>; rel="prev"`,
+			expectedRequestBody: `{"Data":[],"next_cursor":"next","prev_cursor":"prev"}`,
+		},
+		{
+			name:       "Invalid cursor",
+			inputQuery: "?before=cursor",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().GetUsersPage(gomock.Any(), &model.RequestFilters{}, "", "", "cursor", 0).Return(nil, pkg.ErrorInvalidCursor)
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"invalid pagination cursor"}`,
+		},
+		{
+			name:       "Invalid sort",
+			inputQuery: "?limit=2&sort=-password",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().GetUsersPage(gomock.Any(), &model.RequestFilters{}, "-password", "", "", 2).Return(nil, pkg.ErrorInvalidSort)
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"invalid sort parameter"}`,
+		},
+		{
+			name:       "Server error",
+			inputQuery: "?limit=2",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().GetUsersPage(gomock.Any(), &model.RequestFilters{}, "", "", "", 2).Return(nil, fmt.Errorf("server error"))
+			},
+			expectedStatusCode:  500,
+			expectedRequestBody: `{"message":"server error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			getUsers := mock_service.NewMockAppUser(c)
+			getUsers.EXPECT().ParseToken(gomock.Any(), "testToken").Return(&authProto.UserRole{UserId: 1, Role: "Superadmin"}, nil)
+			getUsers.EXPECT().CheckRole([]string{"Superadmin"}, "Superadmin").Return(nil)
+			testCase.mockBehavior(getUsers)
+			logger := logging.GetLogger()
+			services := newTestService(c, getUsers)
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+
+			req := httptest.NewRequest("GET", fmt.Sprintf("/users/%s", testCase.inputQuery), nil)
+			req.Header.Set("Authorization", "Bearer testToken")
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedLink, w.Header().Get("Link"))
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
 func TestHandler_createCustomer(t *testing.T) {
 	type mockBehavior func(s *mock_service.MockAppUser, user model.CreateCustomer)
 	testTable := []struct {
@@ -467,7 +703,7 @@ func TestHandler_createCustomer(t *testing.T) {
 				Password: "HGYKnu!98Tg",
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, user model.CreateCustomer) {
-				s.EXPECT().CreateCustomer(&user).Return(&authProto.GeneratedTokens{
+				s.EXPECT().CreateCustomer(gomock.Any(), &user).Return(&authProto.GeneratedTokens{
 					AccessToken:  "qwerty",
 					RefreshToken: "qwerty",
 				}, 1, nil)
@@ -482,7 +718,7 @@ func TestHandler_createCustomer(t *testing.T) {
 				Email: "test@yandex.ru",
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, user model.CreateCustomer) {
-				s.EXPECT().CreateCustomer(&user).Return(&authProto.GeneratedTokens{
+				s.EXPECT().CreateCustomer(gomock.Any(), &user).Return(&authProto.GeneratedTokens{
 					AccessToken:  "qwerty",
 					RefreshToken: "qwerty",
 				}, 1, nil)
@@ -519,7 +755,7 @@ func TestHandler_createCustomer(t *testing.T) {
 				Password: "HGYKn!u98Tg",
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, user model.CreateCustomer) {
-				s.EXPECT().CreateCustomer(&user).Return(nil, 0, errors.New("server error"))
+				s.EXPECT().CreateCustomer(gomock.Any(), &user).Return(nil, 0, errors.New("server error"))
 			},
 			expectedStatusCode:  500,
 			expectedRequestBody: `{"message":"server error"}`,
@@ -583,7 +819,7 @@ func TestHandler_createStaff(t *testing.T) {
 	}{
 		{
 			name:      "OK",
-			inputBody: `{"email":"test@yandex.ru", "password":"HGYKnu!98Tg", "role":"Courier"}`,
+			inputBody: `{"email":"test@yandex.ru", "password":"HGYKnu!98Tg", "role":"Courier","email_verified":false}`,
 			inputUser: &model.CreateStaff{
 				Email:    "test@yandex.ru",
 				Password: "HGYKnu!98Tg",
@@ -592,7 +828,7 @@ func TestHandler_createStaff(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -602,17 +838,17 @@ func TestHandler_createStaff(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin", "Courier manager"}, role).Return(nil)
 			},
 			mockBehaviorCheckRole: func(s *mock_service.MockAppUser, role string) {
-				s.EXPECT().CheckInputRole(role).Return(nil)
+				s.EXPECT().CheckInputRole(gomock.Any(), role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, user *model.CreateStaff) {
-				s.EXPECT().CreateStaff(user).Return(1, nil)
+				s.EXPECT().CreateStaff(gomock.Any(), user).Return(1, nil)
 			},
 			expectedStatusCode:  201,
 			expectedRequestBody: `{"id":1}`,
 		},
 		{
 			name:      "OK(empty password)",
-			inputBody: `{"email":"test@yandex.ru", "role":"Courier"}`,
+			inputBody: `{"email":"test@yandex.ru", "role":"Courier","email_verified":false}`,
 			inputUser: &model.CreateStaff{
 				Email: "test@yandex.ru",
 				Role:  "Courier",
@@ -620,7 +856,7 @@ func TestHandler_createStaff(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -630,17 +866,17 @@ func TestHandler_createStaff(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin", "Courier manager"}, role).Return(nil)
 			},
 			mockBehaviorCheckRole: func(s *mock_service.MockAppUser, role string) {
-				s.EXPECT().CheckInputRole(role).Return(nil)
+				s.EXPECT().CheckInputRole(gomock.Any(), role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, user *model.CreateStaff) {
-				s.EXPECT().CreateStaff(user).Return(1, nil)
+				s.EXPECT().CreateStaff(gomock.Any(), user).Return(1, nil)
 			},
 			expectedStatusCode:  201,
 			expectedRequestBody: `{"id":1}`,
 		},
 		{
 			name:      "Invalid email",
-			inputBody: `{"email":"testyandex.ru", "role":"Courier"}`,
+			inputBody: `{"email":"testyandex.ru", "role":"Courier","email_verified":false}`,
 			inputUser: &model.CreateStaff{
 				Email: "test@yandex.ru",
 				Role:  "Courier",
@@ -648,7 +884,7 @@ func TestHandler_createStaff(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -664,7 +900,7 @@ func TestHandler_createStaff(t *testing.T) {
 		},
 		{
 			name:      "Invalid password",
-			inputBody: `{"email":"test@yandex.ru", "password":"HGYKnu98Tg", "role":"Courier"}`,
+			inputBody: `{"email":"test@yandex.ru", "password":"HGYKnu98Tg", "role":"Courier","email_verified":false}`,
 			inputUser: &model.CreateStaff{
 				Email:    "test@yandex.ru",
 				Password: "HGYKnu98Tg",
@@ -673,7 +909,7 @@ func TestHandler_createStaff(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -689,7 +925,7 @@ func TestHandler_createStaff(t *testing.T) {
 		},
 		{
 			name:      "Server error",
-			inputBody: `{"email":"test@yandex.ru", "password":"HGYKn!u98Tg", "role":"Courier"}`,
+			inputBody: `{"email":"test@yandex.ru", "password":"HGYKn!u98Tg", "role":"Courier","email_verified":false}`,
 			inputUser: &model.CreateStaff{
 				Email:    "test@yandex.ru",
 				Password: "HGYKn!u98Tg",
@@ -698,7 +934,7 @@ func TestHandler_createStaff(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -708,10 +944,10 @@ func TestHandler_createStaff(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin", "Courier manager"}, role).Return(nil)
 			},
 			mockBehaviorCheckRole: func(s *mock_service.MockAppUser, role string) {
-				s.EXPECT().CheckInputRole(role).Return(nil)
+				s.EXPECT().CheckInputRole(gomock.Any(), role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, user *model.CreateStaff) {
-				s.EXPECT().CreateStaff(user).Return(0, errors.New("server error"))
+				s.EXPECT().CreateStaff(gomock.Any(), user).Return(0, errors.New("server error"))
 			},
 			expectedStatusCode:  500,
 			expectedRequestBody: `{"message":"server error"}`,
@@ -727,7 +963,7 @@ func TestHandler_createStaff(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -737,7 +973,7 @@ func TestHandler_createStaff(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin", "Courier manager"}, role).Return(nil)
 			},
 			mockBehaviorCheckRole: func(s *mock_service.MockAppUser, role string) {
-				s.EXPECT().CheckInputRole(role).Return(errors.New("incorrect role came from the request"))
+				s.EXPECT().CheckInputRole(gomock.Any(), role).Return(errors.New("incorrect role came from the request"))
 			},
 			mockBehavior:        func(s *mock_service.MockAppUser, user *model.CreateStaff) {},
 			expectedStatusCode:  400,
@@ -752,7 +988,7 @@ func TestHandler_createStaff(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -779,7 +1015,7 @@ func TestHandler_createStaff(t *testing.T) {
 			testCase.mockBehaviorCheckRole(auth, testCase.inputUser.Role)
 			testCase.mockBehavior(auth, testCase.inputUser)
 			logger := logging.GetLogger()
-			services := &service.Service{AppUser: auth}
+			services := newTestService(c, auth)
 			handler := NewHandler(logger, services)
 
 			//Init server
@@ -830,7 +1066,7 @@ func TestHandler_updateUser(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -840,7 +1076,7 @@ func TestHandler_updateUser(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin", "Authorized Customer", "Courier", "Courier manager", "Restaurant manager"}, role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, user model.UpdateUser) {
-				s.EXPECT().UpdateUser(&user).Return(nil)
+				s.EXPECT().UpdateUser(gomock.Any(), &user).Return(nil)
 			},
 			expectedStatusCode: 204,
 		},
@@ -850,7 +1086,7 @@ func TestHandler_updateUser(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -869,7 +1105,7 @@ func TestHandler_updateUser(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -894,7 +1130,7 @@ func TestHandler_updateUser(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -904,7 +1140,7 @@ func TestHandler_updateUser(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin", "Authorized Customer", "Courier", "Courier manager", "Restaurant manager"}, role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, user model.UpdateUser) {
-				s.EXPECT().UpdateUser(&user).Return(errors.New("server error"))
+				s.EXPECT().UpdateUser(gomock.Any(), &user).Return(errors.New("server error"))
 			},
 			expectedStatusCode:  500,
 			expectedRequestBody: `{"message":"server error"}`,
@@ -921,7 +1157,7 @@ func TestHandler_updateUser(t *testing.T) {
 			testCase.mockBehaviorCheck(auth, testCase.inputRole)
 			testCase.mockBehavior(auth, testCase.inputUser)
 			logger := logging.GetLogger()
-			services := &service.Service{AppUser: auth}
+			services := newTestService(c, auth)
 			handler := NewHandler(logger, services)
 
 			//Init server
@@ -967,7 +1203,7 @@ func TestHandler_deleteUser(t *testing.T) {
 			inputRole:  "Courier",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Courier",
 					Permissions: "",
@@ -977,7 +1213,7 @@ func TestHandler_deleteUser(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin", "Courier manager"}, role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, id int) {
-				s.EXPECT().DeleteUserByID(id).Return(1, nil)
+				s.EXPECT().DeleteUserByID(gomock.Any(), id).Return(1, nil)
 			},
 			expectedStatusCode:  200,
 			expectedRequestBody: `{"id":1}`,
@@ -988,7 +1224,7 @@ func TestHandler_deleteUser(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -1008,7 +1244,7 @@ func TestHandler_deleteUser(t *testing.T) {
 			inputRole:  "Superadmin",
 			inputToken: "testToken",
 			mockBehaviorParseToken: func(s *mock_service.MockAppUser, token string) {
-				s.EXPECT().ParseToken(token).Return(&authProto.UserRole{
+				s.EXPECT().ParseToken(gomock.Any(), token).Return(&authProto.UserRole{
 					UserId:      1,
 					Role:        "Superadmin",
 					Permissions: "",
@@ -1018,7 +1254,7 @@ func TestHandler_deleteUser(t *testing.T) {
 				s.EXPECT().CheckRole([]string{"Superadmin", "Courier manager"}, role).Return(nil)
 			},
 			mockBehavior: func(s *mock_service.MockAppUser, id int) {
-				s.EXPECT().DeleteUserByID(id).Return(0, errors.New("server error"))
+				s.EXPECT().DeleteUserByID(gomock.Any(), id).Return(0, errors.New("server error"))
 			},
 			expectedStatusCode:  500,
 			expectedRequestBody: `{"message":"server error"}`,
@@ -1035,7 +1271,7 @@ func TestHandler_deleteUser(t *testing.T) {
 			testCase.mockBehaviorCheck(auth, testCase.inputRole)
 			testCase.mockBehavior(auth, testCase.id)
 			logger := logging.GetLogger()
-			services := &service.Service{AppUser: auth}
+			services := newTestService(c, auth)
 			handler := NewHandler(logger, services)
 
 			//Init server
@@ -1071,7 +1307,7 @@ func TestHandler_restorePassword(t *testing.T) {
 			inputBody:  `{"email":"test@yandex.ru"}`,
 			inputEmail: "test@yandex.ru",
 			mockBehavior: func(s *mock_service.MockAppUser, email string) {
-				s.EXPECT().RestorePassword(&model.RestorePassword{
+				s.EXPECT().RestorePassword(gomock.Any(), &model.RestorePassword{
 					Email: email,
 				}).Return(nil)
 			},
@@ -1090,7 +1326,7 @@ func TestHandler_restorePassword(t *testing.T) {
 			inputBody:  `{"email":"test@yandex.ru"}`,
 			inputEmail: "test@yandex.ru",
 			mockBehavior: func(s *mock_service.MockAppUser, email string) {
-				s.EXPECT().RestorePassword(&model.RestorePassword{
+				s.EXPECT().RestorePassword(gomock.Any(), &model.RestorePassword{
 					Email: email,
 				}).Return(errors.New("server error"))
 			},
@@ -1102,12 +1338,11 @@ func TestHandler_restorePassword(t *testing.T) {
 			inputBody:  `{"email":"test@yandex.ru"}`,
 			inputEmail: "test@yandex.ru",
 			mockBehavior: func(s *mock_service.MockAppUser, email string) {
-				s.EXPECT().RestorePassword(&model.RestorePassword{
+				s.EXPECT().RestorePassword(gomock.Any(), &model.RestorePassword{
 					Email: email,
 				}).Return(pkg.ErrorEmailDoesNotExist)
 			},
-			expectedStatusCode:  400,
-			expectedRequestBody: `{"message":"user with this email does not exist"}`,
+			expectedStatusCode: 204,
 		},
 	}
 
@@ -1119,7 +1354,7 @@ func TestHandler_restorePassword(t *testing.T) {
 			auth := mock_service.NewMockAppUser(c)
 			testCase.mockBehavior(auth, testCase.inputEmail)
 			logger := logging.GetLogger()
-			services := &service.Service{AppUser: auth}
+			services := newTestService(c, auth)
 			handler := NewHandler(logger, services)
 
 			//Init server
@@ -1139,3 +1374,428 @@ func TestHandler_restorePassword(t *testing.T) {
 	}
 
 }
+
+func TestHandler_resetPassword(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser)
+	testTable := []struct {
+		name                string
+		inputBody           string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:      "OK",
+			inputBody: `{"token":"token","password":"HGYKnu!98Tg"}`,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().ResetPassword(gomock.Any(), &model.ResetPassword{
+					Token:    "token",
+					Password: "HGYKnu!98Tg",
+				}).Return(nil)
+			},
+			expectedStatusCode: 204,
+		},
+		{
+			name:                "missing token",
+			inputBody:           `{"password":"HGYKnu!98Tg"}`,
+			mockBehavior:        func(s *mock_service.MockAppUser) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"invalid request"}`,
+		},
+		{
+			name:                "weak password",
+			inputBody:           `{"token":"token","password":"qwerty"}`,
+			mockBehavior:        func(s *mock_service.MockAppUser) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"Password":"passwordValidator: the length of the password should be between 8 to 15 characters"}`,
+		},
+		{
+			name:      "invalid token",
+			inputBody: `{"token":"token","password":"HGYKnu!98Tg"}`,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().ResetPassword(gomock.Any(), &model.ResetPassword{
+					Token:    "token",
+					Password: "HGYKnu!98Tg",
+				}).Return(pkg.ErrorInvalidResetToken)
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"password reset token is invalid or expired"}`,
+		},
+		{
+			name:      "Server error",
+			inputBody: `{"token":"token","password":"HGYKnu!98Tg"}`,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().ResetPassword(gomock.Any(), &model.ResetPassword{
+					Token:    "token",
+					Password: "HGYKnu!98Tg",
+				}).Return(errors.New("server error"))
+			},
+			expectedStatusCode:  500,
+			expectedRequestBody: `{"message":"server error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			testCase.mockBehavior(auth)
+			logger := logging.GetLogger()
+			services := newTestService(c, auth)
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/users/resetPassword", bytes.NewBufferString(testCase.inputBody))
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_verifyEmail(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser)
+	testTable := []struct {
+		name                string
+		query               string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:  "OK",
+			query: "?token=token",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().VerifyEmail(gomock.Any(), "token").Return(1, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"id":1}`,
+		},
+		{
+			name:                "Empty token",
+			query:               "",
+			mockBehavior:        func(s *mock_service.MockAppUser) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"invalid request"}`,
+		},
+		{
+			name:  "Invalid token",
+			query: "?token=token",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().VerifyEmail(gomock.Any(), "token").Return(0, pkg.ErrorInvalidVerification)
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"email verification link is invalid or expired"}`,
+		},
+		{
+			name:  "Server error",
+			query: "?token=token",
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().VerifyEmail(gomock.Any(), "token").Return(0, errors.New("server error"))
+			},
+			expectedStatusCode:  500,
+			expectedRequestBody: `{"message":"server error"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			testCase.mockBehavior(auth)
+			logger := logging.GetLogger()
+			services := newTestService(c, auth)
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/users/verify"+testCase.query, nil)
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_resendVerification(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser)
+	testTable := []struct {
+		name                string
+		inputBody           string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:      "OK",
+			inputBody: `{"email":"test@yandex.ru"}`,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().ResendVerification(gomock.Any(), "test@yandex.ru").Return(nil)
+			},
+			expectedStatusCode: 204,
+		},
+		{
+			name:                "incorrect email",
+			inputBody:           `{"email":"testyandex.ru"}`,
+			mockBehavior:        func(s *mock_service.MockAppUser) {},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"Email":"emailValidator: it is not a valid email address"}`,
+		},
+		{
+			name:      "non-existent user",
+			inputBody: `{"email":"test@yandex.ru"}`,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().ResendVerification(gomock.Any(), "test@yandex.ru").Return(pkg.ErrorEmailDoesNotExist)
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"user with this email does not exist"}`,
+		},
+		{
+			name:      "already verified",
+			inputBody: `{"email":"test@yandex.ru"}`,
+			mockBehavior: func(s *mock_service.MockAppUser) {
+				s.EXPECT().ResendVerification(gomock.Any(), "test@yandex.ru").Return(pkg.ErrorEmailVerified)
+			},
+			expectedStatusCode:  409,
+			expectedRequestBody: `{"message":"email is already verified"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			testCase.mockBehavior(auth)
+			logger := logging.GetLogger()
+			services := newTestService(c, auth)
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/users/verify/resend", bytes.NewBufferString(testCase.inputBody))
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_unlockUser(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser, id int)
+	testTable := []struct {
+		name                string
+		input               string
+		id                  int
+		role                string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:  "OK",
+			input: "1",
+			id:    1,
+			role:  "Superadmin",
+			mockBehavior: func(s *mock_service.MockAppUser, id int) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, "Superadmin").Return(nil)
+				s.EXPECT().UnlockUser(gomock.Any(), id).Return(1, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"id":1}`,
+		},
+		{
+			name:  "Not enough rights",
+			input: "1",
+			role:  "Courier",
+			mockBehavior: func(s *mock_service.MockAppUser, id int) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, "Courier").Return(errors.New("not enough rights"))
+			},
+			expectedStatusCode:  401,
+			expectedRequestBody: `{"message":"not enough rights"}`,
+		},
+		{
+			name:  "Invalid id",
+			input: "a",
+			role:  "Superadmin",
+			mockBehavior: func(s *mock_service.MockAppUser, id int) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, "Superadmin").Return(nil)
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"Invalid id"}`,
+		},
+		{
+			name:  "Not found",
+			input: "1",
+			id:    1,
+			role:  "Superadmin",
+			mockBehavior: func(s *mock_service.MockAppUser, id int) {
+				s.EXPECT().CheckRole([]string{"Superadmin"}, "Superadmin").Return(nil)
+				s.EXPECT().UnlockUser(gomock.Any(), id).Return(0, fmt.Errorf("unlockUser:%w", pkg.ErrorUserNotFound))
+			},
+			expectedStatusCode:  404,
+			expectedRequestBody: `{"message":"user not found"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			auth.EXPECT().ParseToken(gomock.Any(), "testToken").Return(&authProto.UserRole{
+				UserId: 1,
+				Role:   testCase.role,
+			}, nil)
+			testCase.mockBehavior(auth, testCase.id)
+			logger := logging.GetLogger()
+			services := newTestService(c, auth)
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", fmt.Sprintf("/users/%s/unlock", testCase.input), nil)
+			req.Header.Set("Authorization", "Bearer testToken")
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_restoreUser(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockAppUser, id int)
+	testTable := []struct {
+		name                string
+		input               string
+		id                  int
+		role                string
+		mockBehavior        mockBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:  "OK",
+			input: "1",
+			id:    1,
+			role:  "Courier manager",
+			mockBehavior: func(s *mock_service.MockAppUser, id int) {
+				s.EXPECT().CheckRole([]string{"Superadmin", "Courier manager"}, "Courier manager").Return(nil)
+				s.EXPECT().RestoreUser(gomock.Any(), id).Return(1, nil)
+			},
+			expectedStatusCode:  200,
+			expectedRequestBody: `{"id":1}`,
+		},
+		{
+			name:  "Not enough rights",
+			input: "1",
+			role:  "Courier",
+			mockBehavior: func(s *mock_service.MockAppUser, id int) {
+				s.EXPECT().CheckRole([]string{"Superadmin", "Courier manager"}, "Courier").Return(errors.New("not enough rights"))
+			},
+			expectedStatusCode:  401,
+			expectedRequestBody: `{"message":"not enough rights"}`,
+		},
+		{
+			name:  "Invalid id",
+			input: "0",
+			role:  "Superadmin",
+			mockBehavior: func(s *mock_service.MockAppUser, id int) {
+				s.EXPECT().CheckRole([]string{"Superadmin", "Courier manager"}, "Superadmin").Return(nil)
+			},
+			expectedStatusCode:  400,
+			expectedRequestBody: `{"message":"Invalid id"}`,
+		},
+		{
+			name:  "Not found",
+			input: "1",
+			id:    1,
+			role:  "Superadmin",
+			mockBehavior: func(s *mock_service.MockAppUser, id int) {
+				s.EXPECT().CheckRole([]string{"Superadmin", "Courier manager"}, "Superadmin").Return(nil)
+				s.EXPECT().RestoreUser(gomock.Any(), id).Return(0, fmt.Errorf("restoreUser:%w", pkg.ErrorUserNotFound))
+			},
+			expectedStatusCode:  404,
+			expectedRequestBody: `{"message":"user not found"}`,
+		},
+		{
+			name:  "Already active",
+			input: "1",
+			id:    1,
+			role:  "Superadmin",
+			mockBehavior: func(s *mock_service.MockAppUser, id int) {
+				s.EXPECT().CheckRole([]string{"Superadmin", "Courier manager"}, "Superadmin").Return(nil)
+				s.EXPECT().RestoreUser(gomock.Any(), id).Return(0, fmt.Errorf("restoreUser:%w", pkg.ErrorUserActive))
+			},
+			expectedStatusCode:  409,
+			expectedRequestBody: `{"message":"user is already active"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_service.NewMockAppUser(c)
+			auth.EXPECT().ParseToken(gomock.Any(), "testToken").Return(&authProto.UserRole{
+				UserId: 1,
+				Role:   testCase.role,
+			}, nil)
+			testCase.mockBehavior(auth, testCase.id)
+			logger := logging.GetLogger()
+			services := newTestService(c, auth)
+			handler := NewHandler(logger, services)
+
+			//Init server
+			r := handler.InitRoutes()
+
+			//Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", fmt.Sprintf("/users/%s/restore", testCase.input), nil)
+			req.Header.Set("Authorization", "Bearer testToken")
+
+			//Execute the request
+			r.ServeHTTP(w, req)
+
+			//Assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
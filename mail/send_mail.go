@@ -1,32 +1,111 @@
 package mail
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/smtp"
-	"os"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/metrics"
 	"strings"
+	"sync"
 )
 
-const (
-	HOST    = "smtp.gmail.com"
-	PORT    = "587"
-	SUBJECT = "Food Delivery"
-)
+const SUBJECT = "Food Delivery"
 
-func SendEmail(logger logging.Logger, post *model.Post) {
-	auth := smtp.PlainAuth("", os.Getenv("POST_FROM"), os.Getenv("POST_PASSWORD"), HOST)
-	from := os.Getenv("POST_FROM")
-	smtpHost := HOST
-	smtpPort := PORT
+// Config is the SMTP server and the account the emails are sent from
+type Config struct {
+	Host     string
+	Port     string
+	From     string
+	Password string
+}
 
+func (c Config) withDefaults() Config {
+	if c.Host == "" {
+		c.Host = "smtp.gmail.com"
+	}
+	if c.Port == "" {
+		c.Port = "587"
+	}
+	return c
+}
+
+// Mailer sends the emails in the background, Wait lets them be delivered before the service stops
+type Mailer struct {
+	logger  logging.Logger
+	cfg     Config
+	sending sync.WaitGroup
+}
+
+func NewMailer(logger logging.Logger, cfg Config) *Mailer {
+	return &Mailer{logger: logger, cfg: cfg.withDefaults()}
+}
+
+func (m *Mailer) SendEmail(post *model.Post) {
 	msg := fmt.Sprintf("Уважаемый клиент, Ваш текущий пароль: %s.", post.Password)
-	message := strings.Replace("From: "+from+"~To: "+post.Email+"~Subject: "+SUBJECT+"~~", "~", "\r\n", -1) + msg
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{post.Email}, []byte(message))
+	m.send(post.Email, msg)
+}
+
+// SendPasswordResetEmail mails the single-use link for choosing a new password
+func (m *Mailer) SendPasswordResetEmail(post *model.Post) {
+	msg := fmt.Sprintf("Уважаемый клиент, для восстановления пароля перейдите по ссылке: %s. "+
+		"Если Вы не запрашивали восстановление пароля, проигнорируйте это письмо.", post.Link)
+	m.send(post.Email, msg)
+}
+
+// SendVerificationEmail mails the link confirming the address of a new customer
+func (m *Mailer) SendVerificationEmail(post *model.Post) {
+	msg := fmt.Sprintf("Уважаемый клиент, для подтверждения адреса электронной почты перейдите по ссылке: %s.", post.Link)
+	m.send(post.Email, msg)
+}
+
+// SendTwoFactorEnrollmentEmail mails the single-use link for enrolling in two-factor authentication
+func (m *Mailer) SendTwoFactorEnrollmentEmail(post *model.Post) {
+	msg := fmt.Sprintf("Уважаемый клиент, для входа необходимо подключить двухфакторную аутентификацию, перейдите по ссылке: %s. "+
+		"Если Вы не входили в систему, смените пароль.", post.Link)
+	m.send(post.Email, msg)
+}
+
+// Wait blocks until the emails being sent are delivered or ctx is done
+func (m *Mailer) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.sending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// send returns at once, the email is delivered in the background
+func (m *Mailer) send(to string, msg string) {
+	m.sending.Add(1)
+	metrics.PendingEmails.Inc()
+	go func() {
+		defer m.sending.Done()
+		defer metrics.PendingEmails.Dec()
+		m.deliver(to, msg)
+	}()
+}
+
+// deliver skips the email when there is no account to send it from
+func (m *Mailer) deliver(to string, msg string) {
+	if m.cfg.From == "" {
+		m.logger.Warnf("Email for %s is not sent, the sender is not configured", to)
+		return
+	}
+	auth := smtp.PlainAuth("", m.cfg.From, m.cfg.Password, m.cfg.Host)
+	message := strings.Replace("From: "+m.cfg.From+"~To: "+to+"~Subject: "+SUBJECT+"~~", "~", "\r\n", -1) + msg
+	err := smtp.SendMail(net.JoinHostPort(m.cfg.Host, m.cfg.Port), auth, m.cfg.From, []string{to}, []byte(message))
 	if err != nil {
-		logger.Errorf("Error while sending email to %s:%s", post.Email, err)
+		m.logger.Errorf("Error while sending email to %s:%s", to, err)
 		return
 	}
-	logger.Infof("Email for %s Sent Successfully!", post.Email)
+	m.logger.Infof("Email for %s Sent Successfully!", to)
 }
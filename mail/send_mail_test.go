@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"testing"
+	"time"
+)
+
+func TestMailer_Wait(t *testing.T) {
+	//Init dependencies
+	// the SMTP server accepts the connection and never answers until it is closed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	mailer := NewMailer(logging.GetLogger(), Config{Host: host, Port: port, From: "food@delivery.com"})
+
+	mailer.SendEmail(&model.Post{Email: "test@yandex.ru", Password: "password"})
+	//Assert
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, mailer.Wait(ctx))
+
+	// the email fails once the server goes away, nothing is left to wait for
+	(<-accepted).Close()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, mailer.Wait(ctx))
+}
+
+func TestMailer_Wait_noSender(t *testing.T) {
+	mailer := NewMailer(logging.GetLogger(), Config{})
+
+	mailer.SendPasswordResetEmail(&model.Post{Email: "test@yandex.ru", Link: "link"})
+	//Assert
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, mailer.Wait(ctx))
+}
@@ -0,0 +1,112 @@
+package model
+
+import "time"
+
+// Actions recorded in the audit log
+const (
+	AuditStaffCreated           = "staff.created"
+	AuditUserDeleted            = "user.deleted"
+	AuditUserUnlocked           = "user.unlocked"
+	AuditUserRestored           = "user.restored"
+	AuditPasswordResetRequested = "password.reset_requested"
+	AuditPasswordReset          = "password.reset"
+	AuditPasswordChanged        = "password.changed"
+	AuditUserLocked             = "user.locked"
+	AuditTokensRevoked          = "tokens.revoked"
+	AuditTwoFactorEnabled       = "two_factor.enabled"
+	AuditTwoFactorDisabled      = "two_factor.disabled"
+	AuditTwoFactorRolesSet      = "two_factor.roles_set"
+)
+
+// AuditRedacted stands for a value which is changed but never recorded, like a password
+const AuditRedacted = "[REDACTED]"
+
+// AuditActor is who makes the change and where the request comes from,
+// ID is zero for the requests made without a token
+type AuditActor struct {
+	ID        int
+	Role      string
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// AuditChange is the value of a field before and after the change, a missing
+// one means the field did not exist or was removed
+type AuditChange struct {
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// AuditEvent is an entry of the append-only audit log. Hash covers the event and
+// PrevHash, the hash of the previous entry, so that the entries form a chain.
+type AuditEvent struct {
+	ID        int64                  `json:"id"`
+	CreatedAt time.Time              `json:"created_at"`
+	ActorID   int                    `json:"actor_id,omitempty"`
+	ActorRole string                 `json:"actor_role,omitempty"`
+	Action    string                 `json:"action"`
+	TargetID  int                    `json:"target_id,omitempty"`
+	IP        string                 `json:"ip,omitempty"`
+	UserAgent string                 `json:"user_agent,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Diff      map[string]AuditChange `json:"diff"`
+	PrevHash  string                 `json:"prev_hash,omitempty"`
+	Hash      string                 `json:"hash,omitempty"`
+}
+
+// AuditFilters narrow the audit log, zero values match every event. The time
+// range includes From and excludes To.
+type AuditFilters struct {
+	ActorID  int       `form:"actor_id" binding:"omitempty,min=1"`
+	TargetID int       `form:"target_id" binding:"omitempty,min=1"`
+	Action   string    `form:"action"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// AuditPage is a page of the audit log, the newest events go first
+type AuditPage struct {
+	Events []AuditEvent
+	Total  int
+	Page   int
+	Limit  int
+	Pages  int
+}
+
+// AuditCheckpoint is a signed head of the chain, the event with EventID has Hash
+type AuditCheckpoint struct {
+	ID        int64     `json:"id"`
+	EventID   int64     `json:"event_id"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	Signature string    `json:"signature"`
+}
+
+// AuditExport is a signed range of the chain for external archiving. The events
+// continue the chain after PrevHash and end with Hash. The signature is made with
+// the Ed25519 audit key, it is checked with the public key kept by the archive.
+type AuditExport struct {
+	FromID     int64        `json:"from_id"`
+	ToID       int64        `json:"to_id"`
+	PrevHash   string       `json:"prev_hash"`
+	Hash       string       `json:"hash"`
+	Events     []AuditEvent `json:"events"`
+	ExportedAt time.Time    `json:"exported_at"`
+	Signature  string       `json:"signature"`
+}
+
+// AuditVerification is the outcome of walking the chain. Unchained events were
+// recorded before the chain was introduced, Break is the first one found if any.
+type AuditVerification struct {
+	Events            int
+	Unchained         int
+	Checkpoints       int
+	SignaturesChecked bool
+	Break             *AuditBreak
+}
+
+type AuditBreak struct {
+	EventID int64
+	Reason  string
+}
@@ -1,11 +1,36 @@
 package model
 
+import "time"
+
 type AuthUser struct {
 	Email    string `json:"email" binding:"required" validate:"email"`
 	Password string `json:"password" binding:"required" validate:"password"`
 }
 
 type RestorePassword struct {
-	Email    string `json:"email" binding:"required" validate:"email"`
-	Password string
+	Email string `json:"email" binding:"required" validate:"email"`
+}
+
+type ResendVerification struct {
+	Email string `json:"email" binding:"required" validate:"email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required" validate:"password"`
+}
+
+type RefreshToken struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type Logout struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	ResetAt   time.Time
 }
@@ -49,10 +49,28 @@ func (c MyTime) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf(`"%s"`, c.Time.Format(Layout))), nil
 }
 
+const (
+	DeletedActive = "active"
+	DeletedOnly   = "deleted"
+	DeletedAll    = "all"
+)
+
+// RequestFilters are combined with AND, every one of them is optional.
+// Roles may be repeated or comma separated, Email matches a part of the address
+// and EmailDomain the part after "@". Query is a search by a part of the email
+// that ranks exact and prefix matches first unless another sort is asked for.
+// StartTime and EndTime bound the registration date inclusively, FilterData is
+// kept for old clients and is no longer needed.
+// Deleted is one of active (the default), deleted or all, ShowDeleted is the old
+// spelling of all.
 type RequestFilters struct {
-	ShowDeleted bool   `form:"show_deleted,omitempty"`
-	FilterData  bool   `form:"filter_data,omitempty"`
-	StartTime   MyTime `form:"start_time,omitempty"`
-	EndTime     MyTime `form:"end_time,omitempty"`
-	Role        string `form:"role,omitempty"`
+	ShowDeleted bool     `form:"show_deleted,omitempty"`
+	Deleted     string   `form:"deleted,omitempty" binding:"omitempty,oneof=active deleted all"`
+	FilterData  bool     `form:"filter_data,omitempty"`
+	StartTime   MyTime   `form:"start_time,omitempty"`
+	EndTime     MyTime   `form:"end_time,omitempty"`
+	Roles       []string `form:"role,omitempty"`
+	Email       string   `form:"email,omitempty"`
+	EmailDomain string   `form:"email_domain,omitempty"`
+	Query       string   `form:"q,omitempty"`
 }
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// UserSortColumns are the columns the user list can be sorted by
+var UserSortColumns = map[string]bool{
+	"id":         true,
+	"email":      true,
+	"role":       true,
+	"created_at": true,
+}
+
+// SortRank orders the search results by SearchRank, it is used instead of a column
+// when the list is searched and no sort is given
+const SortRank = "rank"
+
+// SearchRank is 0 for an exact match of the email, 1 for a prefix and 2 for any other part
+func SearchRank(email string, query string) int {
+	email, query = strings.ToLower(email), strings.ToLower(query)
+	switch {
+	case email == query:
+		return 0
+	case strings.HasPrefix(email, query):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// SortField is one column of a sort like sort=created_at,-email
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// UserCursor is the position of a user in the list, clients only see it encoded.
+// Besides the id it keeps the values of the sort columns, the sort itself and a
+// hash of the search, so that a cursor can not be used with another order or search.
+type UserCursor struct {
+	ID        int        `json:"id"`
+	Email     string     `json:"email,omitempty"`
+	Role      string     `json:"role,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Rank      *int       `json:"rank,omitempty"`
+	Sort      string     `json:"sort,omitempty"`
+	Query     string     `json:"q,omitempty"`
+}
+
+// Value returns the value of a sort column at the cursor
+func (c *UserCursor) Value(column string) interface{} {
+	switch column {
+	case "email":
+		return c.Email
+	case "role":
+		return c.Role
+	case "created_at":
+		if c.CreatedAt == nil {
+			return time.Time{}
+		}
+		return *c.CreatedAt
+	case SortRank:
+		if c.Rank == nil {
+			return 0
+		}
+		return *c.Rank
+	default:
+		return c.ID
+	}
+}
+
+// CursorQuery asks for Limit users following Cursor, or preceding it when Backward
+// is set. A nil Cursor starts from the beginning (or the end) of the list.
+type CursorQuery struct {
+	Cursor   *UserCursor
+	Backward bool
+	Limit    int
+	Sort     []SortField
+}
+
+// UsersPage is a page of the user list. Cursor pages fill the cursors, a cursor is
+// empty when there is nothing in that direction. Offset pages fill Total, Page,
+// Limit and Pages instead.
+type UsersPage struct {
+	Users      []ResponseUser
+	NextCursor string
+	PrevCursor string
+	Total      int
+	Page       int
+	Limit      int
+	Pages      int
+}
@@ -3,4 +3,5 @@ package model
 type Post struct {
 	Email    string
 	Password string
+	Link     string
 }
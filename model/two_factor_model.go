@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+// TwoFactor is the two-factor authentication state of a user
+type TwoFactor struct {
+	UserID              int
+	Email               string
+	Role                string
+	Deleted             bool
+	Secret              string
+	Enabled             bool
+	FailedLoginAttempts int
+	LockoutCount        int
+	LockedUntil         *time.Time
+}
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorChallenge struct {
+	Challenge string    `json:"challenge"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type TwoFactorEnrollmentLink struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// TwoFactorEnrollment is opened with the emailed link, the challenge is finished at
+// /users/login/2fa with a code of the new secret
+type TwoFactorEnrollment struct {
+	Challenge       string    `json:"challenge"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	Secret          string    `json:"secret"`
+	ProvisioningURI string    `json:"provisioning_uri"`
+}
+
+type TwoFactorLogin struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required"`
+}
+
+type TwoFactorCode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorTokens carries recovery codes only when the login finished an enrollment
+type TwoFactorTokens struct {
+	AccessToken   string   `json:"accessToken"`
+	RefreshToken  string   `json:"refreshToken"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type TwoFactorRoles struct {
+	Roles []string `json:"roles" binding:"required"`
+}
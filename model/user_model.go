@@ -1,11 +1,18 @@
 package model
 
+import "time"
+
 type User struct {
-	ID       int    `json:"id"`
-	Email    string `json:"email" `
-	Password string `json:"password"`
-	Role     string `json:"role"`
-	Deleted  bool   `json:"deleted"`
+	ID                  int        `json:"id"`
+	Email               string     `json:"email" `
+	Password            string     `json:"password"`
+	Role                string     `json:"role"`
+	Deleted             bool       `json:"deleted"`
+	EmailVerified       bool       `json:"email_verified"`
+	TOTPEnabled         bool       `json:"totp_enabled"`
+	FailedLoginAttempts int        `json:"failed_login_attempts"`
+	LockoutCount        int        `json:"lockout_count"`
+	LockedUntil         *time.Time `json:"locked_until"`
 }
 
 type CreateStaff struct {
@@ -31,10 +38,13 @@ type UpdateUser struct {
 	NewPassword string `json:"new_password" binding:"required" validate:"password"`
 }
 type ResponseUser struct {
-	ID        int    `json:"id"`
-	Email     string `json:"email"`
-	CreatedAt MyTime `json:"created_at"`
-	Role      string `json:"role"`
+	ID            int    `json:"id"`
+	Email         string `json:"email"`
+	CreatedAt     MyTime `json:"created_at"`
+	Role          string `json:"role"`
+	EmailVerified bool   `json:"email_verified"`
+	// Deleted is only read by GetUserByID, it is left out of the HTTP answers
+	Deleted bool `json:"-"`
 }
 
 type MockUser struct {
@@ -64,3 +74,8 @@ var PasswordComposition = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
 type ErrorResponse struct {
 	Message string `json:"message"`
 }
+
+type LockedResponse struct {
+	Message     string    `json:"message"`
+	LockedUntil time.Time `json:"locked_until"`
+}
@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hashed is the form of the event the hash is taken of, the diff is decoded into
+// plain values first so that it is the same before and after Postgres stores it
+type hashed struct {
+	PrevHash  string      `json:"prev_hash"`
+	CreatedAt string      `json:"created_at"`
+	ActorID   int         `json:"actor_id"`
+	ActorRole string      `json:"actor_role"`
+	Action    string      `json:"action"`
+	TargetID  int         `json:"target_id"`
+	IP        string      `json:"ip"`
+	UserAgent string      `json:"user_agent"`
+	RequestID string      `json:"request_id"`
+	Diff      interface{} `json:"diff"`
+}
+
+// Hash returns the hex SHA-256 of the event chained to its PrevHash. The id is
+// left out, the order of the events is kept by the chain itself.
+func Hash(event *model.AuditEvent) (string, error) {
+	diff, err := json.Marshal(event.Diff)
+	if err != nil {
+		return "", fmt.Errorf("hash:%w", err)
+	}
+	var plain interface{}
+	if err = json.Unmarshal(diff, &plain); err != nil {
+		return "", fmt.Errorf("hash:%w", err)
+	}
+	data, err := json.Marshal(hashed{
+		PrevHash:  event.PrevHash,
+		CreatedAt: event.CreatedAt.UTC().Format(time.RFC3339Nano),
+		ActorID:   event.ActorID,
+		ActorRole: event.ActorRole,
+		Action:    event.Action,
+		TargetID:  event.TargetID,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		RequestID: event.RequestID,
+		Diff:      plain,
+	})
+	if err != nil {
+		return "", fmt.Errorf("hash:%w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Chain checks the events one by one in the order of their ids. The events
+// without a hash are accepted only before the first chained one.
+type Chain struct {
+	head    string
+	started bool
+}
+
+// Next checks that the event continues the chain and moves the head to it
+func (c *Chain) Next(event *model.AuditEvent) error {
+	if event.Hash == "" && !c.started {
+		return nil
+	}
+	if event.PrevHash != c.head {
+		return errors.New("previous hash does not match, an event before it is changed or removed")
+	}
+	hash, err := Hash(event)
+	if err != nil {
+		return err
+	}
+	if hash != event.Hash {
+		return errors.New("hash does not match, the event is changed")
+	}
+	c.head, c.started = event.Hash, true
+	return nil
+}
+
+// Signer signs checkpoints and exports of the chain with an Ed25519 key
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewSigner reads a PEM encoded PKCS #8 Ed25519 private key, like the one made by
+// openssl genpkey -algorithm ed25519
+func NewSigner(data []byte) (*Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("newSigner: private key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("newSigner: parse private key:%w", err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("newSigner: private key of type %T is not an Ed25519 one", key)
+	}
+	return &Signer{key: edKey}, nil
+}
+
+// GenerateSigner returns a signer with a new key, its signatures can not be checked after a restart
+func GenerateSigner() *Signer {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	return &Signer{key: key}
+}
+
+// Verifier returns the verifier of the public part of the key
+func (s *Signer) Verifier() *Verifier {
+	return &Verifier{key: s.key.Public().(ed25519.PublicKey)}
+}
+
+func (s *Signer) sign(payload string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, []byte(payload)))
+}
+
+// SignCheckpoint sets the signature of the checkpoint
+func (s *Signer) SignCheckpoint(checkpoint *model.AuditCheckpoint) {
+	checkpoint.Signature = s.sign(CheckpointPayload(checkpoint))
+}
+
+// SignExport sets the signature of the export
+func (s *Signer) SignExport(export *model.AuditExport) {
+	export.Signature = s.sign(ExportPayload(export))
+}
+
+// Verifier checks checkpoints and exports of the chain with an Ed25519 public
+// key, which is given to it separately and never taken from what it checks
+type Verifier struct {
+	key ed25519.PublicKey
+}
+
+// NewVerifier reads a PEM encoded PKIX Ed25519 public key, like the one made by
+// openssl pkey -pubout from the signing key
+func NewVerifier(data []byte) (*Verifier, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("newVerifier: public key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("newVerifier: parse public key:%w", err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("newVerifier: public key of type %T is not an Ed25519 one", key)
+	}
+	return &Verifier{key: edKey}, nil
+}
+
+// PublicKey returns the base64 encoded public key the signatures are checked with
+func (v *Verifier) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(v.key)
+}
+
+func (v *Verifier) verify(payload string, signature string) bool {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	return err == nil && ed25519.Verify(v.key, []byte(payload), sig)
+}
+
+// VerifyCheckpoint tells whether the checkpoint is signed with the key
+func (v *Verifier) VerifyCheckpoint(checkpoint *model.AuditCheckpoint) bool {
+	return v.verify(CheckpointPayload(checkpoint), checkpoint.Signature)
+}
+
+// VerifyExport checks that the export is signed with the key and that its
+// events are in the requested range and chained from PrevHash up to Hash
+func (v *Verifier) VerifyExport(export *model.AuditExport) error {
+	if !v.verify(ExportPayload(export), export.Signature) {
+		return errors.New("signature of the export is invalid")
+	}
+	chain := Chain{head: export.PrevHash, started: export.PrevHash != ""}
+	for i := range export.Events {
+		event := &export.Events[i]
+		if event.ID < export.FromID || event.ID > export.ToID || (i != 0 && event.ID <= export.Events[i-1].ID) {
+			return fmt.Errorf("event (id = %d) is out of the range or the order of the export", event.ID)
+		}
+		if err := chain.Next(event); err != nil {
+			return fmt.Errorf("event (id = %d):%w", event.ID, err)
+		}
+	}
+	if chain.head != export.Hash {
+		return errors.New("events do not end with the hash of the export")
+	}
+	return nil
+}
+
+// CheckpointPayload is the signed text of the checkpoint, the lines are
+// "audit-checkpoint", the event id, its hash and the RFC 3339 time of the checkpoint
+func CheckpointPayload(checkpoint *model.AuditCheckpoint) string {
+	return strings.Join([]string{
+		"audit-checkpoint",
+		strconv.FormatInt(checkpoint.EventID, 10),
+		checkpoint.Hash,
+		checkpoint.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}, "\n")
+}
+
+// ExportPayload is the signed text of the export, the lines are "audit-export",
+// the requested range, the number of the events, the hashes the events start
+// after and end with, and the RFC 3339 time of the export. The events themselves
+// are covered by the chain.
+func ExportPayload(export *model.AuditExport) string {
+	return strings.Join([]string{
+		"audit-export",
+		strconv.FormatInt(export.FromID, 10),
+		strconv.FormatInt(export.ToID, 10),
+		strconv.Itoa(len(export.Events)),
+		export.PrevHash,
+		export.Hash,
+		export.ExportedAt.UTC().Format(time.RFC3339Nano),
+	}, "\n")
+}
@@ -0,0 +1,241 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"testing"
+	"time"
+)
+
+// chain returns the events chained one after another, the first one is recorded before the chain
+func chain(t *testing.T, count int) []model.AuditEvent {
+	createdAt := time.Date(2022, 03, 11, 1, 0, 0, 0, time.UTC)
+	events := []model.AuditEvent{{ID: 1, CreatedAt: createdAt, Action: model.AuditStaffCreated}}
+	prevHash := ""
+	for id := 2; id <= count; id++ {
+		event := model.AuditEvent{
+			ID:        int64(id),
+			CreatedAt: createdAt.Add(time.Duration(id) * time.Second),
+			ActorID:   1,
+			ActorRole: "Superadmin",
+			Action:    model.AuditUserUnlocked,
+			TargetID:  id,
+			Diff:      map[string]model.AuditChange{"failed_login_attempts": {Old: id, New: 0}},
+			PrevHash:  prevHash,
+		}
+		hash, err := Hash(&event)
+		assert.NoError(t, err)
+		event.Hash, prevHash = hash, hash
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestHash(t *testing.T) {
+	lockedUntil := time.Date(2022, 03, 11, 2, 0, 0, 0, time.UTC)
+	event := &model.AuditEvent{
+		CreatedAt: time.Date(2022, 03, 11, 1, 0, 0, 123456000, time.UTC),
+		ActorID:   1,
+		Action:    model.AuditUserUnlocked,
+		TargetID:  2,
+		Diff: map[string]model.AuditChange{
+			"locked_until":          {Old: lockedUntil},
+			"failed_login_attempts": {Old: 5, New: 0},
+		},
+		PrevHash: "previous",
+	}
+	hash, err := Hash(event)
+	assert.NoError(t, err)
+	assert.Len(t, hash, 64)
+
+	// the diff comes back from Postgres as plain values in another order
+	diff, err := json.Marshal(event.Diff)
+	assert.NoError(t, err)
+	stored := *event
+	stored.Diff = nil
+	assert.NoError(t, json.Unmarshal(diff, &stored.Diff))
+	stored.CreatedAt = event.CreatedAt.In(time.FixedZone("", 3*60*60))
+	storedHash, err := Hash(&stored)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, storedHash)
+
+	stored.PrevHash = "another"
+	changedHash, err := Hash(&stored)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, changedHash)
+}
+
+func TestChain(t *testing.T) {
+	testTable := []struct {
+		name          string
+		change        func(events []model.AuditEvent) []model.AuditEvent
+		expectedBreak int64
+	}{
+		{
+			name:   "Intact",
+			change: func(events []model.AuditEvent) []model.AuditEvent { return events },
+		},
+		{
+			name: "Changed event",
+			change: func(events []model.AuditEvent) []model.AuditEvent {
+				events[2].TargetID = 10
+				return events
+			},
+			expectedBreak: 3,
+		},
+		{
+			name: "Removed event",
+			change: func(events []model.AuditEvent) []model.AuditEvent {
+				return append(events[:2], events[3:]...)
+			},
+			expectedBreak: 4,
+		},
+		{
+			name: "Event without hash after the chain",
+			change: func(events []model.AuditEvent) []model.AuditEvent {
+				events[3].PrevHash, events[3].Hash = "", ""
+				return events
+			},
+			expectedBreak: 4,
+		},
+	}
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			var c Chain
+			var broken int64
+			for _, event := range testCase.change(chain(t, 5)) {
+				if err := c.Next(&event); err != nil {
+					broken = event.ID
+					break
+				}
+			}
+			//Assert
+			assert.Equal(t, testCase.expectedBreak, broken)
+		})
+	}
+}
+
+func TestSigner(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	signer, err := NewSigner(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	assert.NoError(t, err)
+	der, err = x509.MarshalPKIXPublicKey(key.Public())
+	assert.NoError(t, err)
+	verifier, err := NewVerifier(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	assert.NoError(t, err)
+	assert.Equal(t, signer.Verifier().PublicKey(), verifier.PublicKey())
+
+	checkpoint := &model.AuditCheckpoint{EventID: 5, Hash: "hash", CreatedAt: time.Now()}
+	signer.SignCheckpoint(checkpoint)
+	assert.True(t, verifier.VerifyCheckpoint(checkpoint))
+	assert.False(t, GenerateSigner().Verifier().VerifyCheckpoint(checkpoint))
+	checkpoint.EventID = 4
+	assert.False(t, verifier.VerifyCheckpoint(checkpoint))
+}
+
+func TestVerifier_VerifyExport(t *testing.T) {
+	signer := GenerateSigner()
+	export := func(events []model.AuditEvent) *model.AuditExport {
+		export := &model.AuditExport{FromID: 2, ToID: 5, Events: events, ExportedAt: time.Now()}
+		export.PrevHash, export.Hash = events[0].PrevHash, events[len(events)-1].Hash
+		signer.SignExport(export)
+		return export
+	}
+
+	testTable := []struct {
+		name          string
+		export        func(events []model.AuditEvent) *model.AuditExport
+		verifier      *Verifier
+		expectedError string
+	}{
+		{
+			name:   "OK",
+			export: func(events []model.AuditEvent) *model.AuditExport { return export(events[1:]) },
+		},
+		{
+			name: "OK from the middle of the chain",
+			export: func(events []model.AuditEvent) *model.AuditExport {
+				return export(events[2:])
+			},
+		},
+		{
+			name:          "Signed with another key",
+			export:        func(events []model.AuditEvent) *model.AuditExport { return export(events[1:]) },
+			verifier:      GenerateSigner().Verifier(),
+			expectedError: "signature of the export is invalid",
+		},
+		{
+			name: "Removed event",
+			export: func(events []model.AuditEvent) *model.AuditExport {
+				export := export(events[1:])
+				export.Events = export.Events[1:]
+				return export
+			},
+			expectedError: "signature of the export is invalid",
+		},
+		{
+			name: "Changed event",
+			export: func(events []model.AuditEvent) *model.AuditExport {
+				export := export(events[1:])
+				export.Events[1].TargetID = 10
+				return export
+			},
+			expectedError: "event (id = 3):hash does not match, the event is changed",
+		},
+		{
+			name: "Event out of the range",
+			export: func(events []model.AuditEvent) *model.AuditExport {
+				return export(events)
+			},
+			expectedError: "event (id = 1) is out of the range or the order of the export",
+		},
+	}
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			verifier := testCase.verifier
+			if verifier == nil {
+				verifier = signer.Verifier()
+			}
+			err := verifier.VerifyExport(testCase.export(chain(t, 5)))
+			//Assert
+			if testCase.expectedError != "" {
+				assert.EqualError(t, err, testCase.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewSigner_errors(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	assert.NoError(t, err)
+
+	_, err = NewSigner([]byte("not a key"))
+	assert.Error(t, err)
+	_, err = NewSigner(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	assert.Error(t, err)
+}
+
+func TestNewVerifier_errors(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	assert.NoError(t, err)
+
+	_, err = NewVerifier([]byte("not a key"))
+	assert.Error(t, err)
+	_, err = NewVerifier(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	assert.Error(t, err)
+}
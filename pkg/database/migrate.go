@@ -0,0 +1,335 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the pg_advisory_lock key held while migrating, so that
+// replicas starting at the same time apply every migration only once
+const migrationLockKey = 7240311
+
+const MIGRATIONS_SCHEMA = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint not null primary key,
+		name varchar(255) NOT NULL,
+		checksum varchar(64) NOT NULL,
+		applied_at timestamp NOT NULL
+	);
+`
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a pair of files like 0001_create_users.up.sql and
+// 0001_create_users.down.sql, Checksum is a sha256 of the up file
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	// Changed is set when the up file differs from the one that was applied
+	Changed bool
+	// Unknown is set for an applied migration missing from this build
+	Unknown bool
+}
+
+type Migrator struct {
+	db         *sql.DB
+	logger     logging.Logger
+	migrations []Migration
+}
+
+// NewMigrator uses the migrations embedded into the binary
+func NewMigrator(db *sql.DB, logger logging.Logger) (*Migrator, error) {
+	return newMigrator(db, logger, migrationFiles, "migrations")
+}
+
+func newMigrator(db *sql.DB, logger logging.Logger, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := loadMigrations(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, logger: logger, migrations: migrations}, nil
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("loadMigrations:%w", err)
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		parts := migrationName.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("loadMigrations: unexpected file %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("loadMigrations: invalid version of %s:%w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("loadMigrations:%w", err)
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = migration
+		} else if migration.Name != parts[2] {
+			return nil, fmt.Errorf("loadMigrations: version %d is used by %s and %s", version, migration.Name, parts[2])
+		}
+		if parts[3] == "up" {
+			migration.Up = string(content)
+			sum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Checksum == "" {
+			return nil, fmt.Errorf("loadMigrations: migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up applies up to steps pending migrations in order, all of them if steps is zero.
+// It refuses to run when an applied migration was changed afterwards.
+func (m *Migrator) Up(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if done, ok := applied[migration.Version]; ok && done.checksum != migration.Checksum {
+				m.logger.Errorf("Migrator: migration %d_%s was changed after it was applied", migration.Version, migration.Name)
+				return fmt.Errorf("migration %d_%s was changed after it was applied", migration.Version, migration.Name)
+			}
+		}
+		count := 0
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if steps > 0 && count == steps {
+				break
+			}
+			err = m.run(ctx, conn, migration.Version, migration.Name, migration.Up, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)",
+					migration.Version, migration.Name, migration.Checksum, time.Now().UTC())
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			m.logger.Infof("Migrator: applied %d_%s", migration.Version, migration.Name)
+			count++
+		}
+		if count == 0 {
+			m.logger.Info("Migrator: database is up to date")
+		}
+		return nil
+	})
+}
+
+// Down rolls back up to steps applied migrations starting from the latest one,
+// all of them if steps is zero
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		count := 0
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if steps > 0 && count == steps {
+				break
+			}
+			err = m.run(ctx, conn, migration.Version, migration.Name, migration.Down, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			m.logger.Infof("Migrator: rolled back %d_%s", migration.Version, migration.Name)
+			count++
+		}
+		return nil
+	})
+}
+
+// Status lists the known migrations together with applied ones missing from this build
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if done, ok := applied[migration.Version]; ok {
+				appliedAt := done.appliedAt
+				status.AppliedAt = &appliedAt
+				status.Changed = done.checksum != migration.Checksum
+				delete(applied, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for version, done := range applied {
+			appliedAt := done.appliedAt
+			statuses = append(statuses, MigrationStatus{Version: version, Name: done.name, AppliedAt: &appliedAt, Unknown: true})
+		}
+		sort.Slice(statuses, func(i, j int) bool {
+			return statuses[i].Version < statuses[j].Version
+		})
+		return nil
+	})
+	return statuses, err
+}
+
+// Force marks every migration up to version as applied and every later one as
+// pending without running them, it is meant for repairing the bookkeeping
+// after a migration was fixed by hand. Zero marks everything as pending.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("force: unknown migration version %d", version)
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("force: can not start transaction:%w", err)
+		}
+		defer tx.Rollback()
+		if _, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+			return fmt.Errorf("force:%w", err)
+		}
+		now := time.Now().UTC()
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)",
+				migration.Version, migration.Name, migration.Checksum, now)
+			if err != nil {
+				return fmt.Errorf("force:%w", err)
+			}
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("force:%w", err)
+		}
+		m.logger.Warnf("Migrator: forced version %d", version)
+		return nil
+	})
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// run executes the migration and updates the bookkeeping in one transaction
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, version int64, name string, query string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migration %d_%s: can not start transaction:%w", version, name, err)
+	}
+	defer tx.Rollback()
+	if _, err = tx.ExecContext(ctx, query); err != nil {
+		m.logger.Errorf("Migrator: migration %d_%s failed:%s", version, name, err)
+		return fmt.Errorf("migration %d_%s:%w", version, name, err)
+	}
+	if err = record(tx); err != nil {
+		return fmt.Errorf("migration %d_%s: bookkeeping:%w", version, name, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("migration %d_%s: commit:%w", version, name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("applied migrations:%w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var version int64
+		var done appliedMigration
+		if err = rows.Scan(&version, &done.name, &done.checksum, &done.appliedAt); err != nil {
+			return nil, fmt.Errorf("applied migrations:%w", err)
+		}
+		applied[version] = done
+	}
+	return applied, rows.Err()
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock,
+// the bookkeeping table is created first if needed
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrator: can not get connection:%w", err)
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("migrator: can not take lock:%w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+			m.logger.Errorf("Migrator: can not release lock:%s", err)
+		}
+	}()
+	if _, err = conn.ExecContext(ctx, MIGRATIONS_SCHEMA); err != nil {
+		return fmt.Errorf("migrator: can not create bookkeeping table:%w", err)
+	}
+	return fn(conn)
+}
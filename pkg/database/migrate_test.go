@@ -0,0 +1,244 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var testMigrations = fstest.MapFS{
+	"migrations/0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id serial);")},
+	"migrations/0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	"migrations/0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN email text;")},
+	"migrations/0002_add_email.down.sql":    {Data: []byte("ALTER TABLE users DROP COLUMN email;")},
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(migrationLockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(MIGRATIONS_SCHEMA)).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(migrationLockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func appliedRows(versions ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"})
+	for _, version := range versions {
+		switch version {
+		case 1:
+			rows.AddRow(1, "create_users", checksum("CREATE TABLE users (id serial);"), time.Now())
+		case 2:
+			rows.AddRow(2, "add_email", checksum("ALTER TABLE users ADD COLUMN email text;"), time.Now())
+		}
+	}
+	return rows
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	assert.NoError(t, err)
+	for i, migration := range migrations {
+		assert.Equal(t, int64(i+1), migration.Version)
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+
+	testTable := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name:  "Unexpected file",
+			files: fstest.MapFS{"migrations/users.sql": {Data: []byte("")}},
+		},
+		{
+			name:  "No up file",
+			files: fstest.MapFS{"migrations/0001_create_users.down.sql": {Data: []byte("")}},
+		},
+		{
+			name: "Duplicate version",
+			files: fstest.MapFS{
+				"migrations/0001_create_users.up.sql": {Data: []byte("")},
+				"migrations/0001_create_roles.up.sql": {Data: []byte("")},
+			},
+		},
+	}
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := loadMigrations(testCase.files, "migrations")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMigrator_Up(t *testing.T) {
+	testTable := []struct {
+		name          string
+		steps         int
+		mock          func(mock sqlmock.Sqlmock)
+		expectedError bool
+	}{
+		{
+			name: "Pending migration",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock)
+				mock.ExpectQuery("SELECT version, name, checksum, applied_at FROM schema_migrations").WillReturnRows(appliedRows(1))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE users ADD COLUMN email text;")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT INTO schema_migrations").
+					WithArgs(int64(2), "add_email", checksum("ALTER TABLE users ADD COLUMN email text;"), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				expectUnlock(mock)
+			},
+		},
+		{
+			name:  "One step",
+			steps: 1,
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock)
+				mock.ExpectQuery("SELECT version, name, checksum, applied_at FROM schema_migrations").WillReturnRows(appliedRows())
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE users (id serial);")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT INTO schema_migrations").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				expectUnlock(mock)
+			},
+		},
+		{
+			name: "Up to date",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock)
+				mock.ExpectQuery("SELECT version, name, checksum, applied_at FROM schema_migrations").WillReturnRows(appliedRows(1, 2))
+				expectUnlock(mock)
+			},
+		},
+		{
+			name: "Changed migration",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock)
+				mock.ExpectQuery("SELECT version, name, checksum, applied_at FROM schema_migrations").
+					WillReturnRows(sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}).
+						AddRow(1, "create_users", checksum("CREATE TABLE users (id int);"), time.Now()))
+				expectUnlock(mock)
+			},
+			expectedError: true,
+		},
+		{
+			name: "Failed migration",
+			mock: func(mock sqlmock.Sqlmock) {
+				expectLock(mock)
+				mock.ExpectQuery("SELECT version, name, checksum, applied_at FROM schema_migrations").WillReturnRows(appliedRows(1))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE users ADD COLUMN email text;")).WillReturnError(errors.New("syntax error"))
+				mock.ExpectRollback()
+				expectUnlock(mock)
+			},
+			expectedError: true,
+		},
+	}
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			migrator, err := newMigrator(db, logging.GetLogger(), testMigrations, "migrations")
+			assert.NoError(t, err)
+			testCase.mock(mock)
+			err = migrator.Up(context.Background(), testCase.steps)
+			if testCase.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_Down(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	migrator, err := newMigrator(db, logging.GetLogger(), testMigrations, "migrations")
+	assert.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery("SELECT version, name, checksum, applied_at FROM schema_migrations").WillReturnRows(appliedRows(1, 2))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE users DROP COLUMN email;")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations WHERE version = $1")).WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	assert.NoError(t, migrator.Down(context.Background(), 1))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Status(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	migrator, err := newMigrator(db, logging.GetLogger(), testMigrations, "migrations")
+	assert.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery("SELECT version, name, checksum, applied_at FROM schema_migrations").
+		WillReturnRows(appliedRows(1).AddRow(3, "add_roles", "checksum", time.Now()))
+	expectUnlock(mock)
+
+	statuses, err := migrator.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(statuses))
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.False(t, statuses[0].Changed)
+	assert.Nil(t, statuses[1].AppliedAt)
+	assert.True(t, statuses[2].Unknown)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Force(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	migrator, err := newMigrator(db, logging.GetLogger(), testMigrations, "migrations")
+	assert.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM schema_migrations").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs(int64(1), "create_users", checksum("CREATE TABLE users (id serial);"), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	assert.NoError(t, migrator.Force(context.Background(), 1))
+	assert.Error(t, migrator.Force(context.Background(), 5))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
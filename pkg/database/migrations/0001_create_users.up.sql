@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS users (
+    id serial not null primary key,
+    email varchar(225) NOT NULL UNIQUE,
+    password varchar(225) NOT NULL,
+    role varchar(50) NOT NULL,
+    created_at date NOT NULL,
+    deleted bool NOT NULL
+);
@@ -0,0 +1,10 @@
+-- rows with an empty token_hash revoke every token of the user issued before revoked_at
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    id serial not null primary key,
+    user_id int NOT NULL,
+    token_hash varchar(64) UNIQUE,
+    revoked_at timestamp NOT NULL,
+    expires_at timestamp NOT NULL
+);
+CREATE INDEX IF NOT EXISTS revoked_tokens_user_id_idx ON revoked_tokens (user_id);
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
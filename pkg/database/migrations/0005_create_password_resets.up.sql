@@ -0,0 +1,11 @@
+-- only a sha256 hash of the emailed token is kept
+CREATE TABLE IF NOT EXISTS password_resets (
+    id serial not null primary key,
+    user_id int NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash varchar(64) NOT NULL UNIQUE,
+    created_at timestamp NOT NULL,
+    expires_at timestamp NOT NULL,
+    used_at timestamp
+);
+CREATE INDEX IF NOT EXISTS password_resets_user_id_idx ON password_resets (user_id);
+CREATE INDEX IF NOT EXISTS password_resets_expires_at_idx ON password_resets (expires_at);
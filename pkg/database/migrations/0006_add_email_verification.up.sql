@@ -0,0 +1,4 @@
+-- accounts created before verification was introduced are treated as verified,
+-- new customers are inserted unverified
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified bool NOT NULL DEFAULT true;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at timestamp;
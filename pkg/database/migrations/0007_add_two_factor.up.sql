@@ -0,0 +1,17 @@
+-- totp_secret is set on enrollment and is used only after the first code
+-- confirms it, totp_last_step keeps a code from being used twice
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret varchar(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled bool NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id serial not null primary key,
+    user_id int NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash varchar(64) NOT NULL,
+    used_at timestamp
+);
+CREATE INDEX IF NOT EXISTS recovery_codes_user_id_idx ON recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS two_factor_roles (
+    role varchar(50) not null primary key
+);
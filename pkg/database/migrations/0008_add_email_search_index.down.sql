@@ -0,0 +1,2 @@
+-- the extension is left in place, other database objects may depend on it
+DROP INDEX IF EXISTS users_email_trgm_idx;
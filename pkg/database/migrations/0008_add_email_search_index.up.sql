@@ -0,0 +1,4 @@
+-- trigram index for the case-insensitive email search of the user list,
+-- it serves ILIKE '%part%' patterns that a btree index can not
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS users_email_trgm_idx ON users USING gin (email gin_trgm_ops);
@@ -0,0 +1,28 @@
+-- who changed what, written in the transaction of the change. The actor is
+-- empty for the requests made without a token, like a password reset.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id bigserial not null primary key,
+    created_at timestamp NOT NULL,
+    actor_id int,
+    actor_role varchar(50) NOT NULL DEFAULT '',
+    action varchar(50) NOT NULL,
+    target_id int,
+    ip varchar(45) NOT NULL DEFAULT '',
+    user_agent text NOT NULL DEFAULT '',
+    request_id varchar(64) NOT NULL DEFAULT '',
+    diff jsonb NOT NULL DEFAULT '{}'
+);
+CREATE INDEX IF NOT EXISTS audit_events_actor_id_idx ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS audit_events_target_id_idx ON audit_events (target_id);
+CREATE INDEX IF NOT EXISTS audit_events_action_idx ON audit_events (action);
+CREATE INDEX IF NOT EXISTS audit_events_created_at_idx ON audit_events (created_at);
+
+-- the events are never changed or removed, not even by the service itself
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE PROCEDURE audit_events_append_only();
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS audit_checkpoints;
+DROP FUNCTION IF EXISTS audit_checkpoints_append_only();
+ALTER TABLE audit_events DROP COLUMN IF EXISTS hash;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS prev_hash;
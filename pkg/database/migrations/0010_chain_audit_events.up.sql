@@ -0,0 +1,23 @@
+-- every event stores the hash of the previous one, see pkg/audit. The events
+-- recorded before are left without a hash and precede the chain.
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS prev_hash varchar(64) NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS hash varchar(64) NOT NULL DEFAULT '';
+
+-- signed heads of the chain, the events up to a checkpoint can not be changed
+-- or removed without breaking its signature
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id bigserial not null primary key,
+    event_id bigint NOT NULL UNIQUE REFERENCES audit_events (id),
+    hash varchar(64) NOT NULL,
+    created_at timestamp NOT NULL,
+    signature text NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION audit_checkpoints_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_checkpoints is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_checkpoints_append_only ON audit_checkpoints;
+CREATE TRIGGER audit_checkpoints_append_only BEFORE UPDATE OR DELETE ON audit_checkpoints
+    FOR EACH ROW EXECUTE PROCEDURE audit_checkpoints_append_only();
@@ -0,0 +1,3 @@
+-- the nonce of the last login challenge issued to the user, a challenge is
+-- accepted once and only while no later one was issued
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_challenge varchar(64);
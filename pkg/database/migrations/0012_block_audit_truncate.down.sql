@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS audit_checkpoints_no_truncate ON audit_checkpoints;
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
@@ -1,9 +1,112 @@
 package pkg
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 const (
-	EmailDoesNotExist = "user with this email does not exist"
+	EmailDoesNotExist   = "user with this email does not exist"
+	InvalidRefreshToken = "refresh token is expired or revoked"
+	TokenRevoked        = "token has been revoked"
+	InvalidToken        = "token is not a JWT or has no issue time"
+	ForeignToken        = "token belongs to another user"
+	AccountLocked       = "account is temporarily locked due to too many failed login attempts"
+	UserNotFound        = "user not found"
+	InvalidResetToken   = "password reset token is invalid or expired"
+	InvalidVerification = "email verification link is invalid or expired"
+	EmailNotVerified    = "email is not verified"
+	EmailVerified       = "email is already verified"
+	TwoFactorRequired   = "two-factor authentication code is required"
+	InvalidTwoFactor    = "two-factor authentication code is invalid"
+	InvalidChallenge    = "login challenge is invalid or expired"
+	TwoFactorEnabled    = "two-factor authentication is already enabled"
+	TwoFactorNotEnabled = "two-factor authentication is not enabled"
+	TwoFactorMandatory  = "two-factor authentication is mandatory for this role"
+	EnrollmentRequired  = "two-factor authentication is mandatory for this role, the link to enroll is sent by email"
+	InvalidEnrollment   = "two-factor enrollment link is invalid or expired"
+	InvalidCredentials  = "wrong email or password entered"
+	InvalidCursor       = "invalid pagination cursor"
+	InvalidSort         = "invalid sort parameter"
+	InvalidTimeRange    = "invalid time range, from must be before to"
+	InvalidAuditRange   = "invalid audit range"
+	UserActive          = "user is already active"
 )
 
 var ErrorEmailDoesNotExist = errors.New(EmailDoesNotExist)
+
+var ErrorInvalidRefreshToken = errors.New(InvalidRefreshToken)
+
+var ErrorTokenRevoked = errors.New(TokenRevoked)
+
+var ErrorInvalidToken = errors.New(InvalidToken)
+
+var ErrorForeignToken = errors.New(ForeignToken)
+
+var ErrorAccountLocked = errors.New(AccountLocked)
+
+var ErrorUserNotFound = errors.New(UserNotFound)
+
+var ErrorInvalidResetToken = errors.New(InvalidResetToken)
+
+var ErrorInvalidVerification = errors.New(InvalidVerification)
+
+var ErrorEmailNotVerified = errors.New(EmailNotVerified)
+
+var ErrorEmailVerified = errors.New(EmailVerified)
+
+var ErrorTwoFactorRequired = errors.New(TwoFactorRequired)
+
+var ErrorInvalidTwoFactor = errors.New(InvalidTwoFactor)
+
+var ErrorInvalidChallenge = errors.New(InvalidChallenge)
+
+var ErrorTwoFactorEnabled = errors.New(TwoFactorEnabled)
+
+var ErrorTwoFactorNotEnabled = errors.New(TwoFactorNotEnabled)
+
+var ErrorTwoFactorMandatory = errors.New(TwoFactorMandatory)
+
+var ErrorEnrollmentRequired = errors.New(EnrollmentRequired)
+
+var ErrorInvalidEnrollment = errors.New(InvalidEnrollment)
+
+var ErrorInvalidCredentials = errors.New(InvalidCredentials)
+
+var ErrorInvalidCursor = errors.New(InvalidCursor)
+
+var ErrorInvalidSort = errors.New(InvalidSort)
+
+var ErrorInvalidTimeRange = errors.New(InvalidTimeRange)
+
+var ErrorInvalidAuditRange = errors.New(InvalidAuditRange)
+
+var ErrorUserActive = errors.New(UserActive)
+
+// LockedError is returned while the account is locked out, errors.Is matches it with ErrorAccountLocked
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return AccountLocked
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrorAccountLocked
+}
+
+// ChallengeError is returned after a correct password when a second factor is needed,
+// errors.Is matches it with ErrorTwoFactorRequired
+type ChallengeError struct {
+	Challenge string
+	ExpiresAt time.Time
+}
+
+func (e *ChallengeError) Error() string {
+	return TwoFactorRequired
+}
+
+func (e *ChallengeError) Is(target error) bool {
+	return target == ErrorTwoFactorRequired
+}
@@ -1,35 +1,39 @@
 package logging
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
+	"math"
 	"os"
 	"path"
 	"runtime"
+	"time"
 )
 
-type writerHook struct {
-	Writer    []io.Writer
-	LogLevels []logrus.Level
-}
+const (
+	FormatText = "text"
+	FormatJSON = "json"
 
-func (hook *writerHook) Fire(entry *logrus.Entry) error {
-	line, err := entry.String()
-	if err != nil {
-		return err
-	}
-	for _, w := range hook.Writer {
-		_, err = w.Write([]byte(line))
-		if err != nil {
-			return err
-		}
-	}
-	return err
-}
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+)
 
-func (hook *writerHook) Levels() []logrus.Level {
-	return hook.LogLevels
+// Config of the logger. The file is rotated once it grows over MaxSizeMB, the
+// rotated ones are removed after MaxAge or when there are more than MaxBackups,
+// zero keeps them.
+type Config struct {
+	Format     string
+	Level      string
+	Output     string
+	File       string
+	MaxSizeMB  int
+	MaxAge     time.Duration
+	MaxBackups int
 }
 
 var e *logrus.Entry
@@ -38,6 +42,7 @@ type Logger struct {
 	*logrus.Entry
 }
 
+// GetLogger returns the logger of the service, every logger shares the settings applied by Configure
 func GetLogger() Logger {
 	return Logger{e}
 }
@@ -46,47 +51,110 @@ func (l *Logger) GetLoggerWithField(k string, v interface{}) *Logger {
 	return &Logger{l.WithField(k, v)}
 }
 
-func init() {
-	l := logrus.New()
-	l.SetReportCaller(true)
-	l.Formatter = &logrus.TextFormatter{
-		CallerPrettyfier: func(frame *runtime.Frame) (function string, file string) {
-			filename := path.Base(frame.File)
-			return fmt.Sprintf("%s()", frame.Function), fmt.Sprintf("%s:%d", filename, frame.Line)
-		},
-		FullTimestamp: true,
-		ForceColors:   true,
-	}
-	err := os.MkdirAll("logs", 0777)
+// WithContext returns a logger adding the fields of ctx set by ContextWithField to its lines
+func (l Logger) WithContext(ctx context.Context) Logger {
+	return Logger{l.Entry.WithContext(ctx)}
+}
+
+// Configure applies cfg to every logger, the returned closer closes the log file
+func Configure(cfg Config) (io.Closer, error) {
+	level, err := logrus.ParseLevel(cfg.Level)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("configure:%w", err)
 	}
-	allFile, err := os.OpenFile("logs/all.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0777)
-	if err != nil {
-		panic(err)
+	var formatter logrus.Formatter
+	switch cfg.Format {
+	case FormatText:
+		formatter = &logrus.TextFormatter{CallerPrettyfier: callerPrettyfier, FullTimestamp: true}
+	case FormatJSON:
+		formatter = &logrus.JSONFormatter{CallerPrettyfier: callerPrettyfier}
+	default:
+		return nil, fmt.Errorf("configure: unknown format %q", cfg.Format)
 	}
-	l.SetOutput(io.Discard)
-	l.AddHook(&writerHook{
-		Writer: []io.Writer{allFile, os.Stdout},
+	var output io.WriteCloser
+	switch cfg.Output {
+	case OutputStdout:
+		output = nopCloser{os.Stdout}
+	case OutputFile:
+		output = &lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxAge:     int(math.Ceil(cfg.MaxAge.Hours() / 24)),
+			MaxBackups: cfg.MaxBackups,
+		}
+	default:
+		return nil, fmt.Errorf("configure: unknown output %q", cfg.Output)
+	}
+	l := e.Logger
+	l.SetFormatter(redactor{formatter})
+	l.SetOutput(output)
+	l.SetLevel(level)
+	return output, nil
+}
 
-		// PanicLevel level, highest level of severity. Logs and then calls panic with the message passed to Debug, Info, ...
+func callerPrettyfier(frame *runtime.Frame) (function string, file string) {
+	filename := path.Base(frame.File)
+	return fmt.Sprintf("%s()", frame.Function), fmt.Sprintf("%s:%d", filename, frame.Line)
+}
 
-		// FatalLevel level. Logs and then calls `logger.Exit(1)`. It will exit even if the logging level is set to Panic.
+type nopCloser struct {
+	io.Writer
+}
 
-		// ErrorLevel level. Logs. Used for myErrors that should definitely be noted.
-		// Commonly used for hooks to send myErrors to an error tracking service.
+func (nopCloser) Close() error {
+	return nil
+}
 
-		// WarnLevel level. Non-critical entries that deserve eyes.
+// NewRequestID returns a random id for a request which came without one
+func NewRequestID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
 
-		// InfoLevel level. General operational entries about what's going on inside the application.
+type fieldsKey struct{}
 
-		// DebugLevel level. Usually only enabled when debugging. Very verbose logging.
+// ContextWithField returns a copy of ctx whose log lines carry the field, like the id of the request
+func ContextWithField(ctx context.Context, key string, value interface{}) context.Context {
+	fields := make(logrus.Fields)
+	for k, v := range fieldsFrom(ctx) {
+		fields[k] = v
+	}
+	fields[key] = value
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
 
-		// TraceLevel level. Designates finer-grained informational events than the Debug.
-		LogLevels: logrus.AllLevels,
-	})
-	l.SetLevel(logrus.TraceLevel)
+func fieldsFrom(ctx context.Context) logrus.Fields {
+	fields, _ := ctx.Value(fieldsKey{}).(logrus.Fields)
+	return fields
+}
 
-	e = logrus.NewEntry(l)
+// contextHook adds the fields of the context the line is logged with, see logrus.Entry.WithContext
+type contextHook struct{}
+
+func (contextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (contextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	for k, v := range fieldsFrom(entry.Context) {
+		if _, ok := entry.Data[k]; !ok {
+			entry.Data[k] = v
+		}
+	}
+	return nil
+}
 
+func init() {
+	l := logrus.New()
+	l.SetReportCaller(true)
+	l.SetFormatter(redactor{&logrus.TextFormatter{CallerPrettyfier: callerPrettyfier, FullTimestamp: true}})
+	l.SetOutput(os.Stdout)
+	l.SetLevel(logrus.InfoLevel)
+	l.AddHook(contextHook{})
+
+	e = logrus.NewEntry(l)
 }
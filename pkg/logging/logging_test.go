@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	testTable := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Email",
+			input:    "GetUserByEmail: user test.user@yandex.ru not found",
+			expected: "GetUserByEmail: user t***@yandex.ru not found",
+		},
+		{
+			name:     "Database error",
+			input:    `pq: duplicate key value violates unique constraint "users_email_key": Key (email)=(test@yandex.ru) already exists`,
+			expected: `pq: duplicate key value violates unique constraint "users_email_key": Key (email)=(t***@yandex.ru) already exists`,
+		},
+		{
+			name:     "Password-like values",
+			input:    `request {"email":"test@yandex.ru","password":"qwerty"} token=abc Authorization: Bearer xyz`,
+			expected: `request {"email":"t***@yandex.ru","password":"[REDACTED]"} token=[REDACTED] Authorization: [REDACTED]`,
+		},
+		{
+			name:     "Nothing to hide",
+			input:    "userIdentity: invalid auth header",
+			expected: "userIdentity: invalid auth header",
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Redact(tt.input))
+		})
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	//Init dependencies
+	path := filepath.Join(t.TempDir(), "logs", "service.log")
+	file, err := Configure(Config{Format: FormatJSON, Level: "info", Output: OutputFile, File: path, MaxSizeMB: 1})
+	assert.NoError(t, err)
+	defer Configure(Config{Format: FormatText, Level: "info", Output: OutputStdout})
+	ctx := ContextWithField(context.Background(), "request_id", "42")
+	ctx = ContextWithField(ctx, "user_id", 7)
+
+	logger := GetLogger()
+	logger.WithContext(ctx).WithField("new_password", "qwerty").WithError(errors.New("no user test@yandex.ru")).
+		Errorf("UpdateUser: password=%s", "qwerty")
+	logger.Debug("below the level")
+	assert.NoError(t, file.Close())
+
+	//Assert
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if assert.Len(t, lines, 1) {
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+		assert.Equal(t, "error", line["level"])
+		assert.Equal(t, "UpdateUser: password=[REDACTED]", line["msg"])
+		assert.Equal(t, "42", line["request_id"])
+		assert.Equal(t, float64(7), line["user_id"])
+		assert.Equal(t, "[REDACTED]", line["new_password"])
+		assert.Equal(t, "no user t***@yandex.ru", line["error"])
+	}
+}
+
+func TestConfigure_errors(t *testing.T) {
+	_, err := Configure(Config{Format: FormatJSON, Level: "verbose", Output: OutputStdout})
+	assert.Error(t, err)
+	_, err = Configure(Config{Format: "xml", Level: "info", Output: OutputStdout})
+	assert.EqualError(t, err, `configure: unknown format "xml"`)
+	_, err = Configure(Config{Format: FormatJSON, Level: "info", Output: "syslog"})
+	assert.EqualError(t, err, `configure: unknown output "syslog"`)
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+	// secretPattern matches values written after a password-like name, like password=qwerty or "token":"..."
+	secretPattern = regexp.MustCompile(`(?i)((?:password|passwd|secret|token|authorization)[A-Za-z_]*"?\s*[:=]\s*"?)(?:Bearer\s+)?[^\s",}]+`)
+	secretNames   = []string{"password", "passwd", "secret", "token", "authorization"}
+)
+
+// Redact hides the local part of the emails and the values of the password-like settings in text
+func Redact(text string) string {
+	text = emailPattern.ReplaceAllString(text, "$1***@$2")
+	return secretPattern.ReplaceAllString(text, "$1"+redacted)
+}
+
+// isSecret tells whether the field named key holds a password-like value
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, name := range secretNames {
+		if strings.Contains(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactor removes personal data and secrets from the lines before they are formatted
+type redactor struct {
+	logrus.Formatter
+}
+
+func (r redactor) Format(entry *logrus.Entry) ([]byte, error) {
+	entry.Message = Redact(entry.Message)
+	for key, value := range entry.Data {
+		switch {
+		case isSecret(key):
+			entry.Data[key] = redacted
+		case key == logrus.ErrorKey:
+			if err, ok := value.(error); ok {
+				entry.Data[key] = Redact(err.Error())
+			}
+		default:
+			switch value := value.(type) {
+			case string:
+				entry.Data[key] = Redact(value)
+			case fmt.Stringer:
+				entry.Data[key] = Redact(value.String())
+			}
+		}
+	}
+	return r.Formatter.Format(entry)
+}
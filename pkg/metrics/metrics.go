@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const (
+	LoginSuccess           = "success"
+	LoginFailure           = "failure"
+	LoginLocked            = "locked"
+	LoginUnverified        = "unverified"
+	LoginTwoFactorRequired = "two_factor_required"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// Logins are counted by the step of the login, password or two_factor, and by its outcome
+	Logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Logins by step and outcome.",
+	}, []string{"step", "outcome"})
+
+	CustomersCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_customers_created_total",
+		Help: "Customers registered.",
+	})
+
+	StaffCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "auth_staff_created_total",
+		Help: "Staff members created.",
+	})
+
+	// PasswordRestores are counted when the reset link is requested and when the password is reset with it
+	PasswordRestores = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_password_restores_total",
+		Help: "Password restores by stage, requested or completed.",
+	}, []string{"stage"})
+
+	BcryptDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "auth_bcrypt_duration_seconds",
+		Help:    "Duration of hashing and comparing passwords.",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+
+	GRPCClientDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_client_call_duration_seconds",
+		Help:    "Duration of calls to the auth service by method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	PendingEmails = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "mail_pending_emails",
+		Help: "Emails waiting to be delivered.",
+	})
+)
+
+// RegisterDB exposes the connection pool stats of db
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics of the default registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthProto "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+)
+
+func TestUnaryInterceptors(t *testing.T) {
+	//Init dependencies
+	provider, err := Setup(context.Background(), Config{Exporter: ExporterMemory, SampleRatio: 1})
+	assert.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+
+	//Init server
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor))
+	healthProto.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	defer server.Stop()
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithUnaryInterceptor(UnaryClientInterceptor))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	//Execute the request
+	ctx, parent := Start(context.Background(), "parent")
+	_, err = healthProto.NewHealthClient(conn).Check(ctx, &healthProto.HealthCheckRequest{Service: "unknown"})
+	parent.End()
+
+	//Assert
+	assert.Error(t, err)
+	spans := provider.Spans()
+	if assert.Len(t, spans, 3) {
+		server, client := spans[0], spans[1]
+		assert.Equal(t, "grpc.health.v1.Health/Check", client.Name)
+		assert.Equal(t, trace.SpanKindClient, client.SpanKind)
+		assert.Equal(t, parent.SpanContext().SpanID(), client.Parent.SpanID())
+		// the server continues the trace of the client
+		assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+		assert.Equal(t, client.SpanContext.TraceID(), server.SpanContext.TraceID())
+		assert.Equal(t, client.SpanContext.SpanID(), server.Parent.SpanID())
+		assert.True(t, server.Parent.IsRemote())
+		assert.Equal(t, "Error", client.Status.Code.String())
+	}
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+)
+
+const ServiceName = "authentication_service"
+
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterMemory = "memory"
+)
+
+// Config chooses where the spans go. OTLP sends them over gRPC to Endpoint like
+// "collector:4317", stdout prints them as JSON and memory keeps them for tests.
+// SampleRatio is the share of the traces started here which are recorded, the
+// traces of the callers keep their own decision.
+type Config struct {
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+// Provider records the spans of the service, Spans returns the ones kept by the memory exporter
+type Provider struct {
+	*sdktrace.TracerProvider
+	memory *tracetest.InMemoryExporter
+}
+
+func (p *Provider) Spans() tracetest.SpanStubs {
+	if p.memory == nil {
+		return nil
+	}
+	return p.memory.GetSpans()
+}
+
+// Setup installs the provider for the exporter and the W3C trace context propagator
+// globally, Shutdown of the provider flushes the spans which are not exported yet
+func Setup(ctx context.Context, cfg Config) (*Provider, error) {
+	provider := &Provider{}
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+	switch cfg.Exporter {
+	case ExporterNone, "":
+	case ExporterOTLP:
+		clientOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			clientOptions = append(clientOptions, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(ctx, clientOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("setup: otlp exporter:%w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("setup: stdout exporter:%w", err)
+		}
+		options = append(options, sdktrace.WithSyncer(exporter))
+	case ExporterMemory:
+		provider.memory = tracetest.NewInMemoryExporter()
+		options = append(options, sdktrace.WithSyncer(provider.memory))
+	default:
+		return nil, fmt.Errorf("setup: unknown exporter %q", cfg.Exporter)
+	}
+	provider.TracerProvider = sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider.TracerProvider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider, nil
+}
+
+// Start starts a span of the service, the global provider is looked up on every
+// call so that the spans go to the one installed last
+func Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(ServiceName).Start(ctx, name, options...)
+}
+
+// End records the error of the operation, if any, and ends its span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// StartQuery starts the span of a database query
+func StartQuery(ctx context.Context, name string) (context.Context, trace.Span) {
+	return Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(semconv.DBSystemPostgreSQL))
+}
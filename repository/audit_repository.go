@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/audit"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"strings"
+	"time"
+)
+
+type AuditPostgres struct {
+	db     *sql.DB
+	logger logging.Logger
+}
+
+func NewAuditPostgres(db *sql.DB, logger logging.Logger) *AuditPostgres {
+	return &AuditPostgres{db: db, logger: logger}
+}
+
+// auditChainLock is the key of the advisory lock which serializes the writers of the chain
+const auditChainLock = 0x61756469
+
+// insertAuditEvent records the event within the transaction of the change it describes.
+// The event is chained to the last one, so the writers wait for each other until commit.
+func insertAuditEvent(ctx context.Context, tx *sql.Tx, event *model.AuditEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+	// Postgres keeps microseconds, the hash must be taken of the stored time
+	event.CreatedAt = event.CreatedAt.Truncate(time.Microsecond)
+	if event.Diff == nil {
+		event.Diff = map[string]model.AuditChange{}
+	}
+	diff, err := json.Marshal(event.Diff)
+	if err != nil {
+		return fmt.Errorf("insertAuditEvent:%w", err)
+	}
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditChainLock); err != nil {
+		return fmt.Errorf("insertAuditEvent: can not lock the chain:%w", err)
+	}
+	err = tx.QueryRowContext(ctx, "SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1").Scan(&event.PrevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("insertAuditEvent:%w", err)
+	}
+	if event.Hash, err = audit.Hash(event); err != nil {
+		return fmt.Errorf("insertAuditEvent:%w", err)
+	}
+	query := `INSERT INTO audit_events (created_at, actor_id, actor_role, action, target_id, ip, user_agent, request_id, diff, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
+	row := tx.QueryRowContext(ctx, query, event.CreatedAt, nullID(event.ActorID), event.ActorRole, event.Action, nullID(event.TargetID),
+		event.IP, event.UserAgent, event.RequestID, diff, event.PrevHash, event.Hash)
+	if err = row.Scan(&event.ID); err != nil {
+		return fmt.Errorf("insertAuditEvent:%w", err)
+	}
+	return nil
+}
+
+// nullID stores a missing id as NULL
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// GetAuditEvents returns a page of the events matching the filters, the newest first, and the number of all of them
+func (a *AuditPostgres) GetAuditEvents(ctx context.Context, filters *model.AuditFilters, page int, limit int) ([]model.AuditEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+	condition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+	if filters.ActorID != 0 {
+		condition("actor_id = $%d", filters.ActorID)
+	}
+	if filters.TargetID != 0 {
+		condition("target_id = $%d", filters.TargetID)
+	}
+	if filters.Action != "" {
+		condition("action = $%d", filters.Action)
+	}
+	if !filters.From.IsZero() {
+		condition("created_at >= $%d", filters.From.UTC())
+	}
+	if !filters.To.IsZero() {
+		condition("created_at < $%d", filters.To.UTC())
+	}
+	where := ""
+	if len(conditions) != 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	transaction, err := a.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		a.logger.WithContext(ctx).Errorf("GetAuditEvents: can not starts transaction:%s", err)
+		return nil, 0, fmt.Errorf("getAuditEvents: can not starts transaction:%w", err)
+	}
+	defer transaction.Rollback()
+	var total int
+	if err = transaction.QueryRowContext(ctx, "SELECT COUNT(id) FROM audit_events"+where, args...).Scan(&total); err != nil {
+		a.logger.WithContext(ctx).Errorf("GetAuditEvents: error while scanning for total:%s", err)
+		return nil, 0, fmt.Errorf("getAuditEvents:repository error:%w", err)
+	}
+	query := fmt.Sprintf(`SELECT %s FROM audit_events%s ORDER BY id DESC LIMIT $%d OFFSET $%d`,
+		auditEventColumns, where, len(args)+1, len(args)+2)
+	rows, err := transaction.QueryContext(ctx, query, append(args, limit, (page-1)*limit)...)
+	if err != nil {
+		a.logger.WithContext(ctx).Errorf("GetAuditEvents: can not executes a query:%s", err)
+		return nil, 0, fmt.Errorf("getAuditEvents:repository error:%w", err)
+	}
+	defer rows.Close()
+	events, err := scanAuditEvents(rows, limit)
+	if err != nil {
+		a.logger.WithContext(ctx).Errorf("GetAuditEvents:%s", err)
+		return nil, 0, fmt.Errorf("getAuditEvents:repository error:%w", err)
+	}
+	return events, total, transaction.Commit()
+}
+
+const auditEventColumns = "id, created_at, actor_id, actor_role, action, target_id, ip, user_agent, request_id, diff, prev_hash, hash"
+
+// scanAuditEvents reads the rows selected with auditEventColumns
+func scanAuditEvents(rows *sql.Rows, capacity int) ([]model.AuditEvent, error) {
+	events := make([]model.AuditEvent, 0, capacity)
+	for rows.Next() {
+		var event model.AuditEvent
+		var actorID, targetID sql.NullInt64
+		var diff []byte
+		if err := rows.Scan(&event.ID, &event.CreatedAt, &actorID, &event.ActorRole, &event.Action, &targetID,
+			&event.IP, &event.UserAgent, &event.RequestID, &diff, &event.PrevHash, &event.Hash); err != nil {
+			return nil, fmt.Errorf("error while scanning for event:%w", err)
+		}
+		event.ActorID, event.TargetID = int(actorID.Int64), int(targetID.Int64)
+		if err := json.Unmarshal(diff, &event.Diff); err != nil {
+			return nil, fmt.Errorf("invalid diff of event (id = %d):%w", event.ID, err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// GetAuditChain returns up to limit events from fromID to toID in the order of the chain, zero toID means no end
+func (a *AuditPostgres) GetAuditChain(ctx context.Context, fromID int64, toID int64, limit int) ([]model.AuditEvent, error) {
+	query := fmt.Sprintf("SELECT %s FROM audit_events WHERE id >= $1 AND ($2 = 0 OR id <= $2) ORDER BY id LIMIT $3", auditEventColumns)
+	rows, err := a.db.QueryContext(ctx, query, fromID, toID, limit)
+	if err != nil {
+		a.logger.WithContext(ctx).Errorf("GetAuditChain: can not executes a query:%s", err)
+		return nil, fmt.Errorf("getAuditChain:repository error:%w", err)
+	}
+	defer rows.Close()
+	events, err := scanAuditEvents(rows, limit)
+	if err != nil {
+		a.logger.WithContext(ctx).Errorf("GetAuditChain:%s", err)
+		return nil, fmt.Errorf("getAuditChain:repository error:%w", err)
+	}
+	return events, nil
+}
+
+// GetAuditHead returns the id and the hash of the last event, zero id if there are none
+func (a *AuditPostgres) GetAuditHead(ctx context.Context) (int64, string, error) {
+	var id int64
+	var hash string
+	err := a.db.QueryRowContext(ctx, "SELECT id, hash FROM audit_events ORDER BY id DESC LIMIT 1").Scan(&id, &hash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		a.logger.WithContext(ctx).Errorf("GetAuditHead: error while scanning for head:%s", err)
+		return 0, "", fmt.Errorf("getAuditHead:repository error:%w", err)
+	}
+	return id, hash, nil
+}
+
+// CreateAuditCheckpoint stores the checkpoint unless there is one at the same or a later event
+func (a *AuditPostgres) CreateAuditCheckpoint(ctx context.Context, checkpoint *model.AuditCheckpoint) (bool, error) {
+	query := `INSERT INTO audit_checkpoints (event_id, hash, created_at, signature) SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM audit_checkpoints WHERE event_id >= $1) RETURNING id`
+	row := a.db.QueryRowContext(ctx, query, checkpoint.EventID, checkpoint.Hash, checkpoint.CreatedAt, checkpoint.Signature)
+	if err := row.Scan(&checkpoint.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		a.logger.WithContext(ctx).Errorf("CreateAuditCheckpoint: error while scanning for id:%s", err)
+		return false, fmt.Errorf("createAuditCheckpoint:repository error:%w", err)
+	}
+	return true, nil
+}
+
+// GetAuditCheckpoints returns every checkpoint in the order of the chain
+func (a *AuditPostgres) GetAuditCheckpoints(ctx context.Context) ([]model.AuditCheckpoint, error) {
+	rows, err := a.db.QueryContext(ctx, "SELECT id, event_id, hash, created_at, signature FROM audit_checkpoints ORDER BY event_id")
+	if err != nil {
+		a.logger.WithContext(ctx).Errorf("GetAuditCheckpoints: can not executes a query:%s", err)
+		return nil, fmt.Errorf("getAuditCheckpoints:repository error:%w", err)
+	}
+	defer rows.Close()
+	var checkpoints []model.AuditCheckpoint
+	for rows.Next() {
+		var checkpoint model.AuditCheckpoint
+		if err = rows.Scan(&checkpoint.ID, &checkpoint.EventID, &checkpoint.Hash, &checkpoint.CreatedAt, &checkpoint.Signature); err != nil {
+			a.logger.WithContext(ctx).Errorf("GetAuditCheckpoints: error while scanning for checkpoint:%s", err)
+			return nil, fmt.Errorf("getAuditCheckpoints:repository error:%w", err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	if err = rows.Err(); err != nil {
+		a.logger.WithContext(ctx).Errorf("GetAuditCheckpoints:%s", err)
+		return nil, fmt.Errorf("getAuditCheckpoints:repository error:%w", err)
+	}
+	return checkpoints, nil
+}
@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/audit"
+	"testing"
+	"time"
+)
+
+// expectAuditEvent expects the event of the action on the target to be chained to the first event
+func expectAuditEvent(mock sqlmock.Sqlmock, action string, targetID int) {
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(auditChainLock).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1").
+		WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("first"))
+	mock.ExpectQuery("INSERT INTO audit_events (.+) RETURNING id").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), action, int64(targetID),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "first", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+}
+
+func TestRepository_insertAuditEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	newEvent := func() *model.AuditEvent {
+		return &model.AuditEvent{
+			CreatedAt: time.Date(2022, 03, 11, 1, 0, 0, 123456789, time.UTC),
+			ActorID:   1,
+			ActorRole: "Superadmin",
+			Action:    model.AuditUserDeleted,
+			TargetID:  2,
+			IP:        "127.0.0.1",
+			UserAgent: "test",
+			RequestID: "id",
+			Diff:      map[string]model.AuditChange{"deleted": {Old: false, New: true}},
+		}
+	}
+
+	testTable := []struct {
+		name             string
+		mock             func(hash string)
+		expectedPrevHash string
+		expectedError    bool
+	}{
+		{
+			name: "OK",
+			mock: func(hash string) {
+				mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(auditChainLock).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1").
+					WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("previous"))
+				mock.ExpectQuery("INSERT INTO audit_events (.+) RETURNING id").
+					WithArgs(time.Date(2022, 03, 11, 1, 0, 0, 123456000, time.UTC), int64(1), "Superadmin", model.AuditUserDeleted, int64(2),
+						"127.0.0.1", "test", "id", []byte(`{"deleted":{"old":false,"new":true}}`), "previous", hash).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+			},
+			expectedPrevHash: "previous",
+		},
+		{
+			name: "First event",
+			mock: func(hash string) {
+				mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(auditChainLock).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery("SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1").
+					WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+				mock.ExpectQuery("INSERT INTO audit_events (.+) RETURNING id").
+					WithArgs(sqlmock.AnyArg(), int64(1), "Superadmin", model.AuditUserDeleted, int64(2),
+						"127.0.0.1", "test", "id", sqlmock.AnyArg(), "", hash).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+			},
+		},
+		{
+			name: "Lock error",
+			mock: func(hash string) {
+				mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(auditChainLock).WillReturnError(errors.New("lock error"))
+			},
+			expectedError: true,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := newEvent()
+			expected.CreatedAt = expected.CreatedAt.Truncate(time.Microsecond)
+			expected.PrevHash = tt.expectedPrevHash
+			hash, err := audit.Hash(expected)
+			assert.NoError(t, err)
+			mock.ExpectBegin()
+			tt.mock(hash)
+
+			tx, err := db.Begin()
+			assert.NoError(t, err)
+			event := newEvent()
+			err = insertAuditEvent(context.Background(), tx, event)
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(7), event.ID)
+				assert.Equal(t, tt.expectedPrevHash, event.PrevHash)
+				assert.Equal(t, hash, event.Hash)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_GetAuditEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+	createdAt := time.Date(2022, 03, 11, 1, 0, 0, 0, time.UTC)
+	from := time.Date(2022, 03, 1, 0, 0, 0, 0, time.UTC)
+	columns := []string{"id", "created_at", "actor_id", "actor_role", "action", "target_id", "ip", "user_agent", "request_id", "diff", "prev_hash", "hash"}
+
+	testTable := []struct {
+		name           string
+		filters        *model.AuditFilters
+		mock           func()
+		expectedEvents []model.AuditEvent
+		expectedTotal  int
+		expectedError  bool
+	}{
+		{
+			name:    "OK",
+			filters: &model.AuditFilters{ActorID: 1, Action: model.AuditUserDeleted, From: from},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT COUNT(.+) FROM audit_events WHERE actor_id = \\$1 AND action = \\$2 AND created_at >= \\$3").
+					WithArgs(1, model.AuditUserDeleted, from).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+				rows := sqlmock.NewRows(columns).
+					AddRow(11, createdAt, 1, "Superadmin", model.AuditUserDeleted, 2, "127.0.0.1", "test", "id", []byte(`{"deleted":{"old":false,"new":true}}`), "previous", "hash")
+				mock.ExpectQuery("SELECT (.+) FROM audit_events WHERE (.+) ORDER BY id DESC LIMIT \\$4 OFFSET \\$5").
+					WithArgs(1, model.AuditUserDeleted, from, 10, 10).WillReturnRows(rows)
+				mock.ExpectCommit()
+			},
+			expectedEvents: []model.AuditEvent{{
+				ID:        11,
+				CreatedAt: createdAt,
+				ActorID:   1,
+				ActorRole: "Superadmin",
+				Action:    model.AuditUserDeleted,
+				TargetID:  2,
+				IP:        "127.0.0.1",
+				UserAgent: "test",
+				RequestID: "id",
+				Diff:      map[string]model.AuditChange{"deleted": {Old: false, New: true}},
+				PrevHash:  "previous",
+				Hash:      "hash",
+			}},
+			expectedTotal: 11,
+		},
+		{
+			name:    "Without actor",
+			filters: &model.AuditFilters{},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT COUNT(.+) FROM audit_events$").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+				rows := sqlmock.NewRows(columns).
+					AddRow(10, createdAt, nil, "", model.AuditPasswordResetRequested, 2, "127.0.0.1", "test", "id", []byte(`{}`), "", "")
+				mock.ExpectQuery("SELECT (.+) FROM audit_events ORDER BY id DESC LIMIT \\$1 OFFSET \\$2").
+					WithArgs(10, 10).WillReturnRows(rows)
+				mock.ExpectCommit()
+			},
+			expectedEvents: []model.AuditEvent{{
+				ID:        10,
+				CreatedAt: createdAt,
+				Action:    model.AuditPasswordResetRequested,
+				TargetID:  2,
+				IP:        "127.0.0.1",
+				UserAgent: "test",
+				RequestID: "id",
+				Diff:      map[string]model.AuditChange{},
+			}},
+			expectedTotal: 11,
+		},
+		{
+			name:    "Repository error",
+			filters: &model.AuditFilters{},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT COUNT(.+) FROM audit_events").WillReturnError(errors.New("repository error"))
+				mock.ExpectRollback()
+			},
+			expectedError: true,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			events, total, err := r.GetAuditEvents(context.Background(), tt.filters, 2, 10)
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedEvents, events)
+				assert.Equal(t, tt.expectedTotal, total)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_GetAuditChain(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+	createdAt := time.Date(2022, 03, 11, 1, 0, 0, 0, time.UTC)
+	columns := []string{"id", "created_at", "actor_id", "actor_role", "action", "target_id", "ip", "user_agent", "request_id", "diff", "prev_hash", "hash"}
+
+	rows := sqlmock.NewRows(columns).
+		AddRow(3, createdAt, 1, "Superadmin", model.AuditStaffCreated, 2, "", "", "", []byte(`{}`), "second", "third").
+		AddRow(4, createdAt, 1, "Superadmin", model.AuditUserDeleted, 2, "", "", "", []byte(`{}`), "third", "fourth")
+	mock.ExpectQuery("SELECT (.+) FROM audit_events WHERE id >= \\$1 AND \\(\\$2 = 0 OR id <= \\$2\\) ORDER BY id LIMIT \\$3").
+		WithArgs(int64(3), int64(4), 10).WillReturnRows(rows)
+
+	events, err := r.GetAuditChain(context.Background(), 3, 4, 10)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, int64(4), events[1].ID)
+	assert.Equal(t, "third", events[1].PrevHash)
+	assert.Equal(t, "fourth", events[1].Hash)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_CreateAuditCheckpoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+	checkpoint := &model.AuditCheckpoint{EventID: 4, Hash: "fourth", CreatedAt: time.Date(2022, 03, 11, 1, 0, 0, 0, time.UTC), Signature: "signature"}
+
+	testTable := []struct {
+		name            string
+		mock            func()
+		expectedCreated bool
+		expectedError   bool
+	}{
+		{
+			name: "OK",
+			mock: func() {
+				mock.ExpectQuery("INSERT INTO audit_checkpoints (.+) WHERE NOT EXISTS (.+) RETURNING id").
+					WithArgs(int64(4), "fourth", checkpoint.CreatedAt, "signature").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+			expectedCreated: true,
+		},
+		{
+			name: "Already signed",
+			mock: func() {
+				mock.ExpectQuery("INSERT INTO audit_checkpoints (.+) WHERE NOT EXISTS (.+) RETURNING id").
+					WithArgs(int64(4), "fourth", checkpoint.CreatedAt, "signature").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+		},
+		{
+			name: "Repository error",
+			mock: func() {
+				mock.ExpectQuery("INSERT INTO audit_checkpoints (.+) WHERE NOT EXISTS (.+) RETURNING id").
+					WithArgs(int64(4), "fourth", checkpoint.CreatedAt, "signature").WillReturnError(errors.New("repository error"))
+			},
+			expectedError: true,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			created, err := r.CreateAuditCheckpoint(context.Background(), checkpoint)
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedCreated, created)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+)
+
+type HealthPostgres struct {
+	db     *sql.DB
+	logger logging.Logger
+}
+
+func NewHealthPostgres(db *sql.DB, logger logging.Logger) *HealthPostgres {
+	return &HealthPostgres{db: db, logger: logger}
+}
+
+// Ping checks that the database answers, a new connection is made if the pool has none
+func (h *HealthPostgres) Ping(ctx context.Context) error {
+	if err := h.db.PingContext(ctx); err != nil {
+		h.logger.WithContext(ctx).Errorf("Ping: error while pinging database:%s", err)
+		return fmt.Errorf("ping: repository error:%w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRepository_Ping(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+
+	testTable := []struct {
+		name          string
+		mock          func()
+		expectedError bool
+	}{
+		{
+			name: "OK",
+			mock: func() {
+				mock.ExpectPing()
+			},
+			expectedError: false,
+		},
+		{
+			name: "Ping error",
+			mock: func() {
+				mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+			},
+			expectedError: true,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			err := r.Ping(context.Background())
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
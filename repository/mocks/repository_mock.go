@@ -5,7 +5,9 @@
 package mock_repository
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	model "stlab.itechart-group.com/go/food_delivery/authentication_service/model"
@@ -35,181 +37,748 @@ func (m *MockAppUser) EXPECT() *MockAppUserMockRecorder {
 }
 
 // CheckEmail mocks base method.
-func (m *MockAppUser) CheckEmail(email string) error {
+func (m *MockAppUser) CheckEmail(ctx context.Context, email string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CheckEmail", email)
+	ret := m.ctrl.Call(m, "CheckEmail", ctx, email)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CheckEmail indicates an expected call of CheckEmail.
-func (mr *MockAppUserMockRecorder) CheckEmail(email interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) CheckEmail(ctx, email interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckEmail", reflect.TypeOf((*MockAppUser)(nil).CheckEmail), email)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckEmail", reflect.TypeOf((*MockAppUser)(nil).CheckEmail), ctx, email)
 }
 
 // CreateCustomer mocks base method.
-func (m *MockAppUser) CreateCustomer(User *model.CreateCustomer) (int, error) {
+func (m *MockAppUser) CreateCustomer(ctx context.Context, User *model.CreateCustomer) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateCustomer", User)
+	ret := m.ctrl.Call(m, "CreateCustomer", ctx, User)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateCustomer indicates an expected call of CreateCustomer.
-func (mr *MockAppUserMockRecorder) CreateCustomer(User interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) CreateCustomer(ctx, User interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCustomer", reflect.TypeOf((*MockAppUser)(nil).CreateCustomer), User)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCustomer", reflect.TypeOf((*MockAppUser)(nil).CreateCustomer), ctx, User)
 }
 
 // CreateStaff mocks base method.
-func (m *MockAppUser) CreateStaff(User *model.CreateStaff) (int, error) {
+func (m *MockAppUser) CreateStaff(ctx context.Context, User *model.CreateStaff, event *model.AuditEvent) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateStaff", User)
+	ret := m.ctrl.Call(m, "CreateStaff", ctx, User, event)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateStaff indicates an expected call of CreateStaff.
-func (mr *MockAppUserMockRecorder) CreateStaff(User interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) CreateStaff(ctx, User, event interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateStaff", reflect.TypeOf((*MockAppUser)(nil).CreateStaff), User)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateStaff", reflect.TypeOf((*MockAppUser)(nil).CreateStaff), ctx, User, event)
 }
 
 // DeleteUserByID mocks base method.
-func (m *MockAppUser) DeleteUserByID(id int) (int, error) {
+func (m *MockAppUser) DeleteUserByID(ctx context.Context, id int, event *model.AuditEvent) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUserByID", id)
+	ret := m.ctrl.Call(m, "DeleteUserByID", ctx, id, event)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DeleteUserByID indicates an expected call of DeleteUserByID.
-func (mr *MockAppUserMockRecorder) DeleteUserByID(id interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) DeleteUserByID(ctx, id, event interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserByID", reflect.TypeOf((*MockAppUser)(nil).DeleteUserByID), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserByID", reflect.TypeOf((*MockAppUser)(nil).DeleteUserByID), ctx, id, event)
 }
 
-// GetUserAll mocks base method.
-func (m *MockAppUser) GetUserAll(page, limit int) ([]model.ResponseUser, int, error) {
+// GetUserByEmail mocks base method.
+func (m *MockAppUser) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", ctx, email)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockAppUserMockRecorder) GetUserByEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockAppUser)(nil).GetUserByEmail), ctx, email)
+}
+
+// GetUserByID mocks base method.
+func (m *MockAppUser) GetUserByID(ctx context.Context, id int) (*model.ResponseUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, id)
+	ret0, _ := ret[0].(*model.ResponseUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockAppUserMockRecorder) GetUserByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockAppUser)(nil).GetUserByID), ctx, id)
+}
+
+// GetUserPasswordByID mocks base method.
+func (m *MockAppUser) GetUserPasswordByID(ctx context.Context, id int) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserAll", page, limit)
+	ret := m.ctrl.Call(m, "GetUserPasswordByID", ctx, id)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserPasswordByID indicates an expected call of GetUserPasswordByID.
+func (mr *MockAppUserMockRecorder) GetUserPasswordByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserPasswordByID", reflect.TypeOf((*MockAppUser)(nil).GetUserPasswordByID), ctx, id)
+}
+
+// GetUsers mocks base method.
+func (m *MockAppUser) GetUsers(ctx context.Context, page, limit int, filters *model.RequestFilters, sort []model.SortField) ([]model.ResponseUser, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsers", ctx, page, limit, filters, sort)
 	ret0, _ := ret[0].([]model.ResponseUser)
 	ret1, _ := ret[1].(int)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
 
-// GetUserAll indicates an expected call of GetUserAll.
-func (mr *MockAppUserMockRecorder) GetUserAll(page, limit interface{}) *gomock.Call {
+// GetUsers indicates an expected call of GetUsers.
+func (mr *MockAppUserMockRecorder) GetUsers(ctx, page, limit, filters, sort interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockAppUser)(nil).GetUsers), ctx, page, limit, filters, sort)
+}
+
+// GetUsersByCursor mocks base method.
+func (m *MockAppUser) GetUsersByCursor(ctx context.Context, filters *model.RequestFilters, query *model.CursorQuery) ([]model.ResponseUser, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsersByCursor", ctx, filters, query)
+	ret0, _ := ret[0].([]model.ResponseUser)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetUsersByCursor indicates an expected call of GetUsersByCursor.
+func (mr *MockAppUserMockRecorder) GetUsersByCursor(ctx, filters, query interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserAll", reflect.TypeOf((*MockAppUser)(nil).GetUserAll), page, limit)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByCursor", reflect.TypeOf((*MockAppUser)(nil).GetUsersByCursor), ctx, filters, query)
 }
 
-// GetUserByDataFilter mocks base method.
-func (m *MockAppUser) GetUserByDataFilter(page, limit int, filters *model.RequestFilters) ([]model.ResponseUser, int, error) {
+// GetUsersByIDs mocks base method.
+func (m *MockAppUser) GetUsersByIDs(ctx context.Context, ids []int) ([]model.ResponseUser, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByDataFilter", page, limit, filters)
+	ret := m.ctrl.Call(m, "GetUsersByIDs", ctx, ids)
 	ret0, _ := ret[0].([]model.ResponseUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersByIDs indicates an expected call of GetUsersByIDs.
+func (mr *MockAppUserMockRecorder) GetUsersByIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByIDs", reflect.TypeOf((*MockAppUser)(nil).GetUsersByIDs), ctx, ids)
+}
+
+// LockUser mocks base method.
+func (m *MockAppUser) LockUser(ctx context.Context, id int, until time.Time, maxAttempts int, event *model.AuditEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockUser", ctx, id, until, maxAttempts, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockUser indicates an expected call of LockUser.
+func (mr *MockAppUserMockRecorder) LockUser(ctx, id, until, maxAttempts, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockUser", reflect.TypeOf((*MockAppUser)(nil).LockUser), ctx, id, until, maxAttempts, event)
+}
+
+// RegisterFailedLogin mocks base method.
+func (m *MockAppUser) RegisterFailedLogin(ctx context.Context, id int) (int, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterFailedLogin", ctx, id)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(int)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
 
-// GetUserByDataFilter indicates an expected call of GetUserByDataFilter.
-func (mr *MockAppUserMockRecorder) GetUserByDataFilter(page, limit, filters interface{}) *gomock.Call {
+// RegisterFailedLogin indicates an expected call of RegisterFailedLogin.
+func (mr *MockAppUserMockRecorder) RegisterFailedLogin(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByDataFilter", reflect.TypeOf((*MockAppUser)(nil).GetUserByDataFilter), page, limit, filters)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterFailedLogin", reflect.TypeOf((*MockAppUser)(nil).RegisterFailedLogin), ctx, id)
 }
 
-// GetUserByEmail mocks base method.
-func (m *MockAppUser) GetUserByEmail(email string) (*model.User, error) {
+// RestoreUser mocks base method.
+func (m *MockAppUser) RestoreUser(ctx context.Context, id int, event *model.AuditEvent) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByEmail", email)
-	ret0, _ := ret[0].(*model.User)
+	ret := m.ctrl.Call(m, "RestoreUser", ctx, id, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockAppUserMockRecorder) RestoreUser(ctx, id, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockAppUser)(nil).RestoreUser), ctx, id, event)
+}
+
+// UnlockUser mocks base method.
+func (m *MockAppUser) UnlockUser(ctx context.Context, id int, event *model.AuditEvent) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockUser", ctx, id, event)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserByEmail indicates an expected call of GetUserByEmail.
-func (mr *MockAppUserMockRecorder) GetUserByEmail(email interface{}) *gomock.Call {
+// UnlockUser indicates an expected call of UnlockUser.
+func (mr *MockAppUserMockRecorder) UnlockUser(ctx, id, event interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockAppUser)(nil).GetUserByEmail), email)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockUser", reflect.TypeOf((*MockAppUser)(nil).UnlockUser), ctx, id, event)
 }
 
-// GetUserByID mocks base method.
-func (m *MockAppUser) GetUserByID(id int) (*model.ResponseUser, error) {
+// UpdateUser mocks base method.
+func (m *MockAppUser) UpdateUser(ctx context.Context, User *model.UpdateUser, event *model.AuditEvent) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByID", id)
-	ret0, _ := ret[0].(*model.ResponseUser)
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, User, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockAppUserMockRecorder) UpdateUser(ctx, User, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockAppUser)(nil).UpdateUser), ctx, User, event)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockAppUser) VerifyEmail(ctx context.Context, id int, email string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, id, email)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserByID indicates an expected call of GetUserByID.
-func (mr *MockAppUserMockRecorder) GetUserByID(id interface{}) *gomock.Call {
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockAppUserMockRecorder) VerifyEmail(ctx, id, email interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockAppUser)(nil).GetUserByID), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockAppUser)(nil).VerifyEmail), ctx, id, email)
+}
+
+// MockTokenRevocation is a mock of TokenRevocation interface.
+type MockTokenRevocation struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenRevocationMockRecorder
+}
+
+// MockTokenRevocationMockRecorder is the mock recorder for MockTokenRevocation.
+type MockTokenRevocationMockRecorder struct {
+	mock *MockTokenRevocation
 }
 
-// GetUserByRoleFilter mocks base method.
-func (m *MockAppUser) GetUserByRoleFilter(page, limit int, filters *model.RequestFilters) ([]model.ResponseUser, int, error) {
+// NewMockTokenRevocation creates a new mock instance.
+func NewMockTokenRevocation(ctrl *gomock.Controller) *MockTokenRevocation {
+	mock := &MockTokenRevocation{ctrl: ctrl}
+	mock.recorder = &MockTokenRevocationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTokenRevocation) EXPECT() *MockTokenRevocationMockRecorder {
+	return m.recorder
+}
+
+// DeleteExpiredRevocations mocks base method.
+func (m *MockTokenRevocation) DeleteExpiredRevocations(ctx context.Context) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByRoleFilter", page, limit, filters)
-	ret0, _ := ret[0].([]model.ResponseUser)
-	ret1, _ := ret[1].(int)
+	ret := m.ctrl.Call(m, "DeleteExpiredRevocations", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredRevocations indicates an expected call of DeleteExpiredRevocations.
+func (mr *MockTokenRevocationMockRecorder) DeleteExpiredRevocations(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRevocations", reflect.TypeOf((*MockTokenRevocation)(nil).DeleteExpiredRevocations), ctx)
+}
+
+// IsTokenRevoked mocks base method.
+func (m *MockTokenRevocation) IsTokenRevoked(ctx context.Context, userId int, tokenHash string, issuedAt time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenRevoked", ctx, userId, tokenHash, issuedAt)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenRevoked indicates an expected call of IsTokenRevoked.
+func (mr *MockTokenRevocationMockRecorder) IsTokenRevoked(ctx, userId, tokenHash, issuedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenRevoked", reflect.TypeOf((*MockTokenRevocation)(nil).IsTokenRevoked), ctx, userId, tokenHash, issuedAt)
+}
+
+// RevokeToken mocks base method.
+func (m *MockTokenRevocation) RevokeToken(ctx context.Context, userId int, tokenHash string, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", ctx, userId, tokenHash, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockTokenRevocationMockRecorder) RevokeToken(ctx, userId, tokenHash, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockTokenRevocation)(nil).RevokeToken), ctx, userId, tokenHash, expiresAt)
+}
+
+// RevokeUserTokens mocks base method.
+func (m *MockTokenRevocation) RevokeUserTokens(ctx context.Context, userId int, revokedAt, expiresAt time.Time, event *model.AuditEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserTokens", ctx, userId, revokedAt, expiresAt, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserTokens indicates an expected call of RevokeUserTokens.
+func (mr *MockTokenRevocationMockRecorder) RevokeUserTokens(ctx, userId, revokedAt, expiresAt, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserTokens", reflect.TypeOf((*MockTokenRevocation)(nil).RevokeUserTokens), ctx, userId, revokedAt, expiresAt, event)
+}
+
+// MockRateLimit is a mock of RateLimit interface.
+type MockRateLimit struct {
+	ctrl     *gomock.Controller
+	recorder *MockRateLimitMockRecorder
+}
+
+// MockRateLimitMockRecorder is the mock recorder for MockRateLimit.
+type MockRateLimitMockRecorder struct {
+	mock *MockRateLimit
+}
+
+// NewMockRateLimit creates a new mock instance.
+func NewMockRateLimit(ctrl *gomock.Controller) *MockRateLimit {
+	mock := &MockRateLimit{ctrl: ctrl}
+	mock.recorder = &MockRateLimitMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRateLimit) EXPECT() *MockRateLimitMockRecorder {
+	return m.recorder
+}
+
+// DeleteExpiredRateLimits mocks base method.
+func (m *MockRateLimit) DeleteExpiredRateLimits(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredRateLimits", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredRateLimits indicates an expected call of DeleteExpiredRateLimits.
+func (mr *MockRateLimitMockRecorder) DeleteExpiredRateLimits(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRateLimits", reflect.TypeOf((*MockRateLimit)(nil).DeleteExpiredRateLimits), ctx)
+}
+
+// HitRateLimit mocks base method.
+func (m *MockRateLimit) HitRateLimit(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HitRateLimit", ctx, key, window)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(time.Time)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
 
-// GetUserByRoleFilter indicates an expected call of GetUserByRoleFilter.
-func (mr *MockAppUserMockRecorder) GetUserByRoleFilter(page, limit, filters interface{}) *gomock.Call {
+// HitRateLimit indicates an expected call of HitRateLimit.
+func (mr *MockRateLimitMockRecorder) HitRateLimit(ctx, key, window interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByRoleFilter", reflect.TypeOf((*MockAppUser)(nil).GetUserByRoleFilter), page, limit, filters)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HitRateLimit", reflect.TypeOf((*MockRateLimit)(nil).HitRateLimit), ctx, key, window)
 }
 
-// GetUserPasswordByID mocks base method.
-func (m *MockAppUser) GetUserPasswordByID(id int) (string, error) {
+// MockPasswordReset is a mock of PasswordReset interface.
+type MockPasswordReset struct {
+	ctrl     *gomock.Controller
+	recorder *MockPasswordResetMockRecorder
+}
+
+// MockPasswordResetMockRecorder is the mock recorder for MockPasswordReset.
+type MockPasswordResetMockRecorder struct {
+	mock *MockPasswordReset
+}
+
+// NewMockPasswordReset creates a new mock instance.
+func NewMockPasswordReset(ctrl *gomock.Controller) *MockPasswordReset {
+	mock := &MockPasswordReset{ctrl: ctrl}
+	mock.recorder = &MockPasswordResetMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPasswordReset) EXPECT() *MockPasswordResetMockRecorder {
+	return m.recorder
+}
+
+// CreatePasswordReset mocks base method.
+func (m *MockPasswordReset) CreatePasswordReset(ctx context.Context, email, tokenHash string, expiresAt time.Time, event *model.AuditEvent) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserPasswordByID", id)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "CreatePasswordReset", ctx, email, tokenHash, expiresAt, event)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserPasswordByID indicates an expected call of GetUserPasswordByID.
-func (mr *MockAppUserMockRecorder) GetUserPasswordByID(id interface{}) *gomock.Call {
+// CreatePasswordReset indicates an expected call of CreatePasswordReset.
+func (mr *MockPasswordResetMockRecorder) CreatePasswordReset(ctx, email, tokenHash, expiresAt, event interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserPasswordByID", reflect.TypeOf((*MockAppUser)(nil).GetUserPasswordByID), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordReset", reflect.TypeOf((*MockPasswordReset)(nil).CreatePasswordReset), ctx, email, tokenHash, expiresAt, event)
 }
 
-// RestorePassword mocks base method.
-func (m *MockAppUser) RestorePassword(restore *model.RestorePassword) error {
+// DeleteExpiredPasswordResets mocks base method.
+func (m *MockPasswordReset) DeleteExpiredPasswordResets(ctx context.Context) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RestorePassword", restore)
+	ret := m.ctrl.Call(m, "DeleteExpiredPasswordResets", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredPasswordResets indicates an expected call of DeleteExpiredPasswordResets.
+func (mr *MockPasswordResetMockRecorder) DeleteExpiredPasswordResets(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredPasswordResets", reflect.TypeOf((*MockPasswordReset)(nil).DeleteExpiredPasswordResets), ctx)
+}
+
+// ResetPassword mocks base method.
+func (m *MockPasswordReset) ResetPassword(ctx context.Context, tokenHash, passwordHash string, event *model.AuditEvent) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, tokenHash, passwordHash, event)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockPasswordResetMockRecorder) ResetPassword(ctx, tokenHash, passwordHash, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockPasswordReset)(nil).ResetPassword), ctx, tokenHash, passwordHash, event)
+}
+
+// MockTwoFactor is a mock of TwoFactor interface.
+type MockTwoFactor struct {
+	ctrl     *gomock.Controller
+	recorder *MockTwoFactorMockRecorder
+}
+
+// MockTwoFactorMockRecorder is the mock recorder for MockTwoFactor.
+type MockTwoFactorMockRecorder struct {
+	mock *MockTwoFactor
+}
+
+// NewMockTwoFactor creates a new mock instance.
+func NewMockTwoFactor(ctrl *gomock.Controller) *MockTwoFactor {
+	mock := &MockTwoFactor{ctrl: ctrl}
+	mock.recorder = &MockTwoFactorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTwoFactor) EXPECT() *MockTwoFactorMockRecorder {
+	return m.recorder
+}
+
+// DisableTOTP mocks base method.
+func (m *MockTwoFactor) DisableTOTP(ctx context.Context, userId int, event *model.AuditEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTOTP", ctx, userId, event)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// RestorePassword indicates an expected call of RestorePassword.
-func (mr *MockAppUserMockRecorder) RestorePassword(restore interface{}) *gomock.Call {
+// DisableTOTP indicates an expected call of DisableTOTP.
+func (mr *MockTwoFactorMockRecorder) DisableTOTP(ctx, userId, event interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestorePassword", reflect.TypeOf((*MockAppUser)(nil).RestorePassword), restore)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTOTP", reflect.TypeOf((*MockTwoFactor)(nil).DisableTOTP), ctx, userId, event)
 }
 
-// UpdateUser mocks base method.
-func (m *MockAppUser) UpdateUser(User *model.UpdateUser) error {
+// EnableTOTP mocks base method.
+func (m *MockTwoFactor) EnableTOTP(ctx context.Context, userId int, codeHashes []string, event *model.AuditEvent) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUser", User)
+	ret := m.ctrl.Call(m, "EnableTOTP", ctx, userId, codeHashes, event)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateUser indicates an expected call of UpdateUser.
-func (mr *MockAppUserMockRecorder) UpdateUser(User interface{}) *gomock.Call {
+// EnableTOTP indicates an expected call of EnableTOTP.
+func (mr *MockTwoFactorMockRecorder) EnableTOTP(ctx, userId, codeHashes, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTP", reflect.TypeOf((*MockTwoFactor)(nil).EnableTOTP), ctx, userId, codeHashes, event)
+}
+
+// GetTwoFactor mocks base method.
+func (m *MockTwoFactor) GetTwoFactor(ctx context.Context, userId int) (*model.TwoFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTwoFactor", ctx, userId)
+	ret0, _ := ret[0].(*model.TwoFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTwoFactor indicates an expected call of GetTwoFactor.
+func (mr *MockTwoFactorMockRecorder) GetTwoFactor(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTwoFactor", reflect.TypeOf((*MockTwoFactor)(nil).GetTwoFactor), ctx, userId)
+}
+
+// GetTwoFactorRoles mocks base method.
+func (m *MockTwoFactor) GetTwoFactorRoles(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTwoFactorRoles", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTwoFactorRoles indicates an expected call of GetTwoFactorRoles.
+func (mr *MockTwoFactorMockRecorder) GetTwoFactorRoles(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTwoFactorRoles", reflect.TypeOf((*MockTwoFactor)(nil).GetTwoFactorRoles), ctx)
+}
+
+// SetTOTPChallenge mocks base method.
+func (m *MockTwoFactor) SetTOTPChallenge(ctx context.Context, userId int, nonce string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTOTPChallenge", ctx, userId, nonce)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTOTPChallenge indicates an expected call of SetTOTPChallenge.
+func (mr *MockTwoFactorMockRecorder) SetTOTPChallenge(ctx, userId, nonce interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTOTPChallenge", reflect.TypeOf((*MockTwoFactor)(nil).SetTOTPChallenge), ctx, userId, nonce)
+}
+
+// SetTOTPSecret mocks base method.
+func (m *MockTwoFactor) SetTOTPSecret(ctx context.Context, userId int, secret string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTOTPSecret", ctx, userId, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTOTPSecret indicates an expected call of SetTOTPSecret.
+func (mr *MockTwoFactorMockRecorder) SetTOTPSecret(ctx, userId, secret interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTOTPSecret", reflect.TypeOf((*MockTwoFactor)(nil).SetTOTPSecret), ctx, userId, secret)
+}
+
+// SetTwoFactorRoles mocks base method.
+func (m *MockTwoFactor) SetTwoFactorRoles(ctx context.Context, roles []string, event *model.AuditEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTwoFactorRoles", ctx, roles, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTwoFactorRoles indicates an expected call of SetTwoFactorRoles.
+func (mr *MockTwoFactorMockRecorder) SetTwoFactorRoles(ctx, roles, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTwoFactorRoles", reflect.TypeOf((*MockTwoFactor)(nil).SetTwoFactorRoles), ctx, roles, event)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockTwoFactor) UseRecoveryCode(ctx context.Context, userId int, codeHash string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, userId, codeHash)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockTwoFactorMockRecorder) UseRecoveryCode(ctx, userId, codeHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockTwoFactor)(nil).UseRecoveryCode), ctx, userId, codeHash)
+}
+
+// UseTOTPChallenge mocks base method.
+func (m *MockTwoFactor) UseTOTPChallenge(ctx context.Context, userId int, nonce string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPChallenge", ctx, userId, nonce)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPChallenge indicates an expected call of UseTOTPChallenge.
+func (mr *MockTwoFactorMockRecorder) UseTOTPChallenge(ctx, userId, nonce interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPChallenge", reflect.TypeOf((*MockTwoFactor)(nil).UseTOTPChallenge), ctx, userId, nonce)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockTwoFactor) UseTOTPStep(ctx context.Context, userId int, step int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, userId, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockTwoFactorMockRecorder) UseTOTPStep(ctx, userId, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockTwoFactor)(nil).UseTOTPStep), ctx, userId, step)
+}
+
+// MockHealth is a mock of Health interface.
+type MockHealth struct {
+	ctrl     *gomock.Controller
+	recorder *MockHealthMockRecorder
+}
+
+// MockHealthMockRecorder is the mock recorder for MockHealth.
+type MockHealthMockRecorder struct {
+	mock *MockHealth
+}
+
+// NewMockHealth creates a new mock instance.
+func NewMockHealth(ctrl *gomock.Controller) *MockHealth {
+	mock := &MockHealth{ctrl: ctrl}
+	mock.recorder = &MockHealthMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHealth) EXPECT() *MockHealthMockRecorder {
+	return m.recorder
+}
+
+// Ping mocks base method.
+func (m *MockHealth) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockHealthMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockHealth)(nil).Ping), ctx)
+}
+
+// MockAudit is a mock of Audit interface.
+type MockAudit struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditMockRecorder
+}
+
+// MockAuditMockRecorder is the mock recorder for MockAudit.
+type MockAuditMockRecorder struct {
+	mock *MockAudit
+}
+
+// NewMockAudit creates a new mock instance.
+func NewMockAudit(ctrl *gomock.Controller) *MockAudit {
+	mock := &MockAudit{ctrl: ctrl}
+	mock.recorder = &MockAuditMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAudit) EXPECT() *MockAuditMockRecorder {
+	return m.recorder
+}
+
+// CreateAuditCheckpoint mocks base method.
+func (m *MockAudit) CreateAuditCheckpoint(ctx context.Context, checkpoint *model.AuditCheckpoint) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAuditCheckpoint", ctx, checkpoint)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAuditCheckpoint indicates an expected call of CreateAuditCheckpoint.
+func (mr *MockAuditMockRecorder) CreateAuditCheckpoint(ctx, checkpoint interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAuditCheckpoint", reflect.TypeOf((*MockAudit)(nil).CreateAuditCheckpoint), ctx, checkpoint)
+}
+
+// GetAuditChain mocks base method.
+func (m *MockAudit) GetAuditChain(ctx context.Context, fromID, toID int64, limit int) ([]model.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditChain", ctx, fromID, toID, limit)
+	ret0, _ := ret[0].([]model.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditChain indicates an expected call of GetAuditChain.
+func (mr *MockAuditMockRecorder) GetAuditChain(ctx, fromID, toID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditChain", reflect.TypeOf((*MockAudit)(nil).GetAuditChain), ctx, fromID, toID, limit)
+}
+
+// GetAuditCheckpoints mocks base method.
+func (m *MockAudit) GetAuditCheckpoints(ctx context.Context) ([]model.AuditCheckpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditCheckpoints", ctx)
+	ret0, _ := ret[0].([]model.AuditCheckpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditCheckpoints indicates an expected call of GetAuditCheckpoints.
+func (mr *MockAuditMockRecorder) GetAuditCheckpoints(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditCheckpoints", reflect.TypeOf((*MockAudit)(nil).GetAuditCheckpoints), ctx)
+}
+
+// GetAuditEvents mocks base method.
+func (m *MockAudit) GetAuditEvents(ctx context.Context, filters *model.AuditFilters, page, limit int) ([]model.AuditEvent, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditEvents", ctx, filters, page, limit)
+	ret0, _ := ret[0].([]model.AuditEvent)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetAuditEvents indicates an expected call of GetAuditEvents.
+func (mr *MockAuditMockRecorder) GetAuditEvents(ctx, filters, page, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditEvents", reflect.TypeOf((*MockAudit)(nil).GetAuditEvents), ctx, filters, page, limit)
+}
+
+// GetAuditHead mocks base method.
+func (m *MockAudit) GetAuditHead(ctx context.Context) (int64, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditHead", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetAuditHead indicates an expected call of GetAuditHead.
+func (mr *MockAuditMockRecorder) GetAuditHead(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockAppUser)(nil).UpdateUser), User)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditHead", reflect.TypeOf((*MockAudit)(nil).GetAuditHead), ctx)
 }
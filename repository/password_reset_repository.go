@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"time"
+)
+
+type PasswordResetPostgres struct {
+	db     *sql.DB
+	logger logging.Logger
+}
+
+func NewPasswordResetPostgres(db *sql.DB, logger logging.Logger) *PasswordResetPostgres {
+	return &PasswordResetPostgres{db: db, logger: logger}
+}
+
+// CreatePasswordReset stores the token hash for the active user with the given email and
+// returns the user id, the event is recorded in the same transaction
+func (p *PasswordResetPostgres) CreatePasswordReset(ctx context.Context, email string, tokenHash string, expiresAt time.Time, event *model.AuditEvent) (int, error) {
+	var userId int
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		p.logger.WithContext(ctx).Errorf("CreatePasswordReset: can not begin transaction:%s", err)
+		return 0, fmt.Errorf("createPasswordReset: can not begin transaction:%w", err)
+	}
+	defer tx.Rollback()
+	query := `INSERT INTO password_resets (user_id, token_hash, created_at, expires_at)
+		SELECT id, $2, $3, $4 FROM users WHERE email = $1 AND deleted = false RETURNING user_id`
+	row := tx.QueryRowContext(ctx, query, email, tokenHash, time.Now().UTC(), expiresAt)
+	if err := row.Scan(&userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			p.logger.WithContext(ctx).Warn("CreatePasswordReset: user with this email does not exist")
+			return 0, pkg.ErrorEmailDoesNotExist
+		}
+		p.logger.WithContext(ctx).Errorf("CreatePasswordReset: error while scanning for userId:%s", err)
+		return 0, fmt.Errorf("createPasswordReset: repository error:%w", err)
+	}
+	event.TargetID = userId
+	event.Diff = map[string]model.AuditChange{"password_reset_expires_at": {New: expiresAt}}
+	if err = insertAuditEvent(ctx, tx, event); err != nil {
+		p.logger.WithContext(ctx).Errorf("CreatePasswordReset:%s", err)
+		return 0, fmt.Errorf("createPasswordReset: repository error:%w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		p.logger.WithContext(ctx).Errorf("CreatePasswordReset: can not commit transaction:%s", err)
+		return 0, fmt.Errorf("createPasswordReset: can not commit transaction:%w", err)
+	}
+	return userId, nil
+}
+
+// ResetPassword consumes the token, sets the new password hash and records the event in
+// one transaction, other pending tokens of the user are invalidated as well
+func (p *PasswordResetPostgres) ResetPassword(ctx context.Context, tokenHash string, passwordHash string, event *model.AuditEvent) (int, error) {
+	var userId int
+	now := time.Now().UTC()
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		p.logger.WithContext(ctx).Errorf("ResetPassword: can not begin transaction:%s", err)
+		return 0, fmt.Errorf("resetPassword: can not begin transaction:%w", err)
+	}
+	defer tx.Rollback()
+	query := `UPDATE password_resets SET used_at = $1
+		WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1 RETURNING user_id`
+	if err = tx.QueryRowContext(ctx, query, now, tokenHash).Scan(&userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			p.logger.WithContext(ctx).Warn("ResetPassword: invalid or expired token was used")
+			return 0, pkg.ErrorInvalidResetToken
+		}
+		p.logger.WithContext(ctx).Errorf("ResetPassword: error while scanning for userId:%s", err)
+		return 0, fmt.Errorf("resetPassword: repository error:%w", err)
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE users SET password = $1 WHERE id = $2", passwordHash, userId); err != nil {
+		p.logger.WithContext(ctx).Errorf("ResetPassword: error while updating password:%s", err)
+		return 0, fmt.Errorf("resetPassword: repository error:%w", err)
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE password_resets SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL", now, userId); err != nil {
+		p.logger.WithContext(ctx).Errorf("ResetPassword: error while invalidating tokens:%s", err)
+		return 0, fmt.Errorf("resetPassword: repository error:%w", err)
+	}
+	event.TargetID = userId
+	event.Diff = map[string]model.AuditChange{"password": {Old: model.AuditRedacted, New: model.AuditRedacted}}
+	if err = insertAuditEvent(ctx, tx, event); err != nil {
+		p.logger.WithContext(ctx).Errorf("ResetPassword:%s", err)
+		return 0, fmt.Errorf("resetPassword: repository error:%w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		p.logger.WithContext(ctx).Errorf("ResetPassword: can not commit transaction:%s", err)
+		return 0, fmt.Errorf("resetPassword: can not commit transaction:%w", err)
+	}
+	return userId, nil
+}
+
+// DeleteExpiredPasswordResets ...
+func (p *PasswordResetPostgres) DeleteExpiredPasswordResets(ctx context.Context) (int64, error) {
+	result, err := p.db.ExecContext(ctx, "DELETE FROM password_resets WHERE expires_at <= $1", time.Now().UTC())
+	if err != nil {
+		p.logger.WithContext(ctx).Errorf("DeleteExpiredPasswordResets: error while deleting tokens:%s", err)
+		return 0, fmt.Errorf("deleteExpiredPasswordResets: repository error:%w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"testing"
+	"time"
+)
+
+func TestRepository_CreatePasswordReset(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+	expiresAt := time.Date(2022, 03, 11, 1, 0, 0, 0, time.UTC)
+
+	testTable := []struct {
+		name           string
+		mock           func()
+		expectedUserId int
+		expectedError  error
+	}{
+		{
+			name: "OK",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"user_id"}).AddRow(1)
+				mock.ExpectQuery("INSERT INTO password_resets (.+) SELECT (.+) FROM users WHERE email = (.+) AND deleted = false RETURNING user_id").
+					WithArgs("test@yandex.ru", "hash", sqlmock.AnyArg(), expiresAt).WillReturnRows(rows)
+				expectAuditEvent(mock, model.AuditPasswordResetRequested, 1)
+				mock.ExpectCommit()
+			},
+			expectedUserId: 1,
+		},
+		{
+			name: "User does not exist or is deleted",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("INSERT INTO password_resets (.+) SELECT (.+) FROM users WHERE email = (.+) AND deleted = false RETURNING user_id").
+					WithArgs("test@yandex.ru", "hash", sqlmock.AnyArg(), expiresAt).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedError: pkg.ErrorEmailDoesNotExist,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			userId, err := r.CreatePasswordReset(context.Background(), "test@yandex.ru", "hash", expiresAt, &model.AuditEvent{Action: model.AuditPasswordResetRequested})
+			assert.Equal(t, tt.expectedError, err)
+			assert.Equal(t, tt.expectedUserId, userId)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_ResetPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+
+	testTable := []struct {
+		name           string
+		mock           func()
+		expectedUserId int
+		expectedError  bool
+		expectedIs     error
+	}{
+		{
+			name: "OK",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"user_id"}).AddRow(1)
+				mock.ExpectQuery("UPDATE password_resets SET used_at (.+) WHERE token_hash (.+) RETURNING user_id").
+					WithArgs(sqlmock.AnyArg(), "hash").WillReturnRows(rows)
+				mock.ExpectExec("UPDATE users SET password").
+					WithArgs("password hash", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE password_resets SET used_at (.+) WHERE user_id").
+					WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 0))
+				expectAuditEvent(mock, model.AuditPasswordReset, 1)
+				mock.ExpectCommit()
+			},
+			expectedUserId: 1,
+		},
+		{
+			name: "Invalid token",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("UPDATE password_resets SET used_at (.+) WHERE token_hash (.+) RETURNING user_id").
+					WithArgs(sqlmock.AnyArg(), "hash").WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedError: true,
+			expectedIs:    pkg.ErrorInvalidResetToken,
+		},
+		{
+			name: "Update error",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"user_id"}).AddRow(1)
+				mock.ExpectQuery("UPDATE password_resets SET used_at (.+) WHERE token_hash (.+) RETURNING user_id").
+					WithArgs(sqlmock.AnyArg(), "hash").WillReturnRows(rows)
+				mock.ExpectExec("UPDATE users SET password").
+					WithArgs("password hash", 1).WillReturnError(errors.New("update error"))
+				mock.ExpectRollback()
+			},
+			expectedError: true,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			userId, err := r.ResetPassword(context.Background(), "hash", "password hash", &model.AuditEvent{Action: model.AuditPasswordReset})
+			if tt.expectedError {
+				assert.Error(t, err)
+				if tt.expectedIs != nil {
+					assert.True(t, errors.Is(err, tt.expectedIs))
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedUserId, userId)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"sync"
+	"time"
+)
+
+// RateLimitPostgres shares rate limit counters between all replicas of the service
+type RateLimitPostgres struct {
+	db     *sql.DB
+	logger logging.Logger
+}
+
+func NewRateLimitPostgres(db *sql.DB, logger logging.Logger) *RateLimitPostgres {
+	return &RateLimitPostgres{db: db, logger: logger}
+}
+
+// HitRateLimit increments the counter of the key, starting a new window if the previous one is over
+func (r *RateLimitPostgres) HitRateLimit(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	var hits int
+	var resetAt time.Time
+	now := time.Now().UTC()
+	query := `INSERT INTO rate_limits (key, hits, reset_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			hits = CASE WHEN rate_limits.reset_at <= $3 THEN 1 ELSE rate_limits.hits + 1 END,
+			reset_at = CASE WHEN rate_limits.reset_at <= $3 THEN EXCLUDED.reset_at ELSE rate_limits.reset_at END
+		RETURNING hits, reset_at`
+	row := r.db.QueryRowContext(ctx, query, key, now.Add(window), now)
+	if err := row.Scan(&hits, &resetAt); err != nil {
+		r.logger.WithContext(ctx).Errorf("HitRateLimit: error while scanning for hits:%s", err)
+		return 0, time.Time{}, fmt.Errorf("hitRateLimit: repository error:%w", err)
+	}
+	return hits, resetAt, nil
+}
+
+// DeleteExpiredRateLimits ...
+func (r *RateLimitPostgres) DeleteExpiredRateLimits(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM rate_limits WHERE reset_at <= $1", time.Now().UTC())
+	if err != nil {
+		r.logger.WithContext(ctx).Errorf("DeleteExpiredRateLimits: error while deleting rate limits:%s", err)
+		return 0, fmt.Errorf("deleteExpiredRateLimits: repository error:%w", err)
+	}
+	return result.RowsAffected()
+}
+
+type rateLimitEntry struct {
+	hits    int
+	resetAt time.Time
+}
+
+// RateLimitMemory keeps rate limit counters of a single instance in memory
+type RateLimitMemory struct {
+	mu      sync.Mutex
+	entries map[string]*rateLimitEntry
+}
+
+func NewRateLimitMemory() *RateLimitMemory {
+	return &RateLimitMemory{entries: make(map[string]*rateLimitEntry)}
+}
+
+// HitRateLimit increments the counter of the key, starting a new window if the previous one is over
+func (r *RateLimitMemory) HitRateLimit(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	entry, ok := r.entries[key]
+	if !ok || !entry.resetAt.After(now) {
+		entry = &rateLimitEntry{resetAt: now.Add(window)}
+		r.entries[key] = entry
+	}
+	entry.hits++
+	return entry.hits, entry.resetAt, nil
+}
+
+// DeleteExpiredRateLimits ...
+func (r *RateLimitMemory) DeleteExpiredRateLimits(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	now := time.Now().UTC()
+	for key, entry := range r.entries {
+		if !entry.resetAt.After(now) {
+			delete(r.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRepository_HitRateLimit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+	resetAt := time.Date(2022, 03, 11, 0, 1, 0, 0, time.UTC)
+
+	testTable := []struct {
+		name            string
+		mock            func()
+		expectedHits    int
+		expectedResetAt time.Time
+		expectedError   bool
+	}{
+		{
+			name: "OK",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"hits", "reset_at"}).AddRow(2, resetAt)
+				mock.ExpectQuery("INSERT INTO rate_limits (.+) ON CONFLICT (.+) RETURNING hits, reset_at").
+					WithArgs("login:ip:127.0.0.1", sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(rows)
+			},
+			expectedHits:    2,
+			expectedResetAt: resetAt,
+		},
+		{
+			name: "Query error",
+			mock: func() {
+				mock.ExpectQuery("INSERT INTO rate_limits (.+) ON CONFLICT (.+) RETURNING hits, reset_at").
+					WithArgs("login:ip:127.0.0.1", sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnError(errors.New("query error"))
+			},
+			expectedError: true,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			hits, gotResetAt, err := r.HitRateLimit(context.Background(), "login:ip:127.0.0.1", time.Minute)
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedHits, hits)
+				assert.Equal(t, tt.expectedResetAt, gotResetAt)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_RateLimitMemory(t *testing.T) {
+	r := NewRateLimitMemory()
+
+	hits, resetAt, err := r.HitRateLimit(context.Background(), "login:ip:127.0.0.1", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hits)
+	hits, secondResetAt, err := r.HitRateLimit(context.Background(), "login:ip:127.0.0.1", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, hits)
+	assert.Equal(t, resetAt, secondResetAt)
+
+	hits, _, err = r.HitRateLimit(context.Background(), "login:ip:127.0.0.2", time.Nanosecond)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hits)
+	time.Sleep(time.Millisecond)
+	hits, _, err = r.HitRateLimit(context.Background(), "login:ip:127.0.0.2", time.Nanosecond)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hits)
+
+	time.Sleep(time.Millisecond)
+	deleted, err := r.DeleteExpiredRateLimits(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
@@ -1,34 +1,99 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"time"
 )
 
 //go:generate mockgen -source=repository.go -destination=mocks/repository_mock.go
 
 type AppUser interface {
-	GetUserByID(id int) (*model.ResponseUser, error)
-	GetUserAll(page int, limit int) ([]model.ResponseUser, int, error)
-	GetUserByRoleFilter(page int, limit int, filters *model.RequestFilters) ([]model.ResponseUser, int, error)
-	GetUserByDataFilter(page int, limit int, filters *model.RequestFilters) ([]model.ResponseUser, int, error)
-	CreateStaff(User *model.CreateStaff) (int, error)
-	CreateCustomer(User *model.CreateCustomer) (int, error)
-	UpdateUser(User *model.UpdateUser) error
-	DeleteUserByID(id int) (int, error)
-	GetUserByEmail(email string) (*model.User, error)
-	GetUserPasswordByID(id int) (string, error)
-	CheckEmail(email string) error
-	RestorePassword(restore *model.RestorePassword) error
+	GetUserByID(ctx context.Context, id int) (*model.ResponseUser, error)
+	GetUsersByIDs(ctx context.Context, ids []int) ([]model.ResponseUser, error)
+	GetUsers(ctx context.Context, page int, limit int, filters *model.RequestFilters, sort []model.SortField) ([]model.ResponseUser, int, error)
+	GetUsersByCursor(ctx context.Context, filters *model.RequestFilters, query *model.CursorQuery) ([]model.ResponseUser, bool, error)
+	CreateStaff(ctx context.Context, User *model.CreateStaff, event *model.AuditEvent) (int, error)
+	CreateCustomer(ctx context.Context, User *model.CreateCustomer) (int, error)
+	UpdateUser(ctx context.Context, User *model.UpdateUser, event *model.AuditEvent) error
+	DeleteUserByID(ctx context.Context, id int, event *model.AuditEvent) (int, error)
+	RestoreUser(ctx context.Context, id int, event *model.AuditEvent) error
+	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
+	GetUserPasswordByID(ctx context.Context, id int) (string, error)
+	CheckEmail(ctx context.Context, email string) error
+	RegisterFailedLogin(ctx context.Context, id int) (int, int, error)
+	LockUser(ctx context.Context, id int, until time.Time, maxAttempts int, event *model.AuditEvent) error
+	UnlockUser(ctx context.Context, id int, event *model.AuditEvent) (int, error)
+	VerifyEmail(ctx context.Context, id int, email string) (int, error)
+}
+
+type TokenRevocation interface {
+	RevokeToken(ctx context.Context, userId int, tokenHash string, expiresAt time.Time) error
+	RevokeUserTokens(ctx context.Context, userId int, revokedAt time.Time, expiresAt time.Time, event *model.AuditEvent) error
+	IsTokenRevoked(ctx context.Context, userId int, tokenHash string, issuedAt time.Time) (bool, error)
+	DeleteExpiredRevocations(ctx context.Context) (int64, error)
+}
+
+// RateLimit counts hits of a key within a fixed window
+type RateLimit interface {
+	HitRateLimit(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+	DeleteExpiredRateLimits(ctx context.Context) (int64, error)
+}
+
+// PasswordReset keeps hashes of single-use password reset tokens
+type PasswordReset interface {
+	CreatePasswordReset(ctx context.Context, email string, tokenHash string, expiresAt time.Time, event *model.AuditEvent) (int, error)
+	ResetPassword(ctx context.Context, tokenHash string, passwordHash string, event *model.AuditEvent) (int, error)
+	DeleteExpiredPasswordResets(ctx context.Context) (int64, error)
+}
+
+// TwoFactor keeps TOTP secrets, recovery code hashes and the roles which must use a second factor
+type TwoFactor interface {
+	GetTwoFactor(ctx context.Context, userId int) (*model.TwoFactor, error)
+	SetTOTPSecret(ctx context.Context, userId int, secret string) error
+	EnableTOTP(ctx context.Context, userId int, codeHashes []string, event *model.AuditEvent) error
+	DisableTOTP(ctx context.Context, userId int, event *model.AuditEvent) error
+	UseTOTPStep(ctx context.Context, userId int, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userId int, codeHash string) (bool, error)
+	SetTOTPChallenge(ctx context.Context, userId int, nonce string) error
+	UseTOTPChallenge(ctx context.Context, userId int, nonce string) (bool, error)
+	GetTwoFactorRoles(ctx context.Context) ([]string, error)
+	SetTwoFactorRoles(ctx context.Context, roles []string, event *model.AuditEvent) error
+}
+
+type Health interface {
+	Ping(ctx context.Context) error
+}
+
+// Audit reads the audit log, the events are written by the changes they describe
+type Audit interface {
+	GetAuditEvents(ctx context.Context, filters *model.AuditFilters, page int, limit int) ([]model.AuditEvent, int, error)
+	GetAuditChain(ctx context.Context, fromID int64, toID int64, limit int) ([]model.AuditEvent, error)
+	GetAuditHead(ctx context.Context) (int64, string, error)
+	CreateAuditCheckpoint(ctx context.Context, checkpoint *model.AuditCheckpoint) (bool, error)
+	GetAuditCheckpoints(ctx context.Context) ([]model.AuditCheckpoint, error)
 }
 
 type Repository struct {
 	AppUser
+	TokenRevocation
+	RateLimit
+	PasswordReset
+	TwoFactor
+	Health
+	Audit
 }
 
 func NewRepository(db *sql.DB, logger logging.Logger) *Repository {
 	return &Repository{
-		AppUser: NewUserPostgres(db, logger),
+		AppUser:         tracedUsers{AppUser: NewUserPostgres(db, logger)},
+		TokenRevocation: NewTokenPostgres(db, logger),
+		RateLimit:       NewRateLimitPostgres(db, logger),
+		PasswordReset:   NewPasswordResetPostgres(db, logger),
+		TwoFactor:       NewTwoFactorPostgres(db, logger),
+		Health:          NewHealthPostgres(db, logger),
+		Audit:           NewAuditPostgres(db, logger),
 	}
 }
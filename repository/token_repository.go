@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"time"
+)
+
+type TokenPostgres struct {
+	db     *sql.DB
+	logger logging.Logger
+}
+
+func NewTokenPostgres(db *sql.DB, logger logging.Logger) *TokenPostgres {
+	return &TokenPostgres{db: db, logger: logger}
+}
+
+// RevokeToken ...
+func (t *TokenPostgres) RevokeToken(ctx context.Context, userId int, tokenHash string, expiresAt time.Time) error {
+	query := "INSERT INTO revoked_tokens (user_id, token_hash, revoked_at, expires_at) VALUES ($1, $2, $3, $4) ON CONFLICT (token_hash) DO NOTHING"
+	_, err := t.db.ExecContext(ctx, query, userId, tokenHash, time.Now().UTC(), expiresAt)
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("RevokeToken: error while inserting revoked token:%s", err)
+		return fmt.Errorf("revokeToken: repository error:%w", err)
+	}
+	return nil
+}
+
+// RevokeUserTokens revokes every token of the user issued before revokedAt and
+// records the event in one transaction
+func (t *TokenPostgres) RevokeUserTokens(ctx context.Context, userId int, revokedAt time.Time, expiresAt time.Time, event *model.AuditEvent) error {
+	transaction, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("RevokeUserTokens: can not begin transaction:%s", err)
+		return fmt.Errorf("revokeUserTokens: can not begin transaction:%w", err)
+	}
+	defer transaction.Rollback()
+	query := "INSERT INTO revoked_tokens (user_id, token_hash, revoked_at, expires_at) VALUES ($1, NULL, $2, $3)"
+	if _, err = transaction.ExecContext(ctx, query, userId, revokedAt, expiresAt); err != nil {
+		t.logger.WithContext(ctx).Errorf("RevokeUserTokens: error while inserting revocation:%s", err)
+		return fmt.Errorf("revokeUserTokens: repository error:%w", err)
+	}
+	event.TargetID = userId
+	event.Diff = map[string]model.AuditChange{"tokens_revoked_at": {New: revokedAt}}
+	if err = insertAuditEvent(ctx, transaction, event); err != nil {
+		t.logger.WithContext(ctx).Errorf("RevokeUserTokens:%s", err)
+		return fmt.Errorf("revokeUserTokens: repository error:%w", err)
+	}
+	if err = transaction.Commit(); err != nil {
+		t.logger.WithContext(ctx).Errorf("RevokeUserTokens: can not commit transaction:%s", err)
+		return fmt.Errorf("revokeUserTokens: can not commit transaction:%w", err)
+	}
+	return nil
+}
+
+// IsTokenRevoked tells whether the token itself is revoked or all tokens of the user
+// were revoked with a cutoff at or after it was issued, issuedAt has a one second precision
+func (t *TokenPostgres) IsTokenRevoked(ctx context.Context, userId int, tokenHash string, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE expires_at > $1 AND
+		(token_hash = $2 OR (token_hash IS NULL AND user_id = $3 AND revoked_at >= $4)))`
+	row := t.db.QueryRowContext(ctx, query, time.Now().UTC(), tokenHash, userId, issuedAt)
+	if err := row.Scan(&revoked); err != nil {
+		t.logger.WithContext(ctx).Errorf("IsTokenRevoked: error while scanning for revocation:%s", err)
+		return false, fmt.Errorf("isTokenRevoked: repository error:%w", err)
+	}
+	return revoked, nil
+}
+
+// DeleteExpiredRevocations ...
+func (t *TokenPostgres) DeleteExpiredRevocations(ctx context.Context) (int64, error) {
+	result, err := t.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= $1", time.Now().UTC())
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("DeleteExpiredRevocations: error while deleting revocations:%s", err)
+		return 0, fmt.Errorf("deleteExpiredRevocations: repository error:%w", err)
+	}
+	return result.RowsAffected()
+}
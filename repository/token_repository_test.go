@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"testing"
+	"time"
+)
+
+func TestRepository_RevokeToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+	expiresAt := time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)
+
+	testTable := []struct {
+		name          string
+		mock          func()
+		expectedError bool
+	}{
+		{
+			name: "OK",
+			mock: func() {
+				mock.ExpectExec("INSERT INTO revoked_tokens").
+					WithArgs(1, "hash", sqlmock.AnyArg(), expiresAt).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			expectedError: false,
+		},
+		{
+			name: "Insert error",
+			mock: func() {
+				mock.ExpectExec("INSERT INTO revoked_tokens").
+					WithArgs(1, "hash", sqlmock.AnyArg(), expiresAt).
+					WillReturnError(errors.New("insert error"))
+			},
+			expectedError: true,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			err := r.RevokeToken(context.Background(), 1, "hash", expiresAt)
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_RevokeUserTokens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+	revokedAt := time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)
+	expiresAt := revokedAt.Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO revoked_tokens (.+) VALUES (.+) NULL").
+		WithArgs(1, revokedAt, expiresAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectAuditEvent(mock, model.AuditTokensRevoked, 1)
+	mock.ExpectCommit()
+	err = r.RevokeUserTokens(context.Background(), 1, revokedAt, expiresAt, &model.AuditEvent{Action: model.AuditTokensRevoked})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_IsTokenRevoked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+	issuedAt := time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)
+
+	testTable := []struct {
+		name            string
+		mock            func()
+		expectedRevoked bool
+		expectedError   bool
+	}{
+		{
+			name: "Revoked",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"exists"}).AddRow(true)
+				mock.ExpectQuery(`SELECT EXISTS (.+) FROM revoked_tokens (.+) revoked_at >= \$4`).
+					WithArgs(sqlmock.AnyArg(), "hash", 1, issuedAt).WillReturnRows(rows)
+			},
+			expectedRevoked: true,
+		},
+		{
+			name: "Not revoked",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"exists"}).AddRow(false)
+				mock.ExpectQuery(`SELECT EXISTS (.+) FROM revoked_tokens (.+) revoked_at >= \$4`).
+					WithArgs(sqlmock.AnyArg(), "hash", 1, issuedAt).WillReturnRows(rows)
+			},
+			expectedRevoked: false,
+		},
+		{
+			name: "Query error",
+			mock: func() {
+				mock.ExpectQuery(`SELECT EXISTS (.+) FROM revoked_tokens (.+) revoked_at >= \$4`).
+					WithArgs(sqlmock.AnyArg(), "hash", 1, issuedAt).WillReturnError(errors.New("query error"))
+			},
+			expectedError: true,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			got, err := r.IsTokenRevoked(context.Background(), 1, "hash", issuedAt)
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedRevoked, got)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_DeleteExpiredRevocations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+
+	mock.ExpectExec("DELETE FROM revoked_tokens WHERE expires_at <= (.+)").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	deleted, err := r.DeleteExpiredRevocations(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/tracing"
+	"time"
+)
+
+// tracedUsers records a span for every query of the user repository
+type tracedUsers struct {
+	AppUser
+}
+
+func (t tracedUsers) GetUserByID(ctx context.Context, id int) (user *model.ResponseUser, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.GetUserByID")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.GetUserByID(ctx, id)
+}
+
+func (t tracedUsers) GetUsersByIDs(ctx context.Context, ids []int) (users []model.ResponseUser, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.GetUsersByIDs")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.GetUsersByIDs(ctx, ids)
+}
+
+func (t tracedUsers) GetUsers(ctx context.Context, page int, limit int, filters *model.RequestFilters, sort []model.SortField) (users []model.ResponseUser, total int, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.GetUsers")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.GetUsers(ctx, page, limit, filters, sort)
+}
+
+func (t tracedUsers) GetUsersByCursor(ctx context.Context, filters *model.RequestFilters, query *model.CursorQuery) (users []model.ResponseUser, more bool, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.GetUsersByCursor")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.GetUsersByCursor(ctx, filters, query)
+}
+
+func (t tracedUsers) CreateStaff(ctx context.Context, User *model.CreateStaff, event *model.AuditEvent) (id int, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.CreateStaff")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.CreateStaff(ctx, User, event)
+}
+
+func (t tracedUsers) CreateCustomer(ctx context.Context, User *model.CreateCustomer) (id int, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.CreateCustomer")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.CreateCustomer(ctx, User)
+}
+
+func (t tracedUsers) UpdateUser(ctx context.Context, User *model.UpdateUser, event *model.AuditEvent) (err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.UpdateUser")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.UpdateUser(ctx, User, event)
+}
+
+func (t tracedUsers) DeleteUserByID(ctx context.Context, id int, event *model.AuditEvent) (deleted int, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.DeleteUserByID")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.DeleteUserByID(ctx, id, event)
+}
+
+func (t tracedUsers) GetUserByEmail(ctx context.Context, email string) (user *model.User, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.GetUserByEmail")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.GetUserByEmail(ctx, email)
+}
+
+func (t tracedUsers) GetUserPasswordByID(ctx context.Context, id int) (password string, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.GetUserPasswordByID")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.GetUserPasswordByID(ctx, id)
+}
+
+func (t tracedUsers) CheckEmail(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.CheckEmail")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.CheckEmail(ctx, email)
+}
+
+func (t tracedUsers) RegisterFailedLogin(ctx context.Context, id int) (attempts int, lockouts int, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.RegisterFailedLogin")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.RegisterFailedLogin(ctx, id)
+}
+
+func (t tracedUsers) LockUser(ctx context.Context, id int, until time.Time, maxAttempts int, event *model.AuditEvent) (err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.LockUser")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.LockUser(ctx, id, until, maxAttempts, event)
+}
+
+func (t tracedUsers) RestoreUser(ctx context.Context, id int, event *model.AuditEvent) (err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.RestoreUser")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.RestoreUser(ctx, id, event)
+}
+
+func (t tracedUsers) UnlockUser(ctx context.Context, id int, event *model.AuditEvent) (unlocked int, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.UnlockUser")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.UnlockUser(ctx, id, event)
+}
+
+func (t tracedUsers) VerifyEmail(ctx context.Context, id int, email string) (verified int, err error) {
+	ctx, span := tracing.StartQuery(ctx, "UserPostgres.VerifyEmail")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.VerifyEmail(ctx, id, email)
+}
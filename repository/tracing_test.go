@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/tracing"
+	"testing"
+)
+
+func TestRepository_tracing(t *testing.T) {
+	provider, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterMemory, SampleRatio: 1})
+	assert.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+
+	mock.ExpectQuery("SELECT id, email, role, created_at, email_verified, deleted FROM users WHERE id = (.+)").
+		WithArgs(1).WillReturnError(sql.ErrNoRows)
+	ctx, parent := tracing.Start(context.Background(), "UserService.GetUser")
+	_, err = r.GetUserByID(ctx, 1)
+	parent.End()
+	//Assert
+	assert.Error(t, err)
+	spans := provider.Spans()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "UserPostgres.GetUserByID", spans[0].Name)
+		assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+		assert.Contains(t, spans[0].Attributes, semconv.DBSystemPostgreSQL)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"time"
+)
+
+type TwoFactorPostgres struct {
+	db     *sql.DB
+	logger logging.Logger
+}
+
+func NewTwoFactorPostgres(db *sql.DB, logger logging.Logger) *TwoFactorPostgres {
+	return &TwoFactorPostgres{db: db, logger: logger}
+}
+
+// GetTwoFactor ...
+func (t *TwoFactorPostgres) GetTwoFactor(ctx context.Context, userId int) (*model.TwoFactor, error) {
+	var twoFactor model.TwoFactor
+	var secret sql.NullString
+	var lockedUntil sql.NullTime
+	query := `SELECT id, email, role, deleted, totp_secret, totp_enabled, failed_login_attempts, lockout_count, locked_until
+		FROM users WHERE id = $1`
+	row := t.db.QueryRowContext(ctx, query, userId)
+	if err := row.Scan(&twoFactor.UserID, &twoFactor.Email, &twoFactor.Role, &twoFactor.Deleted,
+		&secret, &twoFactor.Enabled, &twoFactor.FailedLoginAttempts, &twoFactor.LockoutCount, &lockedUntil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, pkg.ErrorUserNotFound
+		}
+		t.logger.WithContext(ctx).Errorf("GetTwoFactor: error while scanning for user:%s", err)
+		return nil, fmt.Errorf("getTwoFactor: repository error:%w", err)
+	}
+	twoFactor.Secret = secret.String
+	if lockedUntil.Valid {
+		twoFactor.LockedUntil = &lockedUntil.Time
+	}
+	return &twoFactor, nil
+}
+
+// SetTOTPSecret stores a secret waiting for confirmation, an enabled secret is never replaced
+func (t *TwoFactorPostgres) SetTOTPSecret(ctx context.Context, userId int, secret string) error {
+	query := "UPDATE users SET totp_secret = $1, totp_last_step = 0 WHERE id = $2 AND totp_enabled = false"
+	result, err := t.db.ExecContext(ctx, query, secret, userId)
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("SetTOTPSecret: error while updating user:%s", err)
+		return fmt.Errorf("setTOTPSecret: repository error:%w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("setTOTPSecret: repository error:%w", err)
+	}
+	if updated == 0 {
+		return pkg.ErrorTwoFactorEnabled
+	}
+	return nil
+}
+
+// EnableTOTP enables the pending secret and replaces the recovery codes of the
+// user, the event is recorded in the same transaction
+func (t *TwoFactorPostgres) EnableTOTP(ctx context.Context, userId int, codeHashes []string, event *model.AuditEvent) error {
+	transaction, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("EnableTOTP: can not begin transaction:%s", err)
+		return fmt.Errorf("enableTOTP: can not begin transaction:%w", err)
+	}
+	defer transaction.Rollback()
+	if _, err = transaction.ExecContext(ctx, "UPDATE users SET totp_enabled = true WHERE id = $1", userId); err != nil {
+		t.logger.WithContext(ctx).Errorf("EnableTOTP: error while updating user:%s", err)
+		return fmt.Errorf("enableTOTP: repository error:%w", err)
+	}
+	if err = replaceRecoveryCodes(ctx, transaction, userId, codeHashes); err != nil {
+		t.logger.WithContext(ctx).Errorf("EnableTOTP: error while saving recovery codes:%s", err)
+		return fmt.Errorf("enableTOTP: repository error:%w", err)
+	}
+	event.TargetID = userId
+	event.Diff = map[string]model.AuditChange{
+		"totp_enabled":   {Old: false, New: true},
+		"recovery_codes": {New: model.AuditRedacted},
+	}
+	if err = insertAuditEvent(ctx, transaction, event); err != nil {
+		t.logger.WithContext(ctx).Errorf("EnableTOTP:%s", err)
+		return fmt.Errorf("enableTOTP: repository error:%w", err)
+	}
+	return transaction.Commit()
+}
+
+// DisableTOTP removes the secret and the recovery codes of the user, the event
+// is recorded in the same transaction
+func (t *TwoFactorPostgres) DisableTOTP(ctx context.Context, userId int, event *model.AuditEvent) error {
+	transaction, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("DisableTOTP: can not begin transaction:%s", err)
+		return fmt.Errorf("disableTOTP: can not begin transaction:%w", err)
+	}
+	defer transaction.Rollback()
+	query := "UPDATE users SET totp_secret = NULL, totp_enabled = false, totp_last_step = 0 WHERE id = $1"
+	if _, err = transaction.ExecContext(ctx, query, userId); err != nil {
+		t.logger.WithContext(ctx).Errorf("DisableTOTP: error while updating user:%s", err)
+		return fmt.Errorf("disableTOTP: repository error:%w", err)
+	}
+	if err = replaceRecoveryCodes(ctx, transaction, userId, nil); err != nil {
+		t.logger.WithContext(ctx).Errorf("DisableTOTP: error while deleting recovery codes:%s", err)
+		return fmt.Errorf("disableTOTP: repository error:%w", err)
+	}
+	event.TargetID = userId
+	event.Diff = map[string]model.AuditChange{
+		"totp_enabled":   {Old: true, New: false},
+		"recovery_codes": {Old: model.AuditRedacted},
+	}
+	if err = insertAuditEvent(ctx, transaction, event); err != nil {
+		t.logger.WithContext(ctx).Errorf("DisableTOTP:%s", err)
+		return fmt.Errorf("disableTOTP: repository error:%w", err)
+	}
+	return transaction.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, transaction *sql.Tx, userId int, codeHashes []string) error {
+	if _, err := transaction.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userId); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := transaction.ExecContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userId, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseTOTPStep accepts every time step of the user only once, so a code can not be replayed
+func (t *TwoFactorPostgres) UseTOTPStep(ctx context.Context, userId int, step int64) (bool, error) {
+	query := "UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1"
+	result, err := t.db.ExecContext(ctx, query, step, userId)
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("UseTOTPStep: error while updating user:%s", err)
+		return false, fmt.Errorf("useTOTPStep: repository error:%w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("useTOTPStep: repository error:%w", err)
+	}
+	return updated != 0, nil
+}
+
+// UseRecoveryCode marks the code as used if it is an unused code of the user
+func (t *TwoFactorPostgres) UseRecoveryCode(ctx context.Context, userId int, codeHash string) (bool, error) {
+	query := "UPDATE recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL"
+	result, err := t.db.ExecContext(ctx, query, time.Now().UTC(), userId, codeHash)
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("UseRecoveryCode: error while updating recovery code:%s", err)
+		return false, fmt.Errorf("useRecoveryCode: repository error:%w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("useRecoveryCode: repository error:%w", err)
+	}
+	return updated != 0, nil
+}
+
+// SetTOTPChallenge replaces the pending login challenge of the user
+func (t *TwoFactorPostgres) SetTOTPChallenge(ctx context.Context, userId int, nonce string) error {
+	_, err := t.db.ExecContext(ctx, "UPDATE users SET totp_challenge = $1 WHERE id = $2", nonce, userId)
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("SetTOTPChallenge: error while updating user:%s", err)
+		return fmt.Errorf("setTOTPChallenge: repository error:%w", err)
+	}
+	return nil
+}
+
+// UseTOTPChallenge consumes the pending login challenge if it has the nonce, so a
+// challenge can not be used twice
+func (t *TwoFactorPostgres) UseTOTPChallenge(ctx context.Context, userId int, nonce string) (bool, error) {
+	query := "UPDATE users SET totp_challenge = NULL WHERE id = $1 AND totp_challenge = $2"
+	result, err := t.db.ExecContext(ctx, query, userId, nonce)
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("UseTOTPChallenge: error while updating user:%s", err)
+		return false, fmt.Errorf("useTOTPChallenge: repository error:%w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("useTOTPChallenge: repository error:%w", err)
+	}
+	return updated != 0, nil
+}
+
+// GetTwoFactorRoles ...
+func (t *TwoFactorPostgres) GetTwoFactorRoles(ctx context.Context) ([]string, error) {
+	rows, err := t.db.QueryContext(ctx, "SELECT role FROM two_factor_roles ORDER BY role")
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("GetTwoFactorRoles: can not executes a query:%s", err)
+		return nil, fmt.Errorf("getTwoFactorRoles: repository error:%w", err)
+	}
+	defer rows.Close()
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			t.logger.WithContext(ctx).Errorf("GetTwoFactorRoles: error while scanning for role:%s", err)
+			return nil, fmt.Errorf("getTwoFactorRoles: repository error:%w", err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// SetTwoFactorRoles replaces the roles which require two-factor authentication,
+// the event is recorded in the same transaction
+func (t *TwoFactorPostgres) SetTwoFactorRoles(ctx context.Context, roles []string, event *model.AuditEvent) error {
+	transaction, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("SetTwoFactorRoles: can not begin transaction:%s", err)
+		return fmt.Errorf("setTwoFactorRoles: can not begin transaction:%w", err)
+	}
+	defer transaction.Rollback()
+	rows, err := transaction.QueryContext(ctx, "DELETE FROM two_factor_roles RETURNING role")
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("SetTwoFactorRoles: error while deleting roles:%s", err)
+		return fmt.Errorf("setTwoFactorRoles: repository error:%w", err)
+	}
+	old := []string{}
+	for rows.Next() {
+		var role string
+		if err = rows.Scan(&role); err != nil {
+			rows.Close()
+			t.logger.WithContext(ctx).Errorf("SetTwoFactorRoles: error while scanning for role:%s", err)
+			return fmt.Errorf("setTwoFactorRoles: repository error:%w", err)
+		}
+		old = append(old, role)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		t.logger.WithContext(ctx).Errorf("SetTwoFactorRoles: error while deleting roles:%s", err)
+		return fmt.Errorf("setTwoFactorRoles: repository error:%w", err)
+	}
+	for _, role := range roles {
+		if _, err = transaction.ExecContext(ctx, "INSERT INTO two_factor_roles (role) VALUES ($1) ON CONFLICT DO NOTHING", role); err != nil {
+			t.logger.WithContext(ctx).Errorf("SetTwoFactorRoles: error while inserting role:%s", err)
+			return fmt.Errorf("setTwoFactorRoles: repository error:%w", err)
+		}
+	}
+	sort.Strings(old)
+	event.Diff = map[string]model.AuditChange{"roles": {Old: old, New: roles}}
+	if err = insertAuditEvent(ctx, transaction, event); err != nil {
+		t.logger.WithContext(ctx).Errorf("SetTwoFactorRoles:%s", err)
+		return fmt.Errorf("setTwoFactorRoles: repository error:%w", err)
+	}
+	return transaction.Commit()
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/lib/pq"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"strings"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// usersWhere builds the WHERE clause of the user list from the filters that are set,
+// every value is passed as a placeholder starting from $1
+func usersWhere(filters *model.RequestFilters) (string, []interface{}) {
+	conditions, args := usersConditions(filters)
+	return where(conditions), args
+}
+
+func usersConditions(filters *model.RequestFilters) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	deleted := filters.Deleted
+	if deleted == "" && filters.ShowDeleted {
+		deleted = model.DeletedAll
+	}
+	switch {
+	case deleted == model.DeletedOnly:
+		conditions = append(conditions, "deleted = true")
+	case deleted != model.DeletedAll:
+		conditions = append(conditions, "deleted = false")
+	}
+	if len(filters.Roles) != 0 {
+		add("role = ANY($%d)", pq.Array(filters.Roles))
+	}
+	if !filters.StartTime.IsZero() {
+		add("created_at >= $%d", filters.StartTime.Time)
+	}
+	if !filters.EndTime.IsZero() {
+		// the end date is inclusive, so everything before the next day matches
+		add("created_at < $%d", filters.EndTime.AddDate(0, 0, 1))
+	}
+	if filters.Email != "" {
+		add("email ILIKE $%d", "%"+likeEscaper.Replace(filters.Email)+"%")
+	}
+	if domain := strings.TrimPrefix(filters.EmailDomain, "@"); domain != "" {
+		add("email ILIKE $%d", "%@"+likeEscaper.Replace(domain))
+	}
+	if filters.Query != "" {
+		// served by the users_email_trgm_idx trigram index
+		add("email ILIKE $%d", "%"+likeEscaper.Replace(filters.Query)+"%")
+	}
+	return conditions, args
+}
+
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// sortFields checks the columns and appends id as a tie breaker, so that the order is total
+func sortFields(sort []model.SortField, filters *model.RequestFilters) ([]model.SortField, error) {
+	fields := make([]model.SortField, 0, len(sort)+1)
+	hasID := false
+	for _, field := range sort {
+		if field.Column == model.SortRank && filters.Query == "" {
+			return nil, fmt.Errorf("the search rank needs a search query")
+		}
+		if !model.UserSortColumns[field.Column] && field.Column != model.SortRank {
+			return nil, fmt.Errorf("unknown sort column %q", field.Column)
+		}
+		hasID = hasID || field.Column == "id"
+		fields = append(fields, field)
+	}
+	if !hasID {
+		fields = append(fields, model.SortField{Column: "id"})
+	}
+	return fields, nil
+}
+
+// sortExpressions returns the SQL of the sort fields that are not plain columns,
+// their arguments are appended to args
+func sortExpressions(fields []model.SortField, filters *model.RequestFilters, args []interface{}) (map[string]string, []interface{}) {
+	expressions := make(map[string]string)
+	for _, field := range fields {
+		if field.Column == model.SortRank {
+			// the same ranking as model.SearchRank
+			args = append(args, filters.Query, likeEscaper.Replace(filters.Query)+"%")
+			expressions[model.SortRank] = fmt.Sprintf("CASE WHEN lower(email) = lower($%d) THEN 0 WHEN email ILIKE $%d THEN 1 ELSE 2 END",
+				len(args)-1, len(args))
+		}
+	}
+	return expressions, args
+}
+
+func sortExpression(field model.SortField, expressions map[string]string) string {
+	if expression, ok := expressions[field.Column]; ok {
+		return expression
+	}
+	return field.Column
+}
+
+// orderBy builds the ORDER BY clause, every direction is flipped when backward is set
+func orderBy(fields []model.SortField, backward bool, expressions map[string]string) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = sortExpression(field, expressions)
+		if field.Desc != backward {
+			parts[i] += " DESC"
+		}
+	}
+	return " ORDER BY " + strings.Join(parts, ", ")
+}
+
+// keysetCondition matches the rows following the cursor in the order of fields,
+// or preceding it when backward is set, like (email > $1 OR (email = $1 AND id > $2))
+func keysetCondition(fields []model.SortField, cursor *model.UserCursor, backward bool, args []interface{},
+	expressions map[string]string) (string, []interface{}) {
+	var terms, equal []string
+	for _, field := range fields {
+		args = append(args, cursor.Value(field.Column))
+		op := ">"
+		if field.Desc != backward {
+			op = "<"
+		}
+		column := sortExpression(field, expressions)
+		term := fmt.Sprintf("%s %s $%d", column, op, len(args))
+		if len(equal) != 0 {
+			term = "(" + strings.Join(equal, " AND ") + " AND " + term + ")"
+		}
+		terms = append(terms, term)
+		equal = append(equal, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if len(terms) == 1 {
+		return terms[0], args
+	}
+	return "(" + strings.Join(terms, " OR ") + ")", args
+}
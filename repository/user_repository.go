@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	_ "database/sql"
+	"errors"
 	"fmt"
+	"github.com/lib/pq"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
@@ -20,275 +23,435 @@ func NewUserPostgres(db *sql.DB, logger logging.Logger) *UserPostgres {
 }
 
 // GetUserByID ...
-func (u UserPostgres) GetUserByID(id int) (*model.ResponseUser, error) {
+func (u UserPostgres) GetUserByID(ctx context.Context, id int) (*model.ResponseUser, error) {
 	var user model.ResponseUser
-	result := u.db.QueryRow("SELECT id, email, role, created_at FROM users WHERE id = $1", id)
-	if err := result.Scan(&user.ID, &user.Email, &user.Role, &user.CreatedAt); err != nil {
-		u.logger.Errorf("GetUserByID: error while scanning for user:%s", err)
+	result := u.db.QueryRowContext(ctx, "SELECT id, email, role, created_at, email_verified, deleted FROM users WHERE id = $1", id)
+	if err := result.Scan(&user.ID, &user.Email, &user.Role, &user.CreatedAt, &user.EmailVerified, &user.Deleted); err != nil {
+		u.logger.WithContext(ctx).Errorf("GetUserByID: error while scanning for user:%s", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("getUserByID:%w", pkg.ErrorUserNotFound)
+		}
 		return nil, fmt.Errorf("getUserByID: repository error:%w", err)
 	}
 	return &user, nil
 }
 
+// GetUsersByIDs returns the active users found in the same order as ids, unknown and deleted ids are skipped
+func (u UserPostgres) GetUsersByIDs(ctx context.Context, ids []int) ([]model.ResponseUser, error) {
+	query := "SELECT id, email, role, created_at, email_verified FROM users WHERE id = ANY($1) AND deleted = false " +
+		"ORDER BY array_position($1, id)"
+	rows, err := u.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("GetUsersByIDs: can not executes a query:%s", err)
+		return nil, fmt.Errorf("getUsersByIDs:repository error:%w", err)
+	}
+	defer rows.Close()
+	users := make([]model.ResponseUser, 0, len(ids))
+	for rows.Next() {
+		var user model.ResponseUser
+		if err := rows.Scan(&user.ID, &user.Email, &user.Role, &user.CreatedAt, &user.EmailVerified); err != nil {
+			u.logger.WithContext(ctx).Errorf("GetUsersByIDs: error while scanning for user:%s", err)
+			return nil, fmt.Errorf("getUsersByIDs:repository error:%w", err)
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		u.logger.WithContext(ctx).Errorf("GetUsersByIDs:%s", err)
+		return nil, fmt.Errorf("getUsersByIDs:repository error:%w", err)
+	}
+	return users, nil
+}
+
 // GetUserPasswordByID ...
-func (u UserPostgres) GetUserPasswordByID(id int) (string, error) {
+func (u UserPostgres) GetUserPasswordByID(ctx context.Context, id int) (string, error) {
 	var password string
-	result := u.db.QueryRow("SELECT password FROM users WHERE id = $1", id)
+	result := u.db.QueryRowContext(ctx, "SELECT password FROM users WHERE id = $1", id)
 	if err := result.Scan(&password); err != nil {
-		u.logger.Errorf("GetUserPasswordByID: error while scanning for user:%s", err)
+		u.logger.WithContext(ctx).Errorf("GetUserPasswordByID: error while scanning for user:%s", err)
 		return "", fmt.Errorf("getUserPasswordByID: repository error:%w", err)
 	}
 	return password, nil
 }
 
-// GetUserAll ...
-func (u *UserPostgres) GetUserAll(page int, limit int) ([]model.ResponseUser, int, error) {
-	transaction, err := u.db.Begin()
+// GetUsers returns the users matching every filter that is set in the given order
+// together with their total number. Zero page or limit returns all of them.
+func (u *UserPostgres) GetUsers(ctx context.Context, page int, limit int, filters *model.RequestFilters, sort []model.SortField) ([]model.ResponseUser, int, error) {
+	fields, err := sortFields(sort, filters)
 	if err != nil {
-		u.logger.Errorf("GetUserAll: can not starts transaction:%s", err)
-		return nil, 0, fmt.Errorf("getUserAll: can not starts transaction:%w", err)
-	}
-	var Users []model.ResponseUser
-	var query string
-	var pages int
-	var rows *sql.Rows
-	if page == 0 || limit == 0 {
-		query = "SELECT id, email, role, created_at FROM users WHERE deleted = false ORDER BY id"
-		rows, err = transaction.Query(query)
-		if err != nil {
-			u.logger.Errorf("GetUserAll: can not executes a query:%s", err)
-			return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
-		}
-		pages = 1
-	} else {
-		query = "SELECT id, email, role, created_at FROM users WHERE deleted = false ORDER BY id LIMIT $1 OFFSET $2"
-		rows, err = transaction.Query(query, limit, (page-1)*limit)
-		if err != nil {
-			u.logger.Errorf("GetUserAll: can not executes a query:%s", err)
-			return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
-		}
+		u.logger.WithContext(ctx).Errorf("GetUsers:%s", err)
+		return nil, 0, fmt.Errorf("getUsers:%w", err)
 	}
-	for rows.Next() {
-		var User model.ResponseUser
-		if err := rows.Scan(&User.ID, &User.Email, &User.Role, &User.CreatedAt); err != nil {
-			u.logger.Errorf("Error while scanning for user:%s", err)
-			return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
-		}
-		Users = append(Users, User)
+	where, args := usersWhere(filters)
+	countArgs := args
+	expressions, args := sortExpressions(fields, filters, args)
+	transaction, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("GetUsers: can not starts transaction:%s", err)
+		return nil, 0, fmt.Errorf("getUsers: can not starts transaction:%w", err)
 	}
-	if pages == 0 {
-		query = "SELECT CEILING(COUNT(id)/$1::float) FROM users WHERE deleted = false"
-		row := transaction.QueryRow(query, limit)
-		if err := row.Scan(&pages); err != nil {
-			u.logger.Errorf("Error while scanning for pages:%s", err)
+	defer transaction.Rollback()
+	query := "SELECT id, email, role, created_at, email_verified FROM users" + where + orderBy(fields, false, expressions)
+	total := -1
+	if page != 0 && limit != 0 {
+		if err := transaction.QueryRowContext(ctx, "SELECT COUNT(id) FROM users"+where, countArgs...).Scan(&total); err != nil {
+			u.logger.WithContext(ctx).Errorf("GetUsers: error while scanning for total:%s", err)
+			return nil, 0, fmt.Errorf("getUsers:repository error:%w", err)
 		}
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+		args = append(args, limit, (page-1)*limit)
 	}
-	return Users, pages, transaction.Commit()
-}
-func (u *UserPostgres) GetUserByRoleFilter(page int, limit int, filters *model.RequestFilters) ([]model.ResponseUser, int, error) {
-	transaction, err := u.db.Begin()
+	rows, err := transaction.QueryContext(ctx, query, args...)
 	if err != nil {
-		u.logger.Errorf("GetUserAll: can not starts transaction:%s", err)
-		return nil, 0, fmt.Errorf("getUserAll: can not starts transaction:%w", err)
-	}
-	var Users []model.ResponseUser
-	var pages int
-	var rows *sql.Rows
-	if page == 0 || limit == 0 {
-		if filters.ShowDeleted {
-			query := "SELECT id, email, role, created_at FROM users WHERE role = $1 ORDER BY id"
-			rows, err = transaction.Query(query, filters.Role)
-			if err != nil {
-				u.logger.Errorf("GetUserAll: can not executes a query:%s", err)
-				return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
-			}
-		} else {
-			query := "SELECT id, email, role, created_at FROM users WHERE deleted = false AND role = $1 ORDER BY id"
-			rows, err = transaction.Query(query, filters.Role)
-			if err != nil {
-				u.logger.Errorf("GetUserAll: can not executes a query:%s", err)
-				return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
-			}
-		}
-		pages = 1
-	} else {
-		if filters.ShowDeleted {
-			query := "SELECT CEILING(COUNT(id)/$1::float) FROM users WHERE role = $2"
-			row := transaction.QueryRow(query, limit, filters.Role)
-			if err := row.Scan(&pages); err != nil {
-				u.logger.Errorf("Error while scanning for pages:%s", err)
-			}
-			query2 := "SELECT id, email, role, created_at FROM users WHERE role = $1 ORDER BY id LIMIT $2 OFFSET $3"
-			rows, err = transaction.Query(query2, filters.Role, limit, (page-1)*limit)
-			if err != nil {
-				u.logger.Errorf("GetUserAll: can not executes a query:%s", err)
-				return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
-			}
-		} else {
-			query := "SELECT CEILING(COUNT(id)/$1::float) FROM users WHERE role = $2 AND deleted = false"
-			row := transaction.QueryRow(query, limit, filters.Role)
-			if err := row.Scan(&pages); err != nil {
-				u.logger.Errorf("Error while scanning for pages:%s", err)
-			}
-			query2 := "SELECT id, email, role, created_at FROM users WHERE role = $1 AND deleted = false ORDER BY id LIMIT $2 OFFSET $3"
-			rows, err = transaction.Query(query2, filters.Role, limit, (page-1)*limit)
-			if err != nil {
-				u.logger.Errorf("GetUserAll: can not executes a query:%s", err)
-				return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
-			}
-		}
+		u.logger.WithContext(ctx).Errorf("GetUsers: can not executes a query:%s", err)
+		return nil, 0, fmt.Errorf("getUsers:repository error:%w", err)
 	}
+	defer rows.Close()
+	var users []model.ResponseUser
 	for rows.Next() {
-		var User model.ResponseUser
-		if err := rows.Scan(&User.ID, &User.Email, &User.Role, &User.CreatedAt); err != nil {
-			u.logger.Errorf("Error while scanning for user:%s", err)
-			return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
+		var user model.ResponseUser
+		if err := rows.Scan(&user.ID, &user.Email, &user.Role, &user.CreatedAt, &user.EmailVerified); err != nil {
+			u.logger.WithContext(ctx).Errorf("GetUsers: error while scanning for user:%s", err)
+			return nil, 0, fmt.Errorf("getUsers:repository error:%w", err)
 		}
-		Users = append(Users, User)
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		u.logger.WithContext(ctx).Errorf("GetUsers:%s", err)
+		return nil, 0, fmt.Errorf("getUsers:repository error:%w", err)
+	}
+	if total < 0 {
+		total = len(users)
 	}
-	return Users, pages, transaction.Commit()
+	return users, total, transaction.Commit()
 }
 
-func (u *UserPostgres) GetUserByDataFilter(page int, limit int, filters *model.RequestFilters) ([]model.ResponseUser, int, error) {
-	transaction, err := u.db.Begin()
+// GetUsersByCursor returns up to query.Limit users next to the cursor in the requested order
+// and whether there are more of them in that direction
+func (u *UserPostgres) GetUsersByCursor(ctx context.Context, filters *model.RequestFilters, query *model.CursorQuery) ([]model.ResponseUser, bool, error) {
+	fields, err := sortFields(query.Sort, filters)
 	if err != nil {
-		u.logger.Errorf("GetUserAll: can not starts transaction:%s", err)
-		return nil, 0, fmt.Errorf("getUserAll: can not starts transaction:%w", err)
-	}
-	var Users []model.ResponseUser
-	var pages int
-	var rows *sql.Rows
-	if page == 0 || limit == 0 {
-		if filters.ShowDeleted {
-			query := "SELECT id, email, role, created_at FROM users WHERE created_at >= $1 AND created_at <= $2 ORDER BY id"
-			rows, err = transaction.Query(query, filters.StartTime, filters.EndTime)
-			if err != nil {
-				u.logger.Errorf("GetUserAll: can not executes a query:%s", err)
-				return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
-			}
-		} else {
-			query := "SELECT id, email, role, created_at FROM users WHERE created_at >= $1 AND created_at <= $2 AND deleted = false ORDER BY id"
-			rows, err = transaction.Query(query, filters.StartTime, filters.EndTime)
-			if err != nil {
-				u.logger.Errorf("GetUserAll: can not executes a query:%s", err)
-				return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
-			}
-		}
-		pages = 1
-	} else {
-		if filters.ShowDeleted {
-			query := "SELECT CEILING(COUNT(id)/$1::float) FROM users WHERE created_at >= $2 AND created_at <= $3"
-			row := transaction.QueryRow(query, limit, filters.StartTime, filters.EndTime)
-			if err := row.Scan(&pages); err != nil {
-				u.logger.Errorf("Error while scanning for pages:%s", err)
-			}
-			query2 := "SELECT id, email, role, created_at FROM users WHERE created_at >= $1 AND created_at <= $2 ORDER BY id LIMIT $3 OFFSET $4"
-			rows, err = transaction.Query(query2, filters.StartTime, filters.EndTime, limit, (page-1)*limit)
-			if err != nil {
-				u.logger.Errorf("GetUserAll: can not executes a query:%s", err)
-				return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
-			}
-		} else {
-			query2 := "SELECT CEILING(COUNT(id)/$1::float) FROM users WHERE created_at >= $2 AND created_at <= $3 AND deleted = false"
-			row := transaction.QueryRow(query2, limit, filters.StartTime, filters.EndTime)
-			if err := row.Scan(&pages); err != nil {
-				u.logger.Errorf("Error while scanning for pages:%s", err)
-			}
-			query := "SELECT id, email, role, created_at FROM users WHERE created_at >= $1 AND created_at <= $2 AND deleted = false ORDER BY id LIMIT $3 OFFSET $4"
-			rows, err = transaction.Query(query, filters.StartTime, filters.EndTime, limit, (page-1)*limit)
-			if err != nil {
-				u.logger.Errorf("GetUserAll: can not executes a query:%s", err)
-				return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
-			}
-		}
+		u.logger.WithContext(ctx).Errorf("GetUsersByCursor:%s", err)
+		return nil, false, fmt.Errorf("getUsersByCursor:%w", err)
 	}
+	conditions, args := usersConditions(filters)
+	expressions, args := sortExpressions(fields, filters, args)
+	if query.Cursor != nil {
+		var condition string
+		condition, args = keysetCondition(fields, query.Cursor, query.Backward, args, expressions)
+		conditions = append(conditions, condition)
+	}
+	args = append(args, query.Limit+1)
+	rows, err := u.db.QueryContext(ctx, fmt.Sprintf("SELECT id, email, role, created_at, email_verified FROM users%s%s LIMIT $%d",
+		where(conditions), orderBy(fields, query.Backward, expressions), len(args)), args...)
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("GetUsersByCursor: can not executes a query:%s", err)
+		return nil, false, fmt.Errorf("getUsersByCursor:repository error:%w", err)
+	}
+	defer rows.Close()
+	users := make([]model.ResponseUser, 0, query.Limit+1)
 	for rows.Next() {
-		var User model.ResponseUser
-		if err := rows.Scan(&User.ID, &User.Email, &User.Role, &User.CreatedAt); err != nil {
-			u.logger.Errorf("Error while scanning for user:%s", err)
-			return nil, 0, fmt.Errorf("getUserAll:repository error:%w", err)
+		var user model.ResponseUser
+		if err := rows.Scan(&user.ID, &user.Email, &user.Role, &user.CreatedAt, &user.EmailVerified); err != nil {
+			u.logger.WithContext(ctx).Errorf("GetUsersByCursor: error while scanning for user:%s", err)
+			return nil, false, fmt.Errorf("getUsersByCursor:repository error:%w", err)
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		u.logger.WithContext(ctx).Errorf("GetUsersByCursor:%s", err)
+		return nil, false, fmt.Errorf("getUsersByCursor:repository error:%w", err)
+	}
+	more := len(users) > query.Limit
+	if more {
+		users = users[:query.Limit]
+	}
+	if query.Backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
 		}
-		Users = append(Users, User)
 	}
-	return Users, pages, transaction.Commit()
+	return users, more, nil
 }
 
-// CreateStaff ...
-func (u *UserPostgres) CreateStaff(user *model.CreateStaff) (int, error) {
+// CreateStaff creates the user and records the event in one transaction
+func (u *UserPostgres) CreateStaff(ctx context.Context, user *model.CreateStaff, event *model.AuditEvent) (int, error) {
 	var id int
-	row := u.db.QueryRow("INSERT INTO users (email, password, role, created_at, deleted) VALUES ($1, $2, $3, $4, $5) RETURNING id", user.Email, user.Password, user.Role, time.Now().Format(model.Layout), false)
+	transaction, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("CreateStaff: can not starts transaction:%s", err)
+		return 0, fmt.Errorf("createStaff: can not starts transaction:%w", err)
+	}
+	defer transaction.Rollback()
+	row := transaction.QueryRowContext(ctx, "INSERT INTO users (email, password, role, created_at, deleted) VALUES ($1, $2, $3, $4, $5) RETURNING id", user.Email, user.Password, user.Role, time.Now().Format(model.Layout), false)
 	if err := row.Scan(&id); err != nil {
-		u.logger.Errorf("CreateStaff: error while scanning for user:%s", err)
+		u.logger.WithContext(ctx).Errorf("CreateStaff: error while scanning for user:%s", err)
 		return 0, fmt.Errorf("CreateStaff: error while scanning for user:%w", err)
 	}
+	event.TargetID = id
+	event.Diff = map[string]model.AuditChange{"email": {New: user.Email}, "role": {New: user.Role}}
+	if err = insertAuditEvent(ctx, transaction, event); err != nil {
+		u.logger.WithContext(ctx).Errorf("CreateStaff:%s", err)
+		return 0, fmt.Errorf("createStaff: repository error:%w", err)
+	}
+	if err = transaction.Commit(); err != nil {
+		u.logger.WithContext(ctx).Errorf("CreateStaff: can not commit transaction:%s", err)
+		return 0, fmt.Errorf("createStaff: can not commit transaction:%w", err)
+	}
 	return id, nil
 }
 
 // CreateCustomer ...
-func (u *UserPostgres) CreateCustomer(user *model.CreateCustomer) (int, error) {
+func (u *UserPostgres) CreateCustomer(ctx context.Context, user *model.CreateCustomer) (int, error) {
 	var id int
-	row := u.db.QueryRow("INSERT INTO users (email, password, role, created_at, deleted) VALUES ($1, $2, $3, $4, $5) RETURNING id", user.Email, user.Password, "Authorized Customer", time.Now().Format(model.Layout), false)
+	row := u.db.QueryRowContext(ctx, "INSERT INTO users (email, password, role, created_at, deleted, email_verified) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id", user.Email, user.Password, "Authorized Customer", time.Now().Format(model.Layout), false, false)
 	if err := row.Scan(&id); err != nil {
-		u.logger.Errorf("CreateCustomer: error while scanning for user:%s", err)
+		u.logger.WithContext(ctx).Errorf("CreateCustomer: error while scanning for user:%s", err)
 		return 0, fmt.Errorf("CreateCustomer: error while scanning for user:%w", err)
 	}
 	return id, nil
 }
 
-// UpdateUser ...
-func (u *UserPostgres) UpdateUser(user *model.UpdateUser) error {
-	_, err := u.db.Exec("UPDATE users SET password = $1 WHERE email = $2", user.NewPassword, user.Email)
+// UpdateUser sets the new password of the user and records the event in one transaction
+func (u *UserPostgres) UpdateUser(ctx context.Context, user *model.UpdateUser, event *model.AuditEvent) error {
+	var userId int
+	transaction, err := u.db.BeginTx(ctx, nil)
 	if err != nil {
-		u.logger.Errorf("UpdateUser: error while updating user:%s", err)
+		u.logger.WithContext(ctx).Errorf("UpdateUser: can not starts transaction:%s", err)
+		return fmt.Errorf("updateUser: can not starts transaction:%w", err)
+	}
+	defer transaction.Rollback()
+	row := transaction.QueryRowContext(ctx, "UPDATE users SET password = $1 WHERE email = $2 RETURNING id", user.NewPassword, user.Email)
+	if err = row.Scan(&userId); err != nil {
+		u.logger.WithContext(ctx).Errorf("UpdateUser: error while updating user:%s", err)
 		return fmt.Errorf("updateUser: error while updating user:%w", err)
 	}
+	event.TargetID = userId
+	event.Diff = map[string]model.AuditChange{"password": {Old: model.AuditRedacted, New: model.AuditRedacted}}
+	if err = insertAuditEvent(ctx, transaction, event); err != nil {
+		u.logger.WithContext(ctx).Errorf("UpdateUser:%s", err)
+		return fmt.Errorf("updateUser: repository error:%w", err)
+	}
+	if err = transaction.Commit(); err != nil {
+		u.logger.WithContext(ctx).Errorf("UpdateUser: can not commit transaction:%s", err)
+		return fmt.Errorf("updateUser: can not commit transaction:%w", err)
+	}
 	return nil
 }
 
-// DeleteUserByID ...
-func (u *UserPostgres) DeleteUserByID(id int) (int, error) {
+// DeleteUserByID deactivates the user and records the event in one transaction
+func (u *UserPostgres) DeleteUserByID(ctx context.Context, id int, event *model.AuditEvent) (int, error) {
 	var userId int
-	row := u.db.QueryRow("UPDATE users SET deleted = true WHERE id=$1 RETURNING id", id)
-	if err := row.Scan(&userId); err != nil {
-		u.logger.Errorf("DeleteUserByID: error while scanning for userId:%s", err)
+	var wasDeleted bool
+	transaction, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("DeleteUserByID: can not starts transaction:%s", err)
+		return 0, fmt.Errorf("deleteUserByID: can not starts transaction:%w", err)
+	}
+	defer transaction.Rollback()
+	query := `WITH old AS (SELECT id, deleted FROM users WHERE id = $1 FOR UPDATE)
+		UPDATE users SET deleted = true FROM old WHERE users.id = old.id RETURNING users.id, old.deleted`
+	row := transaction.QueryRowContext(ctx, query, id)
+	if err := row.Scan(&userId, &wasDeleted); err != nil {
+		u.logger.WithContext(ctx).Errorf("DeleteUserByID: error while scanning for userId:%s", err)
 		return 0, fmt.Errorf("deleteUserByID: error while scanning for userId:%w", err)
 	}
+	event.TargetID = userId
+	event.Diff = map[string]model.AuditChange{"deleted": {Old: wasDeleted, New: true}}
+	if err = insertAuditEvent(ctx, transaction, event); err != nil {
+		u.logger.WithContext(ctx).Errorf("DeleteUserByID:%s", err)
+		return 0, fmt.Errorf("deleteUserByID: repository error:%w", err)
+	}
+	if err = transaction.Commit(); err != nil {
+		u.logger.WithContext(ctx).Errorf("DeleteUserByID: can not commit transaction:%s", err)
+		return 0, fmt.Errorf("deleteUserByID: can not commit transaction:%w", err)
+	}
 	return userId, nil
 }
 
 // GetUserByEmail ...
-func (u *UserPostgres) GetUserByEmail(email string) (*model.User, error) {
+func (u *UserPostgres) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
 	var User model.User
-	query := "SELECT id, email, password, role, deleted FROM users WHERE email = $1"
-	row := u.db.QueryRow(query, email)
-	if err := row.Scan(&User.ID, &User.Email, &User.Password, &User.Role, &User.Deleted); err != nil {
-		u.logger.Errorf("Error while scanning for user:%s", err)
+	var lockedUntil sql.NullTime
+	query := "SELECT id, email, password, role, deleted, email_verified, totp_enabled, failed_login_attempts, lockout_count, locked_until FROM users WHERE email = $1"
+	row := u.db.QueryRowContext(ctx, query, email)
+	if err := row.Scan(&User.ID, &User.Email, &User.Password, &User.Role, &User.Deleted, &User.EmailVerified, &User.TOTPEnabled,
+		&User.FailedLoginAttempts, &User.LockoutCount, &lockedUntil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			u.logger.WithContext(ctx).Warn("GetUserByEmail: user with this email does not exist")
+			return nil, pkg.ErrorEmailDoesNotExist
+		}
+		u.logger.WithContext(ctx).Errorf("Error while scanning for user:%s", err)
 		return nil, fmt.Errorf("getUserByEmail: repository error:%w", err)
 
 	}
+	if lockedUntil.Valid {
+		User.LockedUntil = &lockedUntil.Time
+	}
 	return &User, nil
 }
 
 // CheckEmail ...
-func (u *UserPostgres) CheckEmail(email string) error {
+func (u *UserPostgres) CheckEmail(ctx context.Context, email string) error {
 	var exist bool
 	query := "SELECT EXISTS (select 1 from users where email = $1)"
-	row := u.db.QueryRow(query, email)
+	row := u.db.QueryRowContext(ctx, query, email)
 	if err := row.Scan(&exist); err != nil {
-		u.logger.Errorf("Error while scanning for issued email:%s", err)
+		u.logger.WithContext(ctx).Errorf("Error while scanning for issued email:%s", err)
 		return err
 	}
 	if !exist {
-		u.logger.Error("user with this email does not exist")
+		u.logger.WithContext(ctx).Error("user with this email does not exist")
 		return pkg.ErrorEmailDoesNotExist
 	}
 	return nil
 }
-func (u *UserPostgres) RestorePassword(restore *model.RestorePassword) error {
-	query := "UPDATE users SET password = $1 WHERE email=$2"
-	_, err := u.db.Exec(query, restore.Password, restore.Email)
+
+// RegisterFailedLogin increments the failed login counter and returns
+// the new number of attempts and the number of previous lockouts
+func (u *UserPostgres) RegisterFailedLogin(ctx context.Context, id int) (int, int, error) {
+	var attempts, lockouts int
+	query := "UPDATE users SET failed_login_attempts = failed_login_attempts + 1 WHERE id = $1 RETURNING failed_login_attempts, lockout_count"
+	row := u.db.QueryRowContext(ctx, query, id)
+	if err := row.Scan(&attempts, &lockouts); err != nil {
+		u.logger.WithContext(ctx).Errorf("RegisterFailedLogin: error while scanning for attempts:%s", err)
+		return 0, 0, fmt.Errorf("registerFailedLogin: repository error:%w", err)
+	}
+	return attempts, lockouts, nil
+}
+
+// RestoreUser reactivates the deleted user and records the event in one transaction
+func (u *UserPostgres) RestoreUser(ctx context.Context, id int, event *model.AuditEvent) error {
+	var deleted bool
+	transaction, err := u.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		u.logger.WithContext(ctx).Errorf("RestoreUser: can not starts transaction:%s", err)
+		return fmt.Errorf("restoreUser: can not starts transaction:%w", err)
+	}
+	defer transaction.Rollback()
+	row := transaction.QueryRowContext(ctx, "SELECT deleted FROM users WHERE id = $1 FOR UPDATE", id)
+	if err = row.Scan(&deleted); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			u.logger.WithContext(ctx).Warnf("RestoreUser: user (id = %d) does not exist", id)
+			return fmt.Errorf("restoreUser:%w", pkg.ErrorUserNotFound)
+		}
+		u.logger.WithContext(ctx).Errorf("RestoreUser: error while scanning for user:%s", err)
+		return fmt.Errorf("restoreUser: repository error:%w", err)
+	}
+	if !deleted {
+		u.logger.WithContext(ctx).Warnf("RestoreUser: user (id = %d) is already active", id)
+		return fmt.Errorf("restoreUser:%w", pkg.ErrorUserActive)
+	}
+	if _, err = transaction.ExecContext(ctx, "UPDATE users SET deleted = false WHERE id = $1", id); err != nil {
+		u.logger.WithContext(ctx).Errorf("RestoreUser: error while restoring user:%s", err)
+		return fmt.Errorf("restoreUser: repository error:%w", err)
+	}
+	event.TargetID = id
+	event.Diff = map[string]model.AuditChange{"deleted": {Old: true, New: false}}
+	if err = insertAuditEvent(ctx, transaction, event); err != nil {
+		u.logger.WithContext(ctx).Errorf("RestoreUser:%s", err)
+		return fmt.Errorf("restoreUser: repository error:%w", err)
+	}
+	if err = transaction.Commit(); err != nil {
+		u.logger.WithContext(ctx).Errorf("RestoreUser: can not commit transaction:%s", err)
+		return fmt.Errorf("restoreUser: can not commit transaction:%w", err)
 	}
 	return nil
 }
+
+// LockUser locks the user out until the given time, unless a concurrent
+// login has already done it and reset the counter. The event is recorded in
+// the same transaction when the user is locked.
+func (u *UserPostgres) LockUser(ctx context.Context, id int, until time.Time, maxAttempts int, event *model.AuditEvent) error {
+	var attempts int
+	var lockedUntil sql.NullTime
+	transaction, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("LockUser: can not starts transaction:%s", err)
+		return fmt.Errorf("lockUser: can not starts transaction:%w", err)
+	}
+	defer transaction.Rollback()
+	query := `WITH old AS (SELECT id, failed_login_attempts, locked_until FROM users WHERE id = $2 FOR UPDATE)
+		UPDATE users SET failed_login_attempts = 0, lockout_count = lockout_count + 1, locked_until = $1 FROM old
+		WHERE users.id = old.id AND old.failed_login_attempts >= $3 RETURNING old.failed_login_attempts, old.locked_until`
+	row := transaction.QueryRowContext(ctx, query, until, id, maxAttempts)
+	if err = row.Scan(&attempts, &lockedUntil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		u.logger.WithContext(ctx).Errorf("LockUser: error while updating user:%s", err)
+		return fmt.Errorf("lockUser: repository error:%w", err)
+	}
+	event.TargetID = id
+	event.Diff = map[string]model.AuditChange{
+		"failed_login_attempts": {Old: attempts, New: 0},
+		"locked_until":          {New: until.UTC()},
+	}
+	if lockedUntil.Valid {
+		event.Diff["locked_until"] = model.AuditChange{Old: lockedUntil.Time, New: until.UTC()}
+	}
+	if err = insertAuditEvent(ctx, transaction, event); err != nil {
+		u.logger.WithContext(ctx).Errorf("LockUser:%s", err)
+		return fmt.Errorf("lockUser: repository error:%w", err)
+	}
+	if err = transaction.Commit(); err != nil {
+		u.logger.WithContext(ctx).Errorf("LockUser: can not commit transaction:%s", err)
+		return fmt.Errorf("lockUser: can not commit transaction:%w", err)
+	}
+	return nil
+}
+
+// UnlockUser resets the failed login counter and lifts the lockout, the event is
+// recorded in the same transaction
+func (u *UserPostgres) UnlockUser(ctx context.Context, id int, event *model.AuditEvent) (int, error) {
+	var userId, attempts int
+	var lockedUntil sql.NullTime
+	transaction, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("UnlockUser: can not starts transaction:%s", err)
+		return 0, fmt.Errorf("unlockUser: can not starts transaction:%w", err)
+	}
+	defer transaction.Rollback()
+	query := `WITH old AS (SELECT id, failed_login_attempts, locked_until FROM users WHERE id = $1 FOR UPDATE)
+		UPDATE users SET failed_login_attempts = 0, lockout_count = 0, locked_until = NULL FROM old
+		WHERE users.id = old.id RETURNING users.id, old.failed_login_attempts, old.locked_until`
+	row := transaction.QueryRowContext(ctx, query, id)
+	if err := row.Scan(&userId, &attempts, &lockedUntil); err != nil {
+		u.logger.WithContext(ctx).Errorf("UnlockUser: error while scanning for userId:%s", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("unlockUser:%w", pkg.ErrorUserNotFound)
+		}
+		return 0, fmt.Errorf("unlockUser: repository error:%w", err)
+	}
+	event.TargetID = userId
+	event.Diff = map[string]model.AuditChange{"failed_login_attempts": {Old: attempts, New: 0}}
+	if lockedUntil.Valid {
+		event.Diff["locked_until"] = model.AuditChange{Old: lockedUntil.Time}
+	}
+	if err = insertAuditEvent(ctx, transaction, event); err != nil {
+		u.logger.WithContext(ctx).Errorf("UnlockUser:%s", err)
+		return 0, fmt.Errorf("unlockUser: repository error:%w", err)
+	}
+	if err = transaction.Commit(); err != nil {
+		u.logger.WithContext(ctx).Errorf("UnlockUser: can not commit transaction:%s", err)
+		return 0, fmt.Errorf("unlockUser: can not commit transaction:%w", err)
+	}
+	return userId, nil
+}
+
+// VerifyEmail marks the email as verified, the email is matched as well so that
+// a link sent to a previous address can not verify a new one
+func (u *UserPostgres) VerifyEmail(ctx context.Context, id int, email string) (int, error) {
+	var userId int
+	query := `UPDATE users SET email_verified = true, email_verified_at = COALESCE(email_verified_at, $3)
+		WHERE id = $1 AND email = $2 AND deleted = false RETURNING id`
+	row := u.db.QueryRowContext(ctx, query, id, email, time.Now().UTC())
+	if err := row.Scan(&userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			u.logger.WithContext(ctx).Warnf("VerifyEmail: user (id = %d) with this email not found", id)
+			return 0, pkg.ErrorInvalidVerification
+		}
+		u.logger.WithContext(ctx).Errorf("VerifyEmail: error while scanning for userId:%s", err)
+		return 0, fmt.Errorf("verifyEmail: repository error:%w", err)
+	}
+	return userId, nil
+}
@@ -1,11 +1,15 @@
 package repository
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
+	"regexp"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
 	"testing"
 	"time"
@@ -31,9 +35,9 @@ func TestRepository_GetUserByID(t *testing.T) {
 		{
 			name: "OK",
 			mock: func(id int) {
-				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at"}).
-					AddRow(1, "test@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)})
-				mock.ExpectQuery("SELECT id, email, role, created_at FROM users WHERE id = (.+)").
+				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at", "email_verified", "deleted"}).
+					AddRow(1, "test@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}, false, true)
+				mock.ExpectQuery("SELECT id, email, role, created_at, email_verified, deleted FROM users WHERE id = (.+)").
 					WithArgs(id).WillReturnRows(rows)
 			},
 			id: 1,
@@ -42,14 +46,15 @@ func TestRepository_GetUserByID(t *testing.T) {
 				Email:     "test@yandex.ru",
 				Role:      "Courier",
 				CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
+				Deleted:   true,
 			},
 			expectedError: false,
 		},
 		{
 			name: "Not found",
 			mock: func(id int) {
-				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at"})
-				mock.ExpectQuery("SELECT id, email, role, created_at FROM users WHERE id = (.+)").
+				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at", "email_verified", "deleted"})
+				mock.ExpectQuery("SELECT id, email, role, created_at, email_verified, deleted FROM users WHERE id = (.+)").
 					WithArgs(id).WillReturnRows(rows)
 
 			},
@@ -61,9 +66,9 @@ func TestRepository_GetUserByID(t *testing.T) {
 	for _, tt := range testTable {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock(tt.id)
-			got, err := r.GetUserByID(tt.id)
+			got, err := r.GetUserByID(context.Background(), tt.id)
 			if tt.expectedError {
-				assert.Error(t, err)
+				assert.ErrorIs(t, err, pkg.ErrorUserNotFound)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expectedUser, got)
@@ -73,6 +78,72 @@ func TestRepository_GetUserByID(t *testing.T) {
 	}
 }
 
+func TestRepository_GetUsersByIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+
+	testTable := []struct {
+		name          string
+		mock          func(ids []int)
+		ids           []int
+		expectedUsers []model.ResponseUser
+		expectedError bool
+	}{
+		{
+			name: "OK",
+			mock: func(ids []int) {
+				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at", "email_verified"}).
+					AddRow(3, "courier@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}, true).
+					AddRow(1, "test@yandex.ru", "Superadmin", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}, false)
+				mock.ExpectQuery("SELECT id, email, role, created_at, email_verified FROM users WHERE id = ANY(.+) AND deleted = false ORDER BY array_position(.+)").
+					WithArgs(pq.Array(ids)).WillReturnRows(rows)
+			},
+			ids: []int{3, 2, 1},
+			expectedUsers: []model.ResponseUser{
+				{
+					ID:            3,
+					Email:         "courier@yandex.ru",
+					Role:          "Courier",
+					CreatedAt:     model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
+					EmailVerified: true,
+				},
+				{
+					ID:        1,
+					Email:     "test@yandex.ru",
+					Role:      "Superadmin",
+					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+		{
+			name: "Repository error",
+			mock: func(ids []int) {
+				mock.ExpectQuery("SELECT id, email, role, created_at, email_verified FROM users WHERE id = ANY(.+)").
+					WithArgs(pq.Array(ids)).WillReturnError(errors.New("repository error"))
+			},
+			ids:           []int{1},
+			expectedError: true,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.ids)
+			got, err := r.GetUsersByIDs(context.Background(), tt.ids)
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedUsers, got)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestRepository_GetUserPasswordByID(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -115,7 +186,7 @@ func TestRepository_GetUserPasswordByID(t *testing.T) {
 	for _, tt := range testTable {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock(tt.id)
-			got, err := r.GetUserPasswordByID(tt.id)
+			got, err := r.GetUserPasswordByID(context.Background(), tt.id)
 			if tt.expectedError {
 				assert.Error(t, err)
 			} else {
@@ -127,7 +198,7 @@ func TestRepository_GetUserPasswordByID(t *testing.T) {
 	}
 }
 
-func TestRepository_GetUserAll(t *testing.T) {
+func TestRepository_GetUsers(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		logger.Fatal(err)
@@ -135,129 +206,175 @@ func TestRepository_GetUserAll(t *testing.T) {
 	defer db.Close()
 	r := NewRepository(db, logger)
 
+	createdAt := model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "email", "role", "created_at", "email_verified"}).
+			AddRow(1, "test@yandex.ru", "Courier", createdAt, false).
+			AddRow(2, "test1@yandex.ru", "Courier", createdAt, true)
+	}
+	expectedUsers := []model.ResponseUser{
+		{ID: 1, Email: "test@yandex.ru", Role: "Courier", CreatedAt: createdAt},
+		{ID: 2, Email: "test1@yandex.ru", Role: "Courier", CreatedAt: createdAt, EmailVerified: true},
+	}
 	testTable := []struct {
 		name          string
 		inputPage     int
 		inputLimit    int
-		mock          func(page, limit int)
+		inputFilter   *model.RequestFilters
+		inputSort     []model.SortField
+		mock          func()
 		expectedUser  []model.ResponseUser
-		expectedPages int
+		expectedTotal int
 		expectedError bool
 	}{
 		{
-			name:       "Zero page and limit",
-			inputPage:  0,
-			inputLimit: 0,
-			mock: func(page, limit int) {
+			name:        "Zero page and limit",
+			inputFilter: &model.RequestFilters{},
+			mock: func() {
 				mock.ExpectBegin()
-				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at"}).
-					AddRow(1, "test@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(2, "test1@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(3, "test2@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)})
-				mock.ExpectQuery("SELECT id, email, role, created_at FROM users").WillReturnRows(rows)
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email, role, created_at, email_verified FROM users WHERE deleted = false ORDER BY id")).
+					WithArgs().WillReturnRows(newRows())
 				mock.ExpectCommit()
 			},
-
-			expectedUser: []model.ResponseUser{
-				{
-					ID:        1,
-					Email:     "test@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        2,
-					Email:     "test1@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        3,
-					Email:     "test2@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
+			expectedUser:  expectedUsers,
+			expectedTotal: 2,
+		},
+		{
+			name:        "Page and limit",
+			inputPage:   2,
+			inputLimit:  10,
+			inputFilter: &model.RequestFilters{},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(id) FROM users WHERE deleted = false")).
+					WithArgs().WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(22))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email, role, created_at, email_verified FROM users WHERE deleted = false ORDER BY id LIMIT $1 OFFSET $2")).
+					WithArgs(10, 10).WillReturnRows(newRows())
+				mock.ExpectCommit()
 			},
-			expectedError: false,
+			expectedUser:  expectedUsers,
+			expectedTotal: 22,
 		},
 		{
-			name:       "OK",
+			name:       "Combined filters",
 			inputPage:  1,
 			inputLimit: 10,
-			mock: func(page, limit int) {
+			inputFilter: &model.RequestFilters{
+				Deleted:   model.DeletedAll,
+				Roles:     []string{"Courier", "Courier manager"},
+				StartTime: model.MyTime{Time: time.Date(2022, 03, 01, 0, 0, 0, 0, time.UTC)},
+				EndTime:   model.MyTime{Time: time.Date(2022, 03, 31, 0, 0, 0, 0, time.UTC)},
+			},
+			mock: func() {
+				where := " WHERE role = ANY($1) AND created_at >= $2 AND created_at < $3"
+				args := []driver.Value{pq.Array([]string{"Courier", "Courier manager"}),
+					time.Date(2022, 03, 01, 0, 0, 0, 0, time.UTC), time.Date(2022, 04, 01, 0, 0, 0, 0, time.UTC)}
 				mock.ExpectBegin()
-				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at"}).
-					AddRow(1, "test@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(2, "test1@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(3, "test2@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)})
-
-				mock.ExpectQuery("SELECT id, email, role, created_at FROM users WHERE deleted = false ORDER BY id LIMIT (.+) OFFSET (.+)").WithArgs(limit, (page-1)*limit).WillReturnRows(rows)
-				rows2 := sqlmock.NewRows([]string{"pages"}).
-					AddRow(1)
-				mock.ExpectQuery("SELECT CEILING").WillReturnRows(rows2)
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(id) FROM users" + where)).
+					WithArgs(args...).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email, role, created_at, email_verified FROM users" + where + " ORDER BY id LIMIT $4 OFFSET $5")).
+					WithArgs(append(args, 10, 0)...).WillReturnRows(newRows())
 				mock.ExpectCommit()
 			},
-
-			expectedUser: []model.ResponseUser{
-				{
-					ID:        1,
-					Email:     "test@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        2,
-					Email:     "test1@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        3,
-					Email:     "test2@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
+			expectedUser:  expectedUsers,
+			expectedTotal: 2,
+		},
+		{
+			name:        "Sorted",
+			inputFilter: &model.RequestFilters{},
+			inputSort:   []model.SortField{{Column: "created_at", Desc: true}, {Column: "email"}},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email, role, created_at, email_verified FROM users WHERE deleted = false ORDER BY created_at DESC, email, id")).
+					WillReturnRows(newRows())
+				mock.ExpectCommit()
 			},
-			expectedError: false,
+			expectedUser:  expectedUsers,
+			expectedTotal: 2,
 		},
 		{
-			name:       "db error",
-			inputPage:  1,
-			inputLimit: 10,
-			mock: func(page, limit int) {
+			name:        "Ranked search",
+			inputPage:   1,
+			inputLimit:  10,
+			inputFilter: &model.RequestFilters{Roles: []string{"Customer"}, Query: "ivan"},
+			inputSort:   []model.SortField{{Column: model.SortRank}},
+			mock: func() {
+				where := " WHERE deleted = false AND role = ANY($1) AND email ILIKE $2"
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(id) FROM users"+where)).
+					WithArgs(pq.Array([]string{"Customer"}), "%ivan%").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email, role, created_at, email_verified FROM users"+where+
+					" ORDER BY CASE WHEN lower(email) = lower($3) THEN 0 WHEN email ILIKE $4 THEN 1 ELSE 2 END, id LIMIT $5 OFFSET $6")).
+					WithArgs(pq.Array([]string{"Customer"}), "%ivan%", "ivan", "ivan%", 10, 0).WillReturnRows(newRows())
+				mock.ExpectCommit()
+			},
+			expectedUser:  expectedUsers,
+			expectedTotal: 2,
+		},
+		{
+			name:          "Rank without search",
+			inputFilter:   &model.RequestFilters{},
+			inputSort:     []model.SortField{{Column: model.SortRank}},
+			mock:          func() {},
+			expectedError: true,
+		},
+		{
+			name:          "Unknown sort column",
+			inputFilter:   &model.RequestFilters{},
+			inputSort:     []model.SortField{{Column: "password"}},
+			mock:          func() {},
+			expectedError: true,
+		},
+		{
+			name:        "db error",
+			inputPage:   1,
+			inputLimit:  10,
+			inputFilter: &model.RequestFilters{},
+			mock: func() {
 				mock.ExpectBegin().WillReturnError(errors.New("some error"))
 			},
-			expectedUser:  nil,
 			expectedError: true,
 		},
 		{
-			name:       "db error2",
-			inputPage:  1,
-			inputLimit: 10,
-			mock: func(page, limit int) {
+			name:        "Count error",
+			inputPage:   1,
+			inputLimit:  10,
+			inputFilter: &model.RequestFilters{},
+			mock: func() {
 				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT id, email, role, created_at FROM users WHERE deleted = false ORDER BY id LIMIT (.+) OFFSET (.+)").WithArgs(limit, (page-1)*limit).WillReturnError(errors.New("some error"))
+				mock.ExpectQuery("SELECT COUNT").WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
+			},
+			expectedError: true,
+		},
+		{
+			name:        "Query error",
+			inputFilter: &model.RequestFilters{},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT id, email, role, created_at, email_verified FROM users").WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
 			},
-			expectedUser:  nil,
 			expectedError: true,
 		},
 	}
 	for _, tt := range testTable {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.mock(tt.inputPage, tt.inputLimit)
-			got, _, err := r.GetUserAll(tt.inputPage, tt.inputLimit)
+			tt.mock()
+			got, total, err := r.GetUsers(context.Background(), tt.inputPage, tt.inputLimit, tt.inputFilter, tt.inputSort)
 			if tt.expectedError {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expectedUser, got)
+				assert.Equal(t, tt.expectedTotal, total)
 			}
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
 
-func TestRepository_GetUserByRoleFilter(t *testing.T) {
+func TestRepository_contextDone(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		logger.Fatal(err)
@@ -267,172 +384,58 @@ func TestRepository_GetUserByRoleFilter(t *testing.T) {
 
 	testTable := []struct {
 		name          string
-		inputPage     int
-		inputLimit    int
-		inputFilter   *model.RequestFilters
-		mock          func(page, limit int, filter *model.RequestFilters)
-		expectedUser  []model.ResponseUser
-		expectedPages int
-		expectedError bool
+		mock          func()
+		ctx           func() (context.Context, context.CancelFunc)
+		call          func(ctx context.Context) error
+		expectedError error
 	}{
 		{
-			name:       "OK Zero page and limit",
-			inputPage:  0,
-			inputLimit: 0,
-			inputFilter: &model.RequestFilters{
-				ShowDeleted: false,
-				FilterData:  false,
-				StartTime:   model.MyTime{},
-				EndTime:     model.MyTime{},
-				Role:        "Courier",
+			name: "Cancelled before the query",
+			mock: func() {},
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
 			},
-			mock: func(page, limit int, filter *model.RequestFilters) {
-				mock.ExpectBegin()
-				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at"}).
-					AddRow(1, "test@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(2, "test1@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(3, "test2@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)})
-				mock.ExpectQuery("SELECT id, email, role, created_at FROM users WHERE").WithArgs(filter.Role).WillReturnRows(rows)
-				mock.ExpectCommit()
+			call: func(ctx context.Context) error {
+				_, err := r.GetUserByID(ctx, 1)
+				return err
 			},
-
-			expectedUser: []model.ResponseUser{
-				{
-					ID:        1,
-					Email:     "test@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        2,
-					Email:     "test1@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        3,
-					Email:     "test2@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-			},
-			expectedError: false,
+			expectedError: context.Canceled,
 		},
 		{
-			name:       "OK With page and limit",
-			inputPage:  1,
-			inputLimit: 10,
-			inputFilter: &model.RequestFilters{
-				ShowDeleted: false,
-				FilterData:  false,
-				StartTime:   model.MyTime{},
-				EndTime:     model.MyTime{},
-				Role:        "Courier",
-			},
-			mock: func(page, limit int, filter *model.RequestFilters) {
+			name: "Deadline within a transaction",
+			mock: func() {
 				mock.ExpectBegin()
-				rowsForPages := sqlmock.NewRows([]string{"pages"}).AddRow("1")
-				mock.ExpectQuery("SELECT CEILING").WithArgs(limit, filter.Role).WillReturnRows(rowsForPages)
-				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at"}).
-					AddRow(1, "test@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(2, "test1@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(3, "test2@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)})
-				mock.ExpectQuery("SELECT id, email, role, created_at FROM users WHERE").WithArgs(filter.Role, limit, (page-1)*limit).WillReturnRows(rows)
-				mock.ExpectCommit()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(id) FROM users WHERE deleted = false")).
+					WillDelayFor(time.Second).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mock.ExpectRollback()
 			},
-
-			expectedUser: []model.ResponseUser{
-				{
-					ID:        1,
-					Email:     "test@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        2,
-					Email:     "test1@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        3,
-					Email:     "test2@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 50*time.Millisecond)
 			},
-			expectedError: false,
-		},
-		{
-			name:       "OK showDeleted = true Zero page and limit",
-			inputPage:  0,
-			inputLimit: 0,
-			inputFilter: &model.RequestFilters{
-				ShowDeleted: true,
-				FilterData:  false,
-				StartTime:   model.MyTime{},
-				EndTime:     model.MyTime{},
-				Role:        "Courier",
+			call: func(ctx context.Context) error {
+				_, _, err := r.GetUsers(ctx, 1, 10, &model.RequestFilters{}, nil)
+				return err
 			},
-			mock: func(page, limit int, filter *model.RequestFilters) {
-				mock.ExpectBegin()
-				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at"}).
-					AddRow(1, "test@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(2, "test1@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(3, "test2@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)})
-				mock.ExpectQuery("SELECT id, email, role, created_at FROM users WHERE").WithArgs(filter.Role).WillReturnRows(rows)
-				mock.ExpectCommit()
-			},
-
-			expectedUser: []model.ResponseUser{
-				{
-					ID:        1,
-					Email:     "test@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        2,
-					Email:     "test1@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        3,
-					Email:     "test2@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-			},
-			expectedError: false,
-		},
-		{
-			name:       "db error",
-			inputPage:  1,
-			inputLimit: 10,
-			mock: func(page, limit int, filter *model.RequestFilters) {
-				mock.ExpectBegin().WillReturnError(errors.New("some error"))
-			},
-			expectedUser:  nil,
-			expectedError: true,
+			expectedError: sqlmock.ErrCancelled,
 		},
 	}
 	for _, tt := range testTable {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.mock(tt.inputPage, tt.inputLimit, tt.inputFilter)
-			got, _, err := r.GetUserByRoleFilter(tt.inputPage, tt.inputLimit, tt.inputFilter)
-			if tt.expectedError {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-				assert.Equal(t, tt.expectedUser, got)
-			}
+			tt.mock()
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			err := tt.call(ctx)
+			assert.ErrorIs(t, err, tt.expectedError)
+			// a cancelled transaction is rolled back in the background
+			time.Sleep(10 * time.Millisecond)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
 
-func TestRepository_GetUserByDataFilter(t *testing.T) {
+func TestRepository_GetUsersByCursor(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		logger.Fatal(err)
@@ -440,173 +443,197 @@ func TestRepository_GetUserByDataFilter(t *testing.T) {
 	defer db.Close()
 	r := NewRepository(db, logger)
 
+	createdAt := model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}
+	columns := []string{"id", "email", "role", "created_at", "email_verified"}
+	rank := 1
 	testTable := []struct {
 		name          string
-		inputPage     int
-		inputLimit    int
 		inputFilter   *model.RequestFilters
-		mock          func(page, limit int, filter *model.RequestFilters)
-		expectedUser  []model.ResponseUser
-		expectedPages int
+		inputQuery    *model.CursorQuery
+		mock          func()
+		expectedIDs   []int
+		expectedMore  bool
 		expectedError bool
 	}{
 		{
-			name:       "OK Zero page and limit",
-			inputPage:  0,
-			inputLimit: 0,
-			inputFilter: &model.RequestFilters{
-				ShowDeleted: false,
-				FilterData:  true,
-				StartTime:   model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				EndTime:     model.MyTime{},
-				Role:        "",
-			},
-			mock: func(page, limit int, filter *model.RequestFilters) {
-				mock.ExpectBegin()
-				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at"}).
-					AddRow(1, "test@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(2, "test1@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(3, "test2@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)})
-				mock.ExpectQuery("SELECT id, email, role, created_at FROM users WHERE").WithArgs(filter.StartTime, filter.EndTime).WillReturnRows(rows)
-				mock.ExpectCommit()
+			name:        "First page",
+			inputFilter: &model.RequestFilters{},
+			inputQuery:  &model.CursorQuery{Limit: 2},
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email, role, created_at, email_verified FROM users WHERE deleted = false ORDER BY id LIMIT $1")).
+					WithArgs(3).WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(1, "test1@yandex.ru", "Courier", createdAt, false).
+					AddRow(2, "test2@yandex.ru", "Courier", createdAt, false).
+					AddRow(3, "test3@yandex.ru", "Courier", createdAt, false))
 			},
-
-			expectedUser: []model.ResponseUser{
-				{
-					ID:        1,
-					Email:     "test@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        2,
-					Email:     "test1@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        3,
-					Email:     "test2@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
+			expectedIDs:  []int{1, 2},
+			expectedMore: true,
+		},
+		{
+			name:        "After cursor",
+			inputFilter: &model.RequestFilters{Roles: []string{"Courier"}},
+			inputQuery:  &model.CursorQuery{Cursor: &model.UserCursor{ID: 2}, Limit: 2},
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email, role, created_at, email_verified FROM users WHERE deleted = false AND role = ANY($1) AND id > $2 ORDER BY id LIMIT $3")).
+					WithArgs(pq.Array([]string{"Courier"}), 2, 3).WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(3, "test3@yandex.ru", "Courier", createdAt, false))
 			},
-			expectedError: false,
+			expectedIDs:  []int{3},
+			expectedMore: false,
 		},
 		{
-			name:       "OK With page and limit",
-			inputPage:  1,
-			inputLimit: 10,
-			inputFilter: &model.RequestFilters{
-				ShowDeleted: false,
-				FilterData:  true,
-				StartTime:   model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				EndTime:     model.MyTime{},
-				Role:        "",
+			name:        "Before cursor",
+			inputFilter: &model.RequestFilters{},
+			inputQuery:  &model.CursorQuery{Cursor: &model.UserCursor{ID: 5}, Backward: true, Limit: 2},
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email, role, created_at, email_verified FROM users WHERE deleted = false AND id < $1 ORDER BY id DESC LIMIT $2")).
+					WithArgs(5, 3).WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(4, "test4@yandex.ru", "Courier", createdAt, false).
+					AddRow(3, "test3@yandex.ru", "Courier", createdAt, false).
+					AddRow(2, "test2@yandex.ru", "Courier", createdAt, false))
 			},
-			mock: func(page, limit int, filter *model.RequestFilters) {
-				mock.ExpectBegin()
-				rowsForPages := sqlmock.NewRows([]string{"pages"}).AddRow("1")
-				mock.ExpectQuery("SELECT CEILING").WithArgs(limit, filter.StartTime, filter.EndTime).WillReturnRows(rowsForPages)
-				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at"}).
-					AddRow(1, "test@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(2, "test1@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(3, "test2@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)})
-				mock.ExpectQuery("SELECT id, email, role, created_at FROM users WHERE").WithArgs(filter.StartTime, filter.EndTime, limit, (page-1)*limit).WillReturnRows(rows)
-				mock.ExpectCommit()
+			expectedIDs:  []int{3, 4},
+			expectedMore: true,
+		},
+		{
+			name:        "Sorted before cursor",
+			inputFilter: &model.RequestFilters{Deleted: model.DeletedAll},
+			inputQuery: &model.CursorQuery{
+				Cursor:   &model.UserCursor{ID: 5, Email: "test5@yandex.ru"},
+				Backward: true,
+				Limit:    2,
+				Sort:     []model.SortField{{Column: "email", Desc: true}},
 			},
-
-			expectedUser: []model.ResponseUser{
-				{
-					ID:        1,
-					Email:     "test@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        2,
-					Email:     "test1@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        3,
-					Email:     "test2@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
+			mock: func() {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email, role, created_at, email_verified FROM users WHERE (email > $1 OR (email = $1 AND id < $2)) ORDER BY email, id DESC LIMIT $3")).
+					WithArgs("test5@yandex.ru", 5, 3).WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(6, "test6@yandex.ru", "Courier", createdAt, false))
 			},
-			expectedError: false,
+			expectedIDs:  []int{6},
+			expectedMore: false,
 		},
 		{
-			name:       "OK showDeleted = true Zero page and limit",
-			inputPage:  0,
-			inputLimit: 0,
-			inputFilter: &model.RequestFilters{
-				ShowDeleted: true,
-				FilterData:  true,
-				StartTime:   model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				EndTime:     model.MyTime{},
-				Role:        "",
+			name:        "Ranked search after cursor",
+			inputFilter: &model.RequestFilters{Query: "iv"},
+			inputQuery: &model.CursorQuery{
+				Cursor: &model.UserCursor{ID: 4, Rank: &rank},
+				Limit:  2,
+				Sort:   []model.SortField{{Column: model.SortRank}},
 			},
-			mock: func(page, limit int, filter *model.RequestFilters) {
-				mock.ExpectBegin()
-				rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at"}).
-					AddRow(1, "test@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(2, "test1@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)}).
-					AddRow(3, "test2@yandex.ru", "Courier", model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)})
-				mock.ExpectQuery("SELECT id, email, role, created_at FROM users WHERE").WithArgs(filter.StartTime, filter.EndTime).WillReturnRows(rows)
-				mock.ExpectCommit()
+			mock: func() {
+				rankSQL := "CASE WHEN lower(email) = lower($2) THEN 0 WHEN email ILIKE $3 THEN 1 ELSE 2 END"
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, email, role, created_at, email_verified FROM users WHERE deleted = false AND email ILIKE $1 AND ("+
+					rankSQL+" > $4 OR ("+rankSQL+" = $4 AND id > $5)) ORDER BY "+rankSQL+", id LIMIT $6")).
+					WithArgs("%iv%", "iv", "iv%", 1, 4, 3).WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(2, "olivia@yandex.ru", "Courier", createdAt, false))
 			},
-
-			expectedUser: []model.ResponseUser{
-				{
-					ID:        1,
-					Email:     "test@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        2,
-					Email:     "test1@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-				{
-					ID:        3,
-					Email:     "test2@yandex.ru",
-					Role:      "Courier",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				},
-			},
-			expectedError: false,
+			expectedIDs:  []int{2},
+			expectedMore: false,
 		},
 		{
-			name:       "db error",
-			inputPage:  1,
-			inputLimit: 10,
-			mock: func(page, limit int, filter *model.RequestFilters) {
-				mock.ExpectBegin().WillReturnError(errors.New("some error"))
+			name:        "db error",
+			inputFilter: &model.RequestFilters{},
+			inputQuery:  &model.CursorQuery{Limit: 2},
+			mock: func() {
+				mock.ExpectQuery("SELECT id, email, role, created_at, email_verified FROM users").WillReturnError(errors.New("some error"))
 			},
-			expectedUser:  nil,
 			expectedError: true,
 		},
 	}
 	for _, tt := range testTable {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.mock(tt.inputPage, tt.inputLimit, tt.inputFilter)
-			got, _, err := r.GetUserByDataFilter(tt.inputPage, tt.inputLimit, tt.inputFilter)
+			tt.mock()
+			got, more, err := r.GetUsersByCursor(context.Background(), tt.inputFilter, tt.inputQuery)
 			if tt.expectedError {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.expectedUser, got)
+				ids := make([]int, len(got))
+				for i := range got {
+					ids[i] = got[i].ID
+				}
+				assert.Equal(t, tt.expectedIDs, ids)
+				assert.Equal(t, tt.expectedMore, more)
 			}
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
 
+func TestKeysetCondition(t *testing.T) {
+	createdAt := time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)
+	cursor := &model.UserCursor{ID: 7, Email: "test@yandex.ru", CreatedAt: &createdAt}
+	fields, err := sortFields([]model.SortField{{Column: "created_at"}, {Column: "email", Desc: true}}, &model.RequestFilters{})
+	assert.NoError(t, err)
+
+	condition, args := keysetCondition(fields, cursor, false, []interface{}{"Courier"}, nil)
+	assert.Equal(t, "(created_at > $2 OR (created_at = $2 AND email < $3) OR (created_at = $2 AND email = $3 AND id > $4))", condition)
+	assert.Equal(t, []interface{}{"Courier", createdAt, "test@yandex.ru", 7}, args)
+	assert.Equal(t, " ORDER BY created_at, email DESC, id", orderBy(fields, false, nil))
+
+	condition, _ = keysetCondition(fields, cursor, true, nil, nil)
+	assert.Equal(t, "(created_at < $1 OR (created_at = $1 AND email > $2) OR (created_at = $1 AND email = $2 AND id < $3))", condition)
+	assert.Equal(t, " ORDER BY created_at DESC, email, id DESC", orderBy(fields, true, nil))
+
+	fields, err = sortFields([]model.SortField{{Column: "id", Desc: true}}, &model.RequestFilters{})
+	assert.NoError(t, err)
+	condition, _ = keysetCondition(fields, cursor, false, nil, nil)
+	assert.Equal(t, "id < $1", condition)
+}
+
+func TestUsersWhere(t *testing.T) {
+	testTable := []struct {
+		name          string
+		filters       *model.RequestFilters
+		expectedWhere string
+		expectedArgs  []interface{}
+	}{
+		{
+			name:          "No filters",
+			filters:       &model.RequestFilters{},
+			expectedWhere: " WHERE deleted = false",
+		},
+		{
+			name:    "All users",
+			filters: &model.RequestFilters{Deleted: model.DeletedAll},
+		},
+		{
+			name:    "Show deleted",
+			filters: &model.RequestFilters{ShowDeleted: true},
+		},
+		{
+			name:          "Only deleted",
+			filters:       &model.RequestFilters{Deleted: model.DeletedOnly, ShowDeleted: true},
+			expectedWhere: " WHERE deleted = true",
+		},
+		{
+			name:          "Email and domain",
+			filters:       &model.RequestFilters{Email: "a_b%", EmailDomain: "@yandex.ru"},
+			expectedWhere: " WHERE deleted = false AND email ILIKE $1 AND email ILIKE $2",
+			expectedArgs:  []interface{}{`%a\_b\%%`, "%@yandex.ru"},
+		},
+		{
+			name:          "Search",
+			filters:       &model.RequestFilters{Query: "Ivan.P"},
+			expectedWhere: " WHERE deleted = false AND email ILIKE $1",
+			expectedArgs:  []interface{}{"%Ivan.P%"},
+		},
+		{
+			name:          "Start date only",
+			filters:       &model.RequestFilters{Deleted: model.DeletedAll, StartTime: model.MyTime{Time: time.Date(2022, 03, 01, 0, 0, 0, 0, time.UTC)}},
+			expectedWhere: " WHERE created_at >= $1",
+			expectedArgs:  []interface{}{time.Date(2022, 03, 01, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := usersWhere(tt.filters)
+			assert.Equal(t, tt.expectedWhere, where)
+			assert.Equal(t, tt.expectedArgs, args)
+		})
+	}
+}
+
 func TestRepository_GetUserByEmail(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -625,28 +652,29 @@ func TestRepository_GetUserByEmail(t *testing.T) {
 		{
 			name: "OK",
 			mock: func(email string) {
-				rows := sqlmock.NewRows([]string{"id", "email", "password", "role", "deleted"}).
-					AddRow(1, "test@yandex.ru", "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy", "Courier", false)
+				rows := sqlmock.NewRows([]string{"id", "email", "password", "role", "deleted", "email_verified", "totp_enabled", "failed_login_attempts", "lockout_count", "locked_until"}).
+					AddRow(1, "test@yandex.ru", "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy", "Courier", false, true, false, 0, 0, nil)
 
-				mock.ExpectQuery("SELECT id, email, password, role, deleted FROM users WHERE email = (.+)").
+				mock.ExpectQuery("SELECT id, email, password, role, deleted, email_verified, totp_enabled, failed_login_attempts, lockout_count, locked_until FROM users WHERE email = (.+)").
 					WithArgs(email).WillReturnRows(rows)
 			},
 			email: "test@yandex.ru",
 			expectedUser: &model.User{
-				ID:       1,
-				Email:    "test@yandex.ru",
-				Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
-				Role:     "Courier",
-				Deleted:  false,
+				ID:            1,
+				Email:         "test@yandex.ru",
+				Password:      "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+				Role:          "Courier",
+				Deleted:       false,
+				EmailVerified: true,
 			},
 			expectedError: false,
 		},
 		{
 			name: "Not found",
 			mock: func(email string) {
-				rows := sqlmock.NewRows([]string{"id", "email", "password", "role", "deleted"})
+				rows := sqlmock.NewRows([]string{"id", "email", "password", "role", "deleted", "email_verified", "totp_enabled", "failed_login_attempts", "lockout_count", "locked_until"})
 
-				mock.ExpectQuery("SELECT id, email, password, role, deleted FROM users WHERE email = (.+)").
+				mock.ExpectQuery("SELECT id, email, password, role, deleted, email_verified, totp_enabled, failed_login_attempts, lockout_count, locked_until FROM users WHERE email = (.+)").
 					WithArgs(email).WillReturnRows(rows).WillReturnError(errors.New("some error"))
 
 			},
@@ -658,7 +686,7 @@ func TestRepository_GetUserByEmail(t *testing.T) {
 	for _, tt := range testTable {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock(tt.email)
-			got, err := r.GetUserByEmail(tt.email)
+			got, err := r.GetUserByEmail(context.Background(), tt.email)
 			if tt.expectedError {
 				assert.Error(t, err)
 			} else {
@@ -688,10 +716,13 @@ func TestRepository_DeleteUserByID(t *testing.T) {
 		{
 			name: "OK",
 			mock: func(id int) {
-				rows := sqlmock.NewRows([]string{"id"}).
-					AddRow(1)
-				mock.ExpectQuery("UPDATE users SET deleted = true WHERE id=(.+) RETURNING id").
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"id", "deleted"}).
+					AddRow(1, false)
+				mock.ExpectQuery("WITH old AS (.+) UPDATE users SET deleted = true FROM old (.+) RETURNING users.id, old.deleted").
 					WithArgs(id).WillReturnRows(rows)
+				expectAuditEvent(mock, model.AuditUserDeleted, 1)
+				mock.ExpectCommit()
 			},
 			id:             1,
 			expectedUserId: 1,
@@ -700,11 +731,11 @@ func TestRepository_DeleteUserByID(t *testing.T) {
 		{
 			name: "Not found",
 			mock: func(id int) {
-				rows := sqlmock.NewRows([]string{"id"})
-
-				mock.ExpectQuery("UPDATE users SET deleted = true WHERE id=(.+) RETURNING id").
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"id", "deleted"})
+				mock.ExpectQuery("WITH old AS (.+) UPDATE users SET deleted = true FROM old (.+) RETURNING users.id, old.deleted").
 					WithArgs(id).WillReturnRows(rows)
-
+				mock.ExpectRollback()
 			},
 			id:             1,
 			expectedUserId: 0,
@@ -714,7 +745,7 @@ func TestRepository_DeleteUserByID(t *testing.T) {
 	for _, tt := range testTable {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock(tt.id)
-			got, err := r.DeleteUserByID(tt.id)
+			got, err := r.DeleteUserByID(context.Background(), tt.id, &model.AuditEvent{Action: model.AuditUserDeleted})
 			if tt.expectedError {
 				assert.Error(t, err)
 			} else {
@@ -744,10 +775,13 @@ func TestRepository_CreateStaff(t *testing.T) {
 		{
 			name: "OK",
 			mock: func(user *model.CreateStaff) {
+				mock.ExpectBegin()
 				rows := sqlmock.NewRows([]string{"id"}).
 					AddRow(1)
 				mock.ExpectQuery("INSERT INTO users").WithArgs(user.Email, user.Password, user.Role, time.Now().Format(model.Layout), false).
 					WillReturnRows(rows)
+				expectAuditEvent(mock, model.AuditStaffCreated, 1)
+				mock.ExpectCommit()
 			},
 			InputUser: &model.CreateStaff{
 				Email:    "test@yandex.ru",
@@ -761,7 +795,7 @@ func TestRepository_CreateStaff(t *testing.T) {
 	for _, tt := range testTable {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock(tt.InputUser)
-			got, err := r.CreateStaff(tt.InputUser)
+			got, err := r.CreateStaff(context.Background(), tt.InputUser, &model.AuditEvent{Action: model.AuditStaffCreated})
 			if tt.expectedError {
 				assert.Error(t, err)
 			} else {
@@ -793,7 +827,7 @@ func TestRepository_CreateCustomer(t *testing.T) {
 			mock: func(user *model.CreateCustomer) {
 				rows := sqlmock.NewRows([]string{"id"}).
 					AddRow(1)
-				mock.ExpectQuery("INSERT INTO users").WithArgs(user.Email, user.Password, "Authorized Customer", time.Now().Format(model.Layout), false).
+				mock.ExpectQuery("INSERT INTO users").WithArgs(user.Email, user.Password, "Authorized Customer", time.Now().Format(model.Layout), false, false).
 					WillReturnRows(rows)
 			},
 			InputUser: &model.CreateCustomer{
@@ -807,7 +841,7 @@ func TestRepository_CreateCustomer(t *testing.T) {
 	for _, tt := range testTable {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock(tt.InputUser)
-			got, err := r.CreateCustomer(tt.InputUser)
+			got, err := r.CreateCustomer(context.Background(), tt.InputUser)
 			if tt.expectedError {
 				assert.Error(t, err)
 			} else {
@@ -836,9 +870,11 @@ func TestRepository_UpdateUser(t *testing.T) {
 		{
 			name: "OK",
 			mock: func(user *model.UpdateUser) {
-				result := driver.ResultNoRows
-				mock.ExpectExec("UPDATE users").
-					WithArgs(user.NewPassword, user.Email).WillReturnResult(result)
+				mock.ExpectBegin()
+				mock.ExpectQuery("UPDATE users SET password = (.+) WHERE email = (.+) RETURNING id").
+					WithArgs(user.NewPassword, user.Email).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				expectAuditEvent(mock, model.AuditPasswordChanged, 1)
+				mock.ExpectCommit()
 			},
 			InputUser: &model.UpdateUser{
 				Email:       "test@yandex.ru",
@@ -847,11 +883,140 @@ func TestRepository_UpdateUser(t *testing.T) {
 			},
 			expectedError: false,
 		},
+		{
+			name: "Not found",
+			mock: func(user *model.UpdateUser) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("UPDATE users SET password = (.+) WHERE email = (.+) RETURNING id").
+					WithArgs(user.NewPassword, user.Email).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+			InputUser: &model.UpdateUser{
+				Email:       "test@yandex.ru",
+				NewPassword: "$2a$10$EpAGhm0HGkxBiPyBAB7xzuyEbZlZCjvSdcJTjamaJyxZRir1vaMmW",
+			},
+			expectedError: true,
+		},
 	}
 	for _, tt := range testTable {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock(tt.InputUser)
-			err := r.UpdateUser(tt.InputUser)
+			err := r.UpdateUser(context.Background(), tt.InputUser, &model.AuditEvent{Action: model.AuditPasswordChanged})
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_RegisterFailedLogin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+
+	testTable := []struct {
+		name             string
+		mock             func(id int)
+		id               int
+		expectedAttempts int
+		expectedLockouts int
+		expectedError    bool
+	}{
+		{
+			name: "OK",
+			mock: func(id int) {
+				rows := sqlmock.NewRows([]string{"failed_login_attempts", "lockout_count"}).AddRow(3, 1)
+				mock.ExpectQuery("UPDATE users SET failed_login_attempts = failed_login_attempts \\+ 1 WHERE id = (.+) RETURNING failed_login_attempts, lockout_count").
+					WithArgs(id).WillReturnRows(rows)
+			},
+			id:               1,
+			expectedAttempts: 3,
+			expectedLockouts: 1,
+			expectedError:    false,
+		},
+		{
+			name: "Not found",
+			mock: func(id int) {
+				rows := sqlmock.NewRows([]string{"failed_login_attempts", "lockout_count"})
+				mock.ExpectQuery("UPDATE users SET failed_login_attempts = failed_login_attempts \\+ 1 WHERE id = (.+) RETURNING failed_login_attempts, lockout_count").
+					WithArgs(id).WillReturnRows(rows)
+			},
+			id:            1,
+			expectedError: true,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.id)
+			attempts, lockouts, err := r.RegisterFailedLogin(context.Background(), tt.id)
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedAttempts, attempts)
+				assert.Equal(t, tt.expectedLockouts, lockouts)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_LockUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+	until := time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)
+
+	testTable := []struct {
+		name          string
+		mock          func()
+		expectedError bool
+	}{
+		{
+			name: "OK",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"failed_login_attempts", "locked_until"}).AddRow(5, nil)
+				mock.ExpectQuery("WITH old AS (.+) UPDATE users SET failed_login_attempts = 0, lockout_count = lockout_count \\+ 1, locked_until = (.+)").
+					WithArgs(until, 1, 5).WillReturnRows(rows)
+				expectAuditEvent(mock, model.AuditUserLocked, 1)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Already locked by a concurrent login",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"failed_login_attempts", "locked_until"})
+				mock.ExpectQuery("WITH old AS (.+) UPDATE users SET failed_login_attempts = 0, lockout_count = lockout_count \\+ 1, locked_until = (.+)").
+					WithArgs(until, 1, 5).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name: "Repository error",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("WITH old AS (.+) UPDATE users SET failed_login_attempts = 0").
+					WithArgs(until, 1, 5).WillReturnError(errors.New("connection refused"))
+				mock.ExpectRollback()
+			},
+			expectedError: true,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			err := r.LockUser(context.Background(), 1, until, 5, &model.AuditEvent{Action: model.AuditUserLocked})
 			if tt.expectedError {
 				assert.Error(t, err)
 			} else {
@@ -861,3 +1026,126 @@ func TestRepository_UpdateUser(t *testing.T) {
 		})
 	}
 }
+
+func TestRepository_UnlockUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+
+	testTable := []struct {
+		name           string
+		mock           func(id int)
+		id             int
+		expectedUserId int
+		expectedError  error
+	}{
+		{
+			name: "OK",
+			mock: func(id int) {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"id", "failed_login_attempts", "locked_until"}).AddRow(1, 5, time.Now().Add(time.Hour))
+				mock.ExpectQuery("WITH old AS (.+) UPDATE users SET failed_login_attempts = 0, lockout_count = 0, locked_until = NULL FROM old (.+) RETURNING users.id").
+					WithArgs(id).WillReturnRows(rows)
+				expectAuditEvent(mock, model.AuditUserUnlocked, 1)
+				mock.ExpectCommit()
+			},
+			id:             1,
+			expectedUserId: 1,
+		},
+		{
+			name: "Not found",
+			mock: func(id int) {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"id", "failed_login_attempts", "locked_until"})
+				mock.ExpectQuery("WITH old AS (.+) UPDATE users SET failed_login_attempts = 0, lockout_count = 0, locked_until = NULL FROM old (.+) RETURNING users.id").
+					WithArgs(id).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			id:            1,
+			expectedError: pkg.ErrorUserNotFound,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.id)
+			got, err := r.UnlockUser(context.Background(), tt.id, &model.AuditEvent{Action: model.AuditUserUnlocked})
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedUserId, got)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_RestoreUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+	r := NewRepository(db, logger)
+
+	testTable := []struct {
+		name          string
+		mock          func(id int)
+		id            int
+		expectedError error
+	}{
+		{
+			name: "OK",
+			mock: func(id int) {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"deleted"}).AddRow(true)
+				mock.ExpectQuery("SELECT deleted FROM users WHERE id = (.+) FOR UPDATE").
+					WithArgs(id).WillReturnRows(rows)
+				mock.ExpectExec("UPDATE users SET deleted = false WHERE id = (.+)").
+					WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectAuditEvent(mock, model.AuditUserRestored, 1)
+				mock.ExpectCommit()
+			},
+			id: 1,
+		},
+		{
+			name: "Not found",
+			mock: func(id int) {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"deleted"})
+				mock.ExpectQuery("SELECT deleted FROM users WHERE id = (.+) FOR UPDATE").
+					WithArgs(id).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			id:            1,
+			expectedError: pkg.ErrorUserNotFound,
+		},
+		{
+			name: "Already active",
+			mock: func(id int) {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"deleted"}).AddRow(false)
+				mock.ExpectQuery("SELECT deleted FROM users WHERE id = (.+) FOR UPDATE").
+					WithArgs(id).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			id:            1,
+			expectedError: pkg.ErrorUserActive,
+		},
+	}
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.id)
+			err := r.RestoreUser(context.Background(), tt.id, &model.AuditEvent{Action: model.AuditUserRestored})
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthProto "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"net"
+	usersProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/usersProto"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/tracing"
+	"strings"
+	"time"
+)
+
+// GRPCConfig secures the gRPC server. The calls have to carry one of the
+// ServiceTokens as "authorization: Bearer <token>" metadata, unless TLS verifies
+// the client certificates and no tokens are set. Without either every call but
+// the health checks is refused. Timeout returns the deadline of a call by its full method name
+type GRPCConfig struct {
+	ServiceTokens []string
+	TLS           *tls.Config
+	Timeout       func(method string) time.Duration
+}
+
+type GRPCServer struct {
+	grpcServer *grpc.Server
+	health     *health.Server
+}
+
+// NewGRPCServer registers the Users API next to the standard health checking
+// and reflection services
+func NewGRPCServer(users usersProto.UsersServer, cfg GRPCConfig) *GRPCServer {
+	mutualTLS := cfg.TLS != nil && cfg.TLS.ClientAuth == tls.RequireAndVerifyClientCert
+	options := []grpc.ServerOption{grpc.ChainUnaryInterceptor(requestIDInterceptor, tracing.UnaryServerInterceptor,
+		serviceAuthInterceptor(cfg.ServiceTokens, mutualTLS),
+		deadlineInterceptor(cfg.Timeout)),
+		grpc.ChainStreamInterceptor(serviceAuthStreamInterceptor(cfg.ServiceTokens, mutualTLS))}
+	if cfg.TLS != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(cfg.TLS)))
+	}
+	grpcServer := grpc.NewServer(options...)
+	healthServer := health.NewServer()
+	usersProto.RegisterUsersServer(grpcServer, users)
+	healthProto.RegisterHealthServer(grpcServer, healthServer)
+	reflection.Register(grpcServer)
+	healthServer.SetServingStatus(usersProto.Users_ServiceDesc.ServiceName, healthProto.HealthCheckResponse_SERVING)
+	return &GRPCServer{grpcServer: grpcServer, health: healthServer}
+}
+
+// serviceAuthInterceptor lets through the calls carrying one of the tokens, the
+// health checks are open to the probes. A client verified by mutual TLS needs
+// no token when none is configured.
+func serviceAuthInterceptor(tokens []string, mutualTLS bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authorizeService(ctx, info.FullMethod, tokens, mutualTLS); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// serviceAuthStreamInterceptor applies the same check to the streaming calls,
+// reflection among them, so that they are not a way around the service token
+func serviceAuthStreamInterceptor(tokens []string, mutualTLS bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorizeService(stream.Context(), info.FullMethod, tokens, mutualTLS); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+func authorizeService(ctx context.Context, method string, tokens []string, mutualTLS bool) error {
+	if strings.HasPrefix(method, "/"+healthProto.Health_ServiceDesc.ServiceName+"/") || mutualTLS && len(tokens) == 0 {
+		return nil
+	}
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return status.Error(codes.Unauthenticated, "service token is required")
+	}
+	given := []byte(strings.TrimPrefix(values[0], "Bearer "))
+	for _, token := range tokens {
+		if subtle.ConstantTimeCompare(given, []byte(token)) == 1 {
+			return nil
+		}
+	}
+	logging.GetLogger().WithContext(ctx).Warnf("serviceAuth: invalid service token for %s", method)
+	return status.Error(codes.Unauthenticated, "invalid service token")
+}
+
+// requestIDInterceptor tags the log lines of the call with the x-request-id sent by the client or a new id
+func requestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := ""
+	if values := metadata.ValueFromIncomingContext(ctx, "x-request-id"); len(values) > 0 && len(values[0]) <= 64 {
+		id = values[0]
+	}
+	if id == "" {
+		id = logging.NewRequestID()
+	}
+	return handler(logging.ContextWithField(ctx, "request_id", id), req)
+}
+
+// deadlineInterceptor bounds every call by its timeout, a shorter deadline set by the client is kept
+func deadlineInterceptor(timeout func(method string) time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if d := timeout(info.FullMethod); d > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (s *GRPCServer) Run(port string) error {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+func (s *GRPCServer) Serve(listener net.Listener) error {
+	return s.grpcServer.Serve(listener)
+}
+
+// Shutdown reports NOT_SERVING to health checks and waits for the running
+// calls until ctx is done, the remaining ones are cancelled then
+func (s *GRPCServer) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+	stopped := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/audit"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	"time"
+)
+
+const (
+	DefaultAuditLimit = 50
+	// MaxAuditExport is the most events exported at once
+	MaxAuditExport = 10000
+	// auditChainBatch is the number of events read at once while the chain is verified
+	auditChainBatch = 1000
+)
+
+// AuditPolicy holds the key the checkpoints and the exports of the chain are
+// signed with, a temporary one is generated if it is not set. The checkpoints are
+// verified with Verifier, or with the public part of Signer if it is not set.
+type AuditPolicy struct {
+	Signer   *audit.Signer
+	Verifier *audit.Verifier
+}
+
+type AuditService struct {
+	repo     repository.Repository
+	logger   logging.Logger
+	signer   *audit.Signer
+	verifier *audit.Verifier
+}
+
+func NewAuditService(repo repository.Repository, logger logging.Logger, policy AuditPolicy) *AuditService {
+	service := &AuditService{repo: repo, logger: logger, signer: policy.Signer, verifier: policy.Verifier}
+	if service.signer == nil {
+		service.signer = audit.GenerateSigner()
+	} else if service.verifier == nil {
+		service.verifier = service.signer.Verifier()
+	}
+	return service
+}
+
+type auditActorKey struct{}
+
+// ContextWithAuditActor returns a copy of ctx whose changes are recorded as made by the actor
+func ContextWithAuditActor(ctx context.Context, actor model.AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActorFrom returns the actor set by ContextWithAuditActor, a zero one if there is none
+func AuditActorFrom(ctx context.Context) model.AuditActor {
+	actor, _ := ctx.Value(auditActorKey{}).(model.AuditActor)
+	return actor
+}
+
+// newAuditEvent starts the event of the action made by the actor of ctx, the
+// repository fills in the target and the diff
+func newAuditEvent(ctx context.Context, action string) *model.AuditEvent {
+	actor := AuditActorFrom(ctx)
+	return &model.AuditEvent{
+		ActorID:   actor.ID,
+		ActorRole: actor.Role,
+		Action:    action,
+		IP:        actor.IP,
+		UserAgent: actor.UserAgent,
+		RequestID: actor.RequestID,
+	}
+}
+
+// selfAuditEvent starts the event of the action the user makes on their own account
+// before being authenticated, like lifting the lockout by logging in
+func selfAuditEvent(ctx context.Context, user *model.User, action string) *model.AuditEvent {
+	event := newAuditEvent(ctx, action)
+	event.ActorID, event.ActorRole = user.ID, user.Role
+	return event
+}
+
+// GetAuditEvents returns a page of the audit log, the newest events go first
+func (a *AuditService) GetAuditEvents(ctx context.Context, filters *model.AuditFilters, page int, limit int) (*model.AuditPage, error) {
+	if !filters.From.IsZero() && !filters.To.IsZero() && !filters.From.Before(filters.To) {
+		return nil, fmt.Errorf("getAuditEvents:%w", pkg.ErrorInvalidTimeRange)
+	}
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = DefaultAuditLimit
+	} else if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+	events, total, err := a.repo.Audit.GetAuditEvents(ctx, filters, page, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AuditPage{Events: events, Total: total, Page: page, Limit: limit, Pages: (total + limit - 1) / limit}, nil
+}
+
+// Checkpoint signs the head of the chain unless it is signed already, nil is
+// returned when there is nothing new to sign
+func (a *AuditService) Checkpoint(ctx context.Context) (*model.AuditCheckpoint, error) {
+	id, hash, err := a.repo.Audit.GetAuditHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 || hash == "" {
+		return nil, nil
+	}
+	checkpoint := &model.AuditCheckpoint{EventID: id, Hash: hash, CreatedAt: time.Now().UTC().Truncate(time.Microsecond)}
+	a.signer.SignCheckpoint(checkpoint)
+	created, err := a.repo.Audit.CreateAuditCheckpoint(ctx, checkpoint)
+	if err != nil || !created {
+		return nil, err
+	}
+	a.logger.WithContext(ctx).Infof("Checkpoint: audit chain is signed up to event (id = %d)", id)
+	return checkpoint, nil
+}
+
+// ExportAuditEvents returns the events from fromID to toID signed as a whole
+func (a *AuditService) ExportAuditEvents(ctx context.Context, fromID int64, toID int64) (*model.AuditExport, error) {
+	if fromID <= 0 || toID < fromID || toID-fromID >= MaxAuditExport {
+		return nil, fmt.Errorf("exportAuditEvents:%w", pkg.ErrorInvalidAuditRange)
+	}
+	events, err := a.repo.Audit.GetAuditChain(ctx, fromID, toID, MaxAuditExport)
+	if err != nil {
+		return nil, err
+	}
+	export := &model.AuditExport{FromID: fromID, ToID: toID, Events: events, ExportedAt: time.Now().UTC()}
+	if len(events) != 0 {
+		export.PrevHash, export.Hash = events[0].PrevHash, events[len(events)-1].Hash
+	}
+	a.signer.SignExport(export)
+	return export, nil
+}
+
+// VerifyAuditChain walks the whole chain and stops at the first break. The
+// signatures of the checkpoints are not checked when there is no key to check them with.
+func (a *AuditService) VerifyAuditChain(ctx context.Context) (*model.AuditVerification, error) {
+	checkpoints, err := a.repo.Audit.GetAuditCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.AuditVerification{Checkpoints: len(checkpoints), SignaturesChecked: a.verifier != nil}
+	fail := func(id int64, reason string) (*model.AuditVerification, error) {
+		result.Break = &model.AuditBreak{EventID: id, Reason: reason}
+		a.logger.WithContext(ctx).Warnf("VerifyAuditChain: audit chain is broken at event (id = %d): %s", id, reason)
+		return result, nil
+	}
+	var chain audit.Chain
+	var fromID int64 = 1
+	for {
+		events, err := a.repo.Audit.GetAuditChain(ctx, fromID, 0, auditChainBatch)
+		if err != nil {
+			return nil, err
+		}
+		for i := range events {
+			event := &events[i]
+			for len(checkpoints) != 0 && checkpoints[0].EventID < event.ID {
+				return fail(checkpoints[0].EventID, "event of the checkpoint is removed")
+			}
+			if event.Hash == "" && result.Events == result.Unchained {
+				result.Unchained++
+			}
+			result.Events++
+			if err = chain.Next(event); err != nil {
+				return fail(event.ID, err.Error())
+			}
+			if len(checkpoints) != 0 && checkpoints[0].EventID == event.ID {
+				if checkpoints[0].Hash != event.Hash {
+					return fail(event.ID, "hash does not match the checkpoint")
+				}
+				if a.verifier != nil && !a.verifier.VerifyCheckpoint(&checkpoints[0]) {
+					return fail(event.ID, "signature of the checkpoint is invalid")
+				}
+				checkpoints = checkpoints[1:]
+			}
+		}
+		if len(events) < auditChainBatch {
+			break
+		}
+		fromID = events[len(events)-1].ID + 1
+	}
+	if len(checkpoints) != 0 {
+		return fail(checkpoints[0].EventID, "events up to the checkpoint are removed")
+	}
+	return result, nil
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/audit"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	mock_repository "stlab.itechart-group.com/go/food_delivery/authentication_service/repository/mocks"
+	"testing"
+	"time"
+)
+
+func TestService_GetAuditEvents(t *testing.T) {
+	from := time.Date(2022, 03, 1, 0, 0, 0, 0, time.UTC)
+	events := []model.AuditEvent{{ID: 1, Action: model.AuditStaffCreated}}
+
+	type mockBehavior func(s *mock_repository.MockAudit)
+	testTable := []struct {
+		name          string
+		filters       *model.AuditFilters
+		page          int
+		limit         int
+		mockBehavior  mockBehavior
+		expectedPage  *model.AuditPage
+		expectedError error
+	}{
+		{
+			name:    "OK",
+			filters: &model.AuditFilters{From: from, To: from.Add(time.Hour)},
+			page:    2,
+			limit:   10,
+			mockBehavior: func(s *mock_repository.MockAudit) {
+				s.EXPECT().GetAuditEvents(gomock.Any(), gomock.Any(), 2, 10).Return(events, 21, nil)
+			},
+			expectedPage: &model.AuditPage{Events: events, Total: 21, Page: 2, Limit: 10, Pages: 3},
+		},
+		{
+			name:    "Defaults",
+			filters: &model.AuditFilters{},
+			mockBehavior: func(s *mock_repository.MockAudit) {
+				s.EXPECT().GetAuditEvents(gomock.Any(), gomock.Any(), 1, DefaultAuditLimit).Return(events, 1, nil)
+			},
+			expectedPage: &model.AuditPage{Events: events, Total: 1, Page: 1, Limit: DefaultAuditLimit, Pages: 1},
+		},
+		{
+			name:    "Limit over maximum",
+			filters: &model.AuditFilters{},
+			page:    1,
+			limit:   MaxPageLimit + 1,
+			mockBehavior: func(s *mock_repository.MockAudit) {
+				s.EXPECT().GetAuditEvents(gomock.Any(), gomock.Any(), 1, MaxPageLimit).Return(nil, 0, nil)
+			},
+			expectedPage: &model.AuditPage{Page: 1, Limit: MaxPageLimit},
+		},
+		{
+			name:          "Invalid time range",
+			filters:       &model.AuditFilters{From: from, To: from},
+			mockBehavior:  func(s *mock_repository.MockAudit) {},
+			expectedError: pkg.ErrorInvalidTimeRange,
+		},
+		{
+			name:    "Repository failure",
+			filters: &model.AuditFilters{},
+			mockBehavior: func(s *mock_repository.MockAudit) {
+				s.EXPECT().GetAuditEvents(gomock.Any(), gomock.Any(), 1, DefaultAuditLimit).Return(nil, 0, errors.New("repository failure"))
+			},
+			expectedError: errors.New("repository failure"),
+		},
+	}
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			audit := mock_repository.NewMockAudit(c)
+			testCase.mockBehavior(audit)
+			service := NewAuditService(repository.Repository{Audit: audit}, logging.GetLogger(), AuditPolicy{})
+
+			page, err := service.GetAuditEvents(context.Background(), testCase.filters, testCase.page, testCase.limit)
+			//Assert
+			if testCase.expectedError != nil {
+				if errors.Is(testCase.expectedError, pkg.ErrorInvalidTimeRange) {
+					assert.ErrorIs(t, err, pkg.ErrorInvalidTimeRange)
+				} else {
+					assert.Equal(t, testCase.expectedError, err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedPage, page)
+		})
+	}
+}
+
+func TestService_newAuditEvent(t *testing.T) {
+	ctx := ContextWithAuditActor(context.Background(), model.AuditActor{
+		ID:        1,
+		Role:      "Superadmin",
+		IP:        "127.0.0.1",
+		UserAgent: "test",
+		RequestID: "id",
+	})
+	assert.Equal(t, &model.AuditEvent{
+		ActorID:   1,
+		ActorRole: "Superadmin",
+		Action:    model.AuditUserDeleted,
+		IP:        "127.0.0.1",
+		UserAgent: "test",
+		RequestID: "id",
+	}, newAuditEvent(ctx, model.AuditUserDeleted))
+	assert.Equal(t, &model.AuditEvent{Action: model.AuditPasswordReset}, newAuditEvent(context.Background(), model.AuditPasswordReset))
+}
+
+// auditAction matches the audit event of the action
+type auditAction string
+
+func (a auditAction) Matches(x interface{}) bool {
+	event, ok := x.(*model.AuditEvent)
+	return ok && event.Action == string(a)
+}
+
+func (a auditAction) String() string {
+	return "audit event " + string(a)
+}
+
+// auditChain returns the events with ids from 1 to count chained one after another
+func auditChain(t *testing.T, count int) []model.AuditEvent {
+	var events []model.AuditEvent
+	prevHash := ""
+	for id := 1; id <= count; id++ {
+		event := model.AuditEvent{
+			ID:        int64(id),
+			CreatedAt: time.Date(2022, 03, 11, 1, 0, id, 0, time.UTC),
+			Action:    model.AuditUserDeleted,
+			TargetID:  id,
+			Diff:      map[string]model.AuditChange{"deleted": {Old: false, New: true}},
+			PrevHash:  prevHash,
+		}
+		hash, err := audit.Hash(&event)
+		assert.NoError(t, err)
+		event.Hash, prevHash = hash, hash
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestService_Checkpoint(t *testing.T) {
+	signer := audit.GenerateSigner()
+
+	type mockBehavior func(s *mock_repository.MockAudit)
+	testTable := []struct {
+		name               string
+		mockBehavior       mockBehavior
+		expectedCheckpoint bool
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(s *mock_repository.MockAudit) {
+				s.EXPECT().GetAuditHead(gomock.Any()).Return(int64(5), "hash", nil)
+				s.EXPECT().CreateAuditCheckpoint(gomock.Any(), gomock.Any()).Return(true, nil)
+			},
+			expectedCheckpoint: true,
+		},
+		{
+			name: "Already signed",
+			mockBehavior: func(s *mock_repository.MockAudit) {
+				s.EXPECT().GetAuditHead(gomock.Any()).Return(int64(5), "hash", nil)
+				s.EXPECT().CreateAuditCheckpoint(gomock.Any(), gomock.Any()).Return(false, nil)
+			},
+		},
+		{
+			name: "Empty chain",
+			mockBehavior: func(s *mock_repository.MockAudit) {
+				s.EXPECT().GetAuditHead(gomock.Any()).Return(int64(0), "", nil)
+			},
+		},
+	}
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			repo := mock_repository.NewMockAudit(c)
+			testCase.mockBehavior(repo)
+			service := NewAuditService(repository.Repository{Audit: repo}, logging.GetLogger(), AuditPolicy{Signer: signer})
+
+			checkpoint, err := service.Checkpoint(context.Background())
+			//Assert
+			assert.NoError(t, err)
+			if !testCase.expectedCheckpoint {
+				assert.Nil(t, checkpoint)
+				return
+			}
+			assert.Equal(t, int64(5), checkpoint.EventID)
+			assert.Equal(t, "hash", checkpoint.Hash)
+			assert.True(t, signer.Verifier().VerifyCheckpoint(checkpoint))
+		})
+	}
+}
+
+func TestService_ExportAuditEvents(t *testing.T) {
+	signer := audit.GenerateSigner()
+	events := auditChain(t, 5)[1:4]
+
+	type mockBehavior func(s *mock_repository.MockAudit)
+	testTable := []struct {
+		name          string
+		fromID        int64
+		toID          int64
+		mockBehavior  mockBehavior
+		expectedError error
+	}{
+		{
+			name:   "OK",
+			fromID: 2,
+			toID:   4,
+			mockBehavior: func(s *mock_repository.MockAudit) {
+				s.EXPECT().GetAuditChain(gomock.Any(), int64(2), int64(4), MaxAuditExport).Return(events, nil)
+			},
+		},
+		{
+			name:          "Invalid range",
+			fromID:        4,
+			toID:          2,
+			mockBehavior:  func(s *mock_repository.MockAudit) {},
+			expectedError: pkg.ErrorInvalidAuditRange,
+		},
+		{
+			name:          "Range too long",
+			fromID:        1,
+			toID:          MaxAuditExport + 1,
+			mockBehavior:  func(s *mock_repository.MockAudit) {},
+			expectedError: pkg.ErrorInvalidAuditRange,
+		},
+	}
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			repo := mock_repository.NewMockAudit(c)
+			testCase.mockBehavior(repo)
+			service := NewAuditService(repository.Repository{Audit: repo}, logging.GetLogger(), AuditPolicy{Signer: signer})
+
+			export, err := service.ExportAuditEvents(context.Background(), testCase.fromID, testCase.toID)
+			//Assert
+			if testCase.expectedError != nil {
+				assert.ErrorIs(t, err, testCase.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, events[0].PrevHash, export.PrevHash)
+			assert.Equal(t, events[2].Hash, export.Hash)
+			assert.NoError(t, signer.Verifier().VerifyExport(export))
+		})
+	}
+}
+
+func TestService_VerifyAuditChain(t *testing.T) {
+	signer := audit.GenerateSigner()
+	checkpoint := func(event model.AuditEvent) model.AuditCheckpoint {
+		checkpoint := model.AuditCheckpoint{EventID: event.ID, Hash: event.Hash, CreatedAt: event.CreatedAt}
+		signer.SignCheckpoint(&checkpoint)
+		return checkpoint
+	}
+
+	testTable := []struct {
+		name           string
+		events         func(events []model.AuditEvent) []model.AuditEvent
+		checkpoints    func(events []model.AuditEvent) []model.AuditCheckpoint
+		verifier       *audit.Verifier
+		expectedEvents int
+		expectedBreak  *model.AuditBreak
+	}{
+		{
+			name:   "Intact",
+			events: func(events []model.AuditEvent) []model.AuditEvent { return events },
+			checkpoints: func(events []model.AuditEvent) []model.AuditCheckpoint {
+				return []model.AuditCheckpoint{checkpoint(events[1]), checkpoint(events[3])}
+			},
+			expectedEvents: 4,
+		},
+		{
+			name: "Changed event",
+			events: func(events []model.AuditEvent) []model.AuditEvent {
+				events[2].Diff["deleted"] = model.AuditChange{Old: true, New: true}
+				return events
+			},
+			checkpoints:    func(events []model.AuditEvent) []model.AuditCheckpoint { return nil },
+			expectedEvents: 3,
+			expectedBreak:  &model.AuditBreak{EventID: 3, Reason: "hash does not match, the event is changed"},
+		},
+		{
+			name: "Removed last events",
+			events: func(events []model.AuditEvent) []model.AuditEvent {
+				return events[:2]
+			},
+			checkpoints: func(events []model.AuditEvent) []model.AuditCheckpoint {
+				return []model.AuditCheckpoint{checkpoint(events[3])}
+			},
+			expectedEvents: 2,
+			expectedBreak:  &model.AuditBreak{EventID: 4, Reason: "events up to the checkpoint are removed"},
+		},
+		{
+			name:   "Forged checkpoint",
+			events: func(events []model.AuditEvent) []model.AuditEvent { return events },
+			checkpoints: func(events []model.AuditEvent) []model.AuditCheckpoint {
+				forged := checkpoint(events[1])
+				forged.Signature = checkpoint(events[0]).Signature
+				return []model.AuditCheckpoint{forged}
+			},
+			expectedEvents: 2,
+			expectedBreak:  &model.AuditBreak{EventID: 2, Reason: "signature of the checkpoint is invalid"},
+		},
+		{
+			name:   "Signed with another key",
+			events: func(events []model.AuditEvent) []model.AuditEvent { return events },
+			checkpoints: func(events []model.AuditEvent) []model.AuditCheckpoint {
+				return []model.AuditCheckpoint{checkpoint(events[1])}
+			},
+			verifier:       audit.GenerateSigner().Verifier(),
+			expectedEvents: 2,
+			expectedBreak:  &model.AuditBreak{EventID: 2, Reason: "signature of the checkpoint is invalid"},
+		},
+	}
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			events := auditChain(t, 4)
+			checkpoints := testCase.checkpoints(events)
+			events = testCase.events(events)
+			repo := mock_repository.NewMockAudit(c)
+			repo.EXPECT().GetAuditCheckpoints(gomock.Any()).Return(checkpoints, nil)
+			repo.EXPECT().GetAuditChain(gomock.Any(), int64(1), int64(0), auditChainBatch).Return(events, nil)
+			verifier := testCase.verifier
+			if verifier == nil {
+				verifier = signer.Verifier()
+			}
+			service := NewAuditService(repository.Repository{Audit: repo}, logging.GetLogger(), AuditPolicy{Verifier: verifier})
+
+			result, err := service.VerifyAuditChain(context.Background())
+			//Assert
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedEvents, result.Events)
+			assert.True(t, result.SignaturesChecked)
+			assert.Equal(t, testCase.expectedBreak, result.Break)
+		})
+	}
+}
@@ -2,45 +2,249 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/metrics"
+	"time"
 )
 
-func (u *UserService) AuthUser(email string, password string) (*authProto.GeneratedTokens, int, error) {
-	userDb, err := u.repo.AppUser.GetUserByEmail(email)
+// LockoutPolicy locks an account for BaseDuration after MaxAttempts failed
+// logins in a row, every next lockout is twice as long up to MaxDuration
+type LockoutPolicy struct {
+	MaxAttempts  int
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+}
+
+func (p LockoutPolicy) withDefaults() LockoutPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 5
+	}
+	if p.BaseDuration <= 0 {
+		p.BaseDuration = time.Minute
+	}
+	if p.MaxDuration <= 0 {
+		p.MaxDuration = 24 * time.Hour
+	}
+	return p
+}
+
+// window returns the lockout duration after the given number of previous lockouts
+func (p LockoutPolicy) window(lockouts int) time.Duration {
+	window := p.BaseDuration
+	for i := 0; i < lockouts && window < p.MaxDuration; i++ {
+		window *= 2
+	}
+	if window > p.MaxDuration {
+		window = p.MaxDuration
+	}
+	return window
+}
+
+func (u *UserService) AuthUser(ctx context.Context, email string, password string) (*authProto.GeneratedTokens, int, error) {
+	tokens, id, err := u.authUser(ctx, email, password)
+	metrics.Logins.WithLabelValues("password", loginOutcome(err)).Inc()
+	return tokens, id, err
+}
+
+func (u *UserService) authUser(ctx context.Context, email string, password string) (*authProto.GeneratedTokens, int, error) {
+	userDb, err := u.repo.AppUser.GetUserByEmail(ctx, email)
 	if err != nil {
 		return nil, 0, err
 	}
 	if userDb.Deleted {
-		u.logger.Errorf("this user (id = %d) is deactivated", userDb.ID)
+		u.logger.WithContext(ctx).Errorf("this user (id = %d) is deactivated", userDb.ID)
 		return nil, 0, fmt.Errorf("this user (id = %d) is deactivated", userDb.ID)
 	}
+	if userDb.LockedUntil != nil && time.Now().Before(*userDb.LockedUntil) {
+		u.logger.WithContext(ctx).Warnf("AuthUser: user (id = %d) is locked until %s", userDb.ID, userDb.LockedUntil)
+		return nil, 0, &pkg.LockedError{Until: *userDb.LockedUntil}
+	}
 	if u.CheckPasswordHash(password, userDb.Password) {
-		tokens, err := u.grpcCli.TokenGenerationByUserId(context.Background(), &authProto.User{
+		if err = u.checkVerified(ctx, userDb); err != nil {
+			return nil, 0, err
+		}
+		// a second factor keeps the failed attempts until it is passed as well,
+		// otherwise the password would reset the count of wrong codes
+		if err = u.requireSecondFactor(ctx, userDb); err != nil {
+			return nil, 0, err
+		}
+		if err = u.resetFailedLogins(ctx, userDb); err != nil {
+			return nil, 0, err
+		}
+		tokens, err := u.authCli.TokenGenerationByUserId(ctx, &authProto.User{
 			UserId: int32(userDb.ID),
 			Role:   userDb.Role,
 		})
 		if err != nil {
-			u.logger.Errorf("TokenGenerationByUserId:%s", err)
+			u.logger.WithContext(ctx).Errorf("TokenGenerationByUserId:%s", err)
 			return nil, 0, fmt.Errorf("TokenGenerationByUserId:%w", err)
 		}
 		return tokens, userDb.ID, nil
 	} else {
-		u.logger.Warn("AuthUser: wrong email or password entered")
+		u.logger.WithContext(ctx).Warn("AuthUser: wrong email or password entered")
+		if err = u.registerFailedLogin(ctx, userDb); err != nil {
+			return nil, 0, err
+		}
 		return nil, 0, fmt.Errorf("wrong email or password entered")
 	}
 }
 
+// VerifyCredentials checks the password for other services without issuing
+// tokens, failed attempts count towards the lockout like failed logins. The
+// users with unverified emails and those needing a second factor are refused
+// under the same policy as logins.
+func (u *UserService) VerifyCredentials(ctx context.Context, email string, password string) (*model.ResponseUser, error) {
+	userDb, err := u.repo.AppUser.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, pkg.ErrorEmailDoesNotExist) {
+			return nil, fmt.Errorf("verifyCredentials:%w", pkg.ErrorInvalidCredentials)
+		}
+		return nil, err
+	}
+	if userDb.Deleted {
+		u.logger.WithContext(ctx).Warnf("VerifyCredentials: user (id = %d) is deactivated", userDb.ID)
+		return nil, fmt.Errorf("verifyCredentials:%w", pkg.ErrorInvalidCredentials)
+	}
+	if userDb.LockedUntil != nil && time.Now().Before(*userDb.LockedUntil) {
+		u.logger.WithContext(ctx).Warnf("VerifyCredentials: user (id = %d) is locked until %s", userDb.ID, userDb.LockedUntil)
+		return nil, &pkg.LockedError{Until: *userDb.LockedUntil}
+	}
+	if !u.CheckPasswordHash(password, userDb.Password) {
+		u.logger.WithContext(ctx).Warnf("VerifyCredentials: wrong password for user (id = %d)", userDb.ID)
+		if err = u.registerFailedLogin(ctx, userDb); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("verifyCredentials:%w", pkg.ErrorInvalidCredentials)
+	}
+	if err = u.checkVerified(ctx, userDb); err != nil {
+		return nil, err
+	}
+	// the code can not be passed along, so the users having to give one are refused
+	// and keep their failed attempts like after the password step of a login
+	required := userDb.TOTPEnabled
+	if !required {
+		if required, err = roleRequiresTwoFactor(ctx, u.repo, userDb.Role); err != nil {
+			return nil, err
+		}
+	}
+	if required {
+		u.logger.WithContext(ctx).Warnf("VerifyCredentials: user (id = %d) has to pass the second factor", userDb.ID)
+		return nil, fmt.Errorf("verifyCredentials:%w", pkg.ErrorTwoFactorRequired)
+	}
+	if err = u.resetFailedLogins(ctx, userDb); err != nil {
+		return nil, err
+	}
+	return u.repo.AppUser.GetUserByID(ctx, userDb.ID)
+}
+
+// loginOutcome names the outcome of a login step for the metrics
+func loginOutcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.LoginSuccess
+	case errors.Is(err, pkg.ErrorTwoFactorRequired):
+		return metrics.LoginTwoFactorRequired
+	case errors.Is(err, pkg.ErrorAccountLocked):
+		return metrics.LoginLocked
+	case errors.Is(err, pkg.ErrorEmailNotVerified):
+		return metrics.LoginUnverified
+	default:
+		return metrics.LoginFailure
+	}
+}
+
+// resetFailedLogins lifts the lockout state of the user after a successful login
+func (u *UserService) resetFailedLogins(ctx context.Context, user *model.User) error {
+	if user.FailedLoginAttempts == 0 && user.LockoutCount == 0 {
+		return nil
+	}
+	_, err := u.repo.AppUser.UnlockUser(ctx, user.ID, selfAuditEvent(ctx, user, model.AuditUserUnlocked))
+	return err
+}
+
+// registerFailedLogin counts the failed attempt and locks the user out once
+// the policy limit is reached
+func (u *UserService) registerFailedLogin(ctx context.Context, user *model.User) error {
+	attempts, lockouts, err := u.repo.AppUser.RegisterFailedLogin(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if attempts < u.lockout.MaxAttempts {
+		return nil
+	}
+	until := time.Now().Add(u.lockout.window(lockouts))
+	if err = u.repo.AppUser.LockUser(ctx, user.ID, until, u.lockout.MaxAttempts, newAuditEvent(ctx, model.AuditUserLocked)); err != nil {
+		return err
+	}
+	u.logger.WithContext(ctx).Warnf("AuthUser: user (id = %d) is locked until %s after %d failed attempts", user.ID, until, attempts)
+	return &pkg.LockedError{Until: until}
+}
+
+// RefreshTokens exchanges a refresh token for a new pair of tokens
+func (u *UserService) RefreshTokens(ctx context.Context, refreshToken string) (*authProto.GeneratedTokens, error) {
+	tokens, err := u.authCli.TokenGenerationByRefresh(ctx, &authProto.RefreshToken{
+		RefreshToken: refreshToken,
+	})
+	if err != nil {
+		switch status.Code(err) {
+		// the auth service reports rejected tokens either with a proper code
+		// or as a plain error, which arrives as codes.Unknown
+		case codes.Unauthenticated, codes.PermissionDenied, codes.InvalidArgument, codes.NotFound, codes.Unknown:
+			u.logger.WithContext(ctx).Warnf("RefreshTokens: refresh token rejected:%s", err)
+			return nil, fmt.Errorf("refreshTokens:%w", pkg.ErrorInvalidRefreshToken)
+		default:
+			u.logger.WithContext(ctx).Errorf("TokenGenerationByRefresh:%s", err)
+			return nil, fmt.Errorf("tokenGenerationByRefresh:%w", err)
+		}
+	}
+	if tokens == nil || tokens.AccessToken == "" {
+		u.logger.WithContext(ctx).Warn("RefreshTokens: auth service returned no tokens")
+		return nil, fmt.Errorf("refreshTokens:%w", pkg.ErrorInvalidRefreshToken)
+	}
+	// the owner of the refresh token is only known from the new access token
+	user, err := u.ParseToken(ctx, tokens.AccessToken)
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("RefreshTokens: GetUserWithRights:%s", err)
+		return nil, fmt.Errorf("getUserWithRights:%w", err)
+	}
+	claims, err := parseTokenClaims(refreshToken)
+	if err != nil {
+		u.logger.WithContext(ctx).Warnf("RefreshTokens: refresh token of user (id = %d):%s", user.UserId, err)
+		return nil, fmt.Errorf("refreshTokens:%w", pkg.ErrorInvalidRefreshToken)
+	}
+	revoked, err := u.repo.TokenRevocation.IsTokenRevoked(ctx, int(user.UserId), hashToken(refreshToken), claims.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		u.logger.WithContext(ctx).Warnf("RefreshTokens: revoked refresh token of user (id = %d) was used", user.UserId)
+		return nil, fmt.Errorf("refreshTokens:%w", pkg.ErrorInvalidRefreshToken)
+	}
+	return tokens, nil
+}
+
 // HashPassword from string
 // bcrypt.DefaultCost = 10
 func (u *UserService) HashPassword(password string, rounds int) (string, error) {
+	defer observeBcrypt("hash", time.Now())
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), rounds)
 	return string(bytes), err
 }
 
 // CheckPasswordHash compare encrypt
 func (u *UserService) CheckPasswordHash(password string, hash string) bool {
+	defer observeBcrypt("compare", time.Now())
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+func observeBcrypt(operation string, start time.Time) {
+	metrics.BcryptDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
@@ -3,82 +3,133 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
-	mockAuthProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/authProto"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/grpcClient"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/metrics"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
 	mock_repository "stlab.itechart-group.com/go/food_delivery/authentication_service/repository/mocks"
 	"testing"
+	"time"
 )
 
 func TestService_authUser(t *testing.T) {
+	lockedUntil := time.Now().Add(time.Hour)
 	type mockBehaviorGetUser func(s *mock_repository.MockAppUser, email string)
-	type mockBehaviorGetTokens func(s *mockAuthProto.MockAuthServer, user *authProto.User) (*authProto.GeneratedTokens, error)
+	type mockBehaviorAuth func(f *grpcClient.FakeClient)
 	testTable := []struct {
-		name                  string
-		inputPassword         string
-		inputEmail            string
-		mockUser              *authProto.User
-		mockBehaviorGetUser   mockBehaviorGetUser
-		mockBehaviorGetTokens mockBehaviorGetTokens
-		expectedId            int
-		expectedError         error
+		name                string
+		inputPassword       string
+		inputEmail          string
+		mockBehaviorGetUser mockBehaviorGetUser
+		mockBehaviorAuth    mockBehaviorAuth
+		expectedTokens      *authProto.GeneratedTokens
+		expectedId          int
+		expectedError       error
 	}{
 		{
 			name:          "OK",
 			inputPassword: "HGYKnu!98Tg",
 			inputEmail:    "test@yandex.ru",
-			mockUser: &authProto.User{
-				UserId: 1,
-				Role:   "Superadmin",
+			mockBehaviorGetUser: func(s *mock_repository.MockAppUser, email string) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), email).Return(&model.User{
+					ID:       1,
+					Email:    "test@yandex.ru",
+					Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+					Role:     "Superadmin",
+					Deleted:  false,
+				}, nil)
+			},
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) {},
+			expectedTokens:   &authProto.GeneratedTokens{AccessToken: grpcClient.FakeToken("access", 1, 1), RefreshToken: grpcClient.FakeToken("refresh", 1, 1)},
+			expectedId:       1,
+			expectedError:    nil,
+		},
+		{
+			name:          "OK resets failed attempts",
+			inputPassword: "HGYKnu!98Tg",
+			inputEmail:    "test@yandex.ru",
+			mockBehaviorGetUser: func(s *mock_repository.MockAppUser, email string) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), email).Return(&model.User{
+					ID:                  1,
+					Email:               "test@yandex.ru",
+					Password:            "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+					Role:                "Superadmin",
+					FailedLoginAttempts: 2,
+				}, nil)
+				s.EXPECT().UnlockUser(gomock.Any(), 1, gomock.Any()).Return(1, nil)
 			},
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) {},
+			expectedTokens:   &authProto.GeneratedTokens{AccessToken: grpcClient.FakeToken("access", 1, 1), RefreshToken: grpcClient.FakeToken("refresh", 1, 1)},
+			expectedId:       1,
+		},
+		{
+			name:          "Auth service error",
+			inputPassword: "HGYKnu!98Tg",
+			inputEmail:    "test@yandex.ru",
 			mockBehaviorGetUser: func(s *mock_repository.MockAppUser, email string) {
-				s.EXPECT().GetUserByEmail(email).Return(&model.User{
+				s.EXPECT().GetUserByEmail(gomock.Any(), email).Return(&model.User{
 					ID:       1,
 					Email:    "test@yandex.ru",
 					Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+					Role:     "Superadmin",
 					Deleted:  false,
 				}, nil)
 			},
-			mockBehaviorGetTokens: func(s *mockAuthProto.MockAuthServer, user *authProto.User) (*authProto.GeneratedTokens, error) {
-				_, _ = s.TokenGenerationByUserId(context.Background(), user)
-				return &authProto.GeneratedTokens{AccessToken: "qwerty", RefreshToken: "qwerty"}, nil
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) {
+				f.FailWith(grpcClient.MethodTokenGenerationByUserId, errors.New("auth service error"))
 			},
-			expectedId:    1,
-			expectedError: nil,
+			expectedError: fmt.Errorf("TokenGenerationByUserId:%w", errors.New("auth service error")),
 		},
 		{
 			name:          "Wrong password",
 			inputPassword: "HGYKnu!9Tg",
 			inputEmail:    "test@yandex.ru",
 			mockBehaviorGetUser: func(s *mock_repository.MockAppUser, email string) {
-				s.EXPECT().GetUserByEmail(email).Return(&model.User{
+				s.EXPECT().GetUserByEmail(gomock.Any(), email).Return(&model.User{
 					ID:       1,
 					Email:    "test@yandex.ru",
 					Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
 					Deleted:  false,
 				}, nil)
+				s.EXPECT().RegisterFailedLogin(gomock.Any(), 1).Return(1, 0, nil)
 			},
-			mockBehaviorGetTokens: func(s *mockAuthProto.MockAuthServer, user *authProto.User) (*authProto.GeneratedTokens, error) {
-				return nil, nil
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) {},
+			expectedError:    errors.New("wrong email or password entered"),
+		},
+		{
+			name:          "Locked user",
+			inputPassword: "HGYKnu!98Tg",
+			inputEmail:    "test@yandex.ru",
+			mockBehaviorGetUser: func(s *mock_repository.MockAppUser, email string) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), email).Return(&model.User{
+					ID:          1,
+					Email:       "test@yandex.ru",
+					Password:    "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+					Deleted:     false,
+					LockedUntil: &lockedUntil,
+				}, nil)
 			},
-			expectedError: errors.New("wrong email or password entered"),
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) {},
+			expectedError:    &pkg.LockedError{Until: lockedUntil},
 		},
 		{
 			name:          "Repository error",
 			inputPassword: "HGYKnu!98Tg",
 			inputEmail:    "test@yandex.ru",
 			mockBehaviorGetUser: func(s *mock_repository.MockAppUser, email string) {
-				s.EXPECT().GetUserByEmail(email).Return(nil, errors.New("repository error"))
+				s.EXPECT().GetUserByEmail(gomock.Any(), email).Return(nil, errors.New("repository error"))
 			},
-			mockBehaviorGetTokens: func(s *mockAuthProto.MockAuthServer, user *authProto.User) (*authProto.GeneratedTokens, error) {
-				return nil, nil
-			},
-			expectedError: errors.New("repository error"),
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) {},
+			expectedError:    errors.New("repository error"),
 		},
 	}
 
@@ -88,18 +139,342 @@ func TestService_authUser(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
 			repo := mock_repository.NewMockAppUser(c)
-			reposit := &repository.Repository{AppUser: repo}
+			twoFactor := mock_repository.NewMockTwoFactor(c)
+			twoFactor.EXPECT().GetTwoFactorRoles(gomock.Any()).Return(nil, nil).AnyTimes()
+			reposit := &repository.Repository{AppUser: repo, TwoFactor: twoFactor}
 			testCase.mockBehaviorGetUser(repo, testCase.inputEmail)
-			mockProto := new(mockAuthProto.MockAuthServer)
-			testCase.mockBehaviorGetTokens(mockProto, testCase.mockUser)
+			authCli := grpcClient.NewFakeClient("Superadmin")
+			testCase.mockBehaviorAuth(authCli)
 			logger := logging.GetLogger()
-			grpcCli := grpcClient.NewGRPCClient("159.223.1.135")
-			service := NewService(reposit, grpcCli, logger)
-			_, id, err := service.AuthUser(testCase.inputEmail, testCase.inputPassword)
+			service := NewService(reposit, authCli, logger, Config{})
+			tokens, id, err := service.AuthUser(context.Background(), testCase.inputEmail, testCase.inputPassword)
 			//Assert
+			assert.Equal(t, testCase.expectedTokens, tokens)
 			assert.Equal(t, testCase.expectedId, id)
 			assert.Equal(t, testCase.expectedError, err)
 		})
 	}
 
 }
+
+func TestService_RefreshTokens(t *testing.T) {
+	type mockBehaviorAuth func(f *grpcClient.FakeClient) string
+	type mockBehaviorRevoked func(s *mock_repository.MockTokenRevocation)
+	testTable := []struct {
+		name                string
+		mockBehaviorAuth    mockBehaviorAuth
+		mockBehaviorRevoked mockBehaviorRevoked
+		expectedTokens      *authProto.GeneratedTokens
+		expectedError       error
+	}{
+		{
+			name: "OK",
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) string {
+				tokens, _ := f.TokenGenerationByUserId(context.Background(), &authProto.User{UserId: 1, Role: "Courier"})
+				return tokens.RefreshToken
+			},
+			mockBehaviorRevoked: func(s *mock_repository.MockTokenRevocation) {
+				s.EXPECT().IsTokenRevoked(gomock.Any(), 1, hashToken(grpcClient.FakeToken("refresh", 1, 1)), gomock.Any()).Return(false, nil)
+			},
+			expectedTokens: &authProto.GeneratedTokens{AccessToken: grpcClient.FakeToken("access", 1, 2), RefreshToken: grpcClient.FakeToken("refresh", 1, 2)},
+		},
+		{
+			name: "Token revoked by logout",
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) string {
+				tokens, _ := f.TokenGenerationByUserId(context.Background(), &authProto.User{UserId: 1, Role: "Courier"})
+				return tokens.RefreshToken
+			},
+			mockBehaviorRevoked: func(s *mock_repository.MockTokenRevocation) {
+				s.EXPECT().IsTokenRevoked(gomock.Any(), 1, hashToken(grpcClient.FakeToken("refresh", 1, 1)), gomock.Any()).Return(true, nil)
+			},
+			expectedError: pkg.ErrorInvalidRefreshToken,
+		},
+		{
+			name: "Unknown token",
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) string {
+				return "qwerty"
+			},
+			mockBehaviorRevoked: func(s *mock_repository.MockTokenRevocation) {},
+			expectedError:       pkg.ErrorInvalidRefreshToken,
+		},
+		{
+			name: "Revoked token",
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) string {
+				f.FailWith(grpcClient.MethodTokenGenerationByRefresh, errors.New("token is revoked"))
+				return "qwerty"
+			},
+			mockBehaviorRevoked: func(s *mock_repository.MockTokenRevocation) {},
+			expectedError:       pkg.ErrorInvalidRefreshToken,
+		},
+		{
+			name: "Auth service unavailable",
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) string {
+				f.FailWith(grpcClient.MethodTokenGenerationByRefresh, status.Error(codes.Unavailable, "unavailable"))
+				return "qwerty"
+			},
+			mockBehaviorRevoked: func(s *mock_repository.MockTokenRevocation) {},
+			expectedError:       status.Error(codes.Unavailable, "unavailable"),
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			repo := mock_repository.NewMockAppUser(c)
+			revocation := mock_repository.NewMockTokenRevocation(c)
+			testCase.mockBehaviorRevoked(revocation)
+			reposit := &repository.Repository{AppUser: repo, TokenRevocation: revocation}
+			logger := logging.GetLogger()
+			authCli := grpcClient.NewFakeClient("Courier")
+			refreshToken := testCase.mockBehaviorAuth(authCli)
+			service := NewService(reposit, authCli, logger, Config{})
+			tokens, err := service.RefreshTokens(context.Background(), refreshToken)
+			//Assert
+			if testCase.expectedError == pkg.ErrorInvalidRefreshToken {
+				assert.Nil(t, tokens)
+				assert.ErrorIs(t, err, pkg.ErrorInvalidRefreshToken)
+			} else if testCase.expectedError != nil {
+				assert.Nil(t, tokens)
+				assert.Equal(t, status.Code(testCase.expectedError), status.Code(errors.Unwrap(err)))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedTokens, tokens)
+			}
+		})
+	}
+}
+
+func TestService_authUserLockout(t *testing.T) {
+	testTable := []struct {
+		name             string
+		attempts         int
+		lockouts         int
+		expectedLock     bool
+		expectedDuration time.Duration
+	}{
+		{
+			name:     "Below the limit",
+			attempts: 2,
+		},
+		{
+			name:             "First lockout",
+			attempts:         3,
+			lockouts:         0,
+			expectedLock:     true,
+			expectedDuration: time.Minute,
+		},
+		{
+			name:             "Third lockout",
+			attempts:         3,
+			lockouts:         2,
+			expectedLock:     true,
+			expectedDuration: 4 * time.Minute,
+		},
+		{
+			name:             "Capped lockout",
+			attempts:         3,
+			lockouts:         10,
+			expectedLock:     true,
+			expectedDuration: time.Hour,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			repo := mock_repository.NewMockAppUser(c)
+			repo.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(&model.User{
+				ID:       1,
+				Email:    "test@yandex.ru",
+				Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+			}, nil)
+			repo.EXPECT().RegisterFailedLogin(gomock.Any(), 1).Return(testCase.attempts, testCase.lockouts, nil)
+			var lockedUntil time.Time
+			if testCase.expectedLock {
+				repo.EXPECT().LockUser(gomock.Any(), 1, gomock.Any(), 3, auditAction(model.AuditUserLocked)).DoAndReturn(func(_ context.Context, id int, until time.Time, maxAttempts int, _ *model.AuditEvent) error {
+					lockedUntil = until
+					return nil
+				})
+			}
+			reposit := &repository.Repository{AppUser: repo}
+			service := NewService(reposit, grpcClient.NewFakeClient(), logging.GetLogger(), Config{Lockout: LockoutPolicy{
+				MaxAttempts:  3,
+				BaseDuration: time.Minute,
+				MaxDuration:  time.Hour,
+			}})
+			outcome := metrics.LoginFailure
+			if testCase.expectedLock {
+				outcome = metrics.LoginLocked
+			}
+			logins := testutil.ToFloat64(metrics.Logins.WithLabelValues("password", outcome))
+			started := time.Now()
+			_, _, err := service.AuthUser(context.Background(), "test@yandex.ru", "wrong!Pass1")
+			//Assert
+			assert.Equal(t, logins+1, testutil.ToFloat64(metrics.Logins.WithLabelValues("password", outcome)))
+			if testCase.expectedLock {
+				assert.ErrorIs(t, err, pkg.ErrorAccountLocked)
+				assert.Equal(t, &pkg.LockedError{Until: lockedUntil}, err)
+				assert.WithinDuration(t, started.Add(testCase.expectedDuration), lockedUntil, time.Second)
+			} else {
+				assert.Equal(t, errors.New("wrong email or password entered"), err)
+			}
+		})
+	}
+}
+
+func TestLoginOutcome(t *testing.T) {
+	testTable := []struct {
+		err      error
+		expected string
+	}{
+		{nil, metrics.LoginSuccess},
+		{&pkg.ChallengeError{Challenge: "challenge"}, metrics.LoginTwoFactorRequired},
+		{fmt.Errorf("authUser:%w", &pkg.LockedError{Until: time.Now()}), metrics.LoginLocked},
+		{pkg.ErrorEmailNotVerified, metrics.LoginUnverified},
+		{errors.New("wrong email or password entered"), metrics.LoginFailure},
+		{pkg.ErrorInvalidTwoFactor, metrics.LoginFailure},
+	}
+	for _, testCase := range testTable {
+		assert.Equal(t, testCase.expected, loginOutcome(testCase.err))
+	}
+}
+
+func TestService_VerifyCredentials(t *testing.T) {
+	lockedUntil := time.Now().Add(time.Hour)
+	responseUser := &model.ResponseUser{ID: 1, Email: "test@yandex.ru", Role: "Courier"}
+	type mockBehavior func(s *mock_repository.MockAppUser, f *mock_repository.MockTwoFactor)
+	testTable := []struct {
+		name          string
+		inputPassword string
+		unverified    string
+		mockBehavior  mockBehavior
+		expectedUser  *model.ResponseUser
+		expectedError error
+	}{
+		{
+			name:          "OK",
+			inputPassword: "HGYKnu!98Tg",
+			mockBehavior: func(s *mock_repository.MockAppUser, f *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(&model.User{
+					ID:                  1,
+					Email:               "test@yandex.ru",
+					Password:            "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+					FailedLoginAttempts: 2,
+				}, nil)
+				f.EXPECT().GetTwoFactorRoles(gomock.Any()).Return([]string{"Superadmin"}, nil)
+				s.EXPECT().UnlockUser(gomock.Any(), 1, gomock.Any()).Return(1, nil)
+				s.EXPECT().GetUserByID(gomock.Any(), 1).Return(responseUser, nil)
+			},
+			expectedUser: responseUser,
+		},
+		{
+			name:          "Two-factor enabled keeps failed attempts",
+			inputPassword: "HGYKnu!98Tg",
+			mockBehavior: func(s *mock_repository.MockAppUser, f *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(&model.User{
+					ID:                  1,
+					Email:               "test@yandex.ru",
+					Password:            "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+					TOTPEnabled:         true,
+					FailedLoginAttempts: 2,
+				}, nil)
+			},
+			expectedError: fmt.Errorf("verifyCredentials:%w", pkg.ErrorTwoFactorRequired),
+		},
+		{
+			name:          "Two-factor required for role",
+			inputPassword: "HGYKnu!98Tg",
+			mockBehavior: func(s *mock_repository.MockAppUser, f *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(&model.User{
+					ID:       1,
+					Email:    "test@yandex.ru",
+					Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+					Role:     "Superadmin",
+				}, nil)
+				f.EXPECT().GetTwoFactorRoles(gomock.Any()).Return([]string{"Superadmin"}, nil)
+			},
+			expectedError: fmt.Errorf("verifyCredentials:%w", pkg.ErrorTwoFactorRequired),
+		},
+		{
+			name:          "Unverified email",
+			inputPassword: "HGYKnu!98Tg",
+			unverified:    UnverifiedDenyLogin,
+			mockBehavior: func(s *mock_repository.MockAppUser, f *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(&model.User{
+					ID:       1,
+					Email:    "test@yandex.ru",
+					Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+				}, nil)
+			},
+			expectedError: fmt.Errorf("authUser:%w", pkg.ErrorEmailNotVerified),
+		},
+		{
+			name:          "Wrong password",
+			inputPassword: "HGYKnu!9Tg",
+			mockBehavior: func(s *mock_repository.MockAppUser, f *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(&model.User{
+					ID:       1,
+					Email:    "test@yandex.ru",
+					Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+				}, nil)
+				s.EXPECT().RegisterFailedLogin(gomock.Any(), 1).Return(1, 0, nil)
+			},
+			expectedError: fmt.Errorf("verifyCredentials:%w", pkg.ErrorInvalidCredentials),
+		},
+		{
+			name:          "Unknown email",
+			inputPassword: "HGYKnu!98Tg",
+			mockBehavior: func(s *mock_repository.MockAppUser, f *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(nil, fmt.Errorf("getUserByEmail:%w", pkg.ErrorEmailDoesNotExist))
+			},
+			expectedError: fmt.Errorf("verifyCredentials:%w", pkg.ErrorInvalidCredentials),
+		},
+		{
+			name:          "Deleted user",
+			inputPassword: "HGYKnu!98Tg",
+			mockBehavior: func(s *mock_repository.MockAppUser, f *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(&model.User{
+					ID:       1,
+					Email:    "test@yandex.ru",
+					Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+					Deleted:  true,
+				}, nil)
+			},
+			expectedError: fmt.Errorf("verifyCredentials:%w", pkg.ErrorInvalidCredentials),
+		},
+		{
+			name:          "Locked user",
+			inputPassword: "HGYKnu!98Tg",
+			mockBehavior: func(s *mock_repository.MockAppUser, f *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(&model.User{
+					ID:          1,
+					Email:       "test@yandex.ru",
+					Password:    "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+					LockedUntil: &lockedUntil,
+				}, nil)
+			},
+			expectedError: &pkg.LockedError{Until: lockedUntil},
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			repo := mock_repository.NewMockAppUser(c)
+			twoFactor := mock_repository.NewMockTwoFactor(c)
+			testCase.mockBehavior(repo, twoFactor)
+			service := NewUserService(repository.Repository{AppUser: repo, TwoFactor: twoFactor}, grpcClient.NewFakeClient(), logging.GetLogger(),
+				Config{EmailVerification: EmailVerificationPolicy{Unverified: testCase.unverified}})
+			user, err := service.VerifyCredentials(context.Background(), "test@yandex.ru", testCase.inputPassword)
+			//Assert
+			assert.Equal(t, testCase.expectedUser, user)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
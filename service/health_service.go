@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HealthChecker is implemented by the clients of remote dependencies, e.g. the
+// connection to the auth service, the local issuer has nothing to check
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+type HealthService struct {
+	checks   map[string]func(ctx context.Context) error
+	logger   logging.Logger
+	draining int32
+}
+
+func NewHealthService(repo repository.Repository, authCli authProto.AuthClient, logger logging.Logger) *HealthService {
+	checks := map[string]func(ctx context.Context) error{
+		"postgres": func(ctx context.Context) error {
+			return repo.Health.Ping(ctx)
+		},
+	}
+	if checker, ok := authCli.(HealthChecker); ok {
+		checks["auth"] = checker.Check
+	}
+	return &HealthService{checks: checks, logger: logger}
+}
+
+// Ready checks the dependencies at once, the service is ready when all of them are.
+// It is not ready at all once it drains.
+func (h *HealthService) Ready(ctx context.Context) *model.Health {
+	if atomic.LoadInt32(&h.draining) == 1 {
+		return &model.Health{Status: model.HealthShuttingDown}
+	}
+	health := &model.Health{Status: model.HealthOK, Checks: make(map[string]model.DependencyHealth, len(h.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			start := time.Now()
+			err := check(ctx)
+			dependency := model.DependencyHealth{
+				Status:    model.HealthOK,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				h.logger.WithContext(ctx).Warnf("Ready: %s is unavailable:%s", name, err)
+				dependency.Status, dependency.Error = model.HealthUnavailable, err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			health.Checks[name] = dependency
+			if err != nil {
+				health.Status = model.HealthUnavailable
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return health
+}
+
+// Drain makes the service report that it is not ready, so that no new requests
+// are sent to it while it shuts down
+func (h *HealthService) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/grpcClient"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	mock_repository "stlab.itechart-group.com/go/food_delivery/authentication_service/repository/mocks"
+	"testing"
+)
+
+// checkedClient is an auth client with a connection to check
+type checkedClient struct {
+	*grpcClient.FakeClient
+	err error
+}
+
+func (c *checkedClient) Check(ctx context.Context) error {
+	return c.err
+}
+
+func TestService_Ready(t *testing.T) {
+	type mockBehavior func(s *mock_repository.MockHealth)
+	testTable := []struct {
+		name           string
+		authErr        error
+		mockBehavior   mockBehavior
+		expectedStatus string
+		expectedChecks map[string]string
+	}{
+		{
+			name: "OK",
+			mockBehavior: func(s *mock_repository.MockHealth) {
+				s.EXPECT().Ping(gomock.Any()).Return(nil)
+			},
+			expectedStatus: model.HealthOK,
+			expectedChecks: map[string]string{"postgres": model.HealthOK, "auth": model.HealthOK},
+		},
+		{
+			name: "Database unavailable",
+			mockBehavior: func(s *mock_repository.MockHealth) {
+				s.EXPECT().Ping(gomock.Any()).Return(errors.New("connection refused"))
+			},
+			expectedStatus: model.HealthUnavailable,
+			expectedChecks: map[string]string{"postgres": model.HealthUnavailable, "auth": model.HealthOK},
+		},
+		{
+			name:    "Auth service unavailable",
+			authErr: errors.New("check: connection is TRANSIENT_FAILURE"),
+			mockBehavior: func(s *mock_repository.MockHealth) {
+				s.EXPECT().Ping(gomock.Any()).Return(nil)
+			},
+			expectedStatus: model.HealthUnavailable,
+			expectedChecks: map[string]string{"postgres": model.HealthOK, "auth": model.HealthUnavailable},
+		},
+	}
+
+	for _, tt := range testTable {
+		t.Run(tt.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			repo := mock_repository.NewMockHealth(c)
+			tt.mockBehavior(repo)
+			authCli := &checkedClient{FakeClient: grpcClient.NewFakeClient(), err: tt.authErr}
+			service := NewHealthService(repository.Repository{Health: repo}, authCli, logging.GetLogger())
+
+			health := service.Ready(context.Background())
+			//Assert
+			assert.Equal(t, tt.expectedStatus, health.Status)
+			checks := make(map[string]string)
+			for name, check := range health.Checks {
+				checks[name] = check.Status
+				assert.Equal(t, check.Status != model.HealthOK, check.Error != "")
+			}
+			assert.Equal(t, tt.expectedChecks, checks)
+		})
+	}
+}
+
+func TestService_Ready_draining(t *testing.T) {
+	// the fake client has no connection to check and the database is not asked while draining
+	service := NewHealthService(repository.Repository{}, grpcClient.NewFakeClient(), logging.GetLogger())
+	service.Drain()
+
+	//Assert
+	assert.Equal(t, &model.Health{Status: model.HealthShuttingDown}, service.Ready(context.Background()))
+}
@@ -5,11 +5,13 @@
 package mock_service
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
 	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
 	model "stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	service "stlab.itechart-group.com/go/food_delivery/authentication_service/service"
 )
 
 // MockAppUser is a mock of AppUser interface.
@@ -36,9 +38,9 @@ func (m *MockAppUser) EXPECT() *MockAppUserMockRecorder {
 }
 
 // AuthUser mocks base method.
-func (m *MockAppUser) AuthUser(email, password string) (*authProto.GeneratedTokens, int, error) {
+func (m *MockAppUser) AuthUser(ctx context.Context, email, password string) (*authProto.GeneratedTokens, int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AuthUser", email, password)
+	ret := m.ctrl.Call(m, "AuthUser", ctx, email, password)
 	ret0, _ := ret[0].(*authProto.GeneratedTokens)
 	ret1, _ := ret[1].(int)
 	ret2, _ := ret[2].(error)
@@ -46,23 +48,39 @@ func (m *MockAppUser) AuthUser(email, password string) (*authProto.GeneratedToke
 }
 
 // AuthUser indicates an expected call of AuthUser.
-func (mr *MockAppUserMockRecorder) AuthUser(email, password interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) AuthUser(ctx, email, password interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthUser", reflect.TypeOf((*MockAppUser)(nil).AuthUser), email, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthUser", reflect.TypeOf((*MockAppUser)(nil).AuthUser), ctx, email, password)
+}
+
+// AuthUserTwoFactor mocks base method.
+func (m *MockAppUser) AuthUserTwoFactor(ctx context.Context, challenge, code string) (*model.TwoFactorTokens, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthUserTwoFactor", ctx, challenge, code)
+	ret0, _ := ret[0].(*model.TwoFactorTokens)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// AuthUserTwoFactor indicates an expected call of AuthUserTwoFactor.
+func (mr *MockAppUserMockRecorder) AuthUserTwoFactor(ctx, challenge, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthUserTwoFactor", reflect.TypeOf((*MockAppUser)(nil).AuthUserTwoFactor), ctx, challenge, code)
 }
 
 // CheckInputRole mocks base method.
-func (m *MockAppUser) CheckInputRole(role string) error {
+func (m *MockAppUser) CheckInputRole(ctx context.Context, role string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CheckInputRole", role)
+	ret := m.ctrl.Call(m, "CheckInputRole", ctx, role)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CheckInputRole indicates an expected call of CheckInputRole.
-func (mr *MockAppUserMockRecorder) CheckInputRole(role interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) CheckInputRole(ctx, role interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckInputRole", reflect.TypeOf((*MockAppUser)(nil).CheckInputRole), role)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckInputRole", reflect.TypeOf((*MockAppUser)(nil).CheckInputRole), ctx, role)
 }
 
 // CheckPasswordHash mocks base method.
@@ -107,10 +125,25 @@ func (mr *MockAppUserMockRecorder) CheckRole(neededRoles, givenRole interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckRole", reflect.TypeOf((*MockAppUser)(nil).CheckRole), neededRoles, givenRole)
 }
 
+// CleanupPasswordResets mocks base method.
+func (m *MockAppUser) CleanupPasswordResets(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CleanupPasswordResets", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CleanupPasswordResets indicates an expected call of CleanupPasswordResets.
+func (mr *MockAppUserMockRecorder) CleanupPasswordResets(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanupPasswordResets", reflect.TypeOf((*MockAppUser)(nil).CleanupPasswordResets), ctx)
+}
+
 // CreateCustomer mocks base method.
-func (m *MockAppUser) CreateCustomer(user *model.CreateCustomer) (*authProto.GeneratedTokens, int, error) {
+func (m *MockAppUser) CreateCustomer(ctx context.Context, user *model.CreateCustomer) (*authProto.GeneratedTokens, int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateCustomer", user)
+	ret := m.ctrl.Call(m, "CreateCustomer", ctx, user)
 	ret0, _ := ret[0].(*authProto.GeneratedTokens)
 	ret1, _ := ret[1].(int)
 	ret2, _ := ret[2].(error)
@@ -118,70 +151,114 @@ func (m *MockAppUser) CreateCustomer(user *model.CreateCustomer) (*authProto.Gen
 }
 
 // CreateCustomer indicates an expected call of CreateCustomer.
-func (mr *MockAppUserMockRecorder) CreateCustomer(user interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) CreateCustomer(ctx, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCustomer", reflect.TypeOf((*MockAppUser)(nil).CreateCustomer), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCustomer", reflect.TypeOf((*MockAppUser)(nil).CreateCustomer), ctx, user)
 }
 
 // CreateStaff mocks base method.
-func (m *MockAppUser) CreateStaff(user *model.CreateStaff) (int, error) {
+func (m *MockAppUser) CreateStaff(ctx context.Context, user *model.CreateStaff) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateStaff", user)
+	ret := m.ctrl.Call(m, "CreateStaff", ctx, user)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateStaff indicates an expected call of CreateStaff.
-func (mr *MockAppUserMockRecorder) CreateStaff(user interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) CreateStaff(ctx, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateStaff", reflect.TypeOf((*MockAppUser)(nil).CreateStaff), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateStaff", reflect.TypeOf((*MockAppUser)(nil).CreateStaff), ctx, user)
 }
 
 // DeleteUserByID mocks base method.
-func (m *MockAppUser) DeleteUserByID(id int) (int, error) {
+func (m *MockAppUser) DeleteUserByID(ctx context.Context, id int) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUserByID", id)
+	ret := m.ctrl.Call(m, "DeleteUserByID", ctx, id)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DeleteUserByID indicates an expected call of DeleteUserByID.
-func (mr *MockAppUserMockRecorder) DeleteUserByID(id interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) DeleteUserByID(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserByID", reflect.TypeOf((*MockAppUser)(nil).DeleteUserByID), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserByID", reflect.TypeOf((*MockAppUser)(nil).DeleteUserByID), ctx, id)
 }
 
 // GetUser mocks base method.
-func (m *MockAppUser) GetUser(id int) (*model.ResponseUser, error) {
+func (m *MockAppUser) GetUser(ctx context.Context, id int) (*model.ResponseUser, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", id)
+	ret := m.ctrl.Call(m, "GetUser", ctx, id)
 	ret0, _ := ret[0].(*model.ResponseUser)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUser indicates an expected call of GetUser.
-func (mr *MockAppUserMockRecorder) GetUser(id interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) GetUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockAppUser)(nil).GetUser), ctx, id)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockAppUser) GetUserByEmail(ctx context.Context, email string) (*model.ResponseUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", ctx, email)
+	ret0, _ := ret[0].(*model.ResponseUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockAppUserMockRecorder) GetUserByEmail(ctx, email interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockAppUser)(nil).GetUser), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockAppUser)(nil).GetUserByEmail), ctx, email)
 }
 
 // GetUsers mocks base method.
-func (m *MockAppUser) GetUsers(page, limit int, filters *model.RequestFilters) ([]model.ResponseUser, int, error) {
+func (m *MockAppUser) GetUsers(ctx context.Context, page, limit int, filters *model.RequestFilters, sort string) (*model.UsersPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUsers", page, limit, filters)
-	ret0, _ := ret[0].([]model.ResponseUser)
-	ret1, _ := ret[1].(int)
-	ret2, _ := ret[2].(error)
-	return ret0, ret1, ret2
+	ret := m.ctrl.Call(m, "GetUsers", ctx, page, limit, filters, sort)
+	ret0, _ := ret[0].(*model.UsersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetUsers indicates an expected call of GetUsers.
-func (mr *MockAppUserMockRecorder) GetUsers(page, limit, filters interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) GetUsers(ctx, page, limit, filters, sort interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockAppUser)(nil).GetUsers), ctx, page, limit, filters, sort)
+}
+
+// GetUsersByIds mocks base method.
+func (m *MockAppUser) GetUsersByIds(ctx context.Context, ids []int) ([]model.ResponseUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsersByIds", ctx, ids)
+	ret0, _ := ret[0].([]model.ResponseUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersByIds indicates an expected call of GetUsersByIds.
+func (mr *MockAppUserMockRecorder) GetUsersByIds(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByIds", reflect.TypeOf((*MockAppUser)(nil).GetUsersByIds), ctx, ids)
+}
+
+// GetUsersPage mocks base method.
+func (m *MockAppUser) GetUsersPage(ctx context.Context, filters *model.RequestFilters, sort, after, before string, limit int) (*model.UsersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsersPage", ctx, filters, sort, after, before, limit)
+	ret0, _ := ret[0].(*model.UsersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersPage indicates an expected call of GetUsersPage.
+func (mr *MockAppUserMockRecorder) GetUsersPage(ctx, filters, sort, after, before, limit interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockAppUser)(nil).GetUsers), page, limit, filters)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersPage", reflect.TypeOf((*MockAppUser)(nil).GetUsersPage), ctx, filters, sort, after, before, limit)
 }
 
 // HashPassword mocks base method.
@@ -200,44 +277,538 @@ func (mr *MockAppUserMockRecorder) HashPassword(password, rounds interface{}) *g
 }
 
 // ParseToken mocks base method.
-func (m *MockAppUser) ParseToken(token string) (*authProto.UserRole, error) {
+func (m *MockAppUser) ParseToken(ctx context.Context, token string) (*authProto.UserRole, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ParseToken", token)
+	ret := m.ctrl.Call(m, "ParseToken", ctx, token)
 	ret0, _ := ret[0].(*authProto.UserRole)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ParseToken indicates an expected call of ParseToken.
-func (mr *MockAppUserMockRecorder) ParseToken(token interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) ParseToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseToken", reflect.TypeOf((*MockAppUser)(nil).ParseToken), ctx, token)
+}
+
+// RefreshTokens mocks base method.
+func (m *MockAppUser) RefreshTokens(ctx context.Context, refreshToken string) (*authProto.GeneratedTokens, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshTokens", ctx, refreshToken)
+	ret0, _ := ret[0].(*authProto.GeneratedTokens)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshTokens indicates an expected call of RefreshTokens.
+func (mr *MockAppUserMockRecorder) RefreshTokens(ctx, refreshToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTokens", reflect.TypeOf((*MockAppUser)(nil).RefreshTokens), ctx, refreshToken)
+}
+
+// ResendVerification mocks base method.
+func (m *MockAppUser) ResendVerification(ctx context.Context, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResendVerification", ctx, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResendVerification indicates an expected call of ResendVerification.
+func (mr *MockAppUserMockRecorder) ResendVerification(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResendVerification", reflect.TypeOf((*MockAppUser)(nil).ResendVerification), ctx, email)
+}
+
+// ResetPassword mocks base method.
+func (m *MockAppUser) ResetPassword(ctx context.Context, reset *model.ResetPassword) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, reset)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockAppUserMockRecorder) ResetPassword(ctx, reset interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseToken", reflect.TypeOf((*MockAppUser)(nil).ParseToken), token)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockAppUser)(nil).ResetPassword), ctx, reset)
 }
 
 // RestorePassword mocks base method.
-func (m *MockAppUser) RestorePassword(restore *model.RestorePassword) error {
+func (m *MockAppUser) RestorePassword(ctx context.Context, restore *model.RestorePassword) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RestorePassword", restore)
+	ret := m.ctrl.Call(m, "RestorePassword", ctx, restore)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // RestorePassword indicates an expected call of RestorePassword.
-func (mr *MockAppUserMockRecorder) RestorePassword(restore interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) RestorePassword(ctx, restore interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestorePassword", reflect.TypeOf((*MockAppUser)(nil).RestorePassword), restore)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestorePassword", reflect.TypeOf((*MockAppUser)(nil).RestorePassword), ctx, restore)
+}
+
+// RestoreUser mocks base method.
+func (m *MockAppUser) RestoreUser(ctx context.Context, id int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUser", ctx, id)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockAppUserMockRecorder) RestoreUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockAppUser)(nil).RestoreUser), ctx, id)
+}
+
+// StartTwoFactorEnrollment mocks base method.
+func (m *MockAppUser) StartTwoFactorEnrollment(ctx context.Context, token string) (*model.TwoFactorEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartTwoFactorEnrollment", ctx, token)
+	ret0, _ := ret[0].(*model.TwoFactorEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartTwoFactorEnrollment indicates an expected call of StartTwoFactorEnrollment.
+func (mr *MockAppUserMockRecorder) StartTwoFactorEnrollment(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartTwoFactorEnrollment", reflect.TypeOf((*MockAppUser)(nil).StartTwoFactorEnrollment), ctx, token)
+}
+
+// UnlockUser mocks base method.
+func (m *MockAppUser) UnlockUser(ctx context.Context, id int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockUser", ctx, id)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnlockUser indicates an expected call of UnlockUser.
+func (mr *MockAppUserMockRecorder) UnlockUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockUser", reflect.TypeOf((*MockAppUser)(nil).UnlockUser), ctx, id)
 }
 
 // UpdateUser mocks base method.
-func (m *MockAppUser) UpdateUser(user *model.UpdateUser) error {
+func (m *MockAppUser) UpdateUser(ctx context.Context, user *model.UpdateUser) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUser", user)
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateUser indicates an expected call of UpdateUser.
-func (mr *MockAppUserMockRecorder) UpdateUser(user interface{}) *gomock.Call {
+func (mr *MockAppUserMockRecorder) UpdateUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockAppUser)(nil).UpdateUser), ctx, user)
+}
+
+// VerifyCredentials mocks base method.
+func (m *MockAppUser) VerifyCredentials(ctx context.Context, email, password string) (*model.ResponseUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyCredentials", ctx, email, password)
+	ret0, _ := ret[0].(*model.ResponseUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyCredentials indicates an expected call of VerifyCredentials.
+func (mr *MockAppUserMockRecorder) VerifyCredentials(ctx, email, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyCredentials", reflect.TypeOf((*MockAppUser)(nil).VerifyCredentials), ctx, email, password)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockAppUser) VerifyEmail(ctx context.Context, token string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, token)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockAppUserMockRecorder) VerifyEmail(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockAppUser)(nil).VerifyEmail), ctx, token)
+}
+
+// MockTokenRevocation is a mock of TokenRevocation interface.
+type MockTokenRevocation struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenRevocationMockRecorder
+}
+
+// MockTokenRevocationMockRecorder is the mock recorder for MockTokenRevocation.
+type MockTokenRevocationMockRecorder struct {
+	mock *MockTokenRevocation
+}
+
+// NewMockTokenRevocation creates a new mock instance.
+func NewMockTokenRevocation(ctrl *gomock.Controller) *MockTokenRevocation {
+	mock := &MockTokenRevocation{ctrl: ctrl}
+	mock.recorder = &MockTokenRevocationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTokenRevocation) EXPECT() *MockTokenRevocationMockRecorder {
+	return m.recorder
+}
+
+// CheckTokenRevoked mocks base method.
+func (m *MockTokenRevocation) CheckTokenRevoked(ctx context.Context, userId int, token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckTokenRevoked", ctx, userId, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckTokenRevoked indicates an expected call of CheckTokenRevoked.
+func (mr *MockTokenRevocationMockRecorder) CheckTokenRevoked(ctx, userId, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckTokenRevoked", reflect.TypeOf((*MockTokenRevocation)(nil).CheckTokenRevoked), ctx, userId, token)
+}
+
+// CleanupRevokedTokens mocks base method.
+func (m *MockTokenRevocation) CleanupRevokedTokens(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CleanupRevokedTokens", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CleanupRevokedTokens indicates an expected call of CleanupRevokedTokens.
+func (mr *MockTokenRevocationMockRecorder) CleanupRevokedTokens(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanupRevokedTokens", reflect.TypeOf((*MockTokenRevocation)(nil).CleanupRevokedTokens), ctx)
+}
+
+// Logout mocks base method.
+func (m *MockTokenRevocation) Logout(ctx context.Context, userId int, accessToken, refreshToken string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", ctx, userId, accessToken, refreshToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockTokenRevocationMockRecorder) Logout(ctx, userId, accessToken, refreshToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockTokenRevocation)(nil).Logout), ctx, userId, accessToken, refreshToken)
+}
+
+// LogoutAll mocks base method.
+func (m *MockTokenRevocation) LogoutAll(ctx context.Context, userId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LogoutAll", ctx, userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LogoutAll indicates an expected call of LogoutAll.
+func (mr *MockTokenRevocationMockRecorder) LogoutAll(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LogoutAll", reflect.TypeOf((*MockTokenRevocation)(nil).LogoutAll), ctx, userId)
+}
+
+// MockTwoFactor is a mock of TwoFactor interface.
+type MockTwoFactor struct {
+	ctrl     *gomock.Controller
+	recorder *MockTwoFactorMockRecorder
+}
+
+// MockTwoFactorMockRecorder is the mock recorder for MockTwoFactor.
+type MockTwoFactorMockRecorder struct {
+	mock *MockTwoFactor
+}
+
+// NewMockTwoFactor creates a new mock instance.
+func NewMockTwoFactor(ctrl *gomock.Controller) *MockTwoFactor {
+	mock := &MockTwoFactor{ctrl: ctrl}
+	mock.recorder = &MockTwoFactorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTwoFactor) EXPECT() *MockTwoFactorMockRecorder {
+	return m.recorder
+}
+
+// ConfirmTOTP mocks base method.
+func (m *MockTwoFactor) ConfirmTOTP(ctx context.Context, userId int, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", ctx, userId, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP.
+func (mr *MockTwoFactorMockRecorder) ConfirmTOTP(ctx, userId, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockTwoFactor)(nil).ConfirmTOTP), ctx, userId, code)
+}
+
+// DisableTOTP mocks base method.
+func (m *MockTwoFactor) DisableTOTP(ctx context.Context, userId int, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTOTP", ctx, userId, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTOTP indicates an expected call of DisableTOTP.
+func (mr *MockTwoFactorMockRecorder) DisableTOTP(ctx, userId, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTOTP", reflect.TypeOf((*MockTwoFactor)(nil).DisableTOTP), ctx, userId, code)
+}
+
+// EnrollTOTP mocks base method.
+func (m *MockTwoFactor) EnrollTOTP(ctx context.Context, userId int) (*model.TOTPEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTOTP", ctx, userId)
+	ret0, _ := ret[0].(*model.TOTPEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnrollTOTP indicates an expected call of EnrollTOTP.
+func (mr *MockTwoFactorMockRecorder) EnrollTOTP(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTOTP", reflect.TypeOf((*MockTwoFactor)(nil).EnrollTOTP), ctx, userId)
+}
+
+// GetTwoFactorRoles mocks base method.
+func (m *MockTwoFactor) GetTwoFactorRoles(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTwoFactorRoles", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTwoFactorRoles indicates an expected call of GetTwoFactorRoles.
+func (mr *MockTwoFactorMockRecorder) GetTwoFactorRoles(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTwoFactorRoles", reflect.TypeOf((*MockTwoFactor)(nil).GetTwoFactorRoles), ctx)
+}
+
+// SetTwoFactorRoles mocks base method.
+func (m *MockTwoFactor) SetTwoFactorRoles(ctx context.Context, roles []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTwoFactorRoles", ctx, roles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTwoFactorRoles indicates an expected call of SetTwoFactorRoles.
+func (mr *MockTwoFactorMockRecorder) SetTwoFactorRoles(ctx, roles interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTwoFactorRoles", reflect.TypeOf((*MockTwoFactor)(nil).SetTwoFactorRoles), ctx, roles)
+}
+
+// MockRateLimiter is a mock of RateLimiter interface.
+type MockRateLimiter struct {
+	ctrl     *gomock.Controller
+	recorder *MockRateLimiterMockRecorder
+}
+
+// MockRateLimiterMockRecorder is the mock recorder for MockRateLimiter.
+type MockRateLimiterMockRecorder struct {
+	mock *MockRateLimiter
+}
+
+// NewMockRateLimiter creates a new mock instance.
+func NewMockRateLimiter(ctrl *gomock.Controller) *MockRateLimiter {
+	mock := &MockRateLimiter{ctrl: ctrl}
+	mock.recorder = &MockRateLimiterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRateLimiter) EXPECT() *MockRateLimiterMockRecorder {
+	return m.recorder
+}
+
+// Allow mocks base method.
+func (m *MockRateLimiter) Allow(ctx context.Context, route string, keys []string) (*model.RateLimitResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Allow", ctx, route, keys)
+	ret0, _ := ret[0].(*model.RateLimitResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Allow indicates an expected call of Allow.
+func (mr *MockRateLimiterMockRecorder) Allow(ctx, route, keys interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Allow", reflect.TypeOf((*MockRateLimiter)(nil).Allow), ctx, route, keys)
+}
+
+// CleanupRateLimits mocks base method.
+func (m *MockRateLimiter) CleanupRateLimits(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CleanupRateLimits", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CleanupRateLimits indicates an expected call of CleanupRateLimits.
+func (mr *MockRateLimiterMockRecorder) CleanupRateLimits(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanupRateLimits", reflect.TypeOf((*MockRateLimiter)(nil).CleanupRateLimits), ctx)
+}
+
+// Rule mocks base method.
+func (m *MockRateLimiter) Rule(route string) (service.RateLimitRule, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rule", route)
+	ret0, _ := ret[0].(service.RateLimitRule)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Rule indicates an expected call of Rule.
+func (mr *MockRateLimiterMockRecorder) Rule(route interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rule", reflect.TypeOf((*MockRateLimiter)(nil).Rule), route)
+}
+
+// MockHealth is a mock of Health interface.
+type MockHealth struct {
+	ctrl     *gomock.Controller
+	recorder *MockHealthMockRecorder
+}
+
+// MockHealthMockRecorder is the mock recorder for MockHealth.
+type MockHealthMockRecorder struct {
+	mock *MockHealth
+}
+
+// NewMockHealth creates a new mock instance.
+func NewMockHealth(ctrl *gomock.Controller) *MockHealth {
+	mock := &MockHealth{ctrl: ctrl}
+	mock.recorder = &MockHealthMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHealth) EXPECT() *MockHealthMockRecorder {
+	return m.recorder
+}
+
+// Drain mocks base method.
+func (m *MockHealth) Drain() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Drain")
+}
+
+// Drain indicates an expected call of Drain.
+func (mr *MockHealthMockRecorder) Drain() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Drain", reflect.TypeOf((*MockHealth)(nil).Drain))
+}
+
+// Ready mocks base method.
+func (m *MockHealth) Ready(ctx context.Context) *model.Health {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ready", ctx)
+	ret0, _ := ret[0].(*model.Health)
+	return ret0
+}
+
+// Ready indicates an expected call of Ready.
+func (mr *MockHealthMockRecorder) Ready(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ready", reflect.TypeOf((*MockHealth)(nil).Ready), ctx)
+}
+
+// MockAudit is a mock of Audit interface.
+type MockAudit struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditMockRecorder
+}
+
+// MockAuditMockRecorder is the mock recorder for MockAudit.
+type MockAuditMockRecorder struct {
+	mock *MockAudit
+}
+
+// NewMockAudit creates a new mock instance.
+func NewMockAudit(ctrl *gomock.Controller) *MockAudit {
+	mock := &MockAudit{ctrl: ctrl}
+	mock.recorder = &MockAuditMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAudit) EXPECT() *MockAuditMockRecorder {
+	return m.recorder
+}
+
+// Checkpoint mocks base method.
+func (m *MockAudit) Checkpoint(ctx context.Context) (*model.AuditCheckpoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Checkpoint", ctx)
+	ret0, _ := ret[0].(*model.AuditCheckpoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Checkpoint indicates an expected call of Checkpoint.
+func (mr *MockAuditMockRecorder) Checkpoint(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checkpoint", reflect.TypeOf((*MockAudit)(nil).Checkpoint), ctx)
+}
+
+// ExportAuditEvents mocks base method.
+func (m *MockAudit) ExportAuditEvents(ctx context.Context, fromID, toID int64) (*model.AuditExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportAuditEvents", ctx, fromID, toID)
+	ret0, _ := ret[0].(*model.AuditExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportAuditEvents indicates an expected call of ExportAuditEvents.
+func (mr *MockAuditMockRecorder) ExportAuditEvents(ctx, fromID, toID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportAuditEvents", reflect.TypeOf((*MockAudit)(nil).ExportAuditEvents), ctx, fromID, toID)
+}
+
+// GetAuditEvents mocks base method.
+func (m *MockAudit) GetAuditEvents(ctx context.Context, filters *model.AuditFilters, page, limit int) (*model.AuditPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditEvents", ctx, filters, page, limit)
+	ret0, _ := ret[0].(*model.AuditPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditEvents indicates an expected call of GetAuditEvents.
+func (mr *MockAuditMockRecorder) GetAuditEvents(ctx, filters, page, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditEvents", reflect.TypeOf((*MockAudit)(nil).GetAuditEvents), ctx, filters, page, limit)
+}
+
+// VerifyAuditChain mocks base method.
+func (m *MockAudit) VerifyAuditChain(ctx context.Context) (*model.AuditVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyAuditChain", ctx)
+	ret0, _ := ret[0].(*model.AuditVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyAuditChain indicates an expected call of VerifyAuditChain.
+func (mr *MockAuditMockRecorder) VerifyAuditChain(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockAppUser)(nil).UpdateUser), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAuditChain", reflect.TypeOf((*MockAudit)(nil).VerifyAuditChain), ctx)
 }
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"strings"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// GetUsersPage returns a page of the user list after or before an opaque cursor,
+// a first page is returned when both of them are empty
+func (u *UserService) GetUsersPage(ctx context.Context, filters *model.RequestFilters, sort string, after string, before string, limit int) (*model.UsersPage, error) {
+	normalizeFilters(filters)
+	fields, err := listSort(sort, filters)
+	if err != nil {
+		return nil, err
+	}
+	if after != "" && before != "" {
+		return nil, fmt.Errorf("%w: only one of after and before can be set", pkg.ErrorInvalidCursor)
+	}
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	} else if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+	query := &model.CursorQuery{Limit: limit, Sort: fields}
+	if after != "" {
+		if query.Cursor, err = decodeCursor(after, fields, filters.Query); err != nil {
+			return nil, err
+		}
+	} else if before != "" {
+		if query.Cursor, err = decodeCursor(before, fields, filters.Query); err != nil {
+			return nil, err
+		}
+		query.Backward = true
+	}
+	users, more, err := u.repo.AppUser.GetUsersByCursor(ctx, filters, query)
+	if err != nil {
+		return nil, err
+	}
+	page := &model.UsersPage{Users: users}
+	if len(users) == 0 {
+		return page, nil
+	}
+	first, last := encodeCursor(&users[0], fields, filters.Query), encodeCursor(&users[len(users)-1], fields, filters.Query)
+	if query.Backward {
+		// the user the cursor points to comes after this page
+		page.NextCursor = last
+		if more {
+			page.PrevCursor = first
+		}
+	} else {
+		if more {
+			page.NextCursor = last
+		}
+		if query.Cursor != nil {
+			page.PrevCursor = first
+		}
+	}
+	return page, nil
+}
+
+// listSort is the requested sort, the search results are ranked when there is none
+func listSort(sort string, filters *model.RequestFilters) ([]model.SortField, error) {
+	fields, err := parseSort(sort)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 && filters.Query != "" {
+		fields = []model.SortField{{Column: model.SortRank}}
+	}
+	return fields, nil
+}
+
+// parseSort parses a sort like created_at,-email where a minus means the descending order
+func parseSort(sort string) ([]model.SortField, error) {
+	if sort == "" {
+		return nil, nil
+	}
+	var fields []model.SortField
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		field := model.SortField{Column: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if !model.UserSortColumns[field.Column] {
+			return nil, fmt.Errorf("%w: can not sort by %q", pkg.ErrorInvalidSort, field.Column)
+		}
+		if seen[field.Column] {
+			return nil, fmt.Errorf("%w: %s is used twice", pkg.ErrorInvalidSort, field.Column)
+		}
+		seen[field.Column] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func formatSort(fields []model.SortField) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field.Column
+		if field.Desc {
+			parts[i] = "-" + field.Column
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// encodeCursor keeps the id of the user and the values of the sort columns
+func encodeCursor(user *model.ResponseUser, fields []model.SortField, query string) string {
+	cursor := model.UserCursor{ID: user.ID, Sort: formatSort(fields), Query: queryHash(query)}
+	for _, field := range fields {
+		switch field.Column {
+		case "email":
+			cursor.Email = user.Email
+		case "role":
+			cursor.Role = user.Role
+		case "created_at":
+			createdAt := user.CreatedAt.Time
+			cursor.CreatedAt = &createdAt
+		case model.SortRank:
+			rank := model.SearchRank(user.Email, query)
+			cursor.Rank = &rank
+		}
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// queryHash identifies the search of a cursor without revealing it, it is empty without a search
+func queryHash(query string) string {
+	if query == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(query))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// decodeCursor rejects cursors made for another sort or search
+func decodeCursor(cursor string, fields []model.SortField, query string) (*model.UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, pkg.ErrorInvalidCursor
+	}
+	var decoded model.UserCursor
+	if err = json.Unmarshal(data, &decoded); err != nil || decoded.ID <= 0 {
+		return nil, pkg.ErrorInvalidCursor
+	}
+	if decoded.Sort != formatSort(fields) {
+		return nil, fmt.Errorf("%w: the cursor belongs to another sort", pkg.ErrorInvalidCursor)
+	}
+	if decoded.Query != queryHash(query) {
+		return nil, fmt.Errorf("%w: the cursor belongs to another search", pkg.ErrorInvalidCursor)
+	}
+	return &decoded, nil
+}
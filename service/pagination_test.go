@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/grpcClient"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	mock_repository "stlab.itechart-group.com/go/food_delivery/authentication_service/repository/mocks"
+	"testing"
+	"time"
+)
+
+func TestService_GetUsersPage(t *testing.T) {
+	type mockBehavior func(s *mock_repository.MockAppUser)
+	users := []model.ResponseUser{{ID: 3}, {ID: 4}}
+	failure := errors.New("repository failure")
+	createdAt := time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)
+	sort := []model.SortField{{Column: "created_at", Desc: true}}
+	testTable := []struct {
+		name          string
+		sort          string
+		after         string
+		before        string
+		limit         int
+		mockBehavior  mockBehavior
+		expectedPage  *model.UsersPage
+		expectedError error
+	}{
+		{
+			name: "First page",
+			mockBehavior: func(s *mock_repository.MockAppUser) {
+				s.EXPECT().GetUsersByCursor(gomock.Any(), &model.RequestFilters{}, &model.CursorQuery{Limit: DefaultPageLimit}).Return(users, true, nil)
+			},
+			expectedPage: &model.UsersPage{Users: users, NextCursor: encodeCursor(&users[1], nil, "")},
+		},
+		{
+			name:  "After cursor",
+			after: encodeCursor(&model.ResponseUser{ID: 2}, nil, ""),
+			limit: 1000,
+			mockBehavior: func(s *mock_repository.MockAppUser) {
+				s.EXPECT().GetUsersByCursor(gomock.Any(), &model.RequestFilters{}, &model.CursorQuery{Cursor: &model.UserCursor{ID: 2}, Limit: MaxPageLimit}).
+					Return(users, false, nil)
+			},
+			expectedPage: &model.UsersPage{Users: users, PrevCursor: encodeCursor(&users[0], nil, "")},
+		},
+		{
+			name:   "Before cursor",
+			before: encodeCursor(&model.ResponseUser{ID: 5}, nil, ""),
+			limit:  2,
+			mockBehavior: func(s *mock_repository.MockAppUser) {
+				s.EXPECT().GetUsersByCursor(gomock.Any(), &model.RequestFilters{}, &model.CursorQuery{Cursor: &model.UserCursor{ID: 5}, Backward: true, Limit: 2}).
+					Return(users, true, nil)
+			},
+			expectedPage: &model.UsersPage{Users: users, NextCursor: encodeCursor(&users[1], nil, ""), PrevCursor: encodeCursor(&users[0], nil, "")},
+		},
+		{
+			name:  "Empty page",
+			after: encodeCursor(&model.ResponseUser{ID: 9}, nil, ""),
+			mockBehavior: func(s *mock_repository.MockAppUser) {
+				s.EXPECT().GetUsersByCursor(gomock.Any(), &model.RequestFilters{}, gomock.Any()).Return([]model.ResponseUser{}, false, nil)
+			},
+			expectedPage: &model.UsersPage{Users: []model.ResponseUser{}},
+		},
+		{
+			name:  "Sorted",
+			sort:  "-created_at",
+			after: encodeCursor(&model.ResponseUser{ID: 2, CreatedAt: model.MyTime{Time: createdAt}}, sort, ""),
+			limit: 2,
+			mockBehavior: func(s *mock_repository.MockAppUser) {
+				s.EXPECT().GetUsersByCursor(gomock.Any(), &model.RequestFilters{}, &model.CursorQuery{
+					Cursor: &model.UserCursor{ID: 2, CreatedAt: &createdAt, Sort: "-created_at"},
+					Limit:  2,
+					Sort:   sort,
+				}).Return(users, true, nil)
+			},
+			expectedPage: &model.UsersPage{Users: users, NextCursor: encodeCursor(&users[1], sort, ""), PrevCursor: encodeCursor(&users[0], sort, "")},
+		},
+		{
+			name:          "Cursor of another sort",
+			sort:          "email",
+			after:         encodeCursor(&model.ResponseUser{ID: 2}, sort, ""),
+			mockBehavior:  func(s *mock_repository.MockAppUser) {},
+			expectedError: pkg.ErrorInvalidCursor,
+		},
+		{
+			name:          "Invalid sort",
+			sort:          "password",
+			mockBehavior:  func(s *mock_repository.MockAppUser) {},
+			expectedError: pkg.ErrorInvalidSort,
+		},
+		{
+			name:          "Invalid cursor",
+			after:         "not a cursor",
+			mockBehavior:  func(s *mock_repository.MockAppUser) {},
+			expectedError: pkg.ErrorInvalidCursor,
+		},
+		{
+			name:          "Both cursors",
+			after:         encodeCursor(&model.ResponseUser{ID: 2}, nil, ""),
+			before:        encodeCursor(&model.ResponseUser{ID: 5}, nil, ""),
+			mockBehavior:  func(s *mock_repository.MockAppUser) {},
+			expectedError: pkg.ErrorInvalidCursor,
+		},
+		{
+			name: "Repository failure",
+			mockBehavior: func(s *mock_repository.MockAppUser) {
+				s.EXPECT().GetUsersByCursor(gomock.Any(), &model.RequestFilters{}, gomock.Any()).Return(nil, false, failure)
+			},
+			expectedError: failure,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_repository.NewMockAppUser(c)
+			testCase.mockBehavior(auth)
+			logger := logging.GetLogger()
+			repo := &repository.Repository{AppUser: auth}
+
+			service := NewService(repo, grpcClient.NewFakeClient(), logger, Config{})
+			page, err := service.GetUsersPage(context.Background(), &model.RequestFilters{}, testCase.sort, testCase.after, testCase.before, testCase.limit)
+			//Assert
+			if testCase.expectedError != nil {
+				assert.ErrorIs(t, err, testCase.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, testCase.expectedPage, page)
+		})
+	}
+}
+
+func TestService_GetUsersPage_search(t *testing.T) {
+	//Init dependencies
+	c := gomock.NewController(t)
+	defer c.Finish()
+	auth := mock_repository.NewMockAppUser(c)
+	rank := []model.SortField{{Column: model.SortRank}}
+	auth.EXPECT().GetUsersByCursor(gomock.Any(), &model.RequestFilters{Query: "ivan"}, &model.CursorQuery{Limit: 2, Sort: rank}).
+		Return([]model.ResponseUser{{ID: 7, Email: "ivan@yandex.ru"}, {ID: 3, Email: "ivanov@yandex.ru"}}, true, nil)
+	service := NewService(&repository.Repository{AppUser: auth}, grpcClient.NewFakeClient(), logging.GetLogger(), Config{})
+
+	page, err := service.GetUsersPage(context.Background(), &model.RequestFilters{Query: "ivan"}, "", "", "", 2)
+	//Assert
+	assert.NoError(t, err)
+	cursor, err := decodeCursor(page.NextCursor, rank, "ivan")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cursor.ID)
+	assert.Equal(t, 1, *cursor.Rank)
+	_, err = decodeCursor(page.NextCursor, nil, "ivan")
+	assert.ErrorIs(t, err, pkg.ErrorInvalidCursor)
+	_, err = decodeCursor(page.NextCursor, rank, "petr")
+	assert.ErrorIs(t, err, pkg.ErrorInvalidCursor)
+	_, err = service.GetUsersPage(context.Background(), &model.RequestFilters{Query: "petr"}, "", page.NextCursor, "", 2)
+	assert.ErrorIs(t, err, pkg.ErrorInvalidCursor)
+}
+
+func TestParseSort(t *testing.T) {
+	fields, err := parseSort(" created_at,-email ,role")
+	assert.NoError(t, err)
+	assert.Equal(t, []model.SortField{{Column: "created_at"}, {Column: "email", Desc: true}, {Column: "role"}}, fields)
+	assert.Equal(t, "created_at,-email,role", formatSort(fields))
+
+	for _, invalid := range []string{"password", "email,-email", "created_at,", "--id"} {
+		_, err = parseSort(invalid)
+		assert.ErrorIs(t, err, pkg.ErrorInvalidSort)
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	cursor, err := decodeCursor(encodeCursor(&model.ResponseUser{ID: 42, Email: "test@yandex.ru"}, []model.SortField{{Column: "email"}}, ""), []model.SortField{{Column: "email"}}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.UserCursor{ID: 42, Email: "test@yandex.ru", Sort: "email"}, cursor)
+
+	for _, invalid := range []string{"", "!!!", "bnVsbA", "eyJpZCI6MH0"} {
+		_, err = decodeCursor(invalid, nil, "")
+		assert.ErrorIs(t, err, pkg.ErrorInvalidCursor)
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys a rate limit rule can count requests by
+const (
+	RateLimitByIP      = "ip"
+	RateLimitByEmail   = "email"
+	RateLimitByIPEmail = "ip_email"
+)
+
+// RateLimitRule allows Requests per Window for every key chosen by KeyBy
+type RateLimitRule struct {
+	Requests int
+	Window   time.Duration
+	KeyBy    string
+}
+
+// DefaultRateLimits are applied to routes without a configured rule
+var DefaultRateLimits = map[string]RateLimitRule{
+	"login":           {Requests: 10, Window: time.Minute, KeyBy: RateLimitByIPEmail},
+	"customer":        {Requests: 5, Window: time.Hour, KeyBy: RateLimitByIP},
+	"restorePassword": {Requests: 3, Window: time.Hour, KeyBy: RateLimitByIPEmail},
+	"resetPassword":   {Requests: 10, Window: time.Hour, KeyBy: RateLimitByIP},
+	"verify":          {Requests: 3, Window: time.Hour, KeyBy: RateLimitByIPEmail},
+	"login2fa":        {Requests: 10, Window: time.Minute, KeyBy: RateLimitByIP},
+	// the gRPC calls, the ip is the address of the calling service
+	"verifyCredentials": {Requests: 10, Window: time.Minute, KeyBy: RateLimitByEmail},
+	"userByEmail":       {Requests: 60, Window: time.Minute, KeyBy: RateLimitByIP},
+}
+
+// RateLimitEmailKey returns the key counting the requests of the email. The email is
+// hashed, so whatever a client sends as an email fits the store.
+func RateLimitEmailKey(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return "email:" + hex.EncodeToString(sum[:])
+}
+
+// ParseRateLimitRule reads a rule written as "requests/window/key", e.g. "10/1m/ip_email"
+func ParseRateLimitRule(value string) (RateLimitRule, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 3 {
+		return RateLimitRule{}, fmt.Errorf("rate limit rule %q must look like requests/window/key", value)
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests <= 0 {
+		return RateLimitRule{}, fmt.Errorf("rate limit rule %q: requests must be positive integer", value)
+	}
+	window, err := time.ParseDuration(parts[1])
+	if err != nil || window <= 0 {
+		return RateLimitRule{}, fmt.Errorf("rate limit rule %q: invalid window", value)
+	}
+	switch parts[2] {
+	case RateLimitByIP, RateLimitByEmail, RateLimitByIPEmail:
+	default:
+		return RateLimitRule{}, fmt.Errorf("rate limit rule %q: key must be one of ip, email, ip_email", value)
+	}
+	return RateLimitRule{Requests: requests, Window: window, KeyBy: parts[2]}, nil
+}
+
+// UnmarshalText reads the rule like ParseRateLimitRule, so that rules can be configured as text
+func (r *RateLimitRule) UnmarshalText(text []byte) error {
+	rule, err := ParseRateLimitRule(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rule
+	return nil
+}
+
+type RateLimitService struct {
+	repo   repository.Repository
+	logger logging.Logger
+	rules  map[string]RateLimitRule
+}
+
+func NewRateLimitService(repo repository.Repository, logger logging.Logger, rules map[string]RateLimitRule) *RateLimitService {
+	merged := make(map[string]RateLimitRule, len(DefaultRateLimits))
+	for route, rule := range DefaultRateLimits {
+		merged[route] = rule
+	}
+	for route, rule := range rules {
+		merged[route] = rule
+	}
+	return &RateLimitService{repo: repo, logger: logger, rules: merged}
+}
+
+func (r *RateLimitService) Rule(route string) (RateLimitRule, bool) {
+	rule, ok := r.rules[route]
+	return rule, ok
+}
+
+// Allow counts the request for every key and reports the most restrictive result.
+// A key the store fails to count is skipped, the error is returned only when no
+// key is counted.
+func (r *RateLimitService) Allow(ctx context.Context, route string, keys []string) (*model.RateLimitResult, error) {
+	rule, ok := r.rules[route]
+	if !ok {
+		return nil, fmt.Errorf("allow: no rate limit rule for %s", route)
+	}
+	result := &model.RateLimitResult{Allowed: true, Limit: rule.Requests, Remaining: rule.Requests}
+	var failure error
+	counted := 0
+	for _, key := range keys {
+		hits, resetAt, err := r.repo.RateLimit.HitRateLimit(ctx, route+":"+key, rule.Window)
+		if err != nil {
+			r.logger.WithContext(ctx).Errorf("Allow: rate limit of %s is not counted by %s:%s", route, key, err)
+			failure = err
+			continue
+		}
+		counted++
+		remaining := rule.Requests - hits
+		if remaining < 0 {
+			remaining = 0
+		}
+		exceeded := hits > rule.Requests
+		switch {
+		case exceeded && (result.Allowed || resetAt.After(result.ResetAt)):
+			// a blocked request can be retried only when every exceeded window is over
+			result.ResetAt = resetAt
+		case !exceeded && result.Allowed && (remaining < result.Remaining || result.ResetAt.IsZero()):
+			result.ResetAt = resetAt
+		}
+		if remaining < result.Remaining {
+			result.Remaining = remaining
+		}
+		if exceeded {
+			result.Allowed = false
+			r.logger.WithContext(ctx).Warnf("Allow: rate limit of %s exceeded by %s", route, key)
+		}
+	}
+	if counted == 0 && failure != nil {
+		return nil, failure
+	}
+	return result, nil
+}
+
+func (r *RateLimitService) CleanupRateLimits(ctx context.Context) (int64, error) {
+	deleted, err := r.repo.RateLimit.DeleteExpiredRateLimits(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if deleted != 0 {
+		r.logger.WithContext(ctx).Infof("CleanupRateLimits: %d expired rate limits deleted", deleted)
+	}
+	return deleted, nil
+}
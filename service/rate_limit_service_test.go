@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	mock_repository "stlab.itechart-group.com/go/food_delivery/authentication_service/repository/mocks"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestService_ParseRateLimitRule(t *testing.T) {
+	testTable := []struct {
+		name          string
+		input         string
+		expectedRule  RateLimitRule
+		expectedError bool
+	}{
+		{
+			name:         "OK",
+			input:        "10/1m/ip_email",
+			expectedRule: RateLimitRule{Requests: 10, Window: time.Minute, KeyBy: RateLimitByIPEmail},
+		},
+		{
+			name:          "Missing key",
+			input:         "10/1m",
+			expectedError: true,
+		},
+		{
+			name:          "Invalid requests",
+			input:         "0/1m/ip",
+			expectedError: true,
+		},
+		{
+			name:          "Invalid window",
+			input:         "10/minute/ip",
+			expectedError: true,
+		},
+		{
+			name:          "Invalid key",
+			input:         "10/1m/user",
+			expectedError: true,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			rule, err := ParseRateLimitRule(testCase.input)
+			if testCase.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedRule, rule)
+			}
+		})
+	}
+}
+
+func TestRateLimitEmailKey(t *testing.T) {
+	assert.Equal(t, RateLimitEmailKey("test@yandex.ru"), RateLimitEmailKey(" Test@Yandex.ru"))
+	assert.NotEqual(t, RateLimitEmailKey("test@yandex.ru"), RateLimitEmailKey("other@yandex.ru"))
+	// the key of any email fits the varchar(255) of the store with the route before it
+	assert.Len(t, RateLimitEmailKey(strings.Repeat("a", 1000)+"\x00@yandex.ru"), len("email:")+64)
+}
+
+func TestService_Allow(t *testing.T) {
+	ipResetAt := time.Date(2022, 03, 11, 0, 1, 0, 0, time.UTC)
+	emailResetAt := time.Date(2022, 03, 11, 0, 2, 0, 0, time.UTC)
+	rules := map[string]RateLimitRule{"login": {Requests: 3, Window: time.Minute, KeyBy: RateLimitByIPEmail}}
+	emailKey := RateLimitEmailKey("test@yandex.ru")
+
+	type mockBehavior func(s *mock_repository.MockRateLimit)
+	testTable := []struct {
+		name           string
+		route          string
+		mockBehavior   mockBehavior
+		expectedResult *model.RateLimitResult
+		expectedError  bool
+	}{
+		{
+			name:  "Allowed",
+			route: "login",
+			mockBehavior: func(s *mock_repository.MockRateLimit) {
+				s.EXPECT().HitRateLimit(gomock.Any(), "login:ip:127.0.0.1", time.Minute).Return(1, ipResetAt, nil)
+				s.EXPECT().HitRateLimit(gomock.Any(), "login:"+emailKey, time.Minute).Return(2, emailResetAt, nil)
+			},
+			expectedResult: &model.RateLimitResult{Allowed: true, Limit: 3, Remaining: 1, ResetAt: emailResetAt},
+		},
+		{
+			name:  "Exceeded by one key",
+			route: "login",
+			mockBehavior: func(s *mock_repository.MockRateLimit) {
+				s.EXPECT().HitRateLimit(gomock.Any(), "login:ip:127.0.0.1", time.Minute).Return(4, ipResetAt, nil)
+				s.EXPECT().HitRateLimit(gomock.Any(), "login:"+emailKey, time.Minute).Return(1, emailResetAt, nil)
+			},
+			expectedResult: &model.RateLimitResult{Allowed: false, Limit: 3, Remaining: 0, ResetAt: ipResetAt},
+		},
+		{
+			name:  "Exceeded by both keys",
+			route: "login",
+			mockBehavior: func(s *mock_repository.MockRateLimit) {
+				s.EXPECT().HitRateLimit(gomock.Any(), "login:ip:127.0.0.1", time.Minute).Return(5, ipResetAt, nil)
+				s.EXPECT().HitRateLimit(gomock.Any(), "login:"+emailKey, time.Minute).Return(4, emailResetAt, nil)
+			},
+			expectedResult: &model.RateLimitResult{Allowed: false, Limit: 3, Remaining: 0, ResetAt: emailResetAt},
+		},
+		{
+			name:  "Store failure of one key",
+			route: "login",
+			mockBehavior: func(s *mock_repository.MockRateLimit) {
+				s.EXPECT().HitRateLimit(gomock.Any(), "login:ip:127.0.0.1", time.Minute).Return(4, ipResetAt, nil)
+				s.EXPECT().HitRateLimit(gomock.Any(), "login:"+emailKey, time.Minute).Return(0, time.Time{}, errors.New("store failure"))
+			},
+			expectedResult: &model.RateLimitResult{Allowed: false, Limit: 3, Remaining: 0, ResetAt: ipResetAt},
+		},
+		{
+			name:  "Store failure",
+			route: "login",
+			mockBehavior: func(s *mock_repository.MockRateLimit) {
+				s.EXPECT().HitRateLimit(gomock.Any(), "login:ip:127.0.0.1", time.Minute).Return(0, time.Time{}, errors.New("store failure"))
+				s.EXPECT().HitRateLimit(gomock.Any(), "login:"+emailKey, time.Minute).Return(0, time.Time{}, errors.New("store failure"))
+			},
+			expectedError: true,
+		},
+		{
+			name:          "Unknown route",
+			route:         "unknown",
+			mockBehavior:  func(s *mock_repository.MockRateLimit) {},
+			expectedError: true,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			store := mock_repository.NewMockRateLimit(c)
+			testCase.mockBehavior(store)
+			service := NewRateLimitService(repository.Repository{RateLimit: store}, logging.GetLogger(), rules)
+			result, err := service.Allow(context.Background(), testCase.route, []string{"ip:127.0.0.1", emailKey})
+			//Assert
+			if testCase.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedResult, result)
+			}
+		})
+	}
+}
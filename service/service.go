@@ -1,38 +1,150 @@
 package service
 
 import (
+	"context"
 	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
-	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/grpcClient"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/mail"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	"time"
 )
 
 //go:generate mockgen -source=service.go -destination=mocks/service_mock.go
 
 type AppUser interface {
-	GetUser(id int) (*model.ResponseUser, error)
-	GetUsers(page int, limit int, filters *model.RequestFilters) ([]model.ResponseUser, int, error)
-	CreateCustomer(user *model.CreateCustomer) (*authProto.GeneratedTokens, int, error)
-	CreateStaff(user *model.CreateStaff) (int, error)
-	UpdateUser(user *model.UpdateUser) error
-	DeleteUserByID(id int) (int, error)
-	AuthUser(email string, password string) (*authProto.GeneratedTokens, int, error)
+	GetUser(ctx context.Context, id int) (*model.ResponseUser, error)
+	GetUsers(ctx context.Context, page int, limit int, filters *model.RequestFilters, sort string) (*model.UsersPage, error)
+	GetUsersPage(ctx context.Context, filters *model.RequestFilters, sort string, after string, before string, limit int) (*model.UsersPage, error)
+	GetUsersByIds(ctx context.Context, ids []int) ([]model.ResponseUser, error)
+	GetUserByEmail(ctx context.Context, email string) (*model.ResponseUser, error)
+	CreateCustomer(ctx context.Context, user *model.CreateCustomer) (*authProto.GeneratedTokens, int, error)
+	CreateStaff(ctx context.Context, user *model.CreateStaff) (int, error)
+	UpdateUser(ctx context.Context, user *model.UpdateUser) error
+	DeleteUserByID(ctx context.Context, id int) (int, error)
+	RestoreUser(ctx context.Context, id int) (int, error)
+	AuthUser(ctx context.Context, email string, password string) (*authProto.GeneratedTokens, int, error)
+	AuthUserTwoFactor(ctx context.Context, challenge string, code string) (*model.TwoFactorTokens, int, error)
+	StartTwoFactorEnrollment(ctx context.Context, token string) (*model.TwoFactorEnrollment, error)
+	VerifyCredentials(ctx context.Context, email string, password string) (*model.ResponseUser, error)
+	RefreshTokens(ctx context.Context, refreshToken string) (*authProto.GeneratedTokens, error)
 	HashPassword(password string, rounds int) (string, error)
 	CheckPasswordHash(password string, hash string) bool
-	CheckInputRole(role string) error
-	ParseToken(token string) (*authProto.UserRole, error)
+	CheckInputRole(ctx context.Context, role string) error
+	ParseToken(ctx context.Context, token string) (*authProto.UserRole, error)
 	CheckRole(neededRoles []string, givenRole string) error
 	CheckRights(neededPerms []string, givenPerms string) error
-	RestorePassword(restore *model.RestorePassword) error
+	RestorePassword(ctx context.Context, restore *model.RestorePassword) error
+	ResetPassword(ctx context.Context, reset *model.ResetPassword) error
+	CleanupPasswordResets(ctx context.Context) (int64, error)
+	UnlockUser(ctx context.Context, id int) (int, error)
+	VerifyEmail(ctx context.Context, token string) (int, error)
+	ResendVerification(ctx context.Context, email string) error
+}
+
+type TokenRevocation interface {
+	Logout(ctx context.Context, userId int, accessToken string, refreshToken string) error
+	LogoutAll(ctx context.Context, userId int) error
+	CheckTokenRevoked(ctx context.Context, userId int, token string) error
+	CleanupRevokedTokens(ctx context.Context) (int64, error)
+}
+
+type TwoFactor interface {
+	EnrollTOTP(ctx context.Context, userId int) (*model.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userId int, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userId int, code string) error
+	GetTwoFactorRoles(ctx context.Context) ([]string, error)
+	SetTwoFactorRoles(ctx context.Context, roles []string) error
+}
+
+type RateLimiter interface {
+	Rule(route string) (RateLimitRule, bool)
+	Allow(ctx context.Context, route string, keys []string) (*model.RateLimitResult, error)
+	CleanupRateLimits(ctx context.Context) (int64, error)
+}
+
+type Health interface {
+	Ready(ctx context.Context) *model.Health
+	Drain()
+}
+
+type Audit interface {
+	GetAuditEvents(ctx context.Context, filters *model.AuditFilters, page int, limit int) (*model.AuditPage, error)
+	ExportAuditEvents(ctx context.Context, fromID int64, toID int64) (*model.AuditExport, error)
+	Checkpoint(ctx context.Context) (*model.AuditCheckpoint, error)
+	VerifyAuditChain(ctx context.Context) (*model.AuditVerification, error)
 }
 
 type Service struct {
 	AppUser
+	TokenRevocation
+	RateLimiter
+	TwoFactor
+	Health
+	Audit
+	Timeouts TimeoutPolicy
+	mailer   *mail.Mailer
+	logger   logging.Logger
 }
 
-func NewService(rep *repository.Repository, grpcCli *grpcClient.GRPCClient, logger logging.Logger) *Service {
+// Config holds tunables of the service layer, zero values fall back to defaults
+type Config struct {
+	Lockout           LockoutPolicy
+	PasswordReset     PasswordResetPolicy
+	EmailVerification EmailVerificationPolicy
+	TwoFactor         TwoFactorPolicy
+	RateLimits        map[string]RateLimitRule
+	Timeouts          TimeoutPolicy
+	Audit             AuditPolicy
+	Mail              mail.Config
+	BcryptCost        int
+}
+
+// NewService builds the services on top of authCli, which is either the client
+// of the remote auth service or the local issuer
+func NewService(rep *repository.Repository, authCli authProto.AuthClient, logger logging.Logger, cfg Config) *Service {
+	userService := NewUserService(*rep, authCli, logger, cfg)
 	return &Service{
-		AppUser: NewUserService(*rep, grpcCli, logger),
+		AppUser:         tracedUsers{AppUser: userService},
+		TokenRevocation: NewTokenService(*rep, logger),
+		RateLimiter:     NewRateLimitService(*rep, logger, cfg.RateLimits),
+		TwoFactor:       NewTwoFactorService(*rep, logger, cfg),
+		Health:          NewHealthService(*rep, authCli, logger),
+		Audit:           NewAuditService(*rep, logger, cfg.Audit),
+		Timeouts:        cfg.Timeouts.withDefaults(),
+		mailer:          userService.mailer,
+		logger:          logger,
+	}
+}
+
+// RunCleanup periodically removes expired security records and signs the head of
+// the audit chain until ctx is done, a run in progress is finished before it
+// returns. A run which takes longer than the interval is cancelled.
+func (s *Service) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.cleanup(context.Background(), interval)
+		}
+	}
+}
+
+// Shutdown waits for the emails being sent until ctx is done
+func (s *Service) Shutdown(ctx context.Context) error {
+	return s.mailer.Wait(ctx)
+}
+
+func (s *Service) cleanup(ctx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	_, _ = s.TokenRevocation.CleanupRevokedTokens(ctx)
+	_, _ = s.RateLimiter.CleanupRateLimits(ctx)
+	_, _ = s.AppUser.CleanupPasswordResets(ctx)
+	if _, err := s.Audit.Checkpoint(ctx); err != nil {
+		s.logger.WithContext(ctx).Errorf("cleanup: audit checkpoint is not signed:%s", err)
 	}
 }
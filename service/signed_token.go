@@ -0,0 +1,65 @@
+package service
+
+import (
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidSignedToken = errors.New("signed token is invalid or expired")
+
+// signFields builds a stateless token of the form payload.signature, the payload
+// carries the fields and the expiration time. Fields must not contain line breaks
+func signFields(secret string, fields []string, expiresAt time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(
+		strings.Join(append(fields, strconv.FormatInt(expiresAt.Unix(), 10)), "\n")))
+	return payload + "." + signature(secret, payload)
+}
+
+// parseFields checks the signature and expiration of the token and returns its fields
+func parseFields(secret string, token string, count int) ([]string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(signature(secret, parts[0]))) {
+		return nil, errInvalidSignedToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errInvalidSignedToken
+	}
+	fields := strings.Split(string(payload), "\n")
+	if len(fields) != count+1 {
+		return nil, errInvalidSignedToken
+	}
+	expiresAt, err := strconv.ParseInt(fields[count], 10, 64)
+	if err != nil || time.Now().Unix() >= expiresAt {
+		return nil, errInvalidSignedToken
+	}
+	return fields[:count], nil
+}
+
+func signature(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// randomSecret is used when no signing secret is configured
+func randomSecret() string {
+	b := make([]byte, 32)
+	_, _ = cryptorand.Read(b)
+	return string(b)
+}
+
+// generateNonce returns a random value which lets a signed token be used only once
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := cryptorand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
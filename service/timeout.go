@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/protobuf/ptypes/empty"
+	"google.golang.org/grpc"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
+	"strings"
+	"time"
+)
+
+// TimeoutPolicy sets the deadlines of the work done for a client. Request bounds an
+// HTTP request or a gRPC call unless Operations has a deadline for it, the operations
+// are named like "GET /users/" or "/users.Users/ListUsersByRole". Auth bounds every
+// call to the auth service made within a request.
+type TimeoutPolicy struct {
+	Request    time.Duration
+	Auth       time.Duration
+	Operations OperationTimeouts
+}
+
+// OperationTimeouts are the deadlines of the operations by their names
+type OperationTimeouts map[string]time.Duration
+
+// UnmarshalText reads the deadlines like ParseTimeouts
+func (o *OperationTimeouts) UnmarshalText(text []byte) error {
+	timeouts, err := ParseTimeouts(string(text))
+	if err != nil {
+		return err
+	}
+	*o = timeouts
+	return nil
+}
+
+func (p TimeoutPolicy) withDefaults() TimeoutPolicy {
+	if p.Request <= 0 {
+		p.Request = 10 * time.Second
+	}
+	if p.Auth <= 0 {
+		p.Auth = 5 * time.Second
+	}
+	return p
+}
+
+// Timeout returns the deadline of the operation, zero means there is none
+func (p TimeoutPolicy) Timeout(operation string) time.Duration {
+	if timeout, ok := p.Operations[operation]; ok {
+		return timeout
+	}
+	return p.Request
+}
+
+// ParseTimeouts reads deadlines of operations written as "operation=duration" and
+// separated by commas, e.g. "GET /users/=30s,/users.Users/ListUsersByRole=20s"
+func ParseTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, part := range strings.Split(value, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		i := strings.LastIndex(part, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("timeout %q must look like operation=duration", part)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(part[i+1:]))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("timeout %q: invalid duration", part)
+		}
+		timeouts[strings.TrimSpace(part[:i])] = timeout
+	}
+	return timeouts, nil
+}
+
+// authClientWithTimeout bounds every call to the auth service by timeout,
+// a shorter deadline of the caller is kept
+type authClientWithTimeout struct {
+	authProto.AuthClient
+	timeout time.Duration
+}
+
+func (a authClientWithTimeout) GetUserWithRights(ctx context.Context, in *authProto.AccessToken, opts ...grpc.CallOption) (*authProto.UserRole, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	return a.AuthClient.GetUserWithRights(ctx, in, opts...)
+}
+
+func (a authClientWithTimeout) BindUserAndRole(ctx context.Context, in *authProto.User, opts ...grpc.CallOption) (*authProto.ResultBinding, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	return a.AuthClient.BindUserAndRole(ctx, in, opts...)
+}
+
+func (a authClientWithTimeout) TokenGenerationByRefresh(ctx context.Context, in *authProto.RefreshToken, opts ...grpc.CallOption) (*authProto.GeneratedTokens, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	return a.AuthClient.TokenGenerationByRefresh(ctx, in, opts...)
+}
+
+func (a authClientWithTimeout) TokenGenerationByUserId(ctx context.Context, in *authProto.User, opts ...grpc.CallOption) (*authProto.GeneratedTokens, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	return a.AuthClient.TokenGenerationByUserId(ctx, in, opts...)
+}
+
+func (a authClientWithTimeout) GetAllRoles(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*authProto.Roles, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	return a.AuthClient.GetAllRoles(ctx, in, opts...)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/grpcClient"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	mock_repository "stlab.itechart-group.com/go/food_delivery/authentication_service/repository/mocks"
+	"testing"
+	"time"
+)
+
+func TestParseTimeouts(t *testing.T) {
+	timeouts, err := ParseTimeouts("GET /users/=30s, /users.Users/ListUsersByRole=1m,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"GET /users/": 30 * time.Second, "/users.Users/ListUsersByRole": time.Minute}, timeouts)
+
+	timeouts, err = ParseTimeouts("")
+	assert.NoError(t, err)
+	assert.Empty(t, timeouts)
+
+	for _, invalid := range []string{"GET /users/", "=1s", "GET /users/=soon", "GET /users/=-1s"} {
+		_, err = ParseTimeouts(invalid)
+		assert.Error(t, err)
+	}
+}
+
+func TestTimeoutPolicy_Timeout(t *testing.T) {
+	policy := TimeoutPolicy{Operations: map[string]time.Duration{"GET /users/": time.Minute}}.withDefaults()
+	assert.Equal(t, time.Minute, policy.Timeout("GET /users/"))
+	assert.Equal(t, 10*time.Second, policy.Timeout("POST /users/login"))
+	assert.Equal(t, 5*time.Second, policy.Auth)
+}
+
+// deadlineClient records the deadline of the calls to the auth service
+type deadlineClient struct {
+	*grpcClient.FakeClient
+	deadline time.Time
+}
+
+func (d *deadlineClient) GetUserWithRights(ctx context.Context, in *authProto.AccessToken, opts ...grpc.CallOption) (*authProto.UserRole, error) {
+	d.deadline, _ = ctx.Deadline()
+	return d.FakeClient.GetUserWithRights(ctx, in, opts...)
+}
+
+func TestService_authTimeout(t *testing.T) {
+	authCli := &deadlineClient{FakeClient: grpcClient.NewFakeClient()}
+	service := NewUserService(repository.Repository{}, authCli, logging.GetLogger(), Config{Timeouts: TimeoutPolicy{Auth: time.Minute}})
+
+	_, _ = service.ParseToken(context.Background(), "token")
+	//Assert
+	assert.WithinDuration(t, time.Now().Add(time.Minute), authCli.deadline, time.Second)
+
+	// a shorter deadline of the request is kept
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, _ = service.ParseToken(ctx, "token")
+	expected, _ := ctx.Deadline()
+	assert.Equal(t, expected, authCli.deadline)
+}
+
+func TestService_cancelled(t *testing.T) {
+	//Init dependencies
+	c := gomock.NewController(t)
+	defer c.Finish()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo := mock_repository.NewMockAppUser(c)
+	repo.EXPECT().CreateStaff(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, *model.CreateStaff, *model.AuditEvent) (int, error) {
+		// the client goes away while the user is being saved
+		cancel()
+		return 1, nil
+	})
+	authCli := grpcClient.NewFakeClient("Courier")
+	service := NewUserService(repository.Repository{AppUser: repo}, authCli, logging.GetLogger(), Config{})
+
+	_, err := service.CreateStaff(ctx, &model.CreateStaff{Email: "test@yandex.ru", Role: "Courier"})
+	//Assert
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	assert.True(t, errors.As(err, &grpcErr))
+	assert.Equal(t, codes.Canceled, grpcErr.GRPCStatus().Code())
+	_, bound := authCli.Binding(1)
+	assert.False(t, bound)
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxTokenTTL is the longest lifetime of a token issued by the auth service,
+// revocations older than that can be safely forgotten
+const MaxTokenTTL = 30 * 24 * time.Hour
+
+type TokenService struct {
+	repo   repository.Repository
+	logger logging.Logger
+}
+
+func NewTokenService(repo repository.Repository, logger logging.Logger) *TokenService {
+	return &TokenService{repo: repo, logger: logger}
+}
+
+// Logout revokes the access token and, if given, the refresh token of the
+// session. The refresh token has to belong to the user.
+func (t *TokenService) Logout(ctx context.Context, userId int, accessToken string, refreshToken string) error {
+	expiresAt := time.Now().UTC().Add(MaxTokenTTL)
+	if claims, err := parseTokenClaims(accessToken); err == nil {
+		expiresAt = claims.ExpiresAt
+	}
+	if err := t.repo.TokenRevocation.RevokeToken(ctx, userId, hashToken(accessToken), expiresAt); err != nil {
+		return err
+	}
+	if refreshToken == "" {
+		return nil
+	}
+	claims, err := parseTokenClaims(refreshToken)
+	if err != nil || claims.UserId != userId {
+		t.logger.WithContext(ctx).Warnf("Logout: refresh token does not belong to user (id = %d)", userId)
+		return fmt.Errorf("logout:%w", pkg.ErrorForeignToken)
+	}
+	return t.repo.TokenRevocation.RevokeToken(ctx, userId, hashToken(refreshToken), claims.ExpiresAt)
+}
+
+// LogoutAll revokes every token of the user issued so far
+func (t *TokenService) LogoutAll(ctx context.Context, userId int) error {
+	return revokeUserTokens(ctx, t.repo, userId, newAuditEvent(ctx, model.AuditTokensRevoked))
+}
+
+// CheckTokenRevoked rejects the revoked tokens and the ones without an issue
+// time, which can not be checked against the revocations of all user tokens
+func (t *TokenService) CheckTokenRevoked(ctx context.Context, userId int, token string) error {
+	claims, err := parseTokenClaims(token)
+	if err != nil {
+		t.logger.WithContext(ctx).Warnf("CheckTokenRevoked: token of user (id = %d):%s", userId, err)
+		return fmt.Errorf("checkTokenRevoked:%w", pkg.ErrorInvalidToken)
+	}
+	revoked, err := t.repo.TokenRevocation.IsTokenRevoked(ctx, userId, hashToken(token), claims.IssuedAt)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		t.logger.WithContext(ctx).Warnf("CheckTokenRevoked: revoked token of user (id = %d) was used", userId)
+		return pkg.ErrorTokenRevoked
+	}
+	return nil
+}
+
+func (t *TokenService) CleanupRevokedTokens(ctx context.Context) (int64, error) {
+	deleted, err := t.repo.TokenRevocation.DeleteExpiredRevocations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if deleted != 0 {
+		t.logger.WithContext(ctx).Infof("CleanupRevokedTokens: %d expired revocations deleted", deleted)
+	}
+	return deleted, nil
+}
+
+func revokeUserTokens(ctx context.Context, repo repository.Repository, userId int, event *model.AuditEvent) error {
+	// JWT timestamps have a one second precision, so the cutoff is rounded up to
+	// the next second to revoke every token issued before it. Tokens issued up to
+	// the cutoff are revoked too, a login right after the revocation may have to
+	// be retried a second later.
+	cutoff := time.Now().UTC().Truncate(time.Second).Add(time.Second)
+	return repo.TokenRevocation.RevokeUserTokens(ctx, userId, cutoff, cutoff.Add(MaxTokenTTL), event)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenClaims are the claims of a JWT the revocations rely on
+type tokenClaims struct {
+	UserId    int
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// parseTokenClaims reads the claims of a JWT without verifying it, the token is
+// expected to be verified by the auth service already. A token without iat is
+// an error, a missing exp is taken as the longest lifetime.
+func parseTokenClaims(token string) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("parseTokenClaims: token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("parseTokenClaims:%w", err)
+	}
+	var claims struct {
+		UserId    int     `json:"user_id"`
+		Subject   string  `json:"sub"`
+		IssuedAt  float64 `json:"iat"`
+		ExpiresAt float64 `json:"exp"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("parseTokenClaims:%w", err)
+	}
+	if claims.IssuedAt == 0 {
+		return nil, errors.New("parseTokenClaims: token has no iat claim")
+	}
+	result := &tokenClaims{
+		UserId:    claims.UserId,
+		IssuedAt:  time.Unix(int64(claims.IssuedAt), 0).UTC(),
+		ExpiresAt: time.Now().UTC().Add(MaxTokenTTL),
+	}
+	if result.UserId == 0 {
+		result.UserId, _ = strconv.Atoi(claims.Subject)
+	}
+	if claims.ExpiresAt != 0 {
+		result.ExpiresAt = time.Unix(int64(claims.ExpiresAt), 0).UTC()
+	}
+	return result, nil
+}
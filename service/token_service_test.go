@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	mock_repository "stlab.itechart-group.com/go/food_delivery/authentication_service/repository/mocks"
+	"testing"
+	"time"
+)
+
+func testJWT(issuedAt, expiresAt time.Time) string {
+	return testUserJWT(1, issuedAt, expiresAt)
+}
+
+func testUserJWT(userId int, issuedAt, expiresAt time.Time) string {
+	payload := fmt.Sprintf(`{"user_id":%d,"iat":%d,"exp":%d}`, userId, issuedAt.Unix(), expiresAt.Unix())
+	return "header." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+}
+
+func TestService_Logout(t *testing.T) {
+	issuedAt := time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)
+	expiresAt := issuedAt.Add(time.Hour)
+	accessToken := testJWT(issuedAt, expiresAt)
+	refreshToken := testJWT(issuedAt, expiresAt.Add(time.Hour))
+
+	type mockBehavior func(s *mock_repository.MockTokenRevocation)
+	testTable := []struct {
+		name          string
+		refreshToken  string
+		mockBehavior  mockBehavior
+		expectedError error
+	}{
+		{
+			name:         "OK",
+			refreshToken: refreshToken,
+			mockBehavior: func(s *mock_repository.MockTokenRevocation) {
+				s.EXPECT().RevokeToken(gomock.Any(), 1, hashToken(accessToken), expiresAt).Return(nil)
+				s.EXPECT().RevokeToken(gomock.Any(), 1, hashToken(refreshToken), expiresAt.Add(time.Hour)).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name: "OK without refresh token",
+			mockBehavior: func(s *mock_repository.MockTokenRevocation) {
+				s.EXPECT().RevokeToken(gomock.Any(), 1, hashToken(accessToken), expiresAt).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:         "Refresh token of another user",
+			refreshToken: testUserJWT(2, issuedAt, expiresAt),
+			mockBehavior: func(s *mock_repository.MockTokenRevocation) {
+				s.EXPECT().RevokeToken(gomock.Any(), 1, hashToken(accessToken), expiresAt).Return(nil)
+			},
+			expectedError: fmt.Errorf("logout:%w", pkg.ErrorForeignToken),
+		},
+		{
+			name:         "Refresh token is not a JWT",
+			refreshToken: "opaque",
+			mockBehavior: func(s *mock_repository.MockTokenRevocation) {
+				s.EXPECT().RevokeToken(gomock.Any(), 1, hashToken(accessToken), expiresAt).Return(nil)
+			},
+			expectedError: fmt.Errorf("logout:%w", pkg.ErrorForeignToken),
+		},
+		{
+			name:         "Repository failure",
+			refreshToken: refreshToken,
+			mockBehavior: func(s *mock_repository.MockTokenRevocation) {
+				s.EXPECT().RevokeToken(gomock.Any(), 1, hashToken(accessToken), expiresAt).Return(errors.New("repository failure"))
+			},
+			expectedError: errors.New("repository failure"),
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			revocation := mock_repository.NewMockTokenRevocation(c)
+			testCase.mockBehavior(revocation)
+			logger := logging.GetLogger()
+			service := NewTokenService(repository.Repository{TokenRevocation: revocation}, logger)
+			err := service.Logout(context.Background(), 1, accessToken, testCase.refreshToken)
+			//Assert
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestService_LogoutAll(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	revocation := mock_repository.NewMockTokenRevocation(c)
+	now := time.Now()
+	revocation.EXPECT().RevokeUserTokens(gomock.Any(), 1, gomock.Any(), gomock.Any(), auditAction(model.AuditTokensRevoked)).
+		DoAndReturn(func(_ context.Context, userId int, revokedAt time.Time, expiresAt time.Time, _ *model.AuditEvent) error {
+			assert.Equal(t, MaxTokenTTL, expiresAt.Sub(revokedAt))
+			assert.Equal(t, revokedAt, revokedAt.Truncate(time.Second))
+			// rounded up, a token issued in the second of the revocation is revoked
+			assert.True(t, revokedAt.After(now.Truncate(time.Second)))
+			return nil
+		})
+	service := NewTokenService(repository.Repository{TokenRevocation: revocation}, logging.GetLogger())
+	assert.NoError(t, service.LogoutAll(context.Background(), 1))
+}
+
+func TestService_CheckTokenRevoked(t *testing.T) {
+	issuedAt := time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)
+	token := testJWT(issuedAt, issuedAt.Add(time.Hour))
+
+	type mockBehavior func(s *mock_repository.MockTokenRevocation, token string)
+	testTable := []struct {
+		name          string
+		token         string
+		mockBehavior  mockBehavior
+		expectedError error
+	}{
+		{
+			name:  "Active token",
+			token: token,
+			mockBehavior: func(s *mock_repository.MockTokenRevocation, token string) {
+				s.EXPECT().IsTokenRevoked(gomock.Any(), 1, hashToken(token), issuedAt).Return(false, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:  "Revoked token",
+			token: token,
+			mockBehavior: func(s *mock_repository.MockTokenRevocation, token string) {
+				s.EXPECT().IsTokenRevoked(gomock.Any(), 1, hashToken(token), issuedAt).Return(true, nil)
+			},
+			expectedError: pkg.ErrorTokenRevoked,
+		},
+		{
+			name:          "Not a JWT",
+			token:         "opaque",
+			mockBehavior:  func(s *mock_repository.MockTokenRevocation, token string) {},
+			expectedError: fmt.Errorf("checkTokenRevoked:%w", pkg.ErrorInvalidToken),
+		},
+		{
+			name:          "Token without iat",
+			token:         "header." + base64.RawURLEncoding.EncodeToString([]byte(`{"user_id":1}`)) + ".signature",
+			mockBehavior:  func(s *mock_repository.MockTokenRevocation, token string) {},
+			expectedError: fmt.Errorf("checkTokenRevoked:%w", pkg.ErrorInvalidToken),
+		},
+		{
+			name:  "Repository failure",
+			token: token,
+			mockBehavior: func(s *mock_repository.MockTokenRevocation, token string) {
+				s.EXPECT().IsTokenRevoked(gomock.Any(), 1, hashToken(token), issuedAt).Return(false, errors.New("repository failure"))
+			},
+			expectedError: errors.New("repository failure"),
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			revocation := mock_repository.NewMockTokenRevocation(c)
+			testCase.mockBehavior(revocation, testCase.token)
+			logger := logging.GetLogger()
+			service := NewTokenService(repository.Repository{TokenRevocation: revocation}, logger)
+			err := service.CheckTokenRevoked(context.Background(), 1, testCase.token)
+			//Assert
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
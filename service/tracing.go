@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/tracing"
+)
+
+// tracedUsers records a span for every call of the user service, the methods
+// without a context take no time worth a span of their own
+type tracedUsers struct {
+	AppUser
+}
+
+func (t tracedUsers) GetUser(ctx context.Context, id int) (user *model.ResponseUser, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUser")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.GetUser(ctx, id)
+}
+
+func (t tracedUsers) GetUsers(ctx context.Context, page int, limit int, filters *model.RequestFilters, sort string) (users *model.UsersPage, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUsers")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.GetUsers(ctx, page, limit, filters, sort)
+}
+
+func (t tracedUsers) GetUsersPage(ctx context.Context, filters *model.RequestFilters, sort string, after string, before string, limit int) (users *model.UsersPage, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUsersPage")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.GetUsersPage(ctx, filters, sort, after, before, limit)
+}
+
+func (t tracedUsers) GetUsersByIds(ctx context.Context, ids []int) (users []model.ResponseUser, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUsersByIds")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.GetUsersByIds(ctx, ids)
+}
+
+func (t tracedUsers) GetUserByEmail(ctx context.Context, email string) (user *model.ResponseUser, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserByEmail")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.GetUserByEmail(ctx, email)
+}
+
+func (t tracedUsers) CreateCustomer(ctx context.Context, user *model.CreateCustomer) (tokens *authProto.GeneratedTokens, id int, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.CreateCustomer")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.CreateCustomer(ctx, user)
+}
+
+func (t tracedUsers) CreateStaff(ctx context.Context, user *model.CreateStaff) (id int, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.CreateStaff")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.CreateStaff(ctx, user)
+}
+
+func (t tracedUsers) UpdateUser(ctx context.Context, user *model.UpdateUser) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateUser")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.UpdateUser(ctx, user)
+}
+
+func (t tracedUsers) DeleteUserByID(ctx context.Context, id int) (deleted int, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUserByID")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.DeleteUserByID(ctx, id)
+}
+
+func (t tracedUsers) AuthUser(ctx context.Context, email string, password string) (tokens *authProto.GeneratedTokens, id int, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.AuthUser")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.AuthUser(ctx, email, password)
+}
+
+func (t tracedUsers) AuthUserTwoFactor(ctx context.Context, challenge string, code string) (tokens *model.TwoFactorTokens, id int, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.AuthUserTwoFactor")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.AuthUserTwoFactor(ctx, challenge, code)
+}
+
+func (t tracedUsers) StartTwoFactorEnrollment(ctx context.Context, token string) (enrollment *model.TwoFactorEnrollment, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.StartTwoFactorEnrollment")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.StartTwoFactorEnrollment(ctx, token)
+}
+
+func (t tracedUsers) VerifyCredentials(ctx context.Context, email string, password string) (user *model.ResponseUser, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.VerifyCredentials")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.VerifyCredentials(ctx, email, password)
+}
+
+func (t tracedUsers) RefreshTokens(ctx context.Context, refreshToken string) (tokens *authProto.GeneratedTokens, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.RefreshTokens")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.RefreshTokens(ctx, refreshToken)
+}
+
+func (t tracedUsers) CheckInputRole(ctx context.Context, role string) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.CheckInputRole")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.CheckInputRole(ctx, role)
+}
+
+func (t tracedUsers) ParseToken(ctx context.Context, token string) (role *authProto.UserRole, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ParseToken")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.ParseToken(ctx, token)
+}
+
+func (t tracedUsers) RestorePassword(ctx context.Context, restore *model.RestorePassword) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.RestorePassword")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.RestorePassword(ctx, restore)
+}
+
+func (t tracedUsers) ResetPassword(ctx context.Context, reset *model.ResetPassword) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ResetPassword")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.ResetPassword(ctx, reset)
+}
+
+func (t tracedUsers) CleanupPasswordResets(ctx context.Context) (deleted int64, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.CleanupPasswordResets")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.CleanupPasswordResets(ctx)
+}
+
+func (t tracedUsers) RestoreUser(ctx context.Context, id int) (restored int, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.RestoreUser")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.RestoreUser(ctx, id)
+}
+
+func (t tracedUsers) UnlockUser(ctx context.Context, id int) (unlocked int, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UnlockUser")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.UnlockUser(ctx, id)
+}
+
+func (t tracedUsers) VerifyEmail(ctx context.Context, token string) (verified int, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.VerifyEmail")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.VerifyEmail(ctx, token)
+}
+
+func (t tracedUsers) ResendVerification(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ResendVerification")
+	defer func() { tracing.End(span, err) }()
+	return t.AppUser.ResendVerification(ctx, email)
+}
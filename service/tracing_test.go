@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/grpcClient"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/tracing"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	mock_repository "stlab.itechart-group.com/go/food_delivery/authentication_service/repository/mocks"
+	"testing"
+)
+
+func TestService_tracing(t *testing.T) {
+	//Init dependencies
+	provider, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterMemory, SampleRatio: 1})
+	assert.NoError(t, err)
+	defer provider.Shutdown(context.Background())
+	c := gomock.NewController(t)
+	defer c.Finish()
+	repo := mock_repository.NewMockAppUser(c)
+	repo.EXPECT().GetUserByID(gomock.Any(), 1).DoAndReturn(func(ctx context.Context, id int) (interface{}, error) {
+		// the repository is called within the span of the service
+		_, span := tracing.Start(ctx, "UserPostgres.GetUserByID")
+		span.End()
+		return nil, errors.New("repository failure")
+	})
+	service := NewService(&repository.Repository{AppUser: repo}, grpcClient.NewFakeClient(), logging.GetLogger(), Config{})
+
+	_, err = service.AppUser.GetUser(context.Background(), 1)
+	//Assert
+	assert.Error(t, err)
+	spans := provider.Spans()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "UserService.GetUser", spans[1].Name)
+		assert.Equal(t, codes.Error, spans[1].Status.Code)
+		assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+	}
+}
@@ -0,0 +1,408 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/metrics"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	"strconv"
+	"time"
+)
+
+// TwoFactorPolicy configures TOTP login challenges and the emailed enrollment links.
+// Without a Secret a random one is generated, so challenges are accepted only by the
+// replica which issued them
+type TwoFactorPolicy struct {
+	Secret        string
+	ChallengeTTL  time.Duration
+	Issuer        string
+	EnrollmentTTL time.Duration
+	EnrollmentURL string
+}
+
+// enrollmentField marks the enrollment tokens, so a login challenge can not open an enrollment
+const enrollmentField = "enroll"
+
+func (p TwoFactorPolicy) withDefaults() TwoFactorPolicy {
+	if p.Secret == "" {
+		p.Secret = randomSecret()
+	}
+	if p.ChallengeTTL <= 0 {
+		p.ChallengeTTL = 5 * time.Minute
+	}
+	if p.Issuer == "" {
+		p.Issuer = "Food Delivery"
+	}
+	if p.EnrollmentTTL <= 0 {
+		p.EnrollmentTTL = time.Hour
+	}
+	return p
+}
+
+func (p TwoFactorPolicy) challenge(userId int, nonce string) *pkg.ChallengeError {
+	expiresAt := time.Now().Add(p.ChallengeTTL)
+	return &pkg.ChallengeError{
+		Challenge: signFields(p.Secret, []string{strconv.Itoa(userId), nonce}, expiresAt),
+		ExpiresAt: expiresAt.Truncate(time.Second),
+	}
+}
+
+func (p TwoFactorPolicy) enrollmentLink(userId int, nonce string) string {
+	token := signFields(p.Secret, []string{strconv.Itoa(userId), nonce, enrollmentField}, time.Now().Add(p.EnrollmentTTL))
+	return linkWithToken(p.EnrollmentURL, token)
+}
+
+// parseEnrollment returns the user and the nonce of the enrollment token, the nonce
+// is checked against the pending challenge of the user
+func (p TwoFactorPolicy) parseEnrollment(token string) (int, string, error) {
+	fields, err := parseFields(p.Secret, token, 3)
+	if err != nil || fields[2] != enrollmentField {
+		return 0, "", pkg.ErrorInvalidEnrollment
+	}
+	userId, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, "", pkg.ErrorInvalidEnrollment
+	}
+	return userId, fields[1], nil
+}
+
+// parseChallenge returns the user and the nonce of the challenge, the nonce is
+// checked against the pending challenge of the user
+func (p TwoFactorPolicy) parseChallenge(challenge string) (int, string, error) {
+	fields, err := parseFields(p.Secret, challenge, 2)
+	if err != nil {
+		return 0, "", pkg.ErrorInvalidChallenge
+	}
+	userId, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, "", pkg.ErrorInvalidChallenge
+	}
+	return userId, fields[1], nil
+}
+
+type TwoFactorService struct {
+	repo   repository.Repository
+	logger logging.Logger
+	policy TwoFactorPolicy
+}
+
+func NewTwoFactorService(repo repository.Repository, logger logging.Logger, cfg Config) *TwoFactorService {
+	return &TwoFactorService{repo: repo, logger: logger, policy: cfg.TwoFactor.withDefaults()}
+}
+
+// EnrollTOTP generates a new secret, it is used only after ConfirmTOTP
+func (t *TwoFactorService) EnrollTOTP(ctx context.Context, userId int) (*model.TOTPEnrollment, error) {
+	twoFactor, err := t.repo.TwoFactor.GetTwoFactor(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor.Enabled {
+		return nil, pkg.ErrorTwoFactorEnabled
+	}
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.logger.WithContext(ctx).Errorf("EnrollTOTP: can not generate secret:%s", err)
+		return nil, fmt.Errorf("enrollTOTP: can not generate secret:%w", err)
+	}
+	if err = t.repo.TwoFactor.SetTOTPSecret(ctx, userId, secret); err != nil {
+		return nil, err
+	}
+	return &model.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: provisioningURI(t.policy.Issuer, twoFactor.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables the enrolled secret and returns new recovery codes
+func (t *TwoFactorService) ConfirmTOTP(ctx context.Context, userId int, code string) ([]string, error) {
+	twoFactor, err := t.repo.TwoFactor.GetTwoFactor(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor.Enabled {
+		return nil, pkg.ErrorTwoFactorEnabled
+	}
+	if twoFactor.Secret == "" {
+		return nil, pkg.ErrorTwoFactorNotEnabled
+	}
+	return enableTOTP(ctx, t.repo, t.logger, twoFactor, code, newAuditEvent(ctx, model.AuditTwoFactorEnabled))
+}
+
+// DisableTOTP turns two-factor authentication off after checking a current code
+func (t *TwoFactorService) DisableTOTP(ctx context.Context, userId int, code string) error {
+	twoFactor, err := t.repo.TwoFactor.GetTwoFactor(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if !twoFactor.Enabled {
+		return pkg.ErrorTwoFactorNotEnabled
+	}
+	required, err := roleRequiresTwoFactor(ctx, t.repo, twoFactor.Role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return pkg.ErrorTwoFactorMandatory
+	}
+	ok, err := checkSecondFactor(ctx, t.repo, twoFactor, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		t.logger.WithContext(ctx).Warnf("DisableTOTP: invalid code for user (id = %d)", userId)
+		return pkg.ErrorInvalidTwoFactor
+	}
+	if err = t.repo.TwoFactor.DisableTOTP(ctx, userId, newAuditEvent(ctx, model.AuditTwoFactorDisabled)); err != nil {
+		return err
+	}
+	t.logger.WithContext(ctx).Infof("DisableTOTP: two-factor authentication of user (id = %d) is disabled", userId)
+	return nil
+}
+
+func (t *TwoFactorService) GetTwoFactorRoles(ctx context.Context) ([]string, error) {
+	return t.repo.TwoFactor.GetTwoFactorRoles(ctx)
+}
+
+func (t *TwoFactorService) SetTwoFactorRoles(ctx context.Context, roles []string) error {
+	if err := t.repo.TwoFactor.SetTwoFactorRoles(ctx, roles, newAuditEvent(ctx, model.AuditTwoFactorRolesSet)); err != nil {
+		return err
+	}
+	t.logger.WithContext(ctx).Infof("SetTwoFactorRoles: two-factor authentication is required for %v", roles)
+	return nil
+}
+
+// requireSecondFactor returns a login challenge if the user has to pass a second factor.
+// Users of a role requiring it who have not enrolled yet are refused and get a link to
+// enroll by email, so the password alone never reveals a secret.
+func (u *UserService) requireSecondFactor(ctx context.Context, user *model.User) error {
+	if user.TOTPEnabled {
+		challenge, err := u.issueChallenge(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		return challenge
+	}
+	required, err := roleRequiresTwoFactor(ctx, u.repo, user.Role)
+	if err != nil || !required {
+		return err
+	}
+	nonce, err := u.newChallengeNonce(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	u.mailer.SendTwoFactorEnrollmentEmail(&model.Post{
+		Email: user.Email,
+		Link:  u.twoFactor.enrollmentLink(user.ID, nonce),
+	})
+	u.logger.WithContext(ctx).Warnf("AuthUser: user (id = %d) has to enroll in two-factor authentication, the link is sent", user.ID)
+	return fmt.Errorf("authUser:%w", pkg.ErrorEnrollmentRequired)
+}
+
+// issueChallenge returns a new login challenge, it replaces the pending one of the user
+func (u *UserService) issueChallenge(ctx context.Context, userId int) (*pkg.ChallengeError, error) {
+	nonce, err := u.newChallengeNonce(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return u.twoFactor.challenge(userId, nonce), nil
+}
+
+// newChallengeNonce stores the nonce of a new challenge or enrollment link,
+// the one pending before is no longer accepted
+func (u *UserService) newChallengeNonce(ctx context.Context, userId int) (string, error) {
+	nonce, err := generateNonce()
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("AuthUser: can not generate challenge:%s", err)
+		return "", fmt.Errorf("authUser: can not generate challenge:%w", err)
+	}
+	if err = u.repo.TwoFactor.SetTOTPChallenge(ctx, userId, nonce); err != nil {
+		return "", err
+	}
+	return nonce, nil
+}
+
+// StartTwoFactorEnrollment opens the emailed enrollment link, it is accepted once. A new
+// secret is generated, the returned challenge is finished at /users/login/2fa with a
+// code of it, which enables two-factor authentication and logs the user in.
+func (u *UserService) StartTwoFactorEnrollment(ctx context.Context, token string) (*model.TwoFactorEnrollment, error) {
+	userId, nonce, err := u.twoFactor.parseEnrollment(token)
+	if err != nil {
+		u.logger.WithContext(ctx).Warn("StartTwoFactorEnrollment: invalid or expired enrollment link was used")
+		return nil, err
+	}
+	twoFactor, err := u.repo.TwoFactor.GetTwoFactor(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor.Deleted || twoFactor.Enabled {
+		return nil, pkg.ErrorInvalidEnrollment
+	}
+	pending, err := u.repo.TwoFactor.UseTOTPChallenge(ctx, userId, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if !pending {
+		u.logger.WithContext(ctx).Warnf("StartTwoFactorEnrollment: used or replaced enrollment link of user (id = %d)", userId)
+		return nil, pkg.ErrorInvalidEnrollment
+	}
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("StartTwoFactorEnrollment: can not generate secret:%s", err)
+		return nil, fmt.Errorf("startTwoFactorEnrollment: can not generate secret:%w", err)
+	}
+	if err = u.repo.TwoFactor.SetTOTPSecret(ctx, userId, secret); err != nil {
+		return nil, err
+	}
+	challenge, err := u.issueChallenge(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return &model.TwoFactorEnrollment{
+		Challenge:       challenge.Challenge,
+		ExpiresAt:       challenge.ExpiresAt,
+		Secret:          secret,
+		ProvisioningURI: provisioningURI(u.twoFactor.Issuer, twoFactor.Email, secret),
+	}, nil
+}
+
+// AuthUserTwoFactor finishes the login started by AuthUser with a TOTP or recovery code,
+// when the challenge was issued by StartTwoFactorEnrollment the TOTP code also confirms the secret
+func (u *UserService) AuthUserTwoFactor(ctx context.Context, challenge string, code string) (*model.TwoFactorTokens, int, error) {
+	tokens, userId, err := u.authUserTwoFactor(ctx, challenge, code)
+	metrics.Logins.WithLabelValues("two_factor", loginOutcome(err)).Inc()
+	return tokens, userId, err
+}
+
+func (u *UserService) authUserTwoFactor(ctx context.Context, challenge string, code string) (*model.TwoFactorTokens, int, error) {
+	userId, nonce, err := u.twoFactor.parseChallenge(challenge)
+	if err != nil {
+		u.logger.WithContext(ctx).Warn("AuthUserTwoFactor: invalid or expired challenge was used")
+		return nil, 0, err
+	}
+	twoFactor, err := u.repo.TwoFactor.GetTwoFactor(ctx, userId)
+	if err != nil {
+		return nil, 0, err
+	}
+	if twoFactor.Deleted {
+		return nil, 0, pkg.ErrorInvalidChallenge
+	}
+	if twoFactor.LockedUntil != nil && time.Now().Before(*twoFactor.LockedUntil) {
+		return nil, 0, &pkg.LockedError{Until: *twoFactor.LockedUntil}
+	}
+	// every attempt uses the challenge up, after a wrong code the password is asked again
+	pending, err := u.repo.TwoFactor.UseTOTPChallenge(ctx, userId, nonce)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !pending {
+		u.logger.WithContext(ctx).Warnf("AuthUserTwoFactor: used or replaced challenge of user (id = %d)", userId)
+		return nil, 0, pkg.ErrorInvalidChallenge
+	}
+	var recoveryCodes []string
+	if twoFactor.Enabled {
+		ok, err := checkSecondFactor(ctx, u.repo, twoFactor, code)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			return nil, 0, u.failSecondFactor(ctx, userId)
+		}
+	} else {
+		required, err := roleRequiresTwoFactor(ctx, u.repo, twoFactor.Role)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !required || twoFactor.Secret == "" {
+			return nil, 0, pkg.ErrorInvalidChallenge
+		}
+		event := selfAuditEvent(ctx, &model.User{ID: twoFactor.UserID, Role: twoFactor.Role}, model.AuditTwoFactorEnabled)
+		recoveryCodes, err = enableTOTP(ctx, u.repo, u.logger, twoFactor, code, event)
+		if errors.Is(err, pkg.ErrorInvalidTwoFactor) {
+			return nil, 0, u.failSecondFactor(ctx, userId)
+		} else if err != nil {
+			return nil, 0, err
+		}
+	}
+	// the failed attempts are forgiven only after both factors are passed
+	err = u.resetFailedLogins(ctx, &model.User{
+		ID:                  userId,
+		Role:                twoFactor.Role,
+		FailedLoginAttempts: twoFactor.FailedLoginAttempts,
+		LockoutCount:        twoFactor.LockoutCount,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	tokens, err := u.authCli.TokenGenerationByUserId(ctx, &authProto.User{
+		UserId: int32(userId),
+		Role:   twoFactor.Role,
+	})
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("TokenGenerationByUserId:%s", err)
+		return nil, 0, fmt.Errorf("TokenGenerationByUserId:%w", err)
+	}
+	return &model.TwoFactorTokens{
+		AccessToken:   tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		RecoveryCodes: recoveryCodes,
+	}, userId, nil
+}
+
+// failSecondFactor counts a wrong code like a wrong password
+func (u *UserService) failSecondFactor(ctx context.Context, userId int) error {
+	u.logger.WithContext(ctx).Warnf("AuthUserTwoFactor: invalid code for user (id = %d)", userId)
+	if err := u.registerFailedLogin(ctx, &model.User{ID: userId}); err != nil {
+		return err
+	}
+	return pkg.ErrorInvalidTwoFactor
+}
+
+func enableTOTP(ctx context.Context, repo repository.Repository, logger logging.Logger, twoFactor *model.TwoFactor, code string, event *model.AuditEvent) ([]string, error) {
+	step, ok := verifyTOTP(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, pkg.ErrorInvalidTwoFactor
+	}
+	used, err := repo.TwoFactor.UseTOTPStep(ctx, twoFactor.UserID, step)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, pkg.ErrorInvalidTwoFactor
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		logger.WithContext(ctx).Errorf("EnableTOTP: can not generate recovery codes:%s", err)
+		return nil, fmt.Errorf("enableTOTP: can not generate recovery codes:%w", err)
+	}
+	if err = repo.TwoFactor.EnableTOTP(ctx, twoFactor.UserID, hashes, event); err != nil {
+		return nil, err
+	}
+	logger.WithContext(ctx).Infof("EnableTOTP: two-factor authentication of user (id = %d) is enabled", twoFactor.UserID)
+	return codes, nil
+}
+
+// checkSecondFactor accepts an unused TOTP code or an unused recovery code
+func checkSecondFactor(ctx context.Context, repo repository.Repository, twoFactor *model.TwoFactor, code string) (bool, error) {
+	if step, ok := verifyTOTP(twoFactor.Secret, code, time.Now()); ok {
+		return repo.TwoFactor.UseTOTPStep(ctx, twoFactor.UserID, step)
+	}
+	return repo.TwoFactor.UseRecoveryCode(ctx, twoFactor.UserID, hashRecoveryCode(code))
+}
+
+func roleRequiresTwoFactor(ctx context.Context, repo repository.Repository, role string) (bool, error) {
+	roles, err := repo.TwoFactor.GetTwoFactorRoles(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, required := range roles {
+		if required == role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
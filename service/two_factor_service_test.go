@@ -0,0 +1,435 @@
+package service
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/grpcClient"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	mock_repository "stlab.itechart-group.com/go/food_delivery/authentication_service/repository/mocks"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the base32 form of the RFC 6238 test key "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestService_totpCode(t *testing.T) {
+	testTable := []struct {
+		name         string
+		unix         int64
+		expectedCode string
+	}{
+		{name: "59", unix: 59, expectedCode: "287082"},
+		{name: "1111111109", unix: 1111111109, expectedCode: "081804"},
+		{name: "1234567890", unix: 1234567890, expectedCode: "005924"},
+		{name: "20000000000", unix: 20000000000, expectedCode: "353130"},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			code, err := totpCode(rfcSecret, testCase.unix/totpPeriod)
+			//Assert
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedCode, code)
+			step, ok := verifyTOTP(rfcSecret, code, time.Unix(testCase.unix+totpPeriod, 0))
+			assert.True(t, ok)
+			assert.Equal(t, testCase.unix/totpPeriod, step)
+			_, ok = verifyTOTP(rfcSecret, code, time.Unix(testCase.unix+3*totpPeriod, 0))
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestService_recoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, RecoveryCodesCount, len(codes))
+	assert.Equal(t, RecoveryCodesCount, len(hashes))
+	assert.Regexp(t, "^[A-Z2-7]{5}-[A-Z2-7]{5}$", codes[0])
+	assert.Equal(t, hashes[0], hashRecoveryCode(codes[0]))
+	assert.Equal(t, hashes[0], hashRecoveryCode(" "+codes[0][:5]+codes[0][6:]+" "))
+}
+
+func TestService_ConfirmTOTP(t *testing.T) {
+	code, _ := totpCode(rfcSecret, time.Now().Unix()/totpPeriod)
+	type mockBehavior func(s *mock_repository.MockTwoFactor)
+	testTable := []struct {
+		name          string
+		code          string
+		mockBehavior  mockBehavior
+		expectedCodes int
+		expectedError error
+	}{
+		{
+			name: "OK",
+			code: code,
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Secret: rfcSecret}, nil)
+				s.EXPECT().UseTOTPStep(gomock.Any(), 1, gomock.Any()).Return(true, nil)
+				s.EXPECT().EnableTOTP(gomock.Any(), 1, gomock.Len(RecoveryCodesCount), auditAction(model.AuditTwoFactorEnabled)).Return(nil)
+			},
+			expectedCodes: RecoveryCodesCount,
+		},
+		{
+			name: "Replayed code",
+			code: code,
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Secret: rfcSecret}, nil)
+				s.EXPECT().UseTOTPStep(gomock.Any(), 1, gomock.Any()).Return(false, nil)
+			},
+			expectedError: pkg.ErrorInvalidTwoFactor,
+		},
+		{
+			name: "Wrong code",
+			code: "000000",
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Secret: rfcSecret}, nil)
+			},
+			expectedError: pkg.ErrorInvalidTwoFactor,
+		},
+		{
+			name: "Not enrolled",
+			code: code,
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1}, nil)
+			},
+			expectedError: pkg.ErrorTwoFactorNotEnabled,
+		},
+		{
+			name: "Already enabled",
+			code: code,
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Secret: rfcSecret, Enabled: true}, nil)
+			},
+			expectedError: pkg.ErrorTwoFactorEnabled,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			twoFactor := mock_repository.NewMockTwoFactor(c)
+			testCase.mockBehavior(twoFactor)
+			service := NewTwoFactorService(repository.Repository{TwoFactor: twoFactor}, logging.GetLogger(), Config{})
+			codes, err := service.ConfirmTOTP(context.Background(), 1, testCase.code)
+			//Assert
+			assert.Equal(t, testCase.expectedError, err)
+			assert.Equal(t, testCase.expectedCodes, len(codes))
+		})
+	}
+}
+
+func TestService_DisableTOTP(t *testing.T) {
+	type mockBehavior func(s *mock_repository.MockTwoFactor)
+	testTable := []struct {
+		name          string
+		mockBehavior  mockBehavior
+		expectedError error
+	}{
+		{
+			name: "OK with recovery code",
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Courier", Secret: rfcSecret, Enabled: true}, nil)
+				s.EXPECT().GetTwoFactorRoles(gomock.Any()).Return([]string{"Superadmin"}, nil)
+				s.EXPECT().UseRecoveryCode(gomock.Any(), 1, hashRecoveryCode("ABCDE-FGHIJ")).Return(true, nil)
+				s.EXPECT().DisableTOTP(gomock.Any(), 1, auditAction(model.AuditTwoFactorDisabled)).Return(nil)
+			},
+		},
+		{
+			name: "Used recovery code",
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Courier", Secret: rfcSecret, Enabled: true}, nil)
+				s.EXPECT().GetTwoFactorRoles(gomock.Any()).Return([]string{"Superadmin"}, nil)
+				s.EXPECT().UseRecoveryCode(gomock.Any(), 1, hashRecoveryCode("ABCDE-FGHIJ")).Return(false, nil)
+			},
+			expectedError: pkg.ErrorInvalidTwoFactor,
+		},
+		{
+			name: "Mandatory for role",
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Superadmin", Secret: rfcSecret, Enabled: true}, nil)
+				s.EXPECT().GetTwoFactorRoles(gomock.Any()).Return([]string{"Superadmin"}, nil)
+			},
+			expectedError: pkg.ErrorTwoFactorMandatory,
+		},
+		{
+			name: "Not enabled",
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Courier"}, nil)
+			},
+			expectedError: pkg.ErrorTwoFactorNotEnabled,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			twoFactor := mock_repository.NewMockTwoFactor(c)
+			testCase.mockBehavior(twoFactor)
+			service := NewTwoFactorService(repository.Repository{TwoFactor: twoFactor}, logging.GetLogger(), Config{})
+			err := service.DisableTOTP(context.Background(), 1, "ABCDE-FGHIJ")
+			//Assert
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestService_authUserChallenge(t *testing.T) {
+	password := "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy"
+	//Init dependencies
+	c := gomock.NewController(t)
+	defer c.Finish()
+	auth := mock_repository.NewMockAppUser(c)
+	auth.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(&model.User{
+		ID: 1, Email: "test@yandex.ru", Password: password, Role: "Courier", TOTPEnabled: true, FailedLoginAttempts: 2,
+	}, nil)
+	twoFactor := mock_repository.NewMockTwoFactor(c)
+	var pending string
+	twoFactor.EXPECT().SetTOTPChallenge(gomock.Any(), 1, gomock.Any()).DoAndReturn(func(_ context.Context, _ int, nonce string) error {
+		pending = nonce
+		return nil
+	})
+	cfg := Config{TwoFactor: TwoFactorPolicy{Secret: "secret"}}
+	service := NewUserService(repository.Repository{AppUser: auth, TwoFactor: twoFactor}, nil, logging.GetLogger(), cfg)
+	_, _, err := service.AuthUser(context.Background(), "test@yandex.ru", "HGYKnu!98Tg")
+	//Assert
+	assert.ErrorIs(t, err, pkg.ErrorTwoFactorRequired)
+	challenge := err.(*pkg.ChallengeError)
+	userId, nonce, err := cfg.TwoFactor.withDefaults().parseChallenge(challenge.Challenge)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, userId)
+	assert.Equal(t, pending, nonce)
+}
+
+// TestService_authUserEnrollmentRequired shows that the password alone gets neither
+// a challenge nor a secret when the role requires two-factor authentication, the
+// user is not enrolled and the mock fails on any attempt to set a secret
+func TestService_authUserEnrollmentRequired(t *testing.T) {
+	password := "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy"
+	//Init dependencies
+	c := gomock.NewController(t)
+	defer c.Finish()
+	auth := mock_repository.NewMockAppUser(c)
+	auth.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(&model.User{
+		ID: 1, Email: "test@yandex.ru", Password: password, Role: "Superadmin",
+	}, nil)
+	twoFactor := mock_repository.NewMockTwoFactor(c)
+	twoFactor.EXPECT().GetTwoFactorRoles(gomock.Any()).Return([]string{"Superadmin"}, nil)
+	twoFactor.EXPECT().SetTOTPChallenge(gomock.Any(), 1, gomock.Any()).Return(nil)
+	service := NewUserService(repository.Repository{AppUser: auth, TwoFactor: twoFactor}, nil, logging.GetLogger(), Config{})
+	tokens, id, err := service.AuthUser(context.Background(), "test@yandex.ru", "HGYKnu!98Tg")
+	//Assert
+	assert.ErrorIs(t, err, pkg.ErrorEnrollmentRequired)
+	assert.NotErrorIs(t, err, pkg.ErrorTwoFactorRequired)
+	assert.Nil(t, tokens)
+	assert.Equal(t, 0, id)
+}
+
+func TestService_StartTwoFactorEnrollment(t *testing.T) {
+	policy := TwoFactorPolicy{Secret: "secret"}.withDefaults()
+	enrollment := policy.enrollmentLink(1, "nonce")
+	type mockBehavior func(s *mock_repository.MockTwoFactor)
+	testTable := []struct {
+		name          string
+		token         string
+		mockBehavior  mockBehavior
+		expectedError error
+	}{
+		{
+			name:  "OK",
+			token: enrollment,
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Email: "test@yandex.ru", Role: "Superadmin"}, nil)
+				s.EXPECT().UseTOTPChallenge(gomock.Any(), 1, "nonce").Return(true, nil)
+				s.EXPECT().SetTOTPSecret(gomock.Any(), 1, gomock.Any()).Return(nil)
+				s.EXPECT().SetTOTPChallenge(gomock.Any(), 1, gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name:          "Login challenge",
+			token:         policy.challenge(1, "nonce").Challenge,
+			mockBehavior:  func(s *mock_repository.MockTwoFactor) {},
+			expectedError: pkg.ErrorInvalidEnrollment,
+		},
+		{
+			name:  "Used link",
+			token: enrollment,
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Superadmin"}, nil)
+				s.EXPECT().UseTOTPChallenge(gomock.Any(), 1, "nonce").Return(false, nil)
+			},
+			expectedError: pkg.ErrorInvalidEnrollment,
+		},
+		{
+			name:  "Already enabled",
+			token: enrollment,
+			mockBehavior: func(s *mock_repository.MockTwoFactor) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Superadmin", Enabled: true}, nil)
+			},
+			expectedError: pkg.ErrorInvalidEnrollment,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			twoFactor := mock_repository.NewMockTwoFactor(c)
+			testCase.mockBehavior(twoFactor)
+			service := NewUserService(repository.Repository{TwoFactor: twoFactor}, nil, logging.GetLogger(), Config{TwoFactor: policy})
+			result, err := service.StartTwoFactorEnrollment(context.Background(), testCase.token)
+			//Assert
+			assert.Equal(t, testCase.expectedError, err)
+			if testCase.expectedError == nil {
+				assert.Contains(t, result.ProvisioningURI, "secret="+result.Secret)
+				userId, _, err := policy.parseChallenge(result.Challenge)
+				assert.NoError(t, err)
+				assert.Equal(t, 1, userId)
+			}
+		})
+	}
+}
+
+func TestService_AuthUserTwoFactor(t *testing.T) {
+	policy := TwoFactorPolicy{Secret: "secret"}
+	challenge := policy.withDefaults().challenge(1, "nonce").Challenge
+	code, _ := totpCode(rfcSecret, time.Now().Unix()/totpPeriod)
+	type mockBehavior func(s *mock_repository.MockTwoFactor, a *mock_repository.MockAppUser)
+	testTable := []struct {
+		name           string
+		challenge      string
+		code           string
+		mockBehavior   mockBehavior
+		expectedId     int
+		expectedCodes  int
+		expectedError  error
+		expectedTokens bool
+	}{
+		{
+			name:      "OK",
+			challenge: challenge,
+			code:      code,
+			mockBehavior: func(s *mock_repository.MockTwoFactor, a *mock_repository.MockAppUser) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Superadmin", Secret: rfcSecret, Enabled: true}, nil)
+				s.EXPECT().UseTOTPChallenge(gomock.Any(), 1, "nonce").Return(true, nil)
+				s.EXPECT().UseTOTPStep(gomock.Any(), 1, gomock.Any()).Return(true, nil)
+			},
+			expectedId:     1,
+			expectedTokens: true,
+		},
+		{
+			name:      "OK resets failed attempts",
+			challenge: challenge,
+			code:      code,
+			mockBehavior: func(s *mock_repository.MockTwoFactor, a *mock_repository.MockAppUser) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{
+					UserID: 1, Role: "Superadmin", Secret: rfcSecret, Enabled: true, FailedLoginAttempts: 2,
+				}, nil)
+				s.EXPECT().UseTOTPChallenge(gomock.Any(), 1, "nonce").Return(true, nil)
+				s.EXPECT().UseTOTPStep(gomock.Any(), 1, gomock.Any()).Return(true, nil)
+				a.EXPECT().UnlockUser(gomock.Any(), 1, gomock.Any()).Return(1, nil)
+			},
+			expectedId:     1,
+			expectedTokens: true,
+		},
+		{
+			name:      "Used challenge",
+			challenge: challenge,
+			code:      code,
+			mockBehavior: func(s *mock_repository.MockTwoFactor, a *mock_repository.MockAppUser) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Superadmin", Secret: rfcSecret, Enabled: true}, nil)
+				s.EXPECT().UseTOTPChallenge(gomock.Any(), 1, "nonce").Return(false, nil)
+			},
+			expectedError: pkg.ErrorInvalidChallenge,
+		},
+		{
+			name:      "Enrollment",
+			challenge: challenge,
+			code:      code,
+			mockBehavior: func(s *mock_repository.MockTwoFactor, a *mock_repository.MockAppUser) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Superadmin", Secret: rfcSecret}, nil)
+				s.EXPECT().UseTOTPChallenge(gomock.Any(), 1, "nonce").Return(true, nil)
+				s.EXPECT().GetTwoFactorRoles(gomock.Any()).Return([]string{"Superadmin"}, nil)
+				s.EXPECT().UseTOTPStep(gomock.Any(), 1, gomock.Any()).Return(true, nil)
+				s.EXPECT().EnableTOTP(gomock.Any(), 1, gomock.Len(RecoveryCodesCount), auditAction(model.AuditTwoFactorEnabled)).Return(nil)
+			},
+			expectedId:     1,
+			expectedCodes:  RecoveryCodesCount,
+			expectedTokens: true,
+		},
+		{
+			name:      "Replayed enrollment code",
+			challenge: challenge,
+			code:      code,
+			mockBehavior: func(s *mock_repository.MockTwoFactor, a *mock_repository.MockAppUser) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Superadmin", Secret: rfcSecret}, nil)
+				s.EXPECT().UseTOTPChallenge(gomock.Any(), 1, "nonce").Return(true, nil)
+				s.EXPECT().GetTwoFactorRoles(gomock.Any()).Return([]string{"Superadmin"}, nil)
+				s.EXPECT().UseTOTPStep(gomock.Any(), 1, gomock.Any()).Return(false, nil)
+				a.EXPECT().RegisterFailedLogin(gomock.Any(), 1).Return(1, 0, nil)
+			},
+			expectedError: pkg.ErrorInvalidTwoFactor,
+		},
+		{
+			name:      "Replayed code",
+			challenge: challenge,
+			code:      code,
+			mockBehavior: func(s *mock_repository.MockTwoFactor, a *mock_repository.MockAppUser) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Superadmin", Secret: rfcSecret, Enabled: true}, nil)
+				s.EXPECT().UseTOTPChallenge(gomock.Any(), 1, "nonce").Return(true, nil)
+				s.EXPECT().UseTOTPStep(gomock.Any(), 1, gomock.Any()).Return(false, nil)
+				a.EXPECT().RegisterFailedLogin(gomock.Any(), 1).Return(1, 0, nil)
+			},
+			expectedError: pkg.ErrorInvalidTwoFactor,
+		},
+		{
+			name:          "Invalid challenge",
+			challenge:     "challenge",
+			code:          code,
+			mockBehavior:  func(s *mock_repository.MockTwoFactor, a *mock_repository.MockAppUser) {},
+			expectedError: pkg.ErrorInvalidChallenge,
+		},
+		{
+			name:      "Not enrolled",
+			challenge: challenge,
+			code:      code,
+			mockBehavior: func(s *mock_repository.MockTwoFactor, a *mock_repository.MockAppUser) {
+				s.EXPECT().GetTwoFactor(gomock.Any(), 1).Return(&model.TwoFactor{UserID: 1, Role: "Courier"}, nil)
+				s.EXPECT().UseTOTPChallenge(gomock.Any(), 1, "nonce").Return(true, nil)
+				s.EXPECT().GetTwoFactorRoles(gomock.Any()).Return(nil, nil)
+			},
+			expectedError: pkg.ErrorInvalidChallenge,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			twoFactor := mock_repository.NewMockTwoFactor(c)
+			auth := mock_repository.NewMockAppUser(c)
+			testCase.mockBehavior(twoFactor, auth)
+			authCli := grpcClient.NewFakeClient("Superadmin", "Courier")
+			service := NewUserService(repository.Repository{AppUser: auth, TwoFactor: twoFactor}, authCli, logging.GetLogger(), Config{TwoFactor: policy})
+			tokens, id, err := service.AuthUserTwoFactor(context.Background(), testCase.challenge, testCase.code)
+			//Assert
+			assert.Equal(t, testCase.expectedError, err)
+			assert.Equal(t, testCase.expectedId, id)
+			if testCase.expectedTokens {
+				assert.NotEmpty(t, tokens.AccessToken)
+				assert.Equal(t, testCase.expectedCodes, len(tokens.RecoveryCodes))
+			}
+		})
+	}
+}
@@ -2,166 +2,261 @@ package service
 
 import (
 	"context"
+	cryptorand "crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"github.com/golang/protobuf/ptypes/empty"
 	"golang.org/x/crypto/bcrypt"
 	"math/rand"
+	"net/url"
 	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
-	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/grpcClient"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/mail"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/metrics"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
 	"strings"
 	"time"
 )
 
 type UserService struct {
-	repo    repository.Repository
-	logger  logging.Logger
-	grpcCli *grpcClient.GRPCClient
+	repo         repository.Repository
+	logger       logging.Logger
+	authCli      authProto.AuthClient
+	lockout      LockoutPolicy
+	reset        PasswordResetPolicy
+	verification EmailVerificationPolicy
+	twoFactor    TwoFactorPolicy
+	mailer       *mail.Mailer
+	bcryptCost   int
 }
 
-func NewUserService(repo repository.Repository, grpcCli *grpcClient.GRPCClient, logger logging.Logger) *UserService {
-	return &UserService{repo: repo, grpcCli: grpcCli, logger: logger}
+func NewUserService(repo repository.Repository, authCli authProto.AuthClient, logger logging.Logger, cfg Config) *UserService {
+	bcryptCost := cfg.BcryptCost
+	if bcryptCost == 0 {
+		bcryptCost = bcrypt.DefaultCost
+	}
+	return &UserService{
+		repo:         repo,
+		authCli:      authClientWithTimeout{AuthClient: authCli, timeout: cfg.Timeouts.withDefaults().Auth},
+		logger:       logger,
+		lockout:      cfg.Lockout.withDefaults(),
+		reset:        cfg.PasswordReset.withDefaults(),
+		verification: cfg.EmailVerification.withDefaults(),
+		twoFactor:    cfg.TwoFactor.withDefaults(),
+		mailer:       mail.NewMailer(logger, cfg.Mail),
+		bcryptCost:   bcryptCost,
+	}
 }
 
-func (u *UserService) GetUser(id int) (*model.ResponseUser, error) {
-	user, err := u.repo.AppUser.GetUserByID(id)
+func (u *UserService) GetUser(ctx context.Context, id int) (*model.ResponseUser, error) {
+	user, err := u.repo.AppUser.GetUserByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (u *UserService) GetUsers(page int, limit int, filters *model.RequestFilters) ([]model.ResponseUser, int, error) {
-	if filters.Role != "" {
-		users, pages, err := u.repo.AppUser.GetUserByRoleFilter(page, limit, filters)
-		if err != nil {
-			return nil, 0, err
-		}
-		return users, pages, nil
-	} else if filters.FilterData {
-		if filters.EndTime.Unix() < filters.StartTime.Unix() {
-			filters.EndTime.Time = filters.StartTime.Time
-		}
-		users, pages, err := u.repo.AppUser.GetUserByDataFilter(page, limit, filters)
-		if err != nil {
-			return nil, 0, err
-		}
-		return users, pages, nil
-	} else {
-		users, pages, err := u.repo.AppUser.GetUserAll(page, limit)
-		if err != nil {
-			return nil, 0, err
+// GetUsers returns a page of the user list by its number, zero page or limit returns all users as one page
+func (u *UserService) GetUsers(ctx context.Context, page int, limit int, filters *model.RequestFilters, sort string) (*model.UsersPage, error) {
+	normalizeFilters(filters)
+	fields, err := listSort(sort, filters)
+	if err != nil {
+		return nil, err
+	}
+	users, total, err := u.repo.AppUser.GetUsers(ctx, page, limit, filters, fields)
+	if err != nil {
+		return nil, err
+	}
+	if page == 0 || limit == 0 {
+		return &model.UsersPage{Users: users, Total: total, Page: 1, Pages: 1}, nil
+	}
+	return &model.UsersPage{Users: users, Total: total, Page: page, Limit: limit, Pages: (total + limit - 1) / limit}, nil
+}
+
+// normalizeFilters splits comma separated roles, trims the search and moves the end date up to the start one
+func normalizeFilters(filters *model.RequestFilters) {
+	filters.Query = strings.TrimSpace(filters.Query)
+	var roles []string
+	for _, role := range filters.Roles {
+		for _, part := range strings.Split(role, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				roles = append(roles, part)
+			}
 		}
-		return users, pages, nil
+	}
+	filters.Roles = roles
+	if !filters.EndTime.IsZero() && filters.EndTime.Before(filters.StartTime.Time) {
+		filters.EndTime.Time = filters.StartTime.Time
 	}
 }
 
-func (u *UserService) CreateCustomer(user *model.CreateCustomer) (*authProto.GeneratedTokens, int, error) {
+func (u *UserService) GetUsersByIds(ctx context.Context, ids []int) ([]model.ResponseUser, error) {
+	return u.repo.AppUser.GetUsersByIDs(ctx, ids)
+}
+
+func (u *UserService) GetUserByEmail(ctx context.Context, email string) (*model.ResponseUser, error) {
+	userDb, err := u.repo.AppUser.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return u.repo.AppUser.GetUserByID(ctx, userDb.ID)
+}
+
+func (u *UserService) CreateCustomer(ctx context.Context, user *model.CreateCustomer) (*authProto.GeneratedTokens, int, error) {
 	if user.Password == "" {
 		user.Password = GeneratePassword()
 	}
 	pas := user.Password
-	hash, err := u.HashPassword(user.Password, bcrypt.DefaultCost)
+	hash, err := u.HashPassword(user.Password, u.bcryptCost)
 	if err != nil {
-		u.logger.Errorf("CreateUser: can not generate hash from password:%s", err)
+		u.logger.WithContext(ctx).Errorf("CreateUser: can not generate hash from password:%s", err)
 		return nil, 0, fmt.Errorf("createUser: can not generate hash from password:%w", err)
 	}
 	user.Password = hash
-	id, err := u.repo.AppUser.CreateCustomer(user)
+	id, err := u.repo.AppUser.CreateCustomer(ctx, user)
 	if err != nil {
 		return nil, 0, err
 	}
-	go mail.SendEmail(u.logger, &model.Post{
+	metrics.CustomersCreated.Inc()
+	u.mailer.SendEmail(&model.Post{
 		Email:    user.Email,
 		Password: pas,
 	})
-	_, err = u.grpcCli.BindUserAndRole(context.Background(), &authProto.User{
+	u.sendVerification(id, user.Email)
+	_, err = u.authCli.BindUserAndRole(ctx, &authProto.User{
 		UserId: int32(id),
 		Role:   "Authorized Customer",
 	})
 	if err != nil {
-		u.logger.Errorf("BindUserAndRole:%s", err)
+		u.logger.WithContext(ctx).Errorf("BindUserAndRole:%s", err)
 		return nil, id, fmt.Errorf("bindUserAndRole:%w", err)
 	}
-	tokens, err := u.grpcCli.TokenGenerationByUserId(context.Background(), &authProto.User{
+	if u.verification.Unverified == UnverifiedDenyLogin {
+		// no tokens until the customer follows the verification link
+		return nil, id, nil
+	}
+	tokens, err := u.authCli.TokenGenerationByUserId(ctx, &authProto.User{
 		UserId: int32(id),
 		Role:   "Authorized Customer",
 	})
 	if err != nil {
-		u.logger.Errorf("tokenGenerationByUserId:%s", err)
+		u.logger.WithContext(ctx).Errorf("tokenGenerationByUserId:%s", err)
 		return nil, 0, fmt.Errorf("tokenGenerationByUserId:%w", err)
 	}
 	return tokens, id, nil
 }
 
-func (u *UserService) CreateStaff(user *model.CreateStaff) (int, error) {
+func (u *UserService) CreateStaff(ctx context.Context, user *model.CreateStaff) (int, error) {
 	if user.Password == "" {
 		user.Password = GeneratePassword()
 	}
 	pas := user.Password
-	hash, err := u.HashPassword(user.Password, bcrypt.DefaultCost)
+	hash, err := u.HashPassword(user.Password, u.bcryptCost)
 	if err != nil {
-		u.logger.Errorf("CreateStaff: can not generate hash from password:%s", err)
+		u.logger.WithContext(ctx).Errorf("CreateStaff: can not generate hash from password:%s", err)
 		return 0, fmt.Errorf("CreateStaff: can not generate hash from password:%w", err)
 	}
 	user.Password = hash
-	id, err := u.repo.AppUser.CreateStaff(user)
+	id, err := u.repo.AppUser.CreateStaff(ctx, user, newAuditEvent(ctx, model.AuditStaffCreated))
 	if err != nil {
 		return 0, err
 	}
-	go mail.SendEmail(u.logger, &model.Post{
+	metrics.StaffCreated.Inc()
+	u.mailer.SendEmail(&model.Post{
 		Email:    user.Email,
 		Password: pas,
 	})
-	_, err = u.grpcCli.BindUserAndRole(context.Background(), &authProto.User{
+	_, err = u.authCli.BindUserAndRole(ctx, &authProto.User{
 		UserId: int32(id),
 		Role:   user.Role,
 	})
 	if err != nil {
-		u.logger.Errorf("BindUserAndRole:%s", err)
+		u.logger.WithContext(ctx).Errorf("BindUserAndRole:%s", err)
 		return id, fmt.Errorf("bindUserAndRole:%w", err)
 	}
 	return id, nil
 }
 
-func (u *UserService) UpdateUser(user *model.UpdateUser) error {
-	userDb, err := u.repo.AppUser.GetUserByEmail(user.Email)
+func (u *UserService) UpdateUser(ctx context.Context, user *model.UpdateUser) error {
+	userDb, err := u.repo.AppUser.GetUserByEmail(ctx, user.Email)
 	if err != nil {
 		return err
 	}
 	if u.CheckPasswordHash(user.OldPassword, userDb.Password) {
-		newHash, err := u.HashPassword(user.NewPassword, bcrypt.DefaultCost)
+		newHash, err := u.HashPassword(user.NewPassword, u.bcryptCost)
 		if err != nil {
-			u.logger.Errorf("UpdateUser: can not generate hash from password:%s", err)
+			u.logger.WithContext(ctx).Errorf("UpdateUser: can not generate hash from password:%s", err)
 			return fmt.Errorf("updateUser: can not generate hash from password:%w", err)
 		}
 		user.NewPassword = newHash
-		err = u.repo.AppUser.UpdateUser(user)
+		err = u.repo.AppUser.UpdateUser(ctx, user, newAuditEvent(ctx, model.AuditPasswordChanged))
 		if err != nil {
 			return err
 		}
 		return nil
 	} else {
-		u.logger.Warn("wrong email or password entered")
+		u.logger.WithContext(ctx).Warn("wrong email or password entered")
 		return fmt.Errorf("wrong email or password entered")
 	}
 }
 
-func (u *UserService) DeleteUserByID(id int) (int, error) {
-	userId, err := u.repo.AppUser.DeleteUserByID(id)
+func (u *UserService) DeleteUserByID(ctx context.Context, id int) (int, error) {
+	userId, err := u.repo.AppUser.DeleteUserByID(ctx, id, newAuditEvent(ctx, model.AuditUserDeleted))
 	if err != nil {
 		return 0, err
 	}
+	if err = revokeUserTokens(ctx, u.repo, userId, newAuditEvent(ctx, model.AuditTokensRevoked)); err != nil {
+		return 0, err
+	}
 	return userId, nil
 }
 
-func (u *UserService) CheckInputRole(role string) error {
-	roles, err := u.grpcCli.GetAllRoles(context.Background(), &empty.Empty{})
+// RestoreUser reactivates the deleted user. The role is bound again as the auth
+// service may have lost the binding meanwhile, binding is idempotent.
+func (u *UserService) RestoreUser(ctx context.Context, id int) (int, error) {
+	user, err := u.repo.AppUser.GetUserByID(ctx, id)
 	if err != nil {
-		u.logger.Errorf("CheckInputRole:%s", err)
+		return 0, err
+	}
+	if !user.Deleted {
+		u.logger.WithContext(ctx).Warnf("RestoreUser: user (id = %d) is already active", id)
+		return 0, fmt.Errorf("restoreUser:%w", pkg.ErrorUserActive)
+	}
+	// The role is bound before the restore and outside of its transaction, so
+	// that no row stays locked during the call to the auth service. A failed
+	// binding leaves the user deleted and the restore can be retried. A binding
+	// followed by a failed restore needs no undoing: the delete keeps the binding
+	// as well and a deleted user can not log in.
+	_, err = u.authCli.BindUserAndRole(ctx, &authProto.User{
+		UserId: int32(id),
+		Role:   user.Role,
+	})
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("BindUserAndRole:%s", err)
+		return 0, fmt.Errorf("bindUserAndRole:%w", err)
+	}
+	if err = u.repo.AppUser.RestoreUser(ctx, id, newAuditEvent(ctx, model.AuditUserRestored)); err != nil {
+		return 0, err
+	}
+	u.logger.WithContext(ctx).Infof("RestoreUser: user (id = %d) is restored", id)
+	return id, nil
+}
+
+func (u *UserService) UnlockUser(ctx context.Context, id int) (int, error) {
+	userId, err := u.repo.AppUser.UnlockUser(ctx, id, newAuditEvent(ctx, model.AuditUserUnlocked))
+	if err != nil {
+		return 0, err
+	}
+	u.logger.WithContext(ctx).Infof("UnlockUser: user (id = %d) is unlocked", userId)
+	return userId, nil
+}
+
+func (u *UserService) CheckInputRole(ctx context.Context, role string) error {
+	roles, err := u.authCli.GetAllRoles(ctx, &empty.Empty{})
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("CheckInputRole:%s", err)
 		return err
 	}
 	roleSlice := strings.Split(roles.Roles, ",")
@@ -178,8 +273,8 @@ func (u *UserService) CheckInputRole(role string) error {
 	}
 }
 
-func (u *UserService) ParseToken(token string) (*authProto.UserRole, error) {
-	return u.grpcCli.GetUserWithRights(context.Background(), &authProto.AccessToken{AccessToken: token})
+func (u *UserService) ParseToken(ctx context.Context, token string) (*authProto.UserRole, error) {
+	return u.authCli.GetUserWithRights(ctx, &authProto.AccessToken{AccessToken: token})
 }
 
 func (u *UserService) CheckRole(neededRoles []string, givenRole string) error {
@@ -208,29 +303,94 @@ func (u *UserService) CheckRights(neededPerms []string, givenPerms string) error
 	return nil
 }
 
-func (u *UserService) RestorePassword(restore *model.RestorePassword) error {
-	err := u.repo.CheckEmail(restore.Email)
+// PasswordResetPolicy sets how long an emailed reset token is valid and the page
+// the link leads to, the token is appended to URL as the "token" query parameter
+type PasswordResetPolicy struct {
+	TTL time.Duration
+	URL string
+}
+
+func (p PasswordResetPolicy) withDefaults() PasswordResetPolicy {
+	if p.TTL <= 0 {
+		p.TTL = time.Hour
+	}
+	return p
+}
+
+func (p PasswordResetPolicy) link(token string) string {
+	return linkWithToken(p.URL, token)
+}
+
+// linkWithToken appends the token to the page URL, without a page the bare token is mailed
+func linkWithToken(page string, token string) string {
+	if page == "" {
+		return token
+	}
+	separator := "?"
+	if strings.Contains(page, "?") {
+		separator = "&"
+	}
+	return page + separator + "token=" + url.QueryEscape(token)
+}
+
+// RestorePassword emails a single-use reset link, the password itself is not changed
+func (u *UserService) RestorePassword(ctx context.Context, restore *model.RestorePassword) error {
+	token, err := generateResetToken()
+	if err != nil {
+		u.logger.WithContext(ctx).Errorf("RestorePassword: can not generate reset token:%s", err)
+		return fmt.Errorf("RestorePassword: can not generate reset token:%w", err)
+	}
+	_, err = u.repo.PasswordReset.CreatePasswordReset(ctx, restore.Email, hashToken(token), time.Now().UTC().Add(u.reset.TTL),
+		newAuditEvent(ctx, model.AuditPasswordResetRequested))
 	if err != nil {
 		return err
 	}
-	password := GeneratePassword()
-	hash, err := u.HashPassword(password, bcrypt.DefaultCost)
+	u.mailer.SendPasswordResetEmail(&model.Post{
+		Email: restore.Email,
+		Link:  u.reset.link(token),
+	})
+	metrics.PasswordRestores.WithLabelValues("requested").Inc()
+	return nil
+}
+
+// ResetPassword sets the new password if the token is valid and logs the user out everywhere
+func (u *UserService) ResetPassword(ctx context.Context, reset *model.ResetPassword) error {
+	hash, err := u.HashPassword(reset.Password, u.bcryptCost)
 	if err != nil {
-		u.logger.Errorf("RestorePassword: can not generate hash from password:%s", err)
-		return fmt.Errorf("RestorePassword: can not generate hash from password:%w", err)
+		u.logger.WithContext(ctx).Errorf("ResetPassword: can not generate hash from password:%s", err)
+		return fmt.Errorf("ResetPassword: can not generate hash from password:%w", err)
 	}
-	restore.Password = hash
-	err = u.repo.AppUser.RestorePassword(restore)
+	userId, err := u.repo.PasswordReset.ResetPassword(ctx, hashToken(reset.Token), hash, newAuditEvent(ctx, model.AuditPasswordReset))
 	if err != nil {
 		return err
 	}
-	go mail.SendEmail(u.logger, &model.Post{
-		Email:    restore.Email,
-		Password: password,
-	})
+	if err = revokeUserTokens(ctx, u.repo, userId, newAuditEvent(ctx, model.AuditTokensRevoked)); err != nil {
+		return err
+	}
+	u.logger.WithContext(ctx).Infof("ResetPassword: password of user (id = %d) is reset", userId)
+	metrics.PasswordRestores.WithLabelValues("completed").Inc()
 	return nil
 }
 
+func (u *UserService) CleanupPasswordResets(ctx context.Context) (int64, error) {
+	deleted, err := u.repo.PasswordReset.DeleteExpiredPasswordResets(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if deleted != 0 {
+		u.logger.WithContext(ctx).Infof("CleanupPasswordResets: %d expired reset tokens deleted", deleted)
+	}
+	return deleted, nil
+}
+
+func generateResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := cryptorand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 func GeneratePassword() string {
 	rand.Seed(time.Now().UnixNano())
 	length := 8 + rand.Intn(7)
@@ -1,11 +1,17 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	authProto "stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/GRPC/grpcClient"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
 	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
 	mock_repository "stlab.itechart-group.com/go/food_delivery/authentication_service/repository/mocks"
@@ -26,7 +32,7 @@ func TestService_GetUser(t *testing.T) {
 			name:    "OK",
 			inputId: 1,
 			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
-				s.EXPECT().GetUserByID(id).Return(&model.ResponseUser{
+				s.EXPECT().GetUserByID(gomock.Any(), id).Return(&model.ResponseUser{
 					ID:        1,
 					Email:     "test@yandex.ru",
 					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
@@ -45,7 +51,7 @@ func TestService_GetUser(t *testing.T) {
 			name:    "Repository failure",
 			inputId: 1,
 			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
-				s.EXPECT().GetUserByID(id).Return(nil, errors.New("repository failure"))
+				s.EXPECT().GetUserByID(gomock.Any(), id).Return(nil, errors.New("repository failure"))
 			},
 			expectedUser:  nil,
 			expectedError: errors.New("repository failure"),
@@ -61,9 +67,9 @@ func TestService_GetUser(t *testing.T) {
 			testCase.mockBehavior(auth, testCase.inputId)
 			logger := logging.GetLogger()
 			repo := &repository.Repository{AppUser: auth}
-			grpcCli := grpcClient.NewGRPCClient("159.223.1.135")
-			service := NewService(repo, grpcCli, logger)
-			user, err := service.GetUser(testCase.inputId)
+			grpcCli := grpcClient.NewFakeClient()
+			service := NewService(repo, grpcCli, logger, Config{})
+			user, err := service.GetUser(context.Background(), testCase.inputId)
 			//Assert
 			assert.Equal(t, testCase.expectedUser, user)
 			assert.Equal(t, testCase.expectedError, err)
@@ -72,193 +78,139 @@ func TestService_GetUser(t *testing.T) {
 }
 
 func TestService_GetUsers(t *testing.T) {
-	type mockBehaviorGetAllUsers func(s *mock_repository.MockAppUser, page int, limit int)
-	type mockBehaviorFilterByRole func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters)
-	type mockBehaviorFilterByData func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters)
+	type mockBehavior func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters)
+	users := []model.ResponseUser{
+		{ID: 1,
+			Email:     "test@yande.ru",
+			CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
+			Role:      "Courier",
+		}, {ID: 2,
+			Email:     "test2@yande.ru",
+			CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
+			Role:      "Courier",
+		},
+	}
+	failure := errors.New("repository failure")
 	testTable := []struct {
-		name                     string
-		inputPage                int
-		inputLimit               int
-		inputFilter              *model.RequestFilters
-		mockBehaviorGetAllUsers  mockBehaviorGetAllUsers
-		mockBehaviorFilterByRole mockBehaviorFilterByRole
-		mockBehaviorFilterByData mockBehaviorFilterByData
-		expectedUsers            []model.ResponseUser
-		expectedError            error
+		name           string
+		inputPage      int
+		inputLimit     int
+		inputSort      string
+		inputFilter    *model.RequestFilters
+		expectedFilter *model.RequestFilters
+		mockBehavior   mockBehavior
+		expectedPage   *model.UsersPage
+		expectedError  error
 	}{
 		{
-			name:       "OK without filter",
-			inputPage:  1,
-			inputLimit: 10,
-			inputFilter: &model.RequestFilters{
-				ShowDeleted: false,
-				FilterData:  false,
-				StartTime:   model.MyTime{},
-				EndTime:     model.MyTime{},
-				Role:        "",
-			},
-			mockBehaviorGetAllUsers: func(s *mock_repository.MockAppUser, page int, limit int) {
-				s.EXPECT().GetUserAll(page, limit).Return([]model.ResponseUser{
-					{ID: 1,
-						Email:     "test@yande.ru",
-						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-						Role:      "Courier",
-					}, {ID: 2,
-						Email:     "test2@yande.ru",
-						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-						Role:      "Courier",
-					},
-				}, 1, nil)
-			},
-			mockBehaviorFilterByRole: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {},
-			mockBehaviorFilterByData: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {},
-			expectedUsers: []model.ResponseUser{
-				{ID: 1,
-					Email:     "test@yande.ru",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-					Role:      "Courier",
-				}, {ID: 2,
-					Email:     "test2@yande.ru",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-					Role:      "Courier",
-				},
-			},
+			name:           "OK without filter",
+			inputPage:      1,
+			inputLimit:     10,
+			inputFilter:    &model.RequestFilters{},
+			expectedFilter: &model.RequestFilters{},
+			mockBehavior: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, nil).Return(users, 2, nil)
+			},
+			expectedPage:  &model.UsersPage{Users: users, Total: 2, Page: 1, Limit: 10, Pages: 1},
 			expectedError: nil,
 		},
 		{
-			name:       "Repository failure",
-			inputPage:  1,
-			inputLimit: 10,
-			inputFilter: &model.RequestFilters{
-				ShowDeleted: false,
-				FilterData:  false,
-				StartTime:   model.MyTime{},
-				EndTime:     model.MyTime{},
-				Role:        "",
-			},
-			mockBehaviorGetAllUsers: func(s *mock_repository.MockAppUser, page int, limit int) {
-				s.EXPECT().GetUserAll(page, limit).Return(nil, 0, errors.New("repository failure"))
-			},
-			mockBehaviorFilterByRole: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {},
-			mockBehaviorFilterByData: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {},
-			expectedUsers:            nil,
-			expectedError:            errors.New("repository failure"),
+			name:           "Repository failure",
+			inputPage:      1,
+			inputLimit:     10,
+			inputFilter:    &model.RequestFilters{},
+			expectedFilter: &model.RequestFilters{},
+			mockBehavior: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, nil).Return(nil, 0, failure)
+			},
+			expectedPage:  nil,
+			expectedError: failure,
 		},
 		{
-			name:       "OK with role filter",
+			name:       "Comma separated roles",
 			inputPage:  1,
 			inputLimit: 10,
 			inputFilter: &model.RequestFilters{
-				ShowDeleted: false,
-				FilterData:  false,
-				StartTime:   model.MyTime{},
-				EndTime:     model.MyTime{},
-				Role:        "Courier",
-			},
-			mockBehaviorGetAllUsers: func(s *mock_repository.MockAppUser, page int, limit int) {},
-			mockBehaviorFilterByRole: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {
-				s.EXPECT().GetUserByRoleFilter(page, limit, filter).Return([]model.ResponseUser{
-					{ID: 1,
-						Email:     "test@yande.ru",
-						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-						Role:      "Courier",
-					}, {ID: 2,
-						Email:     "test2@yande.ru",
-						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-						Role:      "Courier",
-					},
-				}, 1, nil)
-			},
-			mockBehaviorFilterByData: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {},
-			expectedUsers: []model.ResponseUser{
-				{ID: 1,
-					Email:     "test@yande.ru",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-					Role:      "Courier",
-				}, {ID: 2,
-					Email:     "test2@yande.ru",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-					Role:      "Courier",
-				},
+				Roles:   []string{"Courier, Courier manager", "", "Superadmin"},
+				Deleted: model.DeletedAll,
 			},
+			expectedFilter: &model.RequestFilters{
+				Roles:   []string{"Courier", "Courier manager", "Superadmin"},
+				Deleted: model.DeletedAll,
+			},
+			mockBehavior: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, nil).Return(users, 2, nil)
+			},
+			expectedPage:  &model.UsersPage{Users: users, Total: 2, Page: 1, Limit: 10, Pages: 1},
 			expectedError: nil,
 		},
 		{
-			name:       "Repository failure (role filter)",
-			inputPage:  1,
-			inputLimit: 10,
+			name:       "End date before start date",
+			inputPage:  0,
+			inputLimit: 0,
 			inputFilter: &model.RequestFilters{
-				ShowDeleted: false,
-				FilterData:  false,
-				StartTime:   model.MyTime{},
-				EndTime:     model.MyTime{},
-				Role:        "Courier",
+				StartTime: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
+				EndTime:   model.MyTime{Time: time.Date(2022, 03, 01, 0, 0, 0, 0, time.UTC)},
 			},
-			mockBehaviorGetAllUsers: func(s *mock_repository.MockAppUser, page int, limit int) {},
-			mockBehaviorFilterByRole: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {
-				s.EXPECT().GetUserByRoleFilter(page, limit, filter).Return(nil, 0, errors.New("repository failure"))
+			expectedFilter: &model.RequestFilters{
+				StartTime: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
+				EndTime:   model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
 			},
-			mockBehaviorFilterByData: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {},
-			expectedUsers:            nil,
-			expectedError:            errors.New("repository failure"),
+			mockBehavior: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, nil).Return(users, 2, nil)
+			},
+			expectedPage:  &model.UsersPage{Users: users, Total: 2, Page: 1, Pages: 1},
+			expectedError: nil,
 		},
 		{
-			name:       "OK with data filter",
-			inputPage:  1,
-			inputLimit: 10,
-			inputFilter: &model.RequestFilters{
-				ShowDeleted: false,
-				FilterData:  true,
-				StartTime:   model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				EndTime:     model.MyTime{},
-				Role:        "",
-			},
-			mockBehaviorGetAllUsers:  func(s *mock_repository.MockAppUser, page int, limit int) {},
-			mockBehaviorFilterByRole: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {},
-			mockBehaviorFilterByData: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {
-				s.EXPECT().GetUserByDataFilter(page, limit, filter).Return([]model.ResponseUser{
-					{ID: 1,
-						Email:     "test@yande.ru",
-						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-						Role:      "Courier",
-					}, {ID: 2,
-						Email:     "test2@yande.ru",
-						CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-						Role:      "Courier",
-					},
-				}, 1, nil)
-			},
-			expectedUsers: []model.ResponseUser{
-				{ID: 1,
-					Email:     "test@yande.ru",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-					Role:      "Courier",
-				}, {ID: 2,
-					Email:     "test2@yande.ru",
-					CreatedAt: model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-					Role:      "Courier",
-				},
-			},
+			name:           "Sorted",
+			inputPage:      2,
+			inputLimit:     1,
+			inputSort:      "created_at, -email",
+			inputFilter:    &model.RequestFilters{},
+			expectedFilter: &model.RequestFilters{},
+			mockBehavior: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, []model.SortField{{Column: "created_at"}, {Column: "email", Desc: true}}).
+					Return(users[1:], 3, nil)
+			},
+			expectedPage:  &model.UsersPage{Users: users[1:], Total: 3, Page: 2, Limit: 1, Pages: 3},
 			expectedError: nil,
 		},
 		{
-			name:       "Repository failure (data filter)",
-			inputPage:  1,
-			inputLimit: 10,
-			inputFilter: &model.RequestFilters{
-				ShowDeleted: false,
-				FilterData:  true,
-				StartTime:   model.MyTime{Time: time.Date(2022, 03, 11, 0, 0, 0, 0, time.UTC)},
-				EndTime:     model.MyTime{},
-				Role:        "",
-			},
-			mockBehaviorGetAllUsers:  func(s *mock_repository.MockAppUser, page int, limit int) {},
-			mockBehaviorFilterByRole: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {},
-			mockBehaviorFilterByData: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {
-				s.EXPECT().GetUserByDataFilter(page, limit, filter).Return(nil, 0, errors.New("repository failure"))
-			},
-			expectedUsers: nil,
-			expectedError: errors.New("repository failure"),
+			name:           "Ranked search",
+			inputPage:      1,
+			inputLimit:     10,
+			inputFilter:    &model.RequestFilters{Query: " ivan "},
+			expectedFilter: &model.RequestFilters{Query: "ivan"},
+			mockBehavior: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, []model.SortField{{Column: model.SortRank}}).Return(users, 2, nil)
+			},
+			expectedPage:  &model.UsersPage{Users: users, Total: 2, Page: 1, Limit: 10, Pages: 1},
+			expectedError: nil,
+		},
+		{
+			name:           "Sorted search",
+			inputPage:      1,
+			inputLimit:     10,
+			inputSort:      "-id",
+			inputFilter:    &model.RequestFilters{Query: "ivan"},
+			expectedFilter: &model.RequestFilters{Query: "ivan"},
+			mockBehavior: func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {
+				s.EXPECT().GetUsers(gomock.Any(), page, limit, filter, []model.SortField{{Column: "id", Desc: true}}).Return(users, 2, nil)
+			},
+			expectedPage:  &model.UsersPage{Users: users, Total: 2, Page: 1, Limit: 10, Pages: 1},
+			expectedError: nil,
+		},
+		{
+			name:           "Invalid sort",
+			inputPage:      1,
+			inputLimit:     10,
+			inputSort:      "password",
+			inputFilter:    &model.RequestFilters{},
+			expectedFilter: &model.RequestFilters{},
+			mockBehavior:   func(s *mock_repository.MockAppUser, page int, limit int, filter *model.RequestFilters) {},
+			expectedPage:   nil,
+			expectedError:  pkg.ErrorInvalidSort,
 		},
 	}
 
@@ -268,18 +220,20 @@ func TestService_GetUsers(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
 			auth := mock_repository.NewMockAppUser(c)
-			testCase.mockBehaviorGetAllUsers(auth, testCase.inputPage, testCase.inputLimit)
-			testCase.mockBehaviorFilterByRole(auth, testCase.inputPage, testCase.inputLimit, testCase.inputFilter)
-			testCase.mockBehaviorFilterByData(auth, testCase.inputPage, testCase.inputLimit, testCase.inputFilter)
+			testCase.mockBehavior(auth, testCase.inputPage, testCase.inputLimit, testCase.expectedFilter)
 			logger := logging.GetLogger()
 			repo := &repository.Repository{AppUser: auth}
 
-			grpcCli := grpcClient.NewGRPCClient("159.223.1.135")
-			service := NewService(repo, grpcCli, logger)
-			users, _, err := service.GetUsers(testCase.inputPage, testCase.inputLimit, testCase.inputFilter)
+			grpcCli := grpcClient.NewFakeClient()
+			service := NewService(repo, grpcCli, logger, Config{})
+			page, err := service.GetUsers(context.Background(), testCase.inputPage, testCase.inputLimit, testCase.inputFilter, testCase.inputSort)
 			//Assert
-			assert.Equal(t, testCase.expectedUsers, users)
-			assert.Equal(t, testCase.expectedError, err)
+			assert.Equal(t, testCase.expectedPage, page)
+			if testCase.expectedError != nil {
+				assert.ErrorIs(t, err, testCase.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
 		})
 	}
 }
@@ -302,10 +256,10 @@ func TestService_UpdateUser(t *testing.T) {
 				NewPassword: "HYKnu!98Tg",
 			},
 			mockBehaviorUpdate: func(s *mock_repository.MockAppUser, user *model.UpdateUser) {
-				s.EXPECT().UpdateUser(user).Return(nil)
+				s.EXPECT().UpdateUser(gomock.Any(), user, auditAction(model.AuditPasswordChanged)).Return(nil)
 			},
 			mockBehaviorGet: func(s *mock_repository.MockAppUser, user *model.UpdateUser) {
-				s.EXPECT().GetUserByEmail(user.Email).Return(&model.User{
+				s.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Return(&model.User{
 					ID:       1,
 					Email:    "test@yandex.ru",
 					Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
@@ -323,7 +277,7 @@ func TestService_UpdateUser(t *testing.T) {
 			},
 			mockBehaviorUpdate: func(s *mock_repository.MockAppUser, user *model.UpdateUser) {},
 			mockBehaviorGet: func(s *mock_repository.MockAppUser, user *model.UpdateUser) {
-				s.EXPECT().GetUserByEmail(user.Email).Return(nil, errors.New("error while getting user"))
+				s.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Return(nil, errors.New("error while getting user"))
 			},
 			expectedError: errors.New("error while getting user"),
 		},
@@ -335,10 +289,10 @@ func TestService_UpdateUser(t *testing.T) {
 				NewPassword: "HYKnu!98Tg",
 			},
 			mockBehaviorUpdate: func(s *mock_repository.MockAppUser, user *model.UpdateUser) {
-				s.EXPECT().UpdateUser(user).Return(errors.New("error while getting user"))
+				s.EXPECT().UpdateUser(gomock.Any(), user, auditAction(model.AuditPasswordChanged)).Return(errors.New("error while getting user"))
 			},
 			mockBehaviorGet: func(s *mock_repository.MockAppUser, user *model.UpdateUser) {
-				s.EXPECT().GetUserByEmail(user.Email).Return(&model.User{
+				s.EXPECT().GetUserByEmail(gomock.Any(), user.Email).Return(&model.User{
 					ID:       1,
 					Email:    "test@yandex.ru",
 					Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
@@ -360,9 +314,9 @@ func TestService_UpdateUser(t *testing.T) {
 			testCase.mockBehaviorGet(auth, testCase.inputUser)
 			logger := logging.GetLogger()
 			repo := &repository.Repository{AppUser: auth}
-			grpcCli := grpcClient.NewGRPCClient("159.223.1.135")
-			service := NewService(repo, grpcCli, logger)
-			err := service.UpdateUser(testCase.inputUser)
+			grpcCli := grpcClient.NewFakeClient()
+			service := NewService(repo, grpcCli, logger, Config{})
+			err := service.UpdateUser(context.Background(), testCase.inputUser)
 			//Assert
 
 			assert.Equal(t, testCase.expectedError, err)
@@ -372,18 +326,23 @@ func TestService_UpdateUser(t *testing.T) {
 
 func TestService_DeleteUser(t *testing.T) {
 	type mockBehavior func(s *mock_repository.MockAppUser, id int)
+	type mockBehaviorRevoke func(s *mock_repository.MockTokenRevocation, id int)
 	testTable := []struct {
-		name           string
-		inputId        int
-		mockBehavior   mockBehavior
-		expectedUserId int
-		expectedError  error
+		name               string
+		inputId            int
+		mockBehavior       mockBehavior
+		mockBehaviorRevoke mockBehaviorRevoke
+		expectedUserId     int
+		expectedError      error
 	}{
 		{
 			name:    "OK",
 			inputId: 1,
 			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
-				s.EXPECT().DeleteUserByID(id).Return(1, nil)
+				s.EXPECT().DeleteUserByID(gomock.Any(), id, gomock.Any()).Return(1, nil)
+			},
+			mockBehaviorRevoke: func(s *mock_repository.MockTokenRevocation, id int) {
+				s.EXPECT().RevokeUserTokens(gomock.Any(), id, gomock.Any(), gomock.Any(), auditAction(model.AuditTokensRevoked)).Return(nil)
 			},
 			expectedUserId: 1,
 			expectedError:  nil,
@@ -392,7 +351,20 @@ func TestService_DeleteUser(t *testing.T) {
 			name:    "Repository failure",
 			inputId: 1,
 			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
-				s.EXPECT().DeleteUserByID(id).Return(0, errors.New("repository failure"))
+				s.EXPECT().DeleteUserByID(gomock.Any(), id, gomock.Any()).Return(0, errors.New("repository failure"))
+			},
+			mockBehaviorRevoke: func(s *mock_repository.MockTokenRevocation, id int) {},
+			expectedUserId:     0,
+			expectedError:      errors.New("repository failure"),
+		},
+		{
+			name:    "Revocation failure",
+			inputId: 1,
+			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
+				s.EXPECT().DeleteUserByID(gomock.Any(), id, gomock.Any()).Return(1, nil)
+			},
+			mockBehaviorRevoke: func(s *mock_repository.MockTokenRevocation, id int) {
+				s.EXPECT().RevokeUserTokens(gomock.Any(), id, gomock.Any(), gomock.Any(), auditAction(model.AuditTokensRevoked)).Return(errors.New("repository failure"))
 			},
 			expectedUserId: 0,
 			expectedError:  errors.New("repository failure"),
@@ -405,12 +377,14 @@ func TestService_DeleteUser(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
 			auth := mock_repository.NewMockAppUser(c)
+			revocation := mock_repository.NewMockTokenRevocation(c)
 			testCase.mockBehavior(auth, testCase.inputId)
+			testCase.mockBehaviorRevoke(revocation, testCase.inputId)
 			logger := logging.GetLogger()
-			repo := &repository.Repository{AppUser: auth}
-			grpcCli := grpcClient.NewGRPCClient("159.223.1.135")
-			service := NewService(repo, grpcCli, logger)
-			id, err := service.DeleteUserByID(testCase.inputId)
+			repo := &repository.Repository{AppUser: auth, TokenRevocation: revocation}
+			grpcCli := grpcClient.NewFakeClient()
+			service := NewService(repo, grpcCli, logger, Config{})
+			id, err := service.DeleteUserByID(context.Background(), testCase.inputId)
 			//Assert
 			assert.Equal(t, testCase.expectedUserId, id)
 			assert.Equal(t, testCase.expectedError, err)
@@ -419,25 +393,20 @@ func TestService_DeleteUser(t *testing.T) {
 }
 
 func TestService_RestorePassword(t *testing.T) {
-	type mockBehaviorCheckEmail func(s *mock_repository.MockAppUser, email string)
-	type mockBehavior func(s *mock_repository.MockAppUser, restore *model.RestorePassword)
+	type mockBehavior func(s *mock_repository.MockPasswordReset, email string)
 	testTable := []struct {
-		name                   string
-		input                  *model.RestorePassword
-		mockBehaviorCheckEmail mockBehaviorCheckEmail
-		mockBehavior           mockBehavior
-		expectedError          error
+		name          string
+		input         *model.RestorePassword
+		mockBehavior  mockBehavior
+		expectedError error
 	}{
 		{
 			name: "OK",
 			input: &model.RestorePassword{
 				Email: "test@yandex.ru",
 			},
-			mockBehaviorCheckEmail: func(s *mock_repository.MockAppUser, email string) {
-				s.EXPECT().CheckEmail(email).Return(nil)
-			},
-			mockBehavior: func(s *mock_repository.MockAppUser, restore *model.RestorePassword) {
-				s.EXPECT().RestorePassword(restore).Return(nil)
+			mockBehavior: func(s *mock_repository.MockPasswordReset, email string) {
+				s.EXPECT().CreatePasswordReset(gomock.Any(), email, gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil)
 			},
 			expectedError: nil,
 		},
@@ -446,24 +415,156 @@ func TestService_RestorePassword(t *testing.T) {
 			input: &model.RestorePassword{
 				Email: "test@yandex.ru",
 			},
-			mockBehaviorCheckEmail: func(s *mock_repository.MockAppUser, email string) {
-				s.EXPECT().CheckEmail(email).Return(errors.New("user with this email does not exist"))
+			mockBehavior: func(s *mock_repository.MockPasswordReset, email string) {
+				s.EXPECT().CreatePasswordReset(gomock.Any(), email, gomock.Any(), gomock.Any(), gomock.Any()).Return(0, pkg.ErrorEmailDoesNotExist)
 			},
-			mockBehavior:  func(s *mock_repository.MockAppUser, restore *model.RestorePassword) {},
-			expectedError: errors.New("user with this email does not exist"),
+			expectedError: pkg.ErrorEmailDoesNotExist,
 		},
 		{
-			name: "Error while updating user",
+			name: "Error while saving token",
 			input: &model.RestorePassword{
 				Email: "test@yandex.ru",
 			},
-			mockBehaviorCheckEmail: func(s *mock_repository.MockAppUser, email string) {
-				s.EXPECT().CheckEmail(email).Return(nil)
+			mockBehavior: func(s *mock_repository.MockPasswordReset, email string) {
+				s.EXPECT().CreatePasswordReset(gomock.Any(), email, gomock.Any(), gomock.Any(), gomock.Any()).Return(0, errors.New("error while saving token"))
 			},
-			mockBehavior: func(s *mock_repository.MockAppUser, restore *model.RestorePassword) {
-				s.EXPECT().RestorePassword(restore).Return(errors.New("error while updating password"))
+			expectedError: errors.New("error while saving token"),
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			reset := mock_repository.NewMockPasswordReset(c)
+			testCase.mockBehavior(reset, testCase.input.Email)
+			logger := logging.GetLogger()
+			repo := &repository.Repository{PasswordReset: reset}
+			grpcCli := grpcClient.NewFakeClient()
+			service := NewService(repo, grpcCli, logger, Config{})
+			err := service.RestorePassword(context.Background(), testCase.input)
+			//Assert
+
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestService_RestorePasswordToken(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	reset := mock_repository.NewMockPasswordReset(c)
+	var tokenHash string
+	var expiresAt time.Time
+	reset.EXPECT().CreatePasswordReset(gomock.Any(), "test@yandex.ru", gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, email string, hash string, expires time.Time, _ *model.AuditEvent) (int, error) {
+			tokenHash, expiresAt = hash, expires
+			return 1, nil
+		})
+	service := NewUserService(repository.Repository{PasswordReset: reset}, nil, logging.GetLogger(), Config{
+		PasswordReset: PasswordResetPolicy{TTL: 15 * time.Minute},
+	})
+	err := service.RestorePassword(context.Background(), &model.RestorePassword{Email: "test@yandex.ru"})
+	//Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 64, len(tokenHash))
+	assert.True(t, time.Until(expiresAt) > 14*time.Minute && time.Until(expiresAt) <= 15*time.Minute)
+	assert.Equal(t, "https://food-delivery.com/reset?token=a%2Bb",
+		PasswordResetPolicy{URL: "https://food-delivery.com/reset"}.link("a+b"))
+	assert.Equal(t, "https://food-delivery.com/reset?lang=ru&token=ab",
+		PasswordResetPolicy{URL: "https://food-delivery.com/reset?lang=ru"}.link("ab"))
+}
+
+func TestService_ResetPassword(t *testing.T) {
+	type mockBehavior func(s *mock_repository.MockPasswordReset, r *mock_repository.MockTokenRevocation, tokenHash string)
+	testTable := []struct {
+		name          string
+		input         *model.ResetPassword
+		mockBehavior  mockBehavior
+		expectedError error
+	}{
+		{
+			name: "OK",
+			input: &model.ResetPassword{
+				Token:    "token",
+				Password: "HGYKnu!98Tg",
 			},
-			expectedError: errors.New("error while updating password"),
+			mockBehavior: func(s *mock_repository.MockPasswordReset, r *mock_repository.MockTokenRevocation, tokenHash string) {
+				s.EXPECT().ResetPassword(gomock.Any(), tokenHash, gomock.Any(), gomock.Any()).Return(1, nil)
+				r.EXPECT().RevokeUserTokens(gomock.Any(), 1, gomock.Any(), gomock.Any(), auditAction(model.AuditTokensRevoked)).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name: "Invalid token",
+			input: &model.ResetPassword{
+				Token:    "token",
+				Password: "HGYKnu!98Tg",
+			},
+			mockBehavior: func(s *mock_repository.MockPasswordReset, r *mock_repository.MockTokenRevocation, tokenHash string) {
+				s.EXPECT().ResetPassword(gomock.Any(), tokenHash, gomock.Any(), gomock.Any()).Return(0, pkg.ErrorInvalidResetToken)
+			},
+			expectedError: pkg.ErrorInvalidResetToken,
+		},
+		{
+			name: "Revocation error",
+			input: &model.ResetPassword{
+				Token:    "token",
+				Password: "HGYKnu!98Tg",
+			},
+			mockBehavior: func(s *mock_repository.MockPasswordReset, r *mock_repository.MockTokenRevocation, tokenHash string) {
+				s.EXPECT().ResetPassword(gomock.Any(), tokenHash, gomock.Any(), gomock.Any()).Return(1, nil)
+				r.EXPECT().RevokeUserTokens(gomock.Any(), 1, gomock.Any(), gomock.Any(), auditAction(model.AuditTokensRevoked)).Return(errors.New("revocation error"))
+			},
+			expectedError: errors.New("revocation error"),
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			reset := mock_repository.NewMockPasswordReset(c)
+			revocation := mock_repository.NewMockTokenRevocation(c)
+			testCase.mockBehavior(reset, revocation, hashToken(testCase.input.Token))
+			logger := logging.GetLogger()
+			repo := &repository.Repository{PasswordReset: reset, TokenRevocation: revocation}
+			service := NewUserService(*repo, nil, logger, Config{})
+			err := service.ResetPassword(context.Background(), testCase.input)
+			//Assert
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestService_UnlockUser(t *testing.T) {
+	type mockBehavior func(s *mock_repository.MockAppUser, id int)
+	testTable := []struct {
+		name           string
+		inputId        int
+		mockBehavior   mockBehavior
+		expectedUserId int
+		expectedError  error
+	}{
+		{
+			name:    "OK",
+			inputId: 1,
+			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
+				s.EXPECT().UnlockUser(gomock.Any(), id, gomock.Any()).Return(1, nil)
+			},
+			expectedUserId: 1,
+			expectedError:  nil,
+		},
+		{
+			name:    "Repository failure",
+			inputId: 1,
+			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
+				s.EXPECT().UnlockUser(gomock.Any(), id, gomock.Any()).Return(0, errors.New("repository failure"))
+			},
+			expectedUserId: 0,
+			expectedError:  errors.New("repository failure"),
 		},
 	}
 
@@ -473,16 +574,301 @@ func TestService_RestorePassword(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
 			auth := mock_repository.NewMockAppUser(c)
-			testCase.mockBehaviorCheckEmail(auth, testCase.input.Email)
-			testCase.mockBehavior(auth, testCase.input)
+			testCase.mockBehavior(auth, testCase.inputId)
 			logger := logging.GetLogger()
 			repo := &repository.Repository{AppUser: auth}
-			grpcCli := grpcClient.NewGRPCClient("159.223.1.135")
-			service := NewService(repo, grpcCli, logger)
-			err := service.RestorePassword(testCase.input)
+			grpcCli := grpcClient.NewFakeClient()
+			service := NewService(repo, grpcCli, logger, Config{})
+			id, err := service.UnlockUser(context.Background(), testCase.inputId)
 			//Assert
+			assert.Equal(t, testCase.expectedUserId, id)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestService_RestoreUser(t *testing.T) {
+	type mockBehavior func(s *mock_repository.MockAppUser, id int)
+	testTable := []struct {
+		name           string
+		inputId        int
+		mockBehavior   mockBehavior
+		expectedUserId int
+		expectedBound  bool
+		expectedError  error
+	}{
+		{
+			name:    "OK",
+			inputId: 1,
+			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
+				s.EXPECT().GetUserByID(gomock.Any(), id).Return(&model.ResponseUser{ID: id, Role: "Courier", Deleted: true}, nil)
+				s.EXPECT().RestoreUser(gomock.Any(), id, auditAction(model.AuditUserRestored)).Return(nil)
+			},
+			expectedUserId: 1,
+			expectedBound:  true,
+		},
+		{
+			name:    "Not found",
+			inputId: 1,
+			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
+				s.EXPECT().GetUserByID(gomock.Any(), id).Return(nil, fmt.Errorf("getUserByID:%w", pkg.ErrorUserNotFound))
+			},
+			expectedError: fmt.Errorf("getUserByID:%w", pkg.ErrorUserNotFound),
+		},
+		{
+			name:    "Already active",
+			inputId: 1,
+			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
+				s.EXPECT().GetUserByID(gomock.Any(), id).Return(&model.ResponseUser{ID: id, Role: "Courier"}, nil)
+			},
+			expectedError: fmt.Errorf("restoreUser:%w", pkg.ErrorUserActive),
+		},
+		{
+			name:    "Binding failure",
+			inputId: 1,
+			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
+				s.EXPECT().GetUserByID(gomock.Any(), id).Return(&model.ResponseUser{ID: id, Role: "Pilot", Deleted: true}, nil)
+			},
+			expectedError: fmt.Errorf("bindUserAndRole:%w", status.Error(codes.InvalidArgument, `unknown role "Pilot"`)),
+		},
+		{
+			name:    "Restored concurrently",
+			inputId: 1,
+			mockBehavior: func(s *mock_repository.MockAppUser, id int) {
+				s.EXPECT().GetUserByID(gomock.Any(), id).Return(&model.ResponseUser{ID: id, Role: "Courier", Deleted: true}, nil)
+				s.EXPECT().RestoreUser(gomock.Any(), id, gomock.Any()).Return(fmt.Errorf("restoreUser:%w", pkg.ErrorUserActive))
+			},
+			expectedBound: true,
+			expectedError: fmt.Errorf("restoreUser:%w", pkg.ErrorUserActive),
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_repository.NewMockAppUser(c)
+			testCase.mockBehavior(auth, testCase.inputId)
+			authCli := grpcClient.NewFakeClient("Courier")
+			service := NewUserService(repository.Repository{AppUser: auth}, authCli, logging.GetLogger(), Config{})
+			id, err := service.RestoreUser(context.Background(), testCase.inputId)
+			//Assert
+			assert.Equal(t, testCase.expectedUserId, id)
+			if testCase.expectedError != nil {
+				assert.EqualError(t, err, testCase.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			role, bound := authCli.Binding(int32(testCase.inputId))
+			assert.Equal(t, testCase.expectedBound, bound)
+			if bound {
+				assert.Equal(t, "Courier", role)
+			}
+		})
+	}
+}
+
+// TestService_RestoreUser_tokens shows that the revocation made by the delete is
+// kept after the restore: the tokens issued up to the cutoff of the delete stay
+// revoked, the ones issued after it are accepted.
+func TestService_RestoreUser_tokens(t *testing.T) {
+	//Init dependencies
+	c := gomock.NewController(t)
+	defer c.Finish()
+	auth := mock_repository.NewMockAppUser(c)
+	revocation := mock_repository.NewMockTokenRevocation(c)
+	var revokedAt time.Time
+	auth.EXPECT().DeleteUserByID(gomock.Any(), 1, gomock.Any()).Return(1, nil)
+	revocation.EXPECT().RevokeUserTokens(gomock.Any(), 1, gomock.Any(), gomock.Any(), auditAction(model.AuditTokensRevoked)).
+		DoAndReturn(func(_ context.Context, _ int, cutoff time.Time, _ time.Time, _ *model.AuditEvent) error {
+			revokedAt = cutoff
+			return nil
+		})
+	auth.EXPECT().GetUserByID(gomock.Any(), 1).Return(&model.ResponseUser{ID: 1, Role: "Courier", Deleted: true}, nil)
+	auth.EXPECT().RestoreUser(gomock.Any(), 1, gomock.Any()).Return(nil)
+	// the same comparison as revoked_at >= $4 of the repository
+	revocation.EXPECT().IsTokenRevoked(gomock.Any(), 1, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ int, _ string, issuedAt time.Time) (bool, error) {
+			return !revokedAt.Before(issuedAt), nil
+		}).AnyTimes()
+	repo := &repository.Repository{AppUser: auth, TokenRevocation: revocation}
+	service := NewService(repo, grpcClient.NewFakeClient("Courier"), logging.GetLogger(), Config{})
+
+	_, err := service.DeleteUserByID(context.Background(), 1)
+	assert.NoError(t, err)
+	_, err = service.RestoreUser(context.Background(), 1)
+	assert.NoError(t, err)
+	//Assert
+	assert.ErrorIs(t, service.CheckTokenRevoked(context.Background(), 1, testJWT(revokedAt.Add(-time.Second), revokedAt.Add(time.Hour))), pkg.ErrorTokenRevoked)
+	assert.ErrorIs(t, service.CheckTokenRevoked(context.Background(), 1, testJWT(revokedAt, revokedAt.Add(time.Hour))), pkg.ErrorTokenRevoked)
+	assert.NoError(t, service.CheckTokenRevoked(context.Background(), 1, testJWT(revokedAt.Add(time.Second), revokedAt.Add(time.Hour))))
+	assert.NoError(t, service.CheckTokenRevoked(context.Background(), 1, testJWT(revokedAt.Add(time.Minute), revokedAt.Add(time.Hour))))
+}
 
+func TestService_CreateCustomer(t *testing.T) {
+	type mockBehaviorAuth func(f *grpcClient.FakeClient)
+	testTable := []struct {
+		name             string
+		policy           string
+		mockBehaviorAuth mockBehaviorAuth
+		expectedTokens   *authProto.GeneratedTokens
+		expectedId       int
+		expectedBound    bool
+		expectedError    error
+	}{
+		{
+			name:             "OK",
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) {},
+			expectedTokens:   &authProto.GeneratedTokens{AccessToken: grpcClient.FakeToken("access", 1, 1), RefreshToken: grpcClient.FakeToken("refresh", 1, 1)},
+			expectedId:       1,
+			expectedBound:    true,
+		},
+		{
+			name:             "Unverified customers can not log in",
+			policy:           UnverifiedDenyLogin,
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) {},
+			expectedId:       1,
+			expectedBound:    true,
+		},
+		{
+			name: "Binding failure",
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) {
+				f.FailWith(grpcClient.MethodBindUserAndRole, errors.New("auth service error"))
+			},
+			expectedId:    1,
+			expectedError: fmt.Errorf("bindUserAndRole:%w", errors.New("auth service error")),
+		},
+		{
+			name: "Token generation failure",
+			mockBehaviorAuth: func(f *grpcClient.FakeClient) {
+				f.FailWith(grpcClient.MethodTokenGenerationByUserId, errors.New("auth service error"))
+			},
+			expectedBound: true,
+			expectedError: fmt.Errorf("tokenGenerationByUserId:%w", errors.New("auth service error")),
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_repository.NewMockAppUser(c)
+			auth.EXPECT().CreateCustomer(gomock.Any(), gomock.Any()).Return(1, nil)
+			authCli := grpcClient.NewFakeClient("Authorized Customer")
+			testCase.mockBehaviorAuth(authCli)
+			service := NewUserService(repository.Repository{AppUser: auth}, authCli, logging.GetLogger(), Config{
+				EmailVerification: EmailVerificationPolicy{Unverified: testCase.policy},
+			})
+			tokens, id, err := service.CreateCustomer(context.Background(), &model.CreateCustomer{Email: "test@yandex.ru", Password: "HGYKnu!98Tg"})
+			//Assert
+			assert.Equal(t, testCase.expectedTokens, tokens)
+			assert.Equal(t, testCase.expectedId, id)
+			assert.Equal(t, testCase.expectedError, err)
+			role, bound := authCli.Binding(1)
+			assert.Equal(t, testCase.expectedBound, bound)
+			if bound {
+				assert.Equal(t, "Authorized Customer", role)
+			}
+		})
+	}
+}
+
+func TestService_CreateStaff(t *testing.T) {
+	testTable := []struct {
+		name          string
+		inputRole     string
+		expectedBound bool
+		expectedError error
+	}{
+		{
+			name:          "OK",
+			inputRole:     "Courier",
+			expectedBound: true,
+		},
+		{
+			name:          "Unknown role",
+			inputRole:     "Pilot",
+			expectedError: fmt.Errorf("bindUserAndRole:%w", status.Error(codes.InvalidArgument, `unknown role "Pilot"`)),
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_repository.NewMockAppUser(c)
+			auth.EXPECT().CreateStaff(gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil)
+			authCli := grpcClient.NewFakeClient("Courier")
+			service := NewUserService(repository.Repository{AppUser: auth}, authCli, logging.GetLogger(), Config{})
+			id, err := service.CreateStaff(context.Background(), &model.CreateStaff{Email: "test@yandex.ru", Role: testCase.inputRole})
+			//Assert
+			assert.Equal(t, 1, id)
+			if testCase.expectedError != nil {
+				assert.EqualError(t, err, testCase.expectedError.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			role, bound := authCli.Binding(1)
+			assert.Equal(t, testCase.expectedBound, bound)
+			if bound {
+				assert.Equal(t, testCase.inputRole, role)
+			}
+		})
+	}
+}
+
+func TestService_CheckInputRole(t *testing.T) {
+	testTable := []struct {
+		name          string
+		inputRole     string
+		authErr       error
+		expectedError error
+	}{
+		{
+			name:      "OK",
+			inputRole: "Courier manager",
+		},
+		{
+			name:          "Part of a role",
+			inputRole:     "Courier man",
+			expectedError: errors.New("incorrect role in request"),
+		},
+		{
+			name:          "Auth service error",
+			inputRole:     "Courier",
+			authErr:       errors.New("auth service error"),
+			expectedError: errors.New("auth service error"),
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			authCli := grpcClient.NewFakeClient("Courier", "Courier manager")
+			authCli.FailWith(grpcClient.MethodGetAllRoles, testCase.authErr)
+			service := NewUserService(repository.Repository{}, authCli, logging.GetLogger(), Config{})
+			err := service.CheckInputRole(context.Background(), testCase.inputRole)
+			//Assert
 			assert.Equal(t, testCase.expectedError, err)
 		})
 	}
 }
+
+func TestService_ParseToken(t *testing.T) {
+	authCli := grpcClient.NewFakeClient("Courier")
+	authCli.SetPermissions("Courier", "orders:read")
+	service := NewUserService(repository.Repository{}, authCli, logging.GetLogger(), Config{})
+	tokens, err := authCli.TokenGenerationByUserId(context.Background(), &authProto.User{UserId: 5, Role: "Courier"})
+	assert.NoError(t, err)
+
+	user, err := service.ParseToken(context.Background(), tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(5), user.UserId)
+	assert.Equal(t, "Courier", user.Role)
+	assert.Equal(t, "orders:read", user.Permissions)
+
+	_, err = service.ParseToken(context.Background(), tokens.RefreshToken)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"strconv"
+	"time"
+)
+
+// What a customer with an unverified email may do
+const (
+	UnverifiedAllowLogin = "allow"
+	UnverifiedDenyLogin  = "deny"
+)
+
+// EmailVerificationPolicy configures the signed verification links. Without a
+// Secret a random one is generated, so links do not survive a restart and are
+// not accepted by other replicas
+type EmailVerificationPolicy struct {
+	Secret     string
+	TTL        time.Duration
+	URL        string
+	Unverified string
+}
+
+func (p EmailVerificationPolicy) withDefaults() EmailVerificationPolicy {
+	if p.Secret == "" {
+		p.Secret = randomSecret()
+	}
+	if p.TTL <= 0 {
+		p.TTL = 24 * time.Hour
+	}
+	if p.Unverified != UnverifiedDenyLogin {
+		p.Unverified = UnverifiedAllowLogin
+	}
+	return p
+}
+
+func (p EmailVerificationPolicy) link(token string) string {
+	return linkWithToken(p.URL, token)
+}
+
+func (p EmailVerificationPolicy) sign(userId int, email string, expiresAt time.Time) string {
+	return signFields(p.Secret, []string{strconv.Itoa(userId), email}, expiresAt)
+}
+
+// parse checks the signature and expiration of the token and returns the user id and email
+func (p EmailVerificationPolicy) parse(token string) (int, string, error) {
+	fields, err := parseFields(p.Secret, token, 2)
+	if err != nil {
+		return 0, "", pkg.ErrorInvalidVerification
+	}
+	userId, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, "", pkg.ErrorInvalidVerification
+	}
+	return userId, fields[1], nil
+}
+
+// VerifyEmail marks the email from a valid verification link as verified
+func (u *UserService) VerifyEmail(ctx context.Context, token string) (int, error) {
+	userId, email, err := u.verification.parse(token)
+	if err != nil {
+		u.logger.WithContext(ctx).Warn("VerifyEmail: invalid or expired verification link was used")
+		return 0, err
+	}
+	userId, err = u.repo.AppUser.VerifyEmail(ctx, userId, email)
+	if err != nil {
+		return 0, err
+	}
+	u.logger.WithContext(ctx).Infof("VerifyEmail: email of user (id = %d) is verified", userId)
+	return userId, nil
+}
+
+// ResendVerification mails a new verification link if the email is not verified yet
+func (u *UserService) ResendVerification(ctx context.Context, email string) error {
+	userDb, err := u.repo.AppUser.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if userDb.Deleted {
+		return pkg.ErrorEmailDoesNotExist
+	}
+	if userDb.EmailVerified {
+		return pkg.ErrorEmailVerified
+	}
+	u.sendVerification(userDb.ID, userDb.Email)
+	return nil
+}
+
+func (u *UserService) sendVerification(userId int, email string) {
+	token := u.verification.sign(userId, email, time.Now().Add(u.verification.TTL))
+	u.mailer.SendVerificationEmail(&model.Post{
+		Email: email,
+		Link:  u.verification.link(token),
+	})
+}
+
+// checkVerified applies the policy for unverified customers to a login
+func (u *UserService) checkVerified(ctx context.Context, user *model.User) error {
+	if user.EmailVerified || u.verification.Unverified == UnverifiedAllowLogin {
+		return nil
+	}
+	u.logger.WithContext(ctx).Warnf("AuthUser: email of user (id = %d) is not verified", user.ID)
+	return fmt.Errorf("authUser:%w", pkg.ErrorEmailNotVerified)
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/model"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/pkg/logging"
+	"stlab.itechart-group.com/go/food_delivery/authentication_service/repository"
+	mock_repository "stlab.itechart-group.com/go/food_delivery/authentication_service/repository/mocks"
+	"testing"
+	"time"
+)
+
+func TestService_VerificationToken(t *testing.T) {
+	policy := EmailVerificationPolicy{Secret: "secret"}.withDefaults()
+	other := EmailVerificationPolicy{Secret: "other secret"}.withDefaults()
+	token := policy.sign(1, "test@yandex.ru", time.Now().Add(time.Hour))
+	testTable := []struct {
+		name          string
+		policy        EmailVerificationPolicy
+		token         string
+		expectedId    int
+		expectedEmail string
+		expectedError error
+	}{
+		{
+			name:          "OK",
+			policy:        policy,
+			token:         token,
+			expectedId:    1,
+			expectedEmail: "test@yandex.ru",
+		},
+		{
+			name:          "Tampered payload",
+			policy:        policy,
+			token:         policy.sign(2, "test@yandex.ru", time.Now().Add(time.Hour))[:10] + token[10:],
+			expectedError: pkg.ErrorInvalidVerification,
+		},
+		{
+			name:          "Another secret",
+			policy:        other,
+			token:         token,
+			expectedError: pkg.ErrorInvalidVerification,
+		},
+		{
+			name:          "Expired",
+			policy:        policy,
+			token:         policy.sign(1, "test@yandex.ru", time.Now().Add(-time.Minute)),
+			expectedError: pkg.ErrorInvalidVerification,
+		},
+		{
+			name:          "Malformed",
+			policy:        policy,
+			token:         "token",
+			expectedError: pkg.ErrorInvalidVerification,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			id, email, err := testCase.policy.parse(testCase.token)
+			//Assert
+			assert.Equal(t, testCase.expectedError, err)
+			assert.Equal(t, testCase.expectedId, id)
+			assert.Equal(t, testCase.expectedEmail, email)
+		})
+	}
+}
+
+func TestService_VerifyEmail(t *testing.T) {
+	cfg := Config{EmailVerification: EmailVerificationPolicy{Secret: "secret"}}
+	token := cfg.EmailVerification.withDefaults().sign(1, "test@yandex.ru", time.Now().Add(time.Hour))
+	type mockBehavior func(s *mock_repository.MockAppUser)
+	testTable := []struct {
+		name           string
+		token          string
+		mockBehavior   mockBehavior
+		expectedUserId int
+		expectedError  error
+	}{
+		{
+			name:  "OK",
+			token: token,
+			mockBehavior: func(s *mock_repository.MockAppUser) {
+				s.EXPECT().VerifyEmail(gomock.Any(), 1, "test@yandex.ru").Return(1, nil)
+			},
+			expectedUserId: 1,
+		},
+		{
+			name:          "Invalid token",
+			token:         "token",
+			mockBehavior:  func(s *mock_repository.MockAppUser) {},
+			expectedError: pkg.ErrorInvalidVerification,
+		},
+		{
+			name:  "Email changed",
+			token: token,
+			mockBehavior: func(s *mock_repository.MockAppUser) {
+				s.EXPECT().VerifyEmail(gomock.Any(), 1, "test@yandex.ru").Return(0, pkg.ErrorInvalidVerification)
+			},
+			expectedError: pkg.ErrorInvalidVerification,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_repository.NewMockAppUser(c)
+			testCase.mockBehavior(auth)
+			service := NewUserService(repository.Repository{AppUser: auth}, nil, logging.GetLogger(), cfg)
+			id, err := service.VerifyEmail(context.Background(), testCase.token)
+			//Assert
+			assert.Equal(t, testCase.expectedUserId, id)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestService_ResendVerification(t *testing.T) {
+	type mockBehavior func(s *mock_repository.MockAppUser, email string)
+	testTable := []struct {
+		name          string
+		email         string
+		mockBehavior  mockBehavior
+		expectedError error
+	}{
+		{
+			name:  "OK",
+			email: "test@yandex.ru",
+			mockBehavior: func(s *mock_repository.MockAppUser, email string) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), email).Return(&model.User{ID: 1, Email: email}, nil)
+			},
+		},
+		{
+			name:  "Already verified",
+			email: "test@yandex.ru",
+			mockBehavior: func(s *mock_repository.MockAppUser, email string) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), email).Return(&model.User{ID: 1, Email: email, EmailVerified: true}, nil)
+			},
+			expectedError: pkg.ErrorEmailVerified,
+		},
+		{
+			name:  "Deleted user",
+			email: "test@yandex.ru",
+			mockBehavior: func(s *mock_repository.MockAppUser, email string) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), email).Return(&model.User{ID: 1, Email: email, Deleted: true}, nil)
+			},
+			expectedError: pkg.ErrorEmailDoesNotExist,
+		},
+		{
+			name:  "Repository error",
+			email: "test@yandex.ru",
+			mockBehavior: func(s *mock_repository.MockAppUser, email string) {
+				s.EXPECT().GetUserByEmail(gomock.Any(), email).Return(nil, errors.New("repository error"))
+			},
+			expectedError: errors.New("repository error"),
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			//Init dependencies
+			c := gomock.NewController(t)
+			defer c.Finish()
+			auth := mock_repository.NewMockAppUser(c)
+			testCase.mockBehavior(auth, testCase.email)
+			service := NewUserService(repository.Repository{AppUser: auth}, nil, logging.GetLogger(), Config{})
+			err := service.ResendVerification(context.Background(), testCase.email)
+			//Assert
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestService_authUserUnverified(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	repo := mock_repository.NewMockAppUser(c)
+	repo.EXPECT().GetUserByEmail(gomock.Any(), "test@yandex.ru").Return(&model.User{
+		ID:       1,
+		Email:    "test@yandex.ru",
+		Password: "$2a$10$ooCmcWnLIubagB1MqM3UWOIpJTrq58tPQO6HVraj3yTKASiXBXHqy",
+	}, nil)
+	service := NewUserService(repository.Repository{AppUser: repo}, nil, logging.GetLogger(), Config{
+		EmailVerification: EmailVerificationPolicy{Unverified: UnverifiedDenyLogin},
+	})
+	_, _, err := service.AuthUser(context.Background(), "test@yandex.ru", "HGYKnu!98Tg")
+	//Assert
+	assert.ErrorIs(t, err, pkg.ErrorEmailNotVerified)
+}